@@ -0,0 +1,84 @@
+// Standalone executable that downloads all the sources of an osbuild
+// manifest into a bundle directory or tarball, so that the manifest can be
+// built on a host without network access.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/containers/storage/pkg/reexec"
+
+	"github.com/osbuild/images/pkg/bundle"
+)
+
+func run() error {
+	var output, tarball, location string
+	flag.StringVar(&output, "output", "", "directory to export the bundle to")
+	flag.StringVar(&tarball, "tarball", "", "tarball to export the bundle to")
+	flag.StringVar(&location, "location", "", "absolute path of the bundle on the build host (default: the absolute output directory, required for -tarball)")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s (-output DIR | -tarball FILE -location PATH) MANIFEST\n\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 || (output == "") == (tarball == "") || (tarball != "" && location == "") {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	manifest, err := os.ReadFile(flag.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	dir := output
+	if tarball != "" {
+		dir, err = os.MkdirTemp(filepath.Dir(tarball), ".export-bundle-")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(dir)
+	}
+
+	opts := &bundle.Options{
+		Location: location,
+	}
+	if _, err := bundle.Export(context.Background(), manifest, dir, opts); err != nil {
+		return err
+	}
+
+	if tarball != "" {
+		f, err := os.Create(tarball)
+		if err != nil {
+			return err
+		}
+		if err := bundle.WriteTar(f, dir); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+		fmt.Printf("bundle written to %s, extract it to %s\n", tarball, location)
+		return nil
+	}
+	fmt.Printf("bundle written to %s\n", dir)
+	return nil
+}
+
+func main() {
+	// the containers-storage of the bundle unpacks the layers in a
+	// subprocess
+	if reexec.Init() {
+		return
+	}
+	if err := run(); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		os.Exit(1)
+	}
+}
@@ -211,6 +211,7 @@ func makeManifestJob(
 	cacheRoot string,
 	path string,
 	content map[string]bool,
+	depsolver manifestgen.DepsolveFunc,
 	metadata bool,
 ) manifestJob {
 	name := bc.Name
@@ -272,7 +273,7 @@ func makeManifestJob(
 
 		var depsolvedSets map[string]dnfjson.DepsolveResult
 		if content["packages"] {
-			depsolvedSets, err = manifestgen.DefaultDepsolver(cacheDir, os.Stderr, manifest.GetPackageSetChains(), distribution, archName)
+			depsolvedSets, err = depsolver(cacheDir, os.Stderr, manifest.GetPackageSetChains(), distribution, archName)
 			if err != nil {
 				err = fmt.Errorf("[%s] depsolve failed: %s", filename, err.Error())
 				return
@@ -537,12 +538,14 @@ func u(s string) string {
 
 func main() {
 	// common args
-	var outputDir, cacheRoot, configPath, configMapPath string
+	var outputDir, cacheRoot, depsolveCacheDir, depsolverName, configPath, configMapPath string
 	var nWorkers int
 	var metadata, skipNoconfig, skipNorepos, buildconfigAllowUnknown bool
 	flag.StringVar(&outputDir, "output", "test/data/manifests/", "manifest store directory")
 	flag.IntVar(&nWorkers, "workers", 16, "number of workers to run concurrently")
 	flag.StringVar(&cacheRoot, "cache", "/tmp/rpmmd", "rpm metadata cache directory")
+	flag.StringVar(&depsolveCacheDir, "depsolve-cache", "", "directory to cache depsolve results in, until the repositories change (disabled if empty)")
+	flag.StringVar(&depsolverName, "depsolver", "dnf", "depsolver to use: dnf or the experimental repodata, which does not need python3-dnf")
 	flag.BoolVar(&metadata, "metadata", true, "store metadata in the file")
 	flag.StringVar(&configPath, "config", "", "image config file to use for all images (overrides -config-map)")
 	flag.StringVar(&configMapPath, "config-map", "test/config-map.json", "configuration file mapping image types to configs")
@@ -572,6 +575,21 @@ func main() {
 	distroFac := distrofactory.NewDefault()
 	jobs := make([]manifestJob, 0)
 
+	depsolver := manifestgen.DefaultDepsolver
+	switch depsolverName {
+	case "dnf":
+		if depsolveCacheDir != "" {
+			depsolver = manifestgen.NewCachingDepsolver(depsolveCacheDir)
+		}
+	case "repodata":
+		if depsolveCacheDir != "" {
+			panic("-depsolve-cache is not supported by the repodata depsolver")
+		}
+		depsolver = manifestgen.RepodataDepsolver
+	default:
+		panic(fmt.Sprintf("unknown depsolver %q", depsolverName))
+	}
+
 	contentResolve := map[string]bool{
 		"packages":   packages,
 		"containers": containers,
@@ -655,7 +673,7 @@ func main() {
 						continue
 					}
 
-					job := makeManifestJob(itConfig, imgType, distribution, repos, archName, cacheRoot, outputDir, contentResolve, metadata)
+					job := makeManifestJob(itConfig, imgType, distribution, repos, archName, cacheRoot, outputDir, contentResolve, depsolver, metadata)
 					jobs = append(jobs, job)
 				}
 			}
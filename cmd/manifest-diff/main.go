@@ -0,0 +1,173 @@
+// Standalone executable that explains the difference between two osbuild
+// manifests, or between two directories of manifests like the ones that
+// cmd/gen-manifests writes.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/osbuild/images/pkg/manifestdiff"
+)
+
+// dirDiff is the difference between two directories of manifests
+type dirDiff struct {
+	Added   []string                      `json:"added,omitempty"`
+	Removed []string                      `json:"removed,omitempty"`
+	Changed map[string]*manifestdiff.Diff `json:"changed,omitempty"`
+}
+
+func compareFiles(oldPath, newPath string) (*manifestdiff.Diff, error) {
+	oldData, err := os.ReadFile(oldPath)
+	if err != nil {
+		return nil, err
+	}
+	newData, err := os.ReadFile(newPath)
+	if err != nil {
+		return nil, err
+	}
+	diff, err := manifestdiff.Compare(oldData, newData)
+	if err != nil {
+		return nil, fmt.Errorf("cannot compare %s and %s: %w", oldPath, newPath, err)
+	}
+	return diff, nil
+}
+
+// jsonFiles returns the names of the json files in dir
+func jsonFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".json") {
+			names = append(names, entry.Name())
+		}
+	}
+	return names, nil
+}
+
+func compareDirs(oldDir, newDir string) (*dirDiff, error) {
+	oldNames, err := jsonFiles(oldDir)
+	if err != nil {
+		return nil, err
+	}
+	newNames, err := jsonFiles(newDir)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &dirDiff{
+		Changed: make(map[string]*manifestdiff.Diff),
+	}
+	for _, name := range newNames {
+		if !slices.Contains(oldNames, name) {
+			diff.Added = append(diff.Added, name)
+			continue
+		}
+		manifestDiff, err := compareFiles(filepath.Join(oldDir, name), filepath.Join(newDir, name))
+		if err != nil {
+			return nil, err
+		}
+		if !manifestDiff.Empty() {
+			diff.Changed[name] = manifestDiff
+		}
+	}
+	for _, name := range oldNames {
+		if !slices.Contains(newNames, name) {
+			diff.Removed = append(diff.Removed, name)
+		}
+	}
+	return diff, nil
+}
+
+func (d *dirDiff) writeText(w io.Writer) error {
+	for _, name := range d.Added {
+		fmt.Fprintf(w, "manifest added: %s\n", name)
+	}
+	for _, name := range d.Removed {
+		fmt.Fprintf(w, "manifest removed: %s\n", name)
+	}
+
+	names := make([]string, 0, len(d.Changed))
+	for name := range d.Changed {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "=== %s\n", name)
+		if err := d.Changed[name].WriteText(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func run() error {
+	var jsonOutput bool
+	flag.BoolVar(&jsonOutput, "json", false, "print the difference as json")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [-json] OLD NEW\n\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "OLD and NEW are both manifest files or both directories of manifests.\n\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	oldPath, newPath := flag.Arg(0), flag.Arg(1)
+
+	oldInfo, err := os.Stat(oldPath)
+	if err != nil {
+		return err
+	}
+	newInfo, err := os.Stat(newPath)
+	if err != nil {
+		return err
+	}
+	if oldInfo.IsDir() != newInfo.IsDir() {
+		return fmt.Errorf("cannot compare a file with a directory")
+	}
+
+	if oldInfo.IsDir() {
+		diff, err := compareDirs(oldPath, newPath)
+		if err != nil {
+			return err
+		}
+		if jsonOutput {
+			return writeJSON(os.Stdout, diff)
+		}
+		return diff.writeText(os.Stdout)
+	}
+
+	diff, err := compareFiles(oldPath, newPath)
+	if err != nil {
+		return err
+	}
+	if jsonOutput {
+		return writeJSON(os.Stdout, diff)
+	}
+	return diff.WriteText(os.Stdout)
+}
+
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		os.Exit(1)
+	}
+}
@@ -7,19 +7,56 @@ import (
 	"os"
 	"os/user"
 	"path/filepath"
+	"strings"
 
 	"github.com/osbuild/images/pkg/container"
 )
 
+// archivesFlag collects the paths of all the given -container flags
+type archivesFlag []string
+
+func (a *archivesFlag) String() string {
+	return strings.Join(*a, ",")
+}
+
+func (a *archivesFlag) Set(value string) error {
+	*a = append(*a, value)
+	return nil
+}
+
+// mergeArchives merges the per-architecture archives into a single archive
+// with an image index in a temporary directory
+func mergeArchives(archives []string) (string, func(), error) {
+	tmpdir, err := os.MkdirTemp("", "osbuild-upload-container-")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup := func() { os.RemoveAll(tmpdir) }
+
+	filename := filepath.Join(tmpdir, "oci-archive.tar")
+	f, err := os.Create(filename)
+	if err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	defer f.Close()
+
+	if err := container.MergeOCIArchives(f, archives...); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	return filename, cleanup, nil
+}
+
 func main() {
-	var filename string
+	var archives archivesFlag
 	var destination string
 	var username string
 	var password string
 	var tag string
 	var ignoreTLS bool
 
-	flag.StringVar(&filename, "container", "", "path to the oci-archive to upload (required)")
+	flag.Var(&archives, "container", "path to the oci-archive to upload (required, can be given multiple times to upload a multi-arch image)")
 	flag.StringVar(&destination, "destination", "", "destination to upload to (required)")
 	flag.StringVar(&tag, "tag", "", "destination tag to use for the container")
 	flag.StringVar(&username, "username", "", "username to use for registry")
@@ -27,18 +64,29 @@ func main() {
 	flag.BoolVar(&ignoreTLS, "ignore-tls", false, "ignore tls verification for destination")
 	flag.Parse()
 
-	if filename == "" || destination == "" {
+	if len(archives) == 0 || destination == "" {
 		flag.Usage()
 		os.Exit(1)
 	}
 
+	filename := archives[0]
+	if len(archives) > 1 {
+		merged, cleanup, err := mergeArchives(archives)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error merging the containers: %v\n", err)
+			os.Exit(1)
+		}
+		defer cleanup()
+		filename = merged
+	}
+
 	absPath, err := filepath.Abs(filename)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err.Error())
 		return
 	}
 
-	fmt.Println("Container to upload is:", filename)
+	fmt.Println("Container to upload is:", archives.String())
 
 	client, err := container.NewClient(destination)
 
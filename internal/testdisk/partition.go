@@ -1,6 +1,7 @@
 package testdisk
 
 import (
+	"fmt"
 	"math/rand"
 
 	"github.com/osbuild/images/pkg/datasizes"
@@ -417,6 +418,33 @@ func MakeFakePartitionTable(mntPoints ...string) *disk.PartitionTable {
 	}
 }
 
+// MakeFakeEROFSPartitionTable is similar to MakeFakePartitionTable but the
+// root filesystem is a read-only EROFS filesystem.
+func MakeFakeEROFSPartitionTable(mntPoints ...string) *disk.PartitionTable {
+	pt := MakeFakePartitionTable(mntPoints...)
+	pt.Type = disk.PT_GPT
+	if root, ok := pt.FindMountable("/").(*disk.Filesystem); ok {
+		root.Type = "erofs"
+	}
+	return pt
+}
+
+// MakeFakeVerityPartitionTable is similar to MakeFakeEROFSPartitionTable
+// but the root partition is protected by a dm-verity hash partition, which is
+// added after the other partitions. All partitions get a UUID.
+func MakeFakeVerityPartitionTable(mntPoints ...string) *disk.PartitionTable {
+	pt := MakeFakeEROFSPartitionTable(mntPoints...)
+	pt.Partitions = append(pt.Partitions, disk.Partition{
+		Size:    FakePartitionSize,
+		Type:    disk.RootVerityPartitionX86_64GUID,
+		Payload: &disk.Verity{Mountpoint: "/"},
+	})
+	for idx := range pt.Partitions {
+		pt.Partitions[idx].UUID = fmt.Sprintf("00000000-0000-0000-0000-%012d", idx+1)
+	}
+	return pt
+}
+
 // MakeFakeBtrfsPartitionTable is similar to MakeFakePartitionTable but
 // creates a btrfs-based partition table.
 // Including a "swap" entry creates a swap partition.
@@ -193,3 +193,25 @@ func TestLoadWithFallbackFromStdin(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, expectedBuildConfig, cfg)
 }
+
+func TestLoadDiskEncryptionError(t *testing.T) {
+	// the external blueprint cannot convert the encryption of a partition,
+	// it must not be silently dropped
+	for _, tc := range []struct {
+		fname   string
+		content string
+	}{
+		{"config.json", `{"customizations": {"disk": {"partitions": [{"mountpoint": "/", "fs_type": "xfs", "encryption": {"passphrase": "secret"}}]}}}`},
+		{"config.toml", `
+[[customizations.disk.partitions]]
+mountpoint = "/"
+fs_type = "xfs"
+[customizations.disk.partitions.encryption]
+passphrase = "secret"
+`},
+	} {
+		fakeCnfPath := makeFakeConfig(t, tc.fname, tc.content)
+		_, err := blueprintload.Load(fakeCnfPath)
+		assert.ErrorContains(t, err, `json: unknown field "encryption"`)
+	}
+}
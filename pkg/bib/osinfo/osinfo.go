@@ -12,6 +12,7 @@ import (
 
 	"github.com/osbuild/images/pkg/bib/blueprintload"
 	"github.com/osbuild/images/pkg/blueprint"
+	"github.com/osbuild/images/pkg/customizations/composefs"
 	"github.com/osbuild/images/pkg/distro"
 )
 
@@ -31,6 +32,10 @@ type Info struct {
 	UEFIVendor         string
 	SELinuxPolicy      string
 	ImageCustomization *blueprint.Customizations
+	// Composefs is the configuration of the container for mounting its
+	// deployments with composefs, for image.BootcDiskImage.Composefs. It
+	// is nil if the container does not require composefs.
+	Composefs *composefs.Options
 }
 
 func validateOSRelease(osrelease map[string]string) error {
@@ -99,6 +104,17 @@ func readSelinuxPolicy(root string) (string, error) {
 	return policy, nil
 }
 
+func readComposefs(root string) (*composefs.Options, error) {
+	data, err := os.ReadFile(path.Join(root, composefs.PrepareRootConfPath))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return composefs.ParsePrepareRootConf(data)
+}
+
 func readImageCustomization(root string) (*blueprint.Customizations, error) {
 	prefix := path.Join(root, bibPathPrefix)
 	config, err := blueprintload.Load(path.Join(prefix, "config.json"))
@@ -138,6 +154,11 @@ func Load(root string) (*Info, error) {
 		return nil, err
 	}
 
+	cfs, err := readComposefs(root)
+	if err != nil {
+		return nil, err
+	}
+
 	selinuxPolicy, err := readSelinuxPolicy(root)
 	if err != nil {
 		logrus.Debugf("cannot read selinux policy: %v, setting it to none", err)
@@ -161,5 +182,6 @@ func Load(root string) (*Info, error) {
 		UEFIVendor:         vendor,
 		SELinuxPolicy:      selinuxPolicy,
 		ImageCustomization: customization,
+		Composefs:          cfs,
 	}, nil
 }
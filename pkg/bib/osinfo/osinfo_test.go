@@ -9,6 +9,8 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/pkg/customizations/composefs"
 )
 
 func writeOSRelease(root, id, versionID, name, platformID, variantID, idLike string) error {
@@ -168,3 +170,20 @@ func TestLoadInfo(t *testing.T) {
 		})
 	}
 }
+
+func TestLoadInfoComposefs(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, writeOSRelease(root, "fedora", "42", "Fedora Linux", "platform:f42", "", ""))
+
+	info, err := Load(root)
+	require.NoError(t, err)
+	assert.Nil(t, info.Composefs)
+
+	confPath := path.Join(root, composefs.PrepareRootConfPath)
+	require.NoError(t, os.MkdirAll(path.Dir(confPath), 0755))
+	require.NoError(t, os.WriteFile(confPath, []byte("[composefs]\nenabled = verity\n"), 0644))
+
+	info, err = Load(root)
+	require.NoError(t, err)
+	assert.Equal(t, &composefs.Options{Mode: composefs.ModeVerity}, info.Composefs)
+}
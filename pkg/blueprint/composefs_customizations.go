@@ -0,0 +1,33 @@
+package blueprint
+
+import (
+	"github.com/osbuild/images/pkg/customizations/composefs"
+)
+
+type ComposefsCustomization struct {
+	// How composefs mounts the root filesystem: "yes", "verity" or "signed".
+	// The build does not sign ostree commits. In the "signed" mode the
+	// commit must be signed with "ostree sign" and the private key of the
+	// public key before it is deployed, unsigned commits do not boot.
+	Mode string `json:"mode,omitempty" toml:"mode,omitempty"`
+	// Path of the file with the public keys that verify the signed
+	// composefs digests
+	KeyPath string `json:"keypath,omitempty" toml:"keypath,omitempty"`
+	// Base64 encoded ed25519 public key that is written to the keypath
+	PublicKey string `json:"public_key,omitempty" toml:"public_key,omitempty"`
+	// Mount a transient, writable overlay over the root filesystem
+	TransientRoot bool `json:"transient_root,omitempty" toml:"transient_root,omitempty"`
+}
+
+// Options returns the composefs options of the customization.
+func (c *ComposefsCustomization) Options() *composefs.Options {
+	if c == nil {
+		return nil
+	}
+	return &composefs.Options{
+		Mode:          composefs.Mode(c.Mode),
+		KeyPath:       c.KeyPath,
+		PublicKey:     c.PublicKey,
+		TransientRoot: c.TransientRoot,
+	}
+}
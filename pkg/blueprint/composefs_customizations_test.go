@@ -0,0 +1,49 @@
+package blueprint_test
+
+import (
+	"testing"
+
+	"github.com/BurntSushi/toml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/pkg/blueprint"
+	"github.com/osbuild/images/pkg/customizations/composefs"
+)
+
+func TestComposefsCustomizationUnmarshalTOML(t *testing.T) {
+	input := `
+[customizations.composefs]
+mode = "signed"
+keypath = "/etc/ostree/fleet.key"
+public_key = "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="
+transient_root = true
+`
+	var bp blueprint.Blueprint
+	err := toml.Unmarshal([]byte(input), &bp)
+	require.NoError(t, err)
+
+	cfs, err := bp.Customizations.GetComposefs()
+	require.NoError(t, err)
+	expected := &composefs.Options{
+		Mode:          composefs.ModeSigned,
+		KeyPath:       "/etc/ostree/fleet.key",
+		PublicKey:     "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=",
+		TransientRoot: true,
+	}
+	assert.Equal(t, expected, cfs.Options())
+}
+
+func TestGetComposefs(t *testing.T) {
+	var c *blueprint.Customizations
+	cfs, err := c.GetComposefs()
+	assert.NoError(t, err)
+	assert.Nil(t, cfs)
+	assert.Nil(t, cfs.Options())
+
+	c = &blueprint.Customizations{
+		Composefs: &blueprint.ComposefsCustomization{Mode: "signed"},
+	}
+	_, err = c.GetComposefs()
+	assert.EqualError(t, err, `composefs mode "signed" requires a public key`)
+}
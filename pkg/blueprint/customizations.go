@@ -34,6 +34,18 @@ type Customizations struct {
 	RPM                *RPMCustomization              `json:"rpm,omitempty" toml:"rpm,omitempty"`
 	RHSM               *RHSMCustomization             `json:"rhsm,omitempty" toml:"rhsm,omitempty"`
 	CACerts            *CACustomization               `json:"cacerts,omitempty" toml:"cacerts,omitempty"`
+	Sysctl             SysctlCustomization            `json:"sysctl,omitempty" toml:"sysctl,omitempty"`
+	// KernelModules is not part of KernelCustomization because that type
+	// must stay convertible from github.com/osbuild/blueprint.
+	KernelModules *KernelModulesCustomization `json:"kernel_modules,omitempty" toml:"kernel_modules,omitempty"`
+	Network       *NetworkCustomization       `json:"network,omitempty" toml:"network,omitempty"`
+	Tuned         *TunedCustomization         `json:"tuned,omitempty" toml:"tuned,omitempty"`
+	Journald      *JournaldCustomization      `json:"journald,omitempty" toml:"journald,omitempty"`
+	Composefs     *ComposefsCustomization     `json:"composefs,omitempty" toml:"composefs,omitempty"`
+	// Bootloader selects one of the alternative bootloaders of the image
+	// type, e.g. "uki" or "systemd-boot". The UKI bootloaders do not
+	// support the kernel append customization yet.
+	Bootloader string `json:"bootloader,omitempty" toml:"bootloader,omitempty"`
 }
 
 type IgnitionCustomization struct {
@@ -182,7 +194,7 @@ func (c *Customizations) CheckAllowed(allowed ...string) error {
 			if field.String() == "" {
 				empty = true
 			}
-		case reflect.Array, reflect.Slice:
+		case reflect.Array, reflect.Slice, reflect.Map:
 			if field.Len() == 0 {
 				empty = true
 			}
@@ -278,6 +290,66 @@ func (c *Customizations) GetKernel() *KernelCustomization {
 	}
 }
 
+func (c *Customizations) GetKernelModules() (*KernelModulesCustomization, error) {
+	if c == nil || c.KernelModules == nil {
+		return nil, nil
+	}
+
+	if err := c.KernelModules.Validate(); err != nil {
+		return nil, err
+	}
+
+	return c.KernelModules, nil
+}
+
+func (c *Customizations) GetNetwork() (*NetworkCustomization, error) {
+	if c == nil || c.Network == nil {
+		return nil, nil
+	}
+
+	if err := c.Network.Validate(); err != nil {
+		return nil, err
+	}
+
+	return c.Network, nil
+}
+
+func (c *Customizations) GetTuned() (*TunedCustomization, error) {
+	if c == nil || c.Tuned == nil {
+		return nil, nil
+	}
+
+	if err := c.Tuned.Validate(); err != nil {
+		return nil, err
+	}
+
+	return c.Tuned, nil
+}
+
+func (c *Customizations) GetJournald() (*JournaldCustomization, error) {
+	if c == nil || c.Journald == nil {
+		return nil, nil
+	}
+
+	if err := c.Journald.Validate(); err != nil {
+		return nil, err
+	}
+
+	return c.Journald, nil
+}
+
+func (c *Customizations) GetComposefs() (*ComposefsCustomization, error) {
+	if c == nil || c.Composefs == nil {
+		return nil, nil
+	}
+
+	if err := c.Composefs.Options().Validate(); err != nil {
+		return nil, err
+	}
+
+	return c.Composefs, nil
+}
+
 func (c *Customizations) GetFirewall() *FirewallCustomization {
 	if c == nil {
 		return nil
@@ -335,6 +407,13 @@ func (c *Customizations) GetInstallationDevice() string {
 	return c.InstallationDevice
 }
 
+func (c *Customizations) GetBootloader() string {
+	if c == nil {
+		return ""
+	}
+	return c.Bootloader
+}
+
 func (c *Customizations) GetFDO() *FDOCustomization {
 	if c == nil {
 		return nil
@@ -469,3 +548,15 @@ func (c *Customizations) GetCACerts() (*CACustomization, error) {
 
 	return c.CACerts, nil
 }
+
+func (c *Customizations) GetSysctl() (SysctlCustomization, error) {
+	if c == nil || len(c.Sysctl) == 0 {
+		return nil, nil
+	}
+
+	if err := c.Sysctl.Validate(); err != nil {
+		return nil, err
+	}
+
+	return c.Sysctl, nil
+}
@@ -54,6 +54,9 @@ func (dc *DiskCustomization) UnmarshalTOML(data any) error {
 //     extra fields
 //   - btrfs: the payload will be a btrfs volume. See
 //     [BtrfsVolumeCustomization] for extra fields.
+//
+// The payload of any type can be encrypted with LUKS2. See
+// [EncryptionCustomization].
 type PartitionCustomization struct {
 	// The type of payload for the partition (optional, defaults to "plain").
 	Type string `json:"type" toml:"type"`
@@ -81,6 +84,10 @@ type PartitionCustomization struct {
 	// Note: This is the unique uuid, not the type guid, that is PartType
 	PartUUID string `json:"part_uuid,omitempty" toml:"part_uuid,omitempty"`
 
+	// Encrypt the payload of the partition (the filesystem, the LVM physical
+	// volume, or the btrfs volume) with LUKS2 (optional).
+	Encryption *EncryptionCustomization `json:"encryption,omitempty" toml:"encryption,omitempty"`
+
 	BtrfsVolumeCustomization
 
 	VGCustomization
@@ -148,6 +155,51 @@ func (lv *LVCustomization) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// Passphrase sources for [EncryptionCustomization].
+const (
+	// The passphrase is the value of the "passphrase" field.
+	PassphraseSourcePassphrase = "passphrase"
+
+	// A random passphrase is generated for the build. The passphrase is
+	// derived from the seed of the manifest and is part of the manifest,
+	// so it is not a secret: the volume must be bound with clevis and the
+	// passphrase must be removed from the volume at the end of the build.
+	PassphraseSourceRandom = "random"
+)
+
+// EncryptionCustomization defines the LUKS2 encryption of a partition
+// payload.
+type EncryptionCustomization struct {
+	// The cipher for the LUKS2 volume, in the cryptsetup format, e.g.
+	// aes-xts-plain64 (optional, defaults to the cryptsetup default).
+	Cipher string `json:"cipher,omitempty" toml:"cipher,omitempty"`
+
+	// Where the passphrase that unlocks the volume comes from: "passphrase"
+	// or "random" (optional, defaults to "passphrase").
+	PassphraseSource string `json:"passphrase_source,omitempty" toml:"passphrase_source,omitempty"`
+
+	// The passphrase for the volume (required if the passphrase source is
+	// "passphrase").
+	Passphrase string `json:"passphrase,omitempty" toml:"passphrase,omitempty"`
+
+	// Bind the volume to a clevis pin for automatic unlocking, e.g. with a
+	// TPM2 (optional).
+	Clevis *ClevisCustomization `json:"clevis,omitempty" toml:"clevis,omitempty"`
+}
+
+type ClevisCustomization struct {
+	// The clevis pin to bind the volume with, e.g. "tpm2", "tang", or "sss"
+	// (required).
+	Pin string `json:"pin" toml:"pin"`
+
+	// The JSON configuration of the pin (optional, defaults to "{}").
+	Policy string `json:"policy,omitempty" toml:"policy,omitempty"`
+
+	// Remove the passphrase from the volume at the end of the build, so that
+	// it can only be unlocked through clevis.
+	RemovePassphrase bool `json:"remove_passphrase,omitempty" toml:"remove_passphrase,omitempty"`
+}
+
 // A btrfs volume consisting of one or more subvolumes.
 type BtrfsVolumeCustomization struct {
 	Subvolumes []BtrfsSubvolumeCustomization
@@ -171,11 +223,12 @@ type BtrfsSubvolumeCustomization struct {
 func (v *PartitionCustomization) UnmarshalJSON(data []byte) error {
 	errPrefix := "JSON unmarshal:"
 	var typeSniffer struct {
-		Type      string `json:"type"`
-		MinSize   any    `json:"minsize"`
-		PartType  string `json:"part_type"`
-		PartLabel string `json:"part_label"`
-		PartUUID  string `json:"part_uuid"`
+		Type       string          `json:"type"`
+		MinSize    any             `json:"minsize"`
+		PartType   string          `json:"part_type"`
+		PartLabel  string          `json:"part_label"`
+		PartUUID   string          `json:"part_uuid"`
+		Encryption json.RawMessage `json:"encryption"`
 	}
 	if err := json.Unmarshal(data, &typeSniffer); err != nil {
 		return fmt.Errorf("%s %w", errPrefix, err)
@@ -208,6 +261,12 @@ func (v *PartitionCustomization) UnmarshalJSON(data []byte) error {
 	v.PartLabel = typeSniffer.PartLabel
 	v.PartUUID = typeSniffer.PartUUID
 
+	encryption, err := decodeEncryption(typeSniffer.Encryption)
+	if err != nil {
+		return fmt.Errorf("%s %w", errPrefix, err)
+	}
+	v.Encryption = encryption
+
 	if typeSniffer.MinSize == nil {
 		return fmt.Errorf("minsize is required")
 	}
@@ -226,13 +285,15 @@ func (v *PartitionCustomization) UnmarshalJSON(data []byte) error {
 // the type is "plain", none of the fields for btrfs or lvm are used.
 func decodePlain(v *PartitionCustomization, data []byte) error {
 	var plain struct {
-		// Type, minsize, and part_* are handled by the caller. These are added here to
-		// satisfy "DisallowUnknownFields" when decoding.
-		Type      string `json:"type"`
-		MinSize   any    `json:"minsize"`
-		PartType  string `json:"part_type"`
-		PartLabel string `json:"part_label"`
-		PartUUID  string `json:"part_uuid"`
+		// Type, minsize, part_*, and encryption are handled by the caller.
+		// These are added here to satisfy "DisallowUnknownFields" when
+		// decoding.
+		Type       string `json:"type"`
+		MinSize    any    `json:"minsize"`
+		PartType   string `json:"part_type"`
+		PartLabel  string `json:"part_label"`
+		PartUUID   string `json:"part_uuid"`
+		Encryption any    `json:"encryption"`
 		FilesystemTypedCustomization
 	}
 
@@ -252,13 +313,15 @@ func decodePlain(v *PartitionCustomization, data []byte) error {
 // the type is btrfs, none of the fields for plain or lvm are used.
 func decodeBtrfs(v *PartitionCustomization, data []byte) error {
 	var btrfs struct {
-		// Type, minsize, and part_* are handled by the caller. These are added here to
-		// satisfy "DisallowUnknownFields" when decoding.
-		Type      string `json:"type"`
-		MinSize   any    `json:"minsize"`
-		PartType  string `json:"part_type"`
-		PartLabel string `json:"part_label"`
-		PartUUID  string `json:"part_uuid"`
+		// Type, minsize, part_*, and encryption are handled by the caller.
+		// These are added here to satisfy "DisallowUnknownFields" when
+		// decoding.
+		Type       string `json:"type"`
+		MinSize    any    `json:"minsize"`
+		PartType   string `json:"part_type"`
+		PartLabel  string `json:"part_label"`
+		PartUUID   string `json:"part_uuid"`
+		Encryption any    `json:"encryption"`
 		BtrfsVolumeCustomization
 	}
 
@@ -278,13 +341,15 @@ func decodeBtrfs(v *PartitionCustomization, data []byte) error {
 // is lvm, none of the fields for plain or btrfs are used.
 func decodeLVM(v *PartitionCustomization, data []byte) error {
 	var vg struct {
-		// Type, minsize, and part_* are handled by the caller. These are added here to
-		// satisfy "DisallowUnknownFields" when decoding.
-		Type      string `json:"type"`
-		MinSize   any    `json:"minsize"`
-		PartType  string `json:"part_type"`
-		PartLabel string `json:"part_label"`
-		PartUUID  string `json:"part_uuid"`
+		// Type, minsize, part_*, and encryption are handled by the caller.
+		// These are added here to satisfy "DisallowUnknownFields" when
+		// decoding.
+		Type       string `json:"type"`
+		MinSize    any    `json:"minsize"`
+		PartType   string `json:"part_type"`
+		PartLabel  string `json:"part_label"`
+		PartUUID   string `json:"part_uuid"`
+		Encryption any    `json:"encryption"`
 		VGCustomization
 	}
 
@@ -298,6 +363,22 @@ func decodeLVM(v *PartitionCustomization, data []byte) error {
 	return nil
 }
 
+// decodeEncryption decodes the encryption customization of a partition with
+// DisallowUnknownFields. Empty data decodes to nil.
+func decodeEncryption(data []byte) (*EncryptionCustomization, error) {
+	if len(data) == 0 || bytes.Equal(data, []byte("null")) {
+		return nil, nil
+	}
+
+	var encryption EncryptionCustomization
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&encryption); err != nil {
+		return nil, fmt.Errorf("error decoding encryption customization: %w", err)
+	}
+	return &encryption, nil
+}
+
 // Custom TOML unmarshaller that first reads the value of the "type" field and
 // then deserialises the whole object into a struct that only contains the
 // fields valid for that partition type. This ensures that no fields are set
@@ -343,6 +424,18 @@ func (v *PartitionCustomization) UnmarshalTOML(data any) error {
 
 	v.Type = partType
 
+	if encryptionField, ok := d["encryption"]; ok {
+		encryptionJSON, err := json.Marshal(encryptionField)
+		if err != nil {
+			return fmt.Errorf("%s error while decoding encryption customization: %w", errPrefix, err)
+		}
+		encryption, err := decodeEncryption(encryptionJSON)
+		if err != nil {
+			return fmt.Errorf("%s %w", errPrefix, err)
+		}
+		v.Encryption = encryption
+	}
+
 	minsizeField, ok := d["minsize"]
 	if !ok {
 		return fmt.Errorf("minsize is required")
@@ -371,6 +464,7 @@ func (v *PartitionCustomization) UnmarshalTOML(data any) error {
 //   - All non-empty properties are valid for the partition type (e.g.
 //     LogicalVolumes is empty when the type is "plain" or "btrfs")
 //   - Filesystems with FSType set to "swap" do not specify a mountpoint.
+//   - Encryption is valid and not used for /boot or /boot/efi.
 //
 // Note that in *addition* consumers should also call
 // ValidateLayoutConstraints() to validate that the policy for disk
@@ -408,6 +502,9 @@ func (p *DiskCustomization) Validate() error {
 		if err := part.ValidatePartitionLabel(p.Type); err != nil {
 			errs = append(errs, err)
 		}
+		if err := part.validateEncryption(); err != nil {
+			errs = append(errs, err)
+		}
 		switch part.Type {
 		case "plain", "":
 			errs = append(errs, part.validatePlain(mountpoints))
@@ -592,6 +689,44 @@ func (p *PartitionCustomization) ValidatePartitionLabel(ptType string) error {
 	return nil
 }
 
+func (p *PartitionCustomization) validateEncryption() error {
+	enc := p.Encryption
+	if enc == nil {
+		return nil
+	}
+
+	if slices.Contains(plainOnlyMountpoints, p.Mountpoint) {
+		return fmt.Errorf("encryption is not supported for mountpoint %q", p.Mountpoint)
+	}
+
+	switch enc.PassphraseSource {
+	case PassphraseSourcePassphrase, "":
+		if enc.Passphrase == "" {
+			return fmt.Errorf("encryption requires a passphrase for passphrase source %q", PassphraseSourcePassphrase)
+		}
+	case PassphraseSourceRandom:
+		if enc.Passphrase != "" {
+			return fmt.Errorf("encryption passphrase cannot be set for passphrase source %q", PassphraseSourceRandom)
+		}
+		if enc.Clevis == nil || !enc.Clevis.RemovePassphrase {
+			return fmt.Errorf("encryption with passphrase source %q requires a clevis binding that removes the passphrase", PassphraseSourceRandom)
+		}
+	default:
+		return fmt.Errorf("unknown encryption passphrase source: %s (valid: %s, %s)", enc.PassphraseSource, PassphraseSourcePassphrase, PassphraseSourceRandom)
+	}
+
+	if enc.Clevis != nil {
+		if enc.Clevis.Pin == "" {
+			return fmt.Errorf("encryption clevis binding requires a pin")
+		}
+		if enc.Clevis.Policy != "" && !json.Valid([]byte(enc.Clevis.Policy)) {
+			return fmt.Errorf("encryption clevis policy for pin %q is not valid JSON: %s", enc.Clevis.Pin, enc.Clevis.Policy)
+		}
+	}
+
+	return nil
+}
+
 func (p *PartitionCustomization) validatePlain(mountpoints map[string]bool) error {
 	if p.FSType == "swap" {
 		// make sure the mountpoint is empty and return
@@ -1152,6 +1152,164 @@ func TestPartitioningValidation(t *testing.T) {
 			},
 			expectedMsg: "invalid partitioning customizations:\npart_label is not a valid GPT label, it is too long",
 		},
+		"happy-encrypted-plain+lvm": {
+			partitioning: &blueprint.DiskCustomization{
+				Partitions: []blueprint.PartitionCustomization{
+					{
+						Encryption: &blueprint.EncryptionCustomization{
+							Cipher:     "aes-xts-plain64",
+							Passphrase: "secret",
+						},
+						FilesystemTypedCustomization: blueprint.FilesystemTypedCustomization{
+							FSType:     "ext4",
+							Mountpoint: "/",
+						},
+					},
+					{
+						Type: "lvm",
+						Encryption: &blueprint.EncryptionCustomization{
+							PassphraseSource: "random",
+							Clevis: &blueprint.ClevisCustomization{
+								Pin:              "tpm2",
+								Policy:           `{"pcr_ids":"7"}`,
+								RemovePassphrase: true,
+							},
+						},
+						VGCustomization: blueprint.VGCustomization{
+							LogicalVolumes: []blueprint.LVCustomization{
+								{
+									FilesystemTypedCustomization: blueprint.FilesystemTypedCustomization{
+										FSType:     "xfs",
+										Mountpoint: "/home",
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		"unhappy-encrypted-boot": {
+			partitioning: &blueprint.DiskCustomization{
+				Partitions: []blueprint.PartitionCustomization{
+					{
+						Encryption: &blueprint.EncryptionCustomization{
+							Passphrase: "secret",
+						},
+						FilesystemTypedCustomization: blueprint.FilesystemTypedCustomization{
+							FSType:     "ext4",
+							Mountpoint: "/boot",
+						},
+					},
+				},
+			},
+			expectedMsg: "invalid partitioning customizations:\nencryption is not supported for mountpoint \"/boot\"",
+		},
+		"unhappy-encryption-no-passphrase": {
+			partitioning: &blueprint.DiskCustomization{
+				Partitions: []blueprint.PartitionCustomization{
+					{
+						Encryption: &blueprint.EncryptionCustomization{
+							PassphraseSource: "passphrase",
+						},
+						FilesystemTypedCustomization: blueprint.FilesystemTypedCustomization{
+							FSType:     "ext4",
+							Mountpoint: "/",
+						},
+					},
+				},
+			},
+			expectedMsg: "invalid partitioning customizations:\nencryption requires a passphrase for passphrase source \"passphrase\"",
+		},
+		"unhappy-encryption-random-with-passphrase": {
+			partitioning: &blueprint.DiskCustomization{
+				Partitions: []blueprint.PartitionCustomization{
+					{
+						Encryption: &blueprint.EncryptionCustomization{
+							PassphraseSource: "random",
+							Passphrase:       "secret",
+						},
+						FilesystemTypedCustomization: blueprint.FilesystemTypedCustomization{
+							FSType:     "ext4",
+							Mountpoint: "/",
+						},
+					},
+				},
+			},
+			expectedMsg: "invalid partitioning customizations:\nencryption passphrase cannot be set for passphrase source \"random\"",
+		},
+		"unhappy-encryption-random-keeps-passphrase": {
+			partitioning: &blueprint.DiskCustomization{
+				Partitions: []blueprint.PartitionCustomization{
+					{
+						Encryption: &blueprint.EncryptionCustomization{
+							PassphraseSource: "random",
+							Clevis: &blueprint.ClevisCustomization{
+								Pin: "tpm2",
+							},
+						},
+						FilesystemTypedCustomization: blueprint.FilesystemTypedCustomization{
+							FSType:     "ext4",
+							Mountpoint: "/",
+						},
+					},
+				},
+			},
+			expectedMsg: "invalid partitioning customizations:\nencryption with passphrase source \"random\" requires a clevis binding that removes the passphrase",
+		},
+		"unhappy-encryption-passphrase-source": {
+			partitioning: &blueprint.DiskCustomization{
+				Partitions: []blueprint.PartitionCustomization{
+					{
+						Encryption: &blueprint.EncryptionCustomization{
+							PassphraseSource: "keyfile",
+						},
+						FilesystemTypedCustomization: blueprint.FilesystemTypedCustomization{
+							FSType:     "ext4",
+							Mountpoint: "/",
+						},
+					},
+				},
+			},
+			expectedMsg: "invalid partitioning customizations:\nunknown encryption passphrase source: keyfile (valid: passphrase, random)",
+		},
+		"unhappy-encryption-clevis-no-pin": {
+			partitioning: &blueprint.DiskCustomization{
+				Partitions: []blueprint.PartitionCustomization{
+					{
+						Encryption: &blueprint.EncryptionCustomization{
+							Passphrase: "secret",
+							Clevis:     &blueprint.ClevisCustomization{},
+						},
+						FilesystemTypedCustomization: blueprint.FilesystemTypedCustomization{
+							FSType:     "ext4",
+							Mountpoint: "/",
+						},
+					},
+				},
+			},
+			expectedMsg: "invalid partitioning customizations:\nencryption clevis binding requires a pin",
+		},
+		"unhappy-encryption-clevis-bad-policy": {
+			partitioning: &blueprint.DiskCustomization{
+				Partitions: []blueprint.PartitionCustomization{
+					{
+						Encryption: &blueprint.EncryptionCustomization{
+							Passphrase: "secret",
+							Clevis: &blueprint.ClevisCustomization{
+								Pin:    "tang",
+								Policy: "url=http://tang",
+							},
+						},
+						FilesystemTypedCustomization: blueprint.FilesystemTypedCustomization{
+							FSType:     "ext4",
+							Mountpoint: "/",
+						},
+					},
+				},
+			},
+			expectedMsg: "invalid partitioning customizations:\nencryption clevis policy for pin \"tang\" is not valid JSON: url=http://tang",
+		},
 	}
 
 	for name := range testCases {
@@ -1651,6 +1809,65 @@ func TestPartitionCustomizationUnmarshalJSON(t *testing.T) {
 			}`,
 			errorMsg: `JSON unmarshal: error decoding partition with type "plain": json: unknown field "subvolumes"`,
 		},
+		"lvm-encrypted": {
+			input: `{
+				"type": "lvm",
+				"minsize": "10 GiB",
+				"encryption": {
+					"cipher": "aes-xts-plain64",
+					"passphrase_source": "random",
+					"clevis": {
+						"pin": "tpm2",
+						"policy": "{\"pcr_ids\": \"7\"}",
+						"remove_passphrase": true
+					}
+				},
+				"logical_volumes": [
+					{
+						"minsize": "2 GiB",
+						"mountpoint": "/",
+						"fs_type": "xfs"
+					}
+				]
+			}`,
+			expected: &blueprint.PartitionCustomization{
+				Type:    "lvm",
+				MinSize: 10 * datasizes.GiB,
+				Encryption: &blueprint.EncryptionCustomization{
+					Cipher:           "aes-xts-plain64",
+					PassphraseSource: "random",
+					Clevis: &blueprint.ClevisCustomization{
+						Pin:              "tpm2",
+						Policy:           `{"pcr_ids": "7"}`,
+						RemovePassphrase: true,
+					},
+				},
+				VGCustomization: blueprint.VGCustomization{
+					LogicalVolumes: []blueprint.LVCustomization{
+						{
+							MinSize: 2 * datasizes.GiB,
+							FilesystemTypedCustomization: blueprint.FilesystemTypedCustomization{
+								Mountpoint: "/",
+								FSType:     "xfs",
+							},
+						},
+					},
+				},
+			},
+		},
+		"encryption-unknown-field": {
+			input: `{
+				"type": "plain",
+				"minsize": "10 GiB",
+				"mountpoint": "/",
+				"fs_type": "xfs",
+				"encryption": {
+					"passphrase": "secret",
+					"pin": "tpm2"
+				}
+			}`,
+			errorMsg: `JSON unmarshal: error decoding encryption customization: json: unknown field "pin"`,
+		},
 	}
 
 	for name := range testCases {
@@ -1945,6 +2162,47 @@ func TestPartitionCustomizationUnmarshalTOML(t *testing.T) {
 					`,
 			errorMsg: `toml: line 0: TOML unmarshal: error decoding partition with type "plain": json: unknown field "subvolumes"`,
 		},
+		"plain-encrypted": {
+			input: `type = "plain"
+					minsize = "10 GiB"
+					mountpoint = "/"
+					fs_type = "xfs"
+
+					[encryption]
+					passphrase = "secret"
+
+					[encryption.clevis]
+					pin = "tang"
+					policy = '{"url": "http://tang.example.com"}'
+					`,
+			expected: &blueprint.PartitionCustomization{
+				Type:    "plain",
+				MinSize: 10 * datasizes.GiB,
+				Encryption: &blueprint.EncryptionCustomization{
+					Passphrase: "secret",
+					Clevis: &blueprint.ClevisCustomization{
+						Pin:    "tang",
+						Policy: `{"url": "http://tang.example.com"}`,
+					},
+				},
+				FilesystemTypedCustomization: blueprint.FilesystemTypedCustomization{
+					Mountpoint: "/",
+					FSType:     "xfs",
+				},
+			},
+		},
+		"encryption-unknown-field": {
+			input: `type = "plain"
+					minsize = "10 GiB"
+					mountpoint = "/"
+					fs_type = "xfs"
+
+					[encryption]
+					passphrase = "secret"
+					key = "abc"
+					`,
+			errorMsg: `toml: line 0: TOML unmarshal: error decoding encryption customization: json: unknown field "key"`,
+		},
 	}
 
 	for name := range testCases {
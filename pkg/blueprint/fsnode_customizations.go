@@ -198,8 +198,11 @@ type FileCustomization struct {
 	// change will make the build fail.
 	//
 	// Initially only single files are supported, but this can be
-	// expanded to dirs (which will just be added recursively) and
-	// http{,s}.
+	// expanded to dirs (which will just be added recursively).
+	//
+	// https:// URIs must pin the content by its digest in the
+	// fragment, e.g. https://example.com/bundle.tar#sha256=<hex>,
+	// the file is then fetched when the image is built.
 	URI string `json:"uri,omitempty" toml:"uri,omitempty"`
 }
 
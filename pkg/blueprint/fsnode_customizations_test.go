@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/BurntSushi/toml"
@@ -1329,3 +1330,16 @@ func TestToFileNodeForRef(t *testing.T) {
 	assert.Equal(t, "/some/path", file.Path())
 	assert.Equal(t, testFile, file.URI())
 }
+
+func TestToFileNodeForRemoteURI(t *testing.T) {
+	digest := strings.Repeat("0", 64)
+	fc := FileCustomization{
+		Path: "/some/path",
+		URI:  "https://example.com/bundle.tar#sha256=" + digest,
+	}
+	file, err := fc.ToFsNodeFile()
+	assert.NoError(t, err)
+	assert.Equal(t, "/some/path", file.Path())
+	assert.Equal(t, "https://example.com/bundle.tar", file.URI())
+	assert.Equal(t, "sha256:"+digest, file.Checksum())
+}
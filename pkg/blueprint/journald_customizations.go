@@ -0,0 +1,83 @@
+package blueprint
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/osbuild/images/pkg/customizations/fsnode"
+)
+
+// JournaldConfPath is the journald.conf(5) drop-in for the journald
+// customizations. It is sorted after the drop-ins of the image types so the
+// blueprint settings take precedence.
+const JournaldConfPath = "/etc/systemd/journald.conf.d/99-blueprint.conf"
+
+type JournaldCustomization struct {
+	// Where to store the journal: "volatile", "persistent", "auto" or "none"
+	Storage string `json:"storage,omitempty" toml:"storage,omitempty"`
+	// Maximum disk space the persistent journal may use, in bytes or with a
+	// K, M, G, T, P or E suffix (base 1024)
+	SystemMaxUse string `json:"system_max_use,omitempty" toml:"system_max_use,omitempty"`
+	// Compress journal objects larger than the default threshold
+	Compress *bool `json:"compress,omitempty" toml:"compress,omitempty"`
+	// Forward journal messages to a traditional syslog daemon
+	ForwardToSyslog *bool `json:"forward_to_syslog,omitempty" toml:"forward_to_syslog,omitempty"`
+}
+
+var (
+	journaldStorageValues = []string{"volatile", "persistent", "auto", "none"}
+	journaldSizeRegex     = regexp.MustCompile(`^[0-9]+[KMGTPE]?$`)
+)
+
+func (j *JournaldCustomization) Validate() error {
+	if j == nil {
+		return nil
+	}
+
+	if *j == (JournaldCustomization{}) {
+		return fmt.Errorf("journald customization requires at least one option")
+	}
+	if j.Storage != "" && !slices.Contains(journaldStorageValues, j.Storage) {
+		return fmt.Errorf("invalid journald storage %q (must be one of %v)", j.Storage, journaldStorageValues)
+	}
+	if j.SystemMaxUse != "" && !journaldSizeRegex.MatchString(j.SystemMaxUse) {
+		return fmt.Errorf("invalid journald system_max_use %q: must be a size in bytes with an optional K, M, G, T, P or E suffix", j.SystemMaxUse)
+	}
+
+	return nil
+}
+
+// FsNodeFile returns the journald.conf(5) drop-in for the customization.
+// The org.osbuild.systemd-journald stage does not support all the options,
+// so the drop-in is written as a plain file.
+func (j *JournaldCustomization) FsNodeFile() (*fsnode.File, error) {
+	if j == nil {
+		return nil, nil
+	}
+
+	yesNo := func(b bool) string {
+		if b {
+			return "yes"
+		}
+		return "no"
+	}
+
+	var data strings.Builder
+	data.WriteString("[Journal]\n")
+	if j.Storage != "" {
+		fmt.Fprintf(&data, "Storage=%s\n", j.Storage)
+	}
+	if j.SystemMaxUse != "" {
+		fmt.Fprintf(&data, "SystemMaxUse=%s\n", j.SystemMaxUse)
+	}
+	if j.Compress != nil {
+		fmt.Fprintf(&data, "Compress=%s\n", yesNo(*j.Compress))
+	}
+	if j.ForwardToSyslog != nil {
+		fmt.Fprintf(&data, "ForwardToSyslog=%s\n", yesNo(*j.ForwardToSyslog))
+	}
+
+	return fsnode.NewFile(JournaldConfPath, nil, nil, nil, []byte(data.String()))
+}
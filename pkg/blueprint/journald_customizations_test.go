@@ -0,0 +1,98 @@
+package blueprint_test
+
+import (
+	"testing"
+
+	"github.com/BurntSushi/toml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/internal/common"
+	"github.com/osbuild/images/pkg/blueprint"
+)
+
+func TestJournaldCustomizationUnmarshalTOML(t *testing.T) {
+	input := `
+[customizations.journald]
+storage = "persistent"
+system_max_use = "2G"
+compress = true
+forward_to_syslog = false
+`
+	var bp blueprint.Blueprint
+	err := toml.Unmarshal([]byte(input), &bp)
+	require.NoError(t, err)
+
+	expected := &blueprint.JournaldCustomization{
+		Storage:         "persistent",
+		SystemMaxUse:    "2G",
+		Compress:        common.ToPtr(true),
+		ForwardToSyslog: common.ToPtr(false),
+	}
+	journald, err := bp.Customizations.GetJournald()
+	require.NoError(t, err)
+	assert.Equal(t, expected, journald)
+}
+
+func TestJournaldCustomizationValidate(t *testing.T) {
+	testCases := []struct {
+		name        string
+		journald    blueprint.JournaldCustomization
+		expectedErr string
+	}{
+		{
+			name:     "happy",
+			journald: blueprint.JournaldCustomization{Storage: "volatile", SystemMaxUse: "512M"},
+		},
+		{
+			name:     "happy-bytes",
+			journald: blueprint.JournaldCustomization{SystemMaxUse: "1073741824"},
+		},
+		{
+			name:        "empty",
+			expectedErr: "journald customization requires at least one option",
+		},
+		{
+			name:        "bad-storage",
+			journald:    blueprint.JournaldCustomization{Storage: "disk"},
+			expectedErr: `invalid journald storage "disk" (must be one of [volatile persistent auto none])`,
+		},
+		{
+			name:        "bad-size",
+			journald:    blueprint.JournaldCustomization{SystemMaxUse: "2 GiB"},
+			expectedErr: `invalid journald system_max_use "2 GiB": must be a size in bytes with an optional K, M, G, T, P or E suffix`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &blueprint.Customizations{Journald: &tc.journald}
+			journald, err := c.GetJournald()
+			if tc.expectedErr != "" {
+				assert.EqualError(t, err, tc.expectedErr)
+				assert.Nil(t, journald)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, c.Journald, journald)
+			}
+		})
+	}
+}
+
+func TestJournaldCustomizationFsNodeFile(t *testing.T) {
+	journald := &blueprint.JournaldCustomization{
+		Storage:         "persistent",
+		SystemMaxUse:    "2G",
+		Compress:        common.ToPtr(false),
+		ForwardToSyslog: common.ToPtr(true),
+	}
+	file, err := journald.FsNodeFile()
+	require.NoError(t, err)
+	assert.Equal(t, "/etc/systemd/journald.conf.d/99-blueprint.conf", file.Path())
+	assert.Equal(t, "[Journal]\nStorage=persistent\nSystemMaxUse=2G\nCompress=no\nForwardToSyslog=yes\n", string(file.Data()))
+
+	var nilJournald *blueprint.JournaldCustomization
+	file, err = nilJournald.FsNodeFile()
+	assert.NoError(t, err)
+	assert.Nil(t, file)
+}
@@ -0,0 +1,129 @@
+package blueprint
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/osbuild/images/pkg/customizations/fsnode"
+)
+
+const (
+	// KernelModulesLoadPath is the modules-load.d(5) file for the kernel
+	// modules that are loaded on boot.
+	KernelModulesLoadPath = "/etc/modules-load.d/blueprint.conf"
+	// KernelModulesOptionsPath is the modprobe.d(5) file for the kernel
+	// module options.
+	KernelModulesOptionsPath = "/etc/modprobe.d/blueprint-options.conf"
+)
+
+type KernelModulesCustomization struct {
+	// Kernel modules to load on boot
+	Load []string `json:"load,omitempty" toml:"load,omitempty"`
+	// Kernel modules to prevent from being loaded automatically and to
+	// keep out of the initramfs
+	Blacklist []string `json:"blacklist,omitempty" toml:"blacklist,omitempty"`
+	// Options to pass to a kernel module when it is loaded, keyed by the
+	// module name
+	Options map[string]string `json:"options,omitempty" toml:"options,omitempty"`
+}
+
+var kernelModuleNameRegex = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// NormalizeKernelModuleName returns the name of the module with all dashes
+// replaced by underscores. The kernel and modprobe treat the two characters
+// as equivalent in module names.
+func NormalizeKernelModuleName(name string) string {
+	return strings.ReplaceAll(name, "-", "_")
+}
+
+func (m *KernelModulesCustomization) Validate() error {
+	if m == nil {
+		return nil
+	}
+
+	loaded := make(map[string]bool, len(m.Load))
+	for _, name := range m.Load {
+		if !kernelModuleNameRegex.MatchString(name) {
+			return fmt.Errorf("invalid kernel module name %q", name)
+		}
+		loaded[NormalizeKernelModuleName(name)] = true
+	}
+
+	for _, name := range m.Blacklist {
+		if !kernelModuleNameRegex.MatchString(name) {
+			return fmt.Errorf("invalid kernel module name %q", name)
+		}
+		if loaded[NormalizeKernelModuleName(name)] {
+			return fmt.Errorf("kernel module %q cannot be both loaded and blacklisted", name)
+		}
+	}
+
+	withOptions := make(map[string]bool, len(m.Options))
+	for _, name := range m.OptionsModules() {
+		opts := m.Options[name]
+		if !kernelModuleNameRegex.MatchString(name) {
+			return fmt.Errorf("invalid kernel module name %q", name)
+		}
+		if strings.TrimSpace(opts) == "" {
+			return fmt.Errorf("options for kernel module %q cannot be empty", name)
+		}
+		if strings.ContainsAny(opts, "\n\r") {
+			return fmt.Errorf("options for kernel module %q must not contain newlines", name)
+		}
+		nname := NormalizeKernelModuleName(name)
+		if withOptions[nname] {
+			return fmt.Errorf("duplicate options for kernel module %q", nname)
+		}
+		withOptions[nname] = true
+	}
+
+	return nil
+}
+
+// OptionsModules returns the names of the modules with options in a stable
+// order.
+func (m *KernelModulesCustomization) OptionsModules() []string {
+	names := make([]string, 0, len(m.Options))
+	for name := range m.Options {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// FsNodeFiles returns the modules-load.d and modprobe.d files for the
+// modules to load and the module options. Blacklisting is not part of the
+// returned files and is configured via the modprobe stage.
+func (m *KernelModulesCustomization) FsNodeFiles() ([]*fsnode.File, error) {
+	if m == nil {
+		return nil, nil
+	}
+
+	var files []*fsnode.File
+	if len(m.Load) > 0 {
+		data := strings.Join(m.Load, "\n") + "\n"
+		file, err := fsnode.NewFile(KernelModulesLoadPath, nil, nil, nil, []byte(data))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+
+	// The org.osbuild.modprobe stage only supports the "blacklist" and
+	// "install" commands, so the options are written as a plain file.
+	if len(m.Options) > 0 {
+		var data strings.Builder
+		for _, name := range m.OptionsModules() {
+			fmt.Fprintf(&data, "options %s %s\n", name, m.Options[name])
+		}
+		file, err := fsnode.NewFile(KernelModulesOptionsPath, nil, nil, nil, []byte(data.String()))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+
+	return files, nil
+}
@@ -0,0 +1,134 @@
+package blueprint_test
+
+import (
+	"testing"
+
+	"github.com/BurntSushi/toml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/pkg/blueprint"
+)
+
+func TestKernelModulesCustomizationUnmarshalTOML(t *testing.T) {
+	input := `
+[customizations.kernel_modules]
+load = ["vfio", "vfio-pci"]
+blacklist = ["nouveau"]
+
+[customizations.kernel_modules.options]
+vfio-pci = "ids=10de:1b80,10de:10f0"
+`
+	var bp blueprint.Blueprint
+	err := toml.Unmarshal([]byte(input), &bp)
+	require.NoError(t, err)
+
+	expected := &blueprint.KernelModulesCustomization{
+		Load:      []string{"vfio", "vfio-pci"},
+		Blacklist: []string{"nouveau"},
+		Options: map[string]string{
+			"vfio-pci": "ids=10de:1b80,10de:10f0",
+		},
+	}
+	modules, err := bp.Customizations.GetKernelModules()
+	require.NoError(t, err)
+	assert.Equal(t, expected, modules)
+}
+
+func TestKernelModulesCustomizationValidate(t *testing.T) {
+	testCases := []struct {
+		name        string
+		modules     *blueprint.KernelModulesCustomization
+		expectedErr string
+	}{
+		{
+			name: "happy",
+			modules: &blueprint.KernelModulesCustomization{
+				Load:      []string{"vfio_pci"},
+				Blacklist: []string{"nouveau"},
+				Options:   map[string]string{"nouveau": "modeset=0"},
+			},
+		},
+		{
+			name: "bad-load-name",
+			modules: &blueprint.KernelModulesCustomization{
+				Load: []string{"vfio pci"},
+			},
+			expectedErr: `invalid kernel module name "vfio pci"`,
+		},
+		{
+			name: "bad-blacklist-name",
+			modules: &blueprint.KernelModulesCustomization{
+				Blacklist: []string{"../nouveau"},
+			},
+			expectedErr: `invalid kernel module name "../nouveau"`,
+		},
+		{
+			name: "load-and-blacklist",
+			modules: &blueprint.KernelModulesCustomization{
+				Load:      []string{"vfio-pci"},
+				Blacklist: []string{"vfio_pci"},
+			},
+			expectedErr: `kernel module "vfio_pci" cannot be both loaded and blacklisted`,
+		},
+		{
+			name: "empty-options",
+			modules: &blueprint.KernelModulesCustomization{
+				Options: map[string]string{"nouveau": " "},
+			},
+			expectedErr: `options for kernel module "nouveau" cannot be empty`,
+		},
+		{
+			name: "newline-in-options",
+			modules: &blueprint.KernelModulesCustomization{
+				Options: map[string]string{"nouveau": "modeset=0\ninstall nouveau /bin/true"},
+			},
+			expectedErr: `options for kernel module "nouveau" must not contain newlines`,
+		},
+		{
+			name: "duplicate-options",
+			modules: &blueprint.KernelModulesCustomization{
+				Options: map[string]string{"vfio-pci": "ids=10de:1b80", "vfio_pci": "ids=10de:10f0"},
+			},
+			expectedErr: `duplicate options for kernel module "vfio_pci"`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &blueprint.Customizations{KernelModules: tc.modules}
+			modules, err := c.GetKernelModules()
+			if tc.expectedErr != "" {
+				assert.EqualError(t, err, tc.expectedErr)
+				assert.Nil(t, modules)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.modules, modules)
+			}
+		})
+	}
+}
+
+func TestKernelModulesCustomizationFsNodeFiles(t *testing.T) {
+	modules := &blueprint.KernelModulesCustomization{
+		Load:      []string{"vfio", "vfio-pci"},
+		Blacklist: []string{"nouveau"},
+		Options: map[string]string{
+			"vfio-pci": "ids=10de:1b80",
+			"kvm":      "ignore_msrs=1",
+		},
+	}
+
+	files, err := modules.FsNodeFiles()
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	assert.Equal(t, blueprint.KernelModulesLoadPath, files[0].Path())
+	assert.Equal(t, "vfio\nvfio-pci\n", string(files[0].Data()))
+	assert.Equal(t, blueprint.KernelModulesOptionsPath, files[1].Path())
+	assert.Equal(t, "options kvm ignore_msrs=1\noptions vfio-pci ids=10de:1b80\n", string(files[1].Data()))
+
+	// blacklisting is done via the modprobe stage
+	files, err = (&blueprint.KernelModulesCustomization{Blacklist: []string{"nouveau"}}).FsNodeFiles()
+	require.NoError(t, err)
+	assert.Empty(t, files)
+}
@@ -0,0 +1,301 @@
+package blueprint
+
+import (
+	"fmt"
+	"maps"
+	"net/netip"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// NetworkCustomization defines NetworkManager connection profiles that are
+// written to the image.
+type NetworkCustomization struct {
+	Connections []NetworkConnectionCustomization `json:"connections,omitempty" toml:"connections,omitempty"`
+}
+
+type NetworkConnectionCustomization struct {
+	// Name of the connection profile (connection.id), also used as the
+	// name of the keyfile
+	Name string `json:"name" toml:"name"`
+	// Type of the connection: ethernet, bond, vlan or bridge
+	Type string `json:"type" toml:"type"`
+	// Name of the network interface the connection applies to, for virtual
+	// interfaces (bond, vlan, bridge) this is the name of the created
+	// interface
+	Interface string `json:"interface,omitempty" toml:"interface,omitempty"`
+	// Autoconnect the connection, defaults to true
+	Autoconnect *bool `json:"autoconnect,omitempty" toml:"autoconnect,omitempty"`
+	// MTU of the interface
+	MTU uint32 `json:"mtu,omitempty" toml:"mtu,omitempty"`
+	// Interface name of the bond or bridge this connection is a port of.
+	// Connections with a controller have no IP configuration.
+	Controller string `json:"controller,omitempty" toml:"controller,omitempty"`
+
+	IPv4 *NetworkIPCustomization `json:"ipv4,omitempty" toml:"ipv4,omitempty"`
+	IPv6 *NetworkIPCustomization `json:"ipv6,omitempty" toml:"ipv6,omitempty"`
+
+	Bond   *NetworkBondCustomization   `json:"bond,omitempty" toml:"bond,omitempty"`
+	VLAN   *NetworkVLANCustomization   `json:"vlan,omitempty" toml:"vlan,omitempty"`
+	Bridge *NetworkBridgeCustomization `json:"bridge,omitempty" toml:"bridge,omitempty"`
+}
+
+type NetworkIPCustomization struct {
+	// Method used to configure the addresses: "auto" (default), "manual"
+	// or "disabled"
+	Method string `json:"method,omitempty" toml:"method,omitempty"`
+	// Static addresses in CIDR notation, required for the "manual" method
+	Addresses []string `json:"addresses,omitempty" toml:"addresses,omitempty"`
+	Gateway   string   `json:"gateway,omitempty" toml:"gateway,omitempty"`
+	// DNS servers
+	DNS []string `json:"dns,omitempty" toml:"dns,omitempty"`
+	// DNS search domains
+	DNSSearch []string `json:"dns_search,omitempty" toml:"dns_search,omitempty"`
+}
+
+type NetworkBondCustomization struct {
+	// Bonding mode, for example "active-backup" or "802.3ad"
+	Mode string `json:"mode" toml:"mode"`
+	// Additional bonding options (e.g. miimon)
+	Options map[string]string `json:"options,omitempty" toml:"options,omitempty"`
+}
+
+type NetworkVLANCustomization struct {
+	ID uint16 `json:"id" toml:"id"`
+	// Interface name of the parent device
+	Parent string `json:"parent" toml:"parent"`
+}
+
+type NetworkBridgeCustomization struct {
+	STP *bool `json:"stp,omitempty" toml:"stp,omitempty"`
+}
+
+const (
+	NetworkConnectionTypeEthernet = "ethernet"
+	NetworkConnectionTypeBond     = "bond"
+	NetworkConnectionTypeVLAN     = "vlan"
+	NetworkConnectionTypeBridge   = "bridge"
+
+	NetworkIPMethodAuto     = "auto"
+	NetworkIPMethodManual   = "manual"
+	NetworkIPMethodDisabled = "disabled"
+)
+
+var (
+	networkConnectionTypes = []string{
+		NetworkConnectionTypeEthernet,
+		NetworkConnectionTypeBond,
+		NetworkConnectionTypeVLAN,
+		NetworkConnectionTypeBridge,
+	}
+
+	networkIPMethods = []string{
+		NetworkIPMethodAuto,
+		NetworkIPMethodManual,
+		NetworkIPMethodDisabled,
+	}
+
+	networkBondModes = []string{
+		"balance-rr",
+		"active-backup",
+		"balance-xor",
+		"broadcast",
+		"802.3ad",
+		"balance-tlb",
+		"balance-alb",
+	}
+
+	// connection names are used as file names
+	networkConnectionNameRegex = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]{0,63}$`)
+	// see dev_valid_name() in the kernel
+	networkInterfaceNameRegex = regexp.MustCompile(`^[^/:\s]{1,15}$`)
+	networkBondOptionRegex    = regexp.MustCompile(`^[a-z_]+$`)
+	networkDNSSearchRegex     = regexp.MustCompile(`^[^;,\s]+$`)
+)
+
+func (n *NetworkCustomization) Validate() error {
+	if n == nil {
+		return nil
+	}
+
+	names := make(map[string]bool, len(n.Connections))
+	// interface names of the connections that can be a controller
+	controllers := make(map[string]string)
+	for _, conn := range n.Connections {
+		if names[conn.Name] {
+			return fmt.Errorf("duplicate network connection name %q", conn.Name)
+		}
+		names[conn.Name] = true
+		if conn.Type == NetworkConnectionTypeBond || conn.Type == NetworkConnectionTypeBridge {
+			controllers[conn.Interface] = conn.Type
+		}
+	}
+
+	for _, conn := range n.Connections {
+		if err := conn.validate(); err != nil {
+			return fmt.Errorf("invalid network connection %q: %w", conn.Name, err)
+		}
+		if conn.Controller != "" {
+			if _, ok := controllers[conn.Controller]; !ok {
+				return fmt.Errorf("invalid network connection %q: controller %q is not the interface of a bond or bridge connection", conn.Name, conn.Controller)
+			}
+			if conn.Controller == conn.Interface {
+				return fmt.Errorf("invalid network connection %q: connection cannot be its own controller", conn.Name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// ControllerType returns the type (bond or bridge) of the connection that
+// has the given interface name.
+func (n *NetworkCustomization) ControllerType(iface string) string {
+	for _, conn := range n.Connections {
+		if conn.Interface == iface && (conn.Type == NetworkConnectionTypeBond || conn.Type == NetworkConnectionTypeBridge) {
+			return conn.Type
+		}
+	}
+	return ""
+}
+
+func (c *NetworkConnectionCustomization) validate() error {
+	if !networkConnectionNameRegex.MatchString(c.Name) {
+		return fmt.Errorf("name must match %s", networkConnectionNameRegex.String())
+	}
+	if !slices.Contains(networkConnectionTypes, c.Type) {
+		return fmt.Errorf("unknown type %q (must be one of %v)", c.Type, networkConnectionTypes)
+	}
+
+	switch c.Type {
+	case NetworkConnectionTypeEthernet:
+		// an ethernet connection without an interface name applies to
+		// any device
+		if c.Interface != "" && !networkInterfaceNameRegex.MatchString(c.Interface) {
+			return fmt.Errorf("invalid interface name %q", c.Interface)
+		}
+	default:
+		if c.Interface == "" {
+			return fmt.Errorf("interface name is required for %s connections", c.Type)
+		}
+		if !networkInterfaceNameRegex.MatchString(c.Interface) {
+			return fmt.Errorf("invalid interface name %q", c.Interface)
+		}
+	}
+
+	if (c.Bond != nil) != (c.Type == NetworkConnectionTypeBond) {
+		return fmt.Errorf("bond options are required for, and only valid for, bond connections")
+	}
+	if (c.VLAN != nil) != (c.Type == NetworkConnectionTypeVLAN) {
+		return fmt.Errorf("vlan options are required for, and only valid for, vlan connections")
+	}
+	if c.Bridge != nil && c.Type != NetworkConnectionTypeBridge {
+		return fmt.Errorf("bridge options are only valid for bridge connections")
+	}
+
+	if c.Bond != nil {
+		if !slices.Contains(networkBondModes, c.Bond.Mode) {
+			return fmt.Errorf("unknown bond mode %q (must be one of %v)", c.Bond.Mode, networkBondModes)
+		}
+		for _, opt := range slices.Sorted(maps.Keys(c.Bond.Options)) {
+			value := c.Bond.Options[opt]
+			if opt == "mode" {
+				return fmt.Errorf("bond mode must be set using the mode option")
+			}
+			if !networkBondOptionRegex.MatchString(opt) {
+				return fmt.Errorf("invalid bond option %q", opt)
+			}
+			if !keyfileValueIsValid(value) {
+				return fmt.Errorf("invalid value for bond option %q", opt)
+			}
+		}
+	}
+
+	if c.VLAN != nil {
+		if c.VLAN.ID < 1 || c.VLAN.ID > 4094 {
+			return fmt.Errorf("vlan id must be between 1 and 4094")
+		}
+		if !networkInterfaceNameRegex.MatchString(c.VLAN.Parent) {
+			return fmt.Errorf("invalid vlan parent interface name %q", c.VLAN.Parent)
+		}
+	}
+
+	if c.Controller != "" {
+		if c.IPv4 != nil || c.IPv6 != nil {
+			return fmt.Errorf("connections with a controller cannot have an IP configuration")
+		}
+	}
+
+	if err := c.IPv4.validate(false); err != nil {
+		return fmt.Errorf("ipv4: %w", err)
+	}
+	if err := c.IPv6.validate(true); err != nil {
+		return fmt.Errorf("ipv6: %w", err)
+	}
+
+	return nil
+}
+
+func (ip *NetworkIPCustomization) validate(v6 bool) error {
+	if ip == nil {
+		return nil
+	}
+
+	validAddr := func(addr netip.Addr) bool {
+		if v6 {
+			return addr.Is6() && !addr.Is4In6()
+		}
+		return addr.Is4()
+	}
+
+	method := ip.Method
+	if method == "" {
+		method = NetworkIPMethodAuto
+	}
+	if !slices.Contains(networkIPMethods, method) {
+		return fmt.Errorf("unknown method %q (must be one of %v)", ip.Method, networkIPMethods)
+	}
+
+	if method == NetworkIPMethodManual && len(ip.Addresses) == 0 {
+		return fmt.Errorf("at least one address is required for the manual method")
+	}
+	if method != NetworkIPMethodManual && (len(ip.Addresses) > 0 || ip.Gateway != "") {
+		return fmt.Errorf("addresses and gateway are only valid for the manual method")
+	}
+	if method == NetworkIPMethodDisabled && (len(ip.DNS) > 0 || len(ip.DNSSearch) > 0) {
+		return fmt.Errorf("dns options are not valid for the disabled method")
+	}
+
+	for _, addr := range ip.Addresses {
+		prefix, err := netip.ParsePrefix(addr)
+		if err != nil || !validAddr(prefix.Addr()) {
+			return fmt.Errorf("invalid address %q", addr)
+		}
+	}
+	if ip.Gateway != "" {
+		gw, err := netip.ParseAddr(ip.Gateway)
+		if err != nil || !validAddr(gw) {
+			return fmt.Errorf("invalid gateway %q", ip.Gateway)
+		}
+	}
+	for _, dns := range ip.DNS {
+		addr, err := netip.ParseAddr(dns)
+		if err != nil || !validAddr(addr) {
+			return fmt.Errorf("invalid dns server %q", dns)
+		}
+	}
+	for _, domain := range ip.DNSSearch {
+		if !networkDNSSearchRegex.MatchString(domain) {
+			return fmt.Errorf("invalid dns search domain %q", domain)
+		}
+	}
+
+	return nil
+}
+
+// keyfileValueIsValid checks that the value can be written to a keyfile
+// without breaking its structure.
+func keyfileValueIsValid(value string) bool {
+	return value != "" && !strings.ContainsAny(value, "\n\r")
+}
@@ -0,0 +1,258 @@
+package blueprint_test
+
+import (
+	"testing"
+
+	"github.com/BurntSushi/toml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/internal/common"
+	"github.com/osbuild/images/pkg/blueprint"
+)
+
+func TestNetworkCustomizationUnmarshalTOML(t *testing.T) {
+	input := `
+[[customizations.network.connections]]
+name = "bond0"
+type = "bond"
+interface = "bond0"
+mtu = 9000
+
+[customizations.network.connections.bond]
+mode = "active-backup"
+options = { miimon = "100" }
+
+[customizations.network.connections.ipv4]
+method = "manual"
+addresses = ["192.168.1.10/24"]
+gateway = "192.168.1.1"
+dns = ["192.168.1.1"]
+dns_search = ["example.com"]
+
+[[customizations.network.connections]]
+name = "bond0-port-eth0"
+type = "ethernet"
+interface = "eth0"
+controller = "bond0"
+
+[[customizations.network.connections]]
+name = "vlan100"
+type = "vlan"
+interface = "bond0.100"
+autoconnect = false
+vlan = { id = 100, parent = "bond0" }
+`
+	var bp blueprint.Blueprint
+	err := toml.Unmarshal([]byte(input), &bp)
+	require.NoError(t, err)
+
+	expected := &blueprint.NetworkCustomization{
+		Connections: []blueprint.NetworkConnectionCustomization{
+			{
+				Name:      "bond0",
+				Type:      "bond",
+				Interface: "bond0",
+				MTU:       9000,
+				Bond: &blueprint.NetworkBondCustomization{
+					Mode:    "active-backup",
+					Options: map[string]string{"miimon": "100"},
+				},
+				IPv4: &blueprint.NetworkIPCustomization{
+					Method:    "manual",
+					Addresses: []string{"192.168.1.10/24"},
+					Gateway:   "192.168.1.1",
+					DNS:       []string{"192.168.1.1"},
+					DNSSearch: []string{"example.com"},
+				},
+			},
+			{
+				Name:       "bond0-port-eth0",
+				Type:       "ethernet",
+				Interface:  "eth0",
+				Controller: "bond0",
+			},
+			{
+				Name:        "vlan100",
+				Type:        "vlan",
+				Interface:   "bond0.100",
+				Autoconnect: common.ToPtr(false),
+				VLAN:        &blueprint.NetworkVLANCustomization{ID: 100, Parent: "bond0"},
+			},
+		},
+	}
+	network, err := bp.Customizations.GetNetwork()
+	require.NoError(t, err)
+	assert.Equal(t, expected, network)
+}
+
+func TestNetworkCustomizationValidate(t *testing.T) {
+	eth := func(mod func(c *blueprint.NetworkConnectionCustomization)) blueprint.NetworkConnectionCustomization {
+		c := blueprint.NetworkConnectionCustomization{Name: "eth0", Type: "ethernet", Interface: "eth0"}
+		if mod != nil {
+			mod(&c)
+		}
+		return c
+	}
+
+	testCases := []struct {
+		name        string
+		connections []blueprint.NetworkConnectionCustomization
+		expectedErr string
+	}{
+		{
+			name: "happy-static",
+			connections: []blueprint.NetworkConnectionCustomization{
+				eth(func(c *blueprint.NetworkConnectionCustomization) {
+					c.IPv4 = &blueprint.NetworkIPCustomization{Method: "manual", Addresses: []string{"10.0.0.2/8"}, Gateway: "10.0.0.1"}
+					c.IPv6 = &blueprint.NetworkIPCustomization{Method: "manual", Addresses: []string{"fd00::2/64"}, DNS: []string{"fd00::1"}}
+				}),
+			},
+		},
+		{
+			name: "happy-bridge",
+			connections: []blueprint.NetworkConnectionCustomization{
+				{Name: "br0", Type: "bridge", Interface: "br0", Bridge: &blueprint.NetworkBridgeCustomization{STP: common.ToPtr(false)}},
+				eth(func(c *blueprint.NetworkConnectionCustomization) { c.Controller = "br0" }),
+			},
+		},
+		{
+			name: "duplicate-name",
+			connections: []blueprint.NetworkConnectionCustomization{
+				eth(nil),
+				eth(nil),
+			},
+			expectedErr: `duplicate network connection name "eth0"`,
+		},
+		{
+			name: "bad-name",
+			connections: []blueprint.NetworkConnectionCustomization{
+				eth(func(c *blueprint.NetworkConnectionCustomization) { c.Name = "../eth0" }),
+			},
+			expectedErr: `invalid network connection "../eth0": name must match ^[a-zA-Z0-9][a-zA-Z0-9_.-]{0,63}$`,
+		},
+		{
+			name: "bad-type",
+			connections: []blueprint.NetworkConnectionCustomization{
+				eth(func(c *blueprint.NetworkConnectionCustomization) { c.Type = "wifi" }),
+			},
+			expectedErr: `invalid network connection "eth0": unknown type "wifi" (must be one of [ethernet bond vlan bridge])`,
+		},
+		{
+			name: "bond-without-interface",
+			connections: []blueprint.NetworkConnectionCustomization{
+				{Name: "bond0", Type: "bond", Bond: &blueprint.NetworkBondCustomization{Mode: "802.3ad"}},
+			},
+			expectedErr: `invalid network connection "bond0": interface name is required for bond connections`,
+		},
+		{
+			name: "bond-bad-mode",
+			connections: []blueprint.NetworkConnectionCustomization{
+				{Name: "bond0", Type: "bond", Interface: "bond0", Bond: &blueprint.NetworkBondCustomization{Mode: "fast"}},
+			},
+			expectedErr: `invalid network connection "bond0": unknown bond mode "fast" (must be one of [balance-rr active-backup balance-xor broadcast 802.3ad balance-tlb balance-alb])`,
+		},
+		{
+			name: "vlan-bad-id",
+			connections: []blueprint.NetworkConnectionCustomization{
+				{Name: "vlan0", Type: "vlan", Interface: "eth0.0", VLAN: &blueprint.NetworkVLANCustomization{ID: 0, Parent: "eth0"}},
+			},
+			expectedErr: `invalid network connection "vlan0": vlan id must be between 1 and 4094`,
+		},
+		{
+			name: "vlan-options-on-ethernet",
+			connections: []blueprint.NetworkConnectionCustomization{
+				eth(func(c *blueprint.NetworkConnectionCustomization) {
+					c.VLAN = &blueprint.NetworkVLANCustomization{ID: 10, Parent: "eth1"}
+				}),
+			},
+			expectedErr: `invalid network connection "eth0": vlan options are required for, and only valid for, vlan connections`,
+		},
+		{
+			name: "unknown-controller",
+			connections: []blueprint.NetworkConnectionCustomization{
+				eth(func(c *blueprint.NetworkConnectionCustomization) { c.Controller = "bond0" }),
+			},
+			expectedErr: `invalid network connection "eth0": controller "bond0" is not the interface of a bond or bridge connection`,
+		},
+		{
+			name: "port-with-ip",
+			connections: []blueprint.NetworkConnectionCustomization{
+				{Name: "br0", Type: "bridge", Interface: "br0"},
+				eth(func(c *blueprint.NetworkConnectionCustomization) {
+					c.Controller = "br0"
+					c.IPv4 = &blueprint.NetworkIPCustomization{Method: "auto"}
+				}),
+			},
+			expectedErr: `invalid network connection "eth0": connections with a controller cannot have an IP configuration`,
+		},
+		{
+			name: "manual-without-address",
+			connections: []blueprint.NetworkConnectionCustomization{
+				eth(func(c *blueprint.NetworkConnectionCustomization) {
+					c.IPv4 = &blueprint.NetworkIPCustomization{Method: "manual"}
+				}),
+			},
+			expectedErr: `invalid network connection "eth0": ipv4: at least one address is required for the manual method`,
+		},
+		{
+			name: "address-with-auto",
+			connections: []blueprint.NetworkConnectionCustomization{
+				eth(func(c *blueprint.NetworkConnectionCustomization) {
+					c.IPv4 = &blueprint.NetworkIPCustomization{Addresses: []string{"10.0.0.2/8"}}
+				}),
+			},
+			expectedErr: `invalid network connection "eth0": ipv4: addresses and gateway are only valid for the manual method`,
+		},
+		{
+			name: "ipv6-address-in-ipv4",
+			connections: []blueprint.NetworkConnectionCustomization{
+				eth(func(c *blueprint.NetworkConnectionCustomization) {
+					c.IPv4 = &blueprint.NetworkIPCustomization{Method: "manual", Addresses: []string{"fd00::2/64"}}
+				}),
+			},
+			expectedErr: `invalid network connection "eth0": ipv4: invalid address "fd00::2/64"`,
+		},
+		{
+			name: "address-without-prefix",
+			connections: []blueprint.NetworkConnectionCustomization{
+				eth(func(c *blueprint.NetworkConnectionCustomization) {
+					c.IPv4 = &blueprint.NetworkIPCustomization{Method: "manual", Addresses: []string{"10.0.0.2"}}
+				}),
+			},
+			expectedErr: `invalid network connection "eth0": ipv4: invalid address "10.0.0.2"`,
+		},
+		{
+			name: "bad-dns",
+			connections: []blueprint.NetworkConnectionCustomization{
+				eth(func(c *blueprint.NetworkConnectionCustomization) {
+					c.IPv6 = &blueprint.NetworkIPCustomization{DNS: []string{"1.1.1.1"}}
+				}),
+			},
+			expectedErr: `invalid network connection "eth0": ipv6: invalid dns server "1.1.1.1"`,
+		},
+		{
+			name: "bad-dns-search",
+			connections: []blueprint.NetworkConnectionCustomization{
+				eth(func(c *blueprint.NetworkConnectionCustomization) {
+					c.IPv4 = &blueprint.NetworkIPCustomization{DNSSearch: []string{"a.com;b.com"}}
+				}),
+			},
+			expectedErr: `invalid network connection "eth0": ipv4: invalid dns search domain "a.com;b.com"`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &blueprint.Customizations{Network: &blueprint.NetworkCustomization{Connections: tc.connections}}
+			network, err := c.GetNetwork()
+			if tc.expectedErr != "" {
+				assert.EqualError(t, err, tc.expectedErr)
+				assert.Nil(t, network)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, c.Network, network)
+			}
+		})
+	}
+}
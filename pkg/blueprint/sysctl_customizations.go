@@ -0,0 +1,132 @@
+package blueprint
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// SysctlCustomization maps kernel parameter names to the values they should
+// be set to on boot. The keys use the sysctl.d(5) syntax, either separated
+// by "." or by "/".
+//
+// In TOML, keys that are not quoted are parsed as nested tables, so both
+//
+//	[customizations.sysctl]
+//	"net.ipv4.ip_forward" = 1
+//
+// and
+//
+//	[customizations.sysctl]
+//	net.ipv4.ip_forward = 1
+//
+// are accepted and result in the same setting.
+type SysctlCustomization map[string]string
+
+// sysctlKeyRegex matches sysctl.d(5) keys. A key can be prefixed with "-"
+// to ignore errors when setting it (or to exclude it from a glob match when
+// no value is set) and may contain "*" globs.
+var sysctlKeyRegex = regexp.MustCompile(`^-?[a-zA-Z0-9_*]+([./][a-zA-Z0-9_*:@+\-]+)*$`)
+
+func (sc *SysctlCustomization) UnmarshalJSON(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	// keep numbers as they are written, large values (e.g. kernel.shmmax)
+	// would otherwise lose precision
+	dec.UseNumber()
+
+	var raw map[string]any
+	if err := dec.Decode(&raw); err != nil {
+		return err
+	}
+
+	settings := SysctlCustomization{}
+	if err := flattenSysctl(settings, "", raw); err != nil {
+		return err
+	}
+	*sc = settings
+	return nil
+}
+
+func (sc *SysctlCustomization) UnmarshalTOML(data any) error {
+	return unmarshalTOMLviaJSON(sc, data)
+}
+
+func flattenSysctl(settings SysctlCustomization, prefix string, raw map[string]any) error {
+	for k, v := range raw {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+		switch value := v.(type) {
+		case string:
+			settings[key] = value
+		case json.Number:
+			settings[key] = value.String()
+		case map[string]any:
+			if err := flattenSysctl(settings, key, value); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("invalid value for sysctl key %q: must be a string or a number", key)
+		}
+	}
+	return nil
+}
+
+// NormalizeSysctlKey returns the key in its canonical "." separated form.
+// As described in sysctl.d(5), when the first separator of a key is a "/",
+// the "/" and "." characters are swapped.
+func NormalizeSysctlKey(key string) string {
+	idx := strings.IndexAny(key, "./")
+	if idx == -1 || key[idx] == '.' {
+		return key
+	}
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '/':
+			return '.'
+		case '.':
+			return '/'
+		}
+		return r
+	}, key)
+}
+
+// Validate checks that all keys are valid sysctl names, that values are set
+// (unless the key is an exclusion) and that no two keys that refer to the
+// same kernel parameter have different values.
+func (sc SysctlCustomization) Validate() error {
+	normalized := make(map[string]string, len(sc))
+	for _, key := range sc.Keys() {
+		value := sc[key]
+		if !sysctlKeyRegex.MatchString(key) {
+			return fmt.Errorf("invalid sysctl key %q", key)
+		}
+		if strings.ContainsAny(value, "\n\r") {
+			return fmt.Errorf("invalid value for sysctl key %q: must not contain newlines", key)
+		}
+		if strings.TrimSpace(value) == "" && !strings.HasPrefix(key, "-") {
+			return fmt.Errorf("sysctl key %q requires a value, only keys starting with '-' can have an empty value", key)
+		}
+
+		nkey := NormalizeSysctlKey(strings.TrimPrefix(key, "-"))
+		if prev, ok := normalized[nkey]; ok && prev != value {
+			return fmt.Errorf("conflicting values for sysctl key %q: %q and %q", nkey, prev, value)
+		}
+		normalized[nkey] = value
+	}
+	return nil
+}
+
+// Keys returns the keys of the customization in a stable order.
+func (sc SysctlCustomization) Keys() []string {
+	keys := make([]string, 0, len(sc))
+	for key := range sc {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,154 @@
+package blueprint_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/BurntSushi/toml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/pkg/blueprint"
+)
+
+func TestSysctlCustomizationUnmarshalTOML(t *testing.T) {
+	input := `
+[customizations.sysctl]
+"net.ipv4.ip_forward" = 1
+"kernel.shmmax" = 68719476736
+vm.swappiness = "10"
+"net/ipv4/conf/eth0.100/rp_filter" = "2"
+`
+	var bp blueprint.Blueprint
+	err := toml.Unmarshal([]byte(input), &bp)
+	require.NoError(t, err)
+
+	expected := blueprint.SysctlCustomization{
+		"net.ipv4.ip_forward":              "1",
+		"kernel.shmmax":                    "68719476736",
+		"vm.swappiness":                    "10",
+		"net/ipv4/conf/eth0.100/rp_filter": "2",
+	}
+	assert.Equal(t, expected, bp.Customizations.Sysctl)
+}
+
+func TestSysctlCustomizationUnmarshalJSON(t *testing.T) {
+	input := `{"customizations": {"sysctl": {"net.ipv4.ip_forward": 1, "vm.swappiness": "10"}}}`
+	var bp blueprint.Blueprint
+	err := json.Unmarshal([]byte(input), &bp)
+	require.NoError(t, err)
+
+	expected := blueprint.SysctlCustomization{
+		"net.ipv4.ip_forward": "1",
+		"vm.swappiness":       "10",
+	}
+	assert.Equal(t, expected, bp.Customizations.Sysctl)
+}
+
+func TestSysctlCustomizationUnmarshalUnhappy(t *testing.T) {
+	var sc blueprint.SysctlCustomization
+	err := json.Unmarshal([]byte(`{"net.ipv4.ip_forward": true}`), &sc)
+	assert.EqualError(t, err, `invalid value for sysctl key "net.ipv4.ip_forward": must be a string or a number`)
+}
+
+func TestNormalizeSysctlKey(t *testing.T) {
+	testCases := map[string]string{
+		"net.ipv4.ip_forward":              "net.ipv4.ip_forward",
+		"net/ipv4/ip_forward":              "net.ipv4.ip_forward",
+		"net/ipv4/conf/eth0.100/rp_filter": "net.ipv4.conf.eth0/100.rp_filter",
+		"net.ipv4.conf.eth0/100.rp_filter": "net.ipv4.conf.eth0/100.rp_filter",
+		"kernel":                           "kernel",
+	}
+	for input, expected := range testCases {
+		assert.Equal(t, expected, blueprint.NormalizeSysctlKey(input), input)
+	}
+}
+
+func TestSysctlCustomizationValidate(t *testing.T) {
+	testCases := []struct {
+		name        string
+		sysctl      blueprint.SysctlCustomization
+		expectedErr string
+	}{
+		{
+			name: "happy",
+			sysctl: blueprint.SysctlCustomization{
+				"net.ipv4.ip_forward":         "1",
+				"net.ipv4.conf.*.rp_filter":   "2",
+				"-net.ipv4.conf.lo.rp_filter": "",
+				"kernel.core_pattern":         "|/usr/lib/systemd/systemd-coredump %P %u %g %s %t %c %h",
+			},
+		},
+		{
+			name: "same-value-different-separator",
+			sysctl: blueprint.SysctlCustomization{
+				"net.ipv4.ip_forward": "1",
+				"net/ipv4/ip_forward": "1",
+			},
+		},
+		{
+			name: "conflicting-values",
+			sysctl: blueprint.SysctlCustomization{
+				"net.ipv4.ip_forward": "1",
+				"net/ipv4/ip_forward": "0",
+			},
+			expectedErr: `conflicting values for sysctl key "net.ipv4.ip_forward": "1" and "0"`,
+		},
+		{
+			name: "bad-key-whitespace",
+			sysctl: blueprint.SysctlCustomization{
+				"net.ipv4. ip_forward": "1",
+			},
+			expectedErr: `invalid sysctl key "net.ipv4. ip_forward"`,
+		},
+		{
+			name: "bad-key-leading-dot",
+			sysctl: blueprint.SysctlCustomization{
+				".net.ipv4.ip_forward": "1",
+			},
+			expectedErr: `invalid sysctl key ".net.ipv4.ip_forward"`,
+		},
+		{
+			name: "bad-key-equal-sign",
+			sysctl: blueprint.SysctlCustomization{
+				"vm.swappiness=10": "1",
+			},
+			expectedErr: `invalid sysctl key "vm.swappiness=10"`,
+		},
+		{
+			name: "empty-value",
+			sysctl: blueprint.SysctlCustomization{
+				"vm.swappiness": "",
+			},
+			expectedErr: `sysctl key "vm.swappiness" requires a value, only keys starting with '-' can have an empty value`,
+		},
+		{
+			name: "newline-in-value",
+			sysctl: blueprint.SysctlCustomization{
+				"vm.swappiness": "10\nvm.overcommit_memory = 1",
+			},
+			expectedErr: `invalid value for sysctl key "vm.swappiness": must not contain newlines`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &blueprint.Customizations{Sysctl: tc.sysctl}
+			sysctl, err := c.GetSysctl()
+			if tc.expectedErr != "" {
+				assert.EqualError(t, err, tc.expectedErr)
+				assert.Nil(t, sysctl)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.sysctl, sysctl)
+			}
+		})
+	}
+}
+
+func TestGetSysctlNil(t *testing.T) {
+	var c *blueprint.Customizations
+	sysctl, err := c.GetSysctl()
+	assert.NoError(t, err)
+	assert.Nil(t, sysctl)
+}
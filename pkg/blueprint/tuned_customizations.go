@@ -0,0 +1,39 @@
+package blueprint
+
+import (
+	"fmt"
+	"regexp"
+)
+
+type TunedCustomization struct {
+	// TuneD profiles to activate, multiple profiles are merged by TuneD in
+	// the given order
+	Profiles []string `json:"profiles" toml:"profiles"`
+}
+
+// tunedProfileRegex matches valid TuneD profile names, which are directory
+// names in /usr/lib/tuned or /etc/tuned.
+var tunedProfileRegex = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+func (t *TunedCustomization) Validate() error {
+	if t == nil {
+		return nil
+	}
+
+	if len(t.Profiles) == 0 {
+		return fmt.Errorf("at least one tuned profile is required")
+	}
+
+	seen := make(map[string]bool, len(t.Profiles))
+	for _, profile := range t.Profiles {
+		if !tunedProfileRegex.MatchString(profile) {
+			return fmt.Errorf("invalid tuned profile name %q", profile)
+		}
+		if seen[profile] {
+			return fmt.Errorf("duplicate tuned profile %q", profile)
+		}
+		seen[profile] = true
+	}
+
+	return nil
+}
@@ -0,0 +1,71 @@
+package blueprint_test
+
+import (
+	"testing"
+
+	"github.com/BurntSushi/toml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/pkg/blueprint"
+)
+
+func TestTunedCustomizationUnmarshalTOML(t *testing.T) {
+	input := `
+[customizations.tuned]
+profiles = ["sap-hana", "my-profile"]
+`
+	var bp blueprint.Blueprint
+	err := toml.Unmarshal([]byte(input), &bp)
+	require.NoError(t, err)
+
+	tuned, err := bp.Customizations.GetTuned()
+	require.NoError(t, err)
+	assert.Equal(t, &blueprint.TunedCustomization{Profiles: []string{"sap-hana", "my-profile"}}, tuned)
+}
+
+func TestTunedCustomizationValidate(t *testing.T) {
+	testCases := []struct {
+		name        string
+		profiles    []string
+		expectedErr string
+	}{
+		{
+			name:     "happy",
+			profiles: []string{"throughput-performance", "realtime-virtual-host", "my_profile.v2"},
+		},
+		{
+			name:        "empty",
+			expectedErr: "at least one tuned profile is required",
+		},
+		{
+			name:        "bad-name",
+			profiles:    []string{"../etc"},
+			expectedErr: `invalid tuned profile name "../etc"`,
+		},
+		{
+			name:        "whitespace",
+			profiles:    []string{"sap-hana virtual-guest"},
+			expectedErr: `invalid tuned profile name "sap-hana virtual-guest"`,
+		},
+		{
+			name:        "duplicate",
+			profiles:    []string{"sap-hana", "sap-hana"},
+			expectedErr: `duplicate tuned profile "sap-hana"`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &blueprint.Customizations{Tuned: &blueprint.TunedCustomization{Profiles: tc.profiles}}
+			tuned, err := c.GetTuned()
+			if tc.expectedErr != "" {
+				assert.EqualError(t, err, tc.expectedErr)
+				assert.Nil(t, tuned)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, c.Tuned, tuned)
+			}
+		})
+	}
+}
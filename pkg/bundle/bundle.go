@@ -0,0 +1,268 @@
+// Package bundle exports the sources of an osbuild manifest into a single
+// directory, so that the manifest can be built on a host without network
+// access.
+//
+// A bundle directory contains:
+//
+//	manifest.json  the manifest with its sources pointing into the bundle
+//	files/         the org.osbuild.curl items, named by their checksum
+//	repos/<id>/    the org.osbuild.librepo items of each mirror
+//	containers/    a containers-storage with the org.osbuild.skopeo items
+//	ostree/        an archive ostree repo with the org.osbuild.ostree items
+package bundle
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/containers/storage/pkg/archive"
+
+	"github.com/osbuild/images/pkg/osbuild"
+)
+
+const (
+	ManifestFilename = "manifest.json"
+
+	filesDir      = "files"
+	reposDir      = "repos"
+	containersDir = "containers"
+	ostreeDir     = "ostree"
+)
+
+// Options configure how a bundle is exported.
+type Options struct {
+	// Location is the absolute path of the bundle directory on the host
+	// that builds the manifest. It defaults to the absolute path of the
+	// directory the bundle is exported to.
+	Location string
+
+	// HTTPClient downloads the curl and librepo items, defaults to
+	// http.DefaultClient. Items that need secrets (like
+	// org.osbuild.rhsm) can only be downloaded with a client that is
+	// configured with the required client certificates.
+	HTTPClient *http.Client
+}
+
+// exporter holds the state of a single export
+type exporter struct {
+	dir      string
+	location string
+	client   *http.Client
+}
+
+// fileURL returns the url of the path in the bundle on the build host
+func (e *exporter) fileURL(elem ...string) string {
+	return "file://" + filepath.Join(append([]string{e.location}, elem...)...)
+}
+
+// Export downloads all the sources of the manifest into dir, which is
+// created if needed, and writes the manifest with its sources rewritten to
+// point into the bundle to dir/manifest.json. The rewritten manifest is
+// returned as well.
+//
+// Containers are unpacked into a containers-storage, which runs a
+// subprocess: programs that export containers must call reexec.Init() of
+// github.com/containers/storage/pkg/reexec first thing in main().
+func Export(ctx context.Context, manifest []byte, dir string, opts *Options) ([]byte, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	e := &exporter{
+		dir:      dir,
+		location: opts.Location,
+		client:   opts.HTTPClient,
+	}
+	if e.location == "" {
+		var err error
+		e.location, err = filepath.Abs(dir)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if !filepath.IsAbs(e.location) {
+		return nil, fmt.Errorf("bundle location %q is not an absolute path", e.location)
+	}
+	if e.client == nil {
+		e.client = http.DefaultClient
+	}
+
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(manifest, &doc); err != nil {
+		return nil, fmt.Errorf("cannot parse manifest: %w", err)
+	}
+	var sources map[string]json.RawMessage
+	if rawSources, ok := doc["sources"]; ok {
+		if err := json.Unmarshal(rawSources, &sources); err != nil {
+			return nil, fmt.Errorf("cannot parse manifest sources: %w", err)
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	newSources := make(map[string]interface{}, len(sources))
+	for _, name := range sortedKeys(sources) {
+		raw := sources[name]
+		var err error
+		switch name {
+		case osbuild.SourceNameCurl:
+			var curl osbuild.CurlSource
+			if err := json.Unmarshal(raw, &curl); err != nil {
+				return nil, fmt.Errorf("cannot parse %s source: %w", name, err)
+			}
+			newSources[name], err = e.exportCurl(ctx, &curl)
+		case osbuild.SourceNameLibrepo:
+			var librepo osbuild.LibrepoSource
+			if err := json.Unmarshal(raw, &librepo); err != nil {
+				return nil, fmt.Errorf("cannot parse %s source: %w", name, err)
+			}
+			newSources[name], err = e.exportLibrepo(ctx, &librepo)
+		case osbuild.SourceNameOstree:
+			var ostree osbuild.OSTreeSource
+			if err := json.Unmarshal(raw, &ostree); err != nil {
+				return nil, fmt.Errorf("cannot parse %s source: %w", name, err)
+			}
+			newSources[name], err = e.exportOSTree(ctx, &ostree)
+		case osbuild.SourceNameSkopeo:
+			var skopeo osbuild.SkopeoSource
+			if err := json.Unmarshal(raw, &skopeo); err != nil {
+				return nil, fmt.Errorf("cannot parse %s source: %w", name, err)
+			}
+			newSources[name], err = e.exportContainers(ctx, &skopeo)
+		case osbuild.SourceNameSkopeoIndex:
+			// the source can only fetch the lists from their registries
+			return nil, fmt.Errorf("cannot export source %s: container manifest lists can only be fetched from a registry", name)
+		case osbuild.SourceNameInline:
+			// the data is part of the manifest already
+			newSources[name] = raw
+		default:
+			return nil, fmt.Errorf("cannot export source %s", name)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if sources != nil {
+		rawSources, err := json.Marshal(newSources)
+		if err != nil {
+			return nil, err
+		}
+		doc["sources"] = rawSources
+	}
+	newManifest, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(dir, ManifestFilename), newManifest, 0644); err != nil {
+		return nil, err
+	}
+	return newManifest, nil
+}
+
+// exportCurl downloads the curl items to files/<checksum>
+func (e *exporter) exportCurl(ctx context.Context, curl *osbuild.CurlSource) (*osbuild.CurlSource, error) {
+	newCurl := osbuild.NewCurlSource()
+	for _, checksum := range sortedKeys(curl.Items) {
+		var url string
+		insecure := false
+		switch item := curl.Items[checksum].(type) {
+		case osbuild.URL:
+			url = string(item)
+		case osbuild.CurlSourceOptions:
+			url = item.URL
+			insecure = item.Insecure
+		case *osbuild.CurlSourceOptions:
+			url = item.URL
+			insecure = item.Insecure
+		default:
+			return nil, fmt.Errorf("unexpected curl source item %T", item)
+		}
+		if err := e.download(ctx, url, insecure, checksum, filepath.Join(e.dir, filesDir, checksum)); err != nil {
+			return nil, err
+		}
+		newCurl.Items[checksum] = &osbuild.CurlSourceOptions{
+			URL: e.fileURL(filesDir, checksum),
+		}
+	}
+	return newCurl, nil
+}
+
+// exportLibrepo downloads the librepo items to repos/<mirror-id>/<path>
+// and replaces all mirrors with local baseurls
+func (e *exporter) exportLibrepo(ctx context.Context, librepo *osbuild.LibrepoSource) (*osbuild.LibrepoSource, error) {
+	newLibrepo := osbuild.NewLibrepoSource()
+	// the baseurl of the mirrors, resolved on demand
+	baseURLs := make(map[string]string)
+	for _, checksum := range sortedKeys(librepo.Items) {
+		item := librepo.Items[checksum]
+		var mirror *osbuild.LibrepoSourceMirror
+		if librepo.Options != nil {
+			mirror = librepo.Options.Mirrors[item.MirrorID]
+		}
+		if mirror == nil {
+			return nil, fmt.Errorf("librepo item %s has unknown mirror %q", checksum, item.MirrorID)
+		}
+		if !filepath.IsLocal(item.MirrorID) || filepath.Base(item.MirrorID) != item.MirrorID {
+			return nil, fmt.Errorf("cannot export librepo mirror %q: invalid mirror id", item.MirrorID)
+		}
+		if !filepath.IsLocal(item.Path) {
+			return nil, fmt.Errorf("cannot export librepo item %s: invalid path %q", checksum, item.Path)
+		}
+
+		baseURL, ok := baseURLs[item.MirrorID]
+		if !ok {
+			var err error
+			baseURL, err = e.resolveMirror(ctx, mirror)
+			if err != nil {
+				return nil, fmt.Errorf("cannot resolve librepo mirror %q: %w", item.MirrorID, err)
+			}
+			baseURLs[item.MirrorID] = baseURL
+		}
+
+		path := filepath.Join(e.dir, reposDir, item.MirrorID, item.Path)
+		if err := e.download(ctx, joinURL(baseURL, item.Path), mirror.Insecure, checksum, path); err != nil {
+			return nil, err
+		}
+		newLibrepo.Items[checksum] = &osbuild.LibrepoSourceItem{
+			Path:     item.Path,
+			MirrorID: item.MirrorID,
+		}
+		newLibrepo.Options.Mirrors[item.MirrorID] = &osbuild.LibrepoSourceMirror{
+			// librepo needs the trailing slash of the baseurl
+			URL:  e.fileURL(reposDir, item.MirrorID) + "/",
+			Type: "baseurl",
+		}
+	}
+	return newLibrepo, nil
+}
+
+// WriteTar writes the bundle in dir as a tarball to w. The tarball has to
+// be extracted (with the ownership, xattrs and special files of the
+// containers-storage) to the location the bundle was exported for.
+func WriteTar(w io.Writer, dir string) error {
+	rc, err := archive.TarWithOptions(dir, &archive.TarOptions{Compression: archive.Uncompressed})
+	if err != nil {
+		return fmt.Errorf("cannot write bundle tarball: %w", err)
+	}
+	defer rc.Close()
+	if _, err := io.Copy(w, rc); err != nil {
+		return fmt.Errorf("cannot write bundle tarball: %w", err)
+	}
+	return nil
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,282 @@
+package bundle_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/containers/image/v5/manifest"
+	istorage "github.com/containers/image/v5/storage"
+	"github.com/containers/image/v5/types"
+	"github.com/containers/storage"
+	"github.com/containers/storage/pkg/reexec"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/internal/testregistry"
+	"github.com/osbuild/images/pkg/arch"
+	"github.com/osbuild/images/pkg/bundle"
+	"github.com/osbuild/images/pkg/container"
+	"github.com/osbuild/images/pkg/osbuild"
+)
+
+func TestMain(m *testing.M) {
+	// the containers-storage unpacks the layers in a subprocess
+	if reexec.Init() {
+		return
+	}
+	os.Exit(m.Run())
+}
+
+func sha256sum(data string) string {
+	return fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(data)))
+}
+
+// fakeOSTree puts an "ostree" script first in PATH that records its
+// arguments in the "calls" file of the returned directory
+func fakeOSTree(t *testing.T) string {
+	dir := t.TempDir()
+	script := fmt.Sprintf(`#!/bin/sh
+echo "$@" >> %s/calls
+if [ "$2" = "init" ]; then
+	mkdir -p "${1#--repo=}"
+	touch "${1#--repo=}/config"
+fi
+`, dir)
+	// #nosec G306
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "ostree"), []byte(script), 0755))
+	t.Setenv("PATH", dir+":"+os.Getenv("PATH"))
+	return dir
+}
+
+func newFileServer(t *testing.T, files map[string]string) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, strings.ReplaceAll(data, "@SERVER@", "http://"+r.Host))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestExport(t *testing.T) {
+	ostreeDir := fakeOSTree(t)
+
+	files := map[string]string{
+		"/files/hello.txt":        "hello",
+		"/repo1/Packages/a-1.rpm": "package a",
+		"/repo2/Packages/b-1.rpm": "package b",
+		"/repo3/Packages/c-1.rpm": "package c",
+		"/mirrorlist":             "# mirrors\n\n@SERVER@/repo2/\n",
+		"/metalink": `<?xml version="1.0" encoding="utf-8"?>
+<metalink version="3.0" xmlns="http://www.metalinker.org/">
+  <files>
+    <file name="repomd.xml">
+      <resources maxconnections="1">
+        <url protocol="rsync" type="rsync">rsync://example.com/repo3/repodata/repomd.xml</url>
+        <url protocol="http" type="http">@SERVER@/repo3/repodata/repomd.xml</url>
+      </resources>
+    </file>
+  </files>
+</metalink>`,
+	}
+	srv := newFileServer(t, files)
+
+	registry := testregistry.New()
+	defer registry.Close()
+	repo := registry.AddRepo("library/osbuild")
+	repo.AddImage(
+		[]testregistry.Blob{testregistry.NewDataBlobFromBase64(testregistry.RootLayer)},
+		[]string{"amd64", "ppc64le"},
+		"cool container",
+		time.Time{})
+	ref := registry.GetRef("library/osbuild")
+	spec, err := registry.Resolve(ref, arch.ARCH_X86_64)
+	require.NoError(t, err)
+	// manifest lists can't be exported, see TestExportBad
+	spec.ListDigest = ""
+
+	sources, err := osbuild.GenSources(osbuild.SourceInputs{
+		Containers: []container.Spec{spec},
+		InlineData: []string{"inline data"},
+	}, osbuild.RpmDownloaderCurl)
+	require.NoError(t, err)
+	curl := osbuild.NewCurlSource()
+	curl.Items[sha256sum("hello")] = &osbuild.CurlSourceOptions{URL: srv.URL + "/files/hello.txt"}
+	sources[osbuild.SourceNameCurl] = curl
+	librepo := osbuild.NewLibrepoSource()
+	librepo.Items[sha256sum("package a")] = &osbuild.LibrepoSourceItem{Path: "Packages/a-1.rpm", MirrorID: "baseurl"}
+	librepo.Items[sha256sum("package b")] = &osbuild.LibrepoSourceItem{Path: "Packages/b-1.rpm", MirrorID: "mirrorlist"}
+	librepo.Items[sha256sum("package c")] = &osbuild.LibrepoSourceItem{Path: "Packages/c-1.rpm", MirrorID: "metalink"}
+	librepo.Options.Mirrors["baseurl"] = &osbuild.LibrepoSourceMirror{URL: srv.URL + "/repo1", Type: "baseurl"}
+	librepo.Options.Mirrors["mirrorlist"] = &osbuild.LibrepoSourceMirror{URL: srv.URL + "/mirrorlist", Type: "mirrorlist"}
+	librepo.Options.Mirrors["metalink"] = &osbuild.LibrepoSourceMirror{URL: srv.URL + "/metalink", Type: "metalink"}
+	sources[osbuild.SourceNameLibrepo] = librepo
+	ostree := osbuild.NewOSTreeSource()
+	ostree.Items["commit1"] = osbuild.OSTreeSourceItem{
+		Remote: osbuild.OSTreeSourceRemote{
+			URL:     "https://ostree.example.com/repo",
+			GPGKeys: []string{"key"},
+		},
+	}
+	sources[osbuild.SourceNameOstree] = ostree
+
+	mf, err := json.Marshal(map[string]interface{}{
+		"version":   "2",
+		"pipelines": []interface{}{map[string]string{"name": "os"}},
+		"sources":   sources,
+	})
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	newManifest, err := bundle.Export(context.Background(), mf, dir, &bundle.Options{Location: "/bundle"})
+	require.NoError(t, err)
+
+	written, err := os.ReadFile(filepath.Join(dir, bundle.ManifestFilename))
+	require.NoError(t, err)
+	assert.Equal(t, newManifest, written)
+
+	var result struct {
+		Version   string          `json:"version"`
+		Pipelines json.RawMessage `json:"pipelines"`
+		Sources   map[string]json.RawMessage
+	}
+	require.NoError(t, json.Unmarshal(newManifest, &result))
+	assert.Equal(t, "2", result.Version)
+	assert.JSONEq(t, `[{"name": "os"}]`, string(result.Pipelines))
+
+	assert.JSONEq(t, fmt.Sprintf(`{"items": {"%s": {"url": "file:///bundle/files/%s"}}}`, sha256sum("hello"), sha256sum("hello")), string(result.Sources[osbuild.SourceNameCurl]))
+	assert.JSONEq(t, fmt.Sprintf(`{
+	  "items": {
+	    "%s": {"path": "Packages/a-1.rpm", "mirror": "baseurl"},
+	    "%s": {"path": "Packages/b-1.rpm", "mirror": "mirrorlist"},
+	    "%s": {"path": "Packages/c-1.rpm", "mirror": "metalink"}
+	  },
+	  "options": {
+	    "mirrors": {
+	      "baseurl": {"url": "file:///bundle/repos/baseurl/", "type": "baseurl"},
+	      "mirrorlist": {"url": "file:///bundle/repos/mirrorlist/", "type": "baseurl"},
+	      "metalink": {"url": "file:///bundle/repos/metalink/", "type": "baseurl"}
+	    }
+	  }
+	}`, sha256sum("package a"), sha256sum("package b"), sha256sum("package c")), string(result.Sources[osbuild.SourceNameLibrepo]))
+	assert.JSONEq(t, `{"items": {"commit1": {"remote": {"url": "file:///bundle/ostree", "gpgkeys": ["key"]}}}}`, string(result.Sources[osbuild.SourceNameOstree]))
+	assert.JSONEq(t, fmt.Sprintf(`{
+	  "items": {
+	    "%s": {
+	      "image": {"name": "%s", "digest": "%s", "containers-transport": "containers-storage", "storage-location": "/bundle/containers"}
+	    }
+	  }
+	}`, spec.ImageID, spec.Source, spec.Digest), string(result.Sources[osbuild.SourceNameSkopeo]))
+	assert.NotContains(t, result.Sources, osbuild.SourceNameSkopeoIndex)
+	inline, err := json.Marshal(sources[osbuild.SourceNameInline])
+	require.NoError(t, err)
+	assert.JSONEq(t, string(inline), string(result.Sources[osbuild.SourceNameInline]))
+
+	for path, content := range map[string]string{
+		"files/" + sha256sum("hello"):       "hello",
+		"repos/baseurl/Packages/a-1.rpm":    "package a",
+		"repos/mirrorlist/Packages/b-1.rpm": "package b",
+		"repos/metalink/Packages/c-1.rpm":   "package c",
+	} {
+		data, err := os.ReadFile(filepath.Join(dir, path))
+		require.NoError(t, err)
+		assert.Equal(t, content, string(data))
+	}
+
+	// the manifest is kept verbatim, osbuild looks up the image by its
+	// digest
+	store, err := storage.GetStore(storage.StoreOptions{
+		GraphRoot:       filepath.Join(dir, "containers"),
+		RunRoot:         t.TempDir(),
+		GraphDriverName: "overlay",
+	})
+	require.NoError(t, err)
+	defer func() { _, _ = store.Shutdown(true) }()
+	imgRef, err := istorage.Transport.ParseStoreReference(store, spec.Source+"@"+spec.Digest)
+	require.NoError(t, err)
+	src, err := imgRef.NewImageSource(context.Background(), &types.SystemContext{})
+	require.NoError(t, err)
+	raw, _, err := src.GetManifest(context.Background(), nil)
+	src.Close()
+	require.NoError(t, err)
+	matches, err := manifest.MatchesDigest(raw, digest.Digest(spec.Digest))
+	require.NoError(t, err)
+	assert.True(t, matches)
+
+	calls, err := os.ReadFile(filepath.Join(ostreeDir, "calls"))
+	require.NoError(t, err)
+	repoArg := "--repo=" + filepath.Join(dir, "ostree")
+	assert.Equal(t, fmt.Sprintf(`%[1]s init --mode=archive
+%[1]s remote add --force --no-gpg-verify bundle-0 https://ostree.example.com/repo
+%[1]s pull bundle-0 commit1
+`, repoArg), string(calls))
+}
+
+func TestExportChecksumMismatch(t *testing.T) {
+	srv := newFileServer(t, map[string]string{"/hello.txt": "hello"})
+	curl := osbuild.NewCurlSource()
+	curl.Items[sha256sum("bye")] = osbuild.URL(srv.URL + "/hello.txt")
+	mf, err := json.Marshal(map[string]interface{}{
+		"sources": osbuild.Sources{osbuild.SourceNameCurl: curl},
+	})
+	require.NoError(t, err)
+
+	_, err = bundle.Export(context.Background(), mf, t.TempDir(), nil)
+	assert.EqualError(t, err, fmt.Sprintf("checksum mismatch for %s/hello.txt: expected %s, got %s", srv.URL, strings.TrimPrefix(sha256sum("bye"), "sha256:"), strings.TrimPrefix(sha256sum("hello"), "sha256:")))
+}
+
+func TestExportBad(t *testing.T) {
+	for _, tc := range []struct {
+		manifest    string
+		location    string
+		expectedErr string
+	}{
+		{`{"sources": {"org.osbuild.unknown": {}}}`, "", "cannot export source org.osbuild.unknown"},
+		{`{"sources": {"org.osbuild.librepo": {"items": {"sha256:1234": {"path": "a.rpm", "mirror": "m"}}, "options": {"mirrors": {}}}}}`, "", `librepo item sha256:1234 has unknown mirror "m"`},
+		{`{"sources": {"org.osbuild.librepo": {"items": {"sha256:1234": {"path": "../a.rpm", "mirror": "m"}}, "options": {"mirrors": {"m": {"url": "http://example.com", "type": "baseurl"}}}}}}`, "", `cannot export librepo item sha256:1234: invalid path "../a.rpm"`},
+		{`{"sources": {"org.osbuild.ostree": {"items": {"commit1": {"remote": {"url": "https://example.com", "secrets": {"name": "org.osbuild.rhsm"}}}}}}}`, "", `cannot export ostree commit commit1: secrets "org.osbuild.rhsm" are not supported`},
+		{`{}`, "bundle", `bundle location "bundle" is not an absolute path`},
+		{`{"sources": {"org.osbuild.skopeo-index": {"items": {}}}}`, "", "cannot export source org.osbuild.skopeo-index: container manifest lists can only be fetched from a registry"},
+	} {
+		t.Run(tc.expectedErr, func(t *testing.T) {
+			fakeOSTree(t)
+			_, err := bundle.Export(context.Background(), []byte(tc.manifest), t.TempDir(), &bundle.Options{Location: tc.location})
+			assert.EqualError(t, err, tc.expectedErr)
+		})
+	}
+}
+
+func TestWriteTar(t *testing.T) {
+	dir := t.TempDir()
+	_, err := bundle.Export(context.Background(), []byte(`{"version": "2"}`), dir, nil)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, bundle.WriteTar(&buf, dir))
+
+	tr := tar.NewReader(&buf)
+	hdr, err := tr.Next()
+	require.NoError(t, err)
+	assert.Equal(t, bundle.ManifestFilename, hdr.Name)
+	data, err := io.ReadAll(tr)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"version": "2"}`, string(data))
+	_, err = tr.Next()
+	assert.Equal(t, io.EOF, err)
+}
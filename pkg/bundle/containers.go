@@ -0,0 +1,123 @@
+package bundle
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/containers/image/v5/copy"
+	"github.com/containers/image/v5/docker"
+	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/signature"
+	istorage "github.com/containers/image/v5/storage"
+	"github.com/containers/image/v5/types"
+	"github.com/containers/storage"
+	"github.com/opencontainers/go-digest"
+
+	"github.com/osbuild/images/pkg/container"
+	"github.com/osbuild/images/pkg/osbuild"
+)
+
+// containersStorageDriver is the graph driver of the containers-storage of
+// the bundle, osbuild reads containers-storage items with the overlay driver
+const containersStorageDriver = "overlay"
+
+func canonicalName(name string, d digest.Digest) (reference.Canonical, error) {
+	named, err := reference.ParseNormalizedNamed(name)
+	if err != nil {
+		return nil, err
+	}
+	return reference.WithDigest(reference.TrimNamed(named), d)
+}
+
+// copyImage copies the image name@d into the containers-storage store. The
+// manifest is kept verbatim, so the image can be looked up by its digest.
+func copyImage(ctx context.Context, store storage.Store, name, d string, tlsVerify *bool) error {
+	expected, err := digest.Parse(d)
+	if err != nil {
+		return err
+	}
+	canonical, err := canonicalName(name, expected)
+	if err != nil {
+		return err
+	}
+	srcRef, err := docker.NewReference(canonical)
+	if err != nil {
+		return err
+	}
+	destRef, err := istorage.Transport.NewStoreReference(store, canonical, "")
+	if err != nil {
+		return err
+	}
+
+	sys := &types.SystemContext{
+		AuthFilePath: container.GetDefaultAuthFile(),
+		OSChoice:     "linux",
+	}
+	if tlsVerify != nil {
+		sys.DockerInsecureSkipTLSVerify = types.NewOptionalBool(!*tlsVerify)
+	}
+	// the image is pinned by its digest
+	policy := &signature.Policy{Default: []signature.PolicyRequirement{signature.NewPRInsecureAcceptAnything()}}
+	policyContext, err := signature.NewPolicyContext(policy)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = policyContext.Destroy() }()
+
+	raw, err := copy.Image(ctx, policyContext, destRef, srcRef, &copy.Options{
+		SourceCtx:       sys,
+		PreserveDigests: true,
+	})
+	if err != nil {
+		return err
+	}
+	if matches, err := manifest.MatchesDigest(raw, expected); err != nil {
+		return err
+	} else if !matches {
+		return fmt.Errorf("manifest does not match digest %s", d)
+	}
+	return nil
+}
+
+// exportContainers copies the skopeo items into the containers-storage
+// containers/. The items are rewritten to be fetched from there with the
+// containers-storage transport.
+func (e *exporter) exportContainers(ctx context.Context, skopeo *osbuild.SkopeoSource) (*osbuild.SkopeoSource, error) {
+	// the run root is only needed while the images are copied
+	runRoot, err := os.MkdirTemp("", "bundle-containers-run-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(runRoot)
+
+	store, err := storage.GetStore(storage.StoreOptions{
+		GraphRoot:       filepath.Join(e.dir, containersDir),
+		RunRoot:         runRoot,
+		GraphDriverName: containersStorageDriver,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot create the containers-storage of the bundle: %w", err)
+	}
+	defer func() { _, _ = store.Shutdown(true) }()
+
+	location := filepath.Join(e.location, containersDir)
+	newSkopeo := osbuild.NewSkopeoSource()
+	for _, imageID := range sortedKeys(skopeo.Items) {
+		img := skopeo.Items[imageID].Image
+		if err := copyImage(ctx, store, img.Name, img.Digest, img.TLSVerify); err != nil {
+			return nil, fmt.Errorf("cannot export container %s@%s: %w", img.Name, img.Digest, err)
+		}
+		newSkopeo.Items[imageID] = osbuild.SkopeoSourceItem{
+			Image: osbuild.SkopeopSourceImage{
+				Name:                img.Name,
+				Digest:              img.Digest,
+				ContainersTransport: osbuild.ContainersStorageTransport,
+				StorageLocation:     location,
+			},
+		}
+	}
+	return newSkopeo, nil
+}
@@ -0,0 +1,215 @@
+package bundle
+
+import (
+	"bufio"
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/osbuild/images/pkg/osbuild"
+)
+
+// newHash returns the hash of a "<algorithm>:<hex>" checksum
+func newHash(checksum string) (hash.Hash, string, error) {
+	algo, sum, ok := strings.Cut(checksum, ":")
+	if !ok {
+		return nil, "", fmt.Errorf("invalid checksum %q", checksum)
+	}
+	switch algo {
+	case "md5":
+		return md5.New(), sum, nil
+	case "sha1":
+		return sha1.New(), sum, nil
+	case "sha256":
+		return sha256.New(), sum, nil
+	case "sha384":
+		return sha512.New384(), sum, nil
+	case "sha512":
+		return sha512.New(), sum, nil
+	}
+	return nil, "", fmt.Errorf("unsupported checksum algorithm %q", algo)
+}
+
+// open returns the body of the url, "file:" urls are read from the local
+// filesystem
+func (e *exporter) open(ctx context.Context, url string, insecure bool) (io.ReadCloser, error) {
+	if path, ok := strings.CutPrefix(url, "file:"); ok {
+		return os.Open(strings.TrimPrefix(path, "//"))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	client := e.client
+	if insecure {
+		client = insecureClient(client)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected status %q", resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// insecureClient returns a copy of the client that doesn't verify the TLS
+// certificates of the server
+func insecureClient(client *http.Client) *http.Client {
+	transport, ok := client.Transport.(*http.Transport)
+	if client.Transport == nil {
+		transport, ok = http.DefaultTransport.(*http.Transport)
+	}
+	if !ok {
+		// a custom transport has to take care of it
+		return client
+	}
+	transport = transport.Clone()
+	if transport.TLSClientConfig == nil {
+		transport.TLSClientConfig = &tls.Config{}
+	}
+	transport.TLSClientConfig.InsecureSkipVerify = true
+	insecure := *client
+	insecure.Transport = transport
+	return &insecure
+}
+
+// download downloads the url to path and verifies its checksum. Nothing
+// is downloaded if path already has the expected checksum.
+func (e *exporter) download(ctx context.Context, url string, insecure bool, checksum, path string) error {
+	if verifyFile(path, checksum) == nil {
+		return nil
+	}
+
+	h, sum, err := newHash(checksum)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	body, err := e.open(ctx, url, insecure)
+	if err != nil {
+		return fmt.Errorf("cannot download %s: %w", url, err)
+	}
+	defer body.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".download-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = io.Copy(io.MultiWriter(tmp, h), body)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("cannot download %s: %w", url, err)
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); got != sum {
+		return fmt.Errorf("checksum mismatch for %s: expected %s, got %s", url, sum, got)
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// verifyFile returns an error if the file at path doesn't exist or doesn't
+// have the checksum
+func verifyFile(path, checksum string) error {
+	h, sum, err := newHash(checksum)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+	if hex.EncodeToString(h.Sum(nil)) != sum {
+		return fmt.Errorf("checksum mismatch for %s", path)
+	}
+	return nil
+}
+
+func joinURL(baseURL, path string) string {
+	return strings.TrimSuffix(baseURL, "/") + "/" + strings.TrimPrefix(path, "/")
+}
+
+// metalink is the part of a metalink that points to the repomd.xml
+type metalink struct {
+	Files []struct {
+		Name string `xml:"name,attr"`
+		URLs []struct {
+			Protocol string `xml:"protocol,attr"`
+			URL      string `xml:",chardata"`
+		} `xml:"resources>url"`
+	} `xml:"files>file"`
+}
+
+// resolveMirror returns the baseurl of the mirror. For metalinks and
+// mirrorlists the first http(s) mirror is used.
+func (e *exporter) resolveMirror(ctx context.Context, mirror *osbuild.LibrepoSourceMirror) (string, error) {
+	if mirror.Type == "baseurl" {
+		return mirror.URL, nil
+	}
+	if mirror.Type != "metalink" && mirror.Type != "mirrorlist" {
+		return "", fmt.Errorf("unsupported mirror type %q", mirror.Type)
+	}
+
+	body, err := e.open(ctx, mirror.URL, mirror.Insecure)
+	if err != nil {
+		return "", fmt.Errorf("cannot download %s: %w", mirror.URL, err)
+	}
+	defer body.Close()
+
+	if mirror.Type == "metalink" {
+		var ml metalink
+		if err := xml.NewDecoder(body).Decode(&ml); err != nil {
+			return "", fmt.Errorf("cannot parse metalink %s: %w", mirror.URL, err)
+		}
+		for _, file := range ml.Files {
+			if file.Name != "repomd.xml" {
+				continue
+			}
+			for _, url := range file.URLs {
+				if url.Protocol == "http" || url.Protocol == "https" {
+					u := strings.TrimSpace(url.URL)
+					return strings.TrimSuffix(u, "repodata/repomd.xml"), nil
+				}
+			}
+		}
+		return "", fmt.Errorf("no http(s) mirror in metalink %s", mirror.URL)
+	}
+
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "http://") || strings.HasPrefix(line, "https://") {
+			return line, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("cannot read mirrorlist %s: %w", mirror.URL, err)
+	}
+	return "", fmt.Errorf("no http(s) mirror in mirrorlist %s", mirror.URL)
+}
@@ -0,0 +1,70 @@
+package bundle
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/osbuild/images/pkg/osbuild"
+)
+
+func runOSTree(ctx context.Context, args ...string) error {
+	output, err := exec.CommandContext(ctx, "ostree", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("running ostree %s failed: %w\n%s", strings.Join(args, " "), err, output)
+	}
+	return nil
+}
+
+// exportOSTree pulls the commits into the archive repo ostree/ with the
+// ostree command
+func (e *exporter) exportOSTree(ctx context.Context, source *osbuild.OSTreeSource) (*osbuild.OSTreeSource, error) {
+	repo := filepath.Join(e.dir, ostreeDir)
+	repoArg := "--repo=" + repo
+	if _, err := os.Stat(filepath.Join(repo, "config")); os.IsNotExist(err) {
+		if err := runOSTree(ctx, repoArg, "init", "--mode=archive"); err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	newSource := osbuild.NewOSTreeSource()
+	// the names of the remotes added for each remote of the source
+	remotes := make(map[[2]string]string)
+	for _, checksum := range sortedKeys(source.Items) {
+		remote := source.Items[checksum].Remote
+		if remote.Secrets != nil {
+			return nil, fmt.Errorf("cannot export ostree commit %s: secrets %q are not supported", checksum, remote.Secrets.Name)
+		}
+
+		key := [2]string{remote.URL, remote.ContentURL}
+		name, ok := remotes[key]
+		if !ok {
+			name = fmt.Sprintf("bundle-%d", len(remotes))
+			args := []string{repoArg, "remote", "add", "--force", "--no-gpg-verify"}
+			if remote.ContentURL != "" {
+				args = append(args, "--set=contenturl="+remote.ContentURL)
+			}
+			args = append(args, name, remote.URL)
+			if err := runOSTree(ctx, args...); err != nil {
+				return nil, err
+			}
+			remotes[key] = name
+		}
+		if err := runOSTree(ctx, repoArg, "pull", name, checksum); err != nil {
+			return nil, err
+		}
+
+		newSource.Items[checksum] = osbuild.OSTreeSourceItem{
+			Remote: osbuild.OSTreeSourceRemote{
+				URL:     e.fileURL(ostreeDir),
+				GPGKeys: remote.GPGKeys,
+			},
+		}
+	}
+	return newSource, nil
+}
@@ -9,3 +9,15 @@ func MockNewAwsClient(f func(string) (awsClient, error)) (restore func()) {
 		newAwsClient = saved
 	}
 }
+
+type S3Client = s3Client
+
+func MockNewS3Client(f func(*S3UploaderOptions) (s3Client, error)) (restore func()) {
+	saved := newS3Client
+	newS3Client = f
+	return func() {
+		newS3Client = saved
+	}
+}
+
+const S3MinPartSize = s3MinPartSize
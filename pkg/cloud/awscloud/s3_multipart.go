@@ -0,0 +1,123 @@
+package awscloud
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+
+	"github.com/osbuild/images/pkg/datasizes"
+	"github.com/osbuild/images/pkg/olog"
+	"github.com/osbuild/images/pkg/upload/chunked"
+)
+
+// s3MinPartSize is the minimum size of all but the last part of a
+// multipart upload.
+// See https://docs.aws.amazon.com/AmazonS3/latest/userguide/qfacts.html
+const s3MinPartSize = 5 * datasizes.MiB
+
+// s3MultipartBackend uploads the parts of a chunked upload using the S3
+// multipart upload API.
+type s3MultipartBackend struct {
+	s3     *s3.S3
+	bucket string
+	key    string
+}
+
+var _ chunked.Backend = &s3MultipartBackend{}
+
+// newS3MultipartBackend returns the backend for a chunked upload with the
+// given part size. A part size of 0 selects chunked.DefaultPartSize.
+func newS3MultipartBackend(client *s3.S3, bucket, key string, partSize int64) (*s3MultipartBackend, error) {
+	if partSize > 0 && partSize < s3MinPartSize {
+		return nil, fmt.Errorf("part size %d is smaller than the minimum part size of S3 multipart uploads (%d)", partSize, s3MinPartSize)
+	}
+	return &s3MultipartBackend{
+		s3:     client,
+		bucket: bucket,
+		key:    key,
+	}, nil
+}
+
+func (b *s3MultipartBackend) Target() string {
+	return fmt.Sprintf("s3://%s/%s", b.bucket, b.key)
+}
+
+func (b *s3MultipartBackend) Create(ctx context.Context) (string, error) {
+	out, err := b.s3.CreateMultipartUploadWithContext(ctx, &s3.CreateMultipartUploadInput{
+		Bucket: aws.String(b.bucket),
+		Key:    aws.String(b.key),
+	})
+	if err != nil {
+		return "", fmt.Errorf("cannot create multipart upload for %s/%s: %w", b.bucket, b.key, err)
+	}
+	return aws.StringValue(out.UploadId), nil
+}
+
+func (b *s3MultipartBackend) UploadPart(ctx context.Context, uploadID string, part chunked.Part, r io.ReadSeeker) (string, error) {
+	out, err := b.s3.UploadPartWithContext(ctx, &s3.UploadPartInput{
+		Bucket:        aws.String(b.bucket),
+		Key:           aws.String(b.key),
+		UploadId:      aws.String(uploadID),
+		PartNumber:    aws.Int64(int64(part.Number)),
+		ContentLength: aws.Int64(part.Size),
+		Body:          r,
+	})
+	if err != nil {
+		return "", err
+	}
+	return aws.StringValue(out.ETag), nil
+}
+
+func (b *s3MultipartBackend) Complete(ctx context.Context, uploadID string, parts []chunked.Part) error {
+	completed := make([]*s3.CompletedPart, 0, len(parts))
+	for _, part := range parts {
+		completed = append(completed, &s3.CompletedPart{
+			ETag:       aws.String(part.ETag),
+			PartNumber: aws.Int64(int64(part.Number)),
+		})
+	}
+	_, err := b.s3.CompleteMultipartUploadWithContext(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:   aws.String(b.bucket),
+		Key:      aws.String(b.key),
+		UploadId: aws.String(uploadID),
+		MultipartUpload: &s3.CompletedMultipartUpload{
+			Parts: completed,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("cannot complete multipart upload for %s/%s: %w", b.bucket, b.key, err)
+	}
+	return nil
+}
+
+func (b *s3MultipartBackend) Abort(ctx context.Context, uploadID string) error {
+	_, err := b.s3.AbortMultipartUploadWithContext(ctx, &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(b.bucket),
+		Key:      aws.String(b.key),
+		UploadId: aws.String(uploadID),
+	})
+	if err != nil {
+		return fmt.Errorf("cannot abort multipart upload for %s/%s: %w", b.bucket, b.key, err)
+	}
+	return nil
+}
+
+// UploadFromReaderResumable uploads the data read from r to the bucket
+// using a chunked multipart upload, see chunked.Upload(). Unlike
+// UploadFromReader() a failed upload can be resumed if opts.StateFile is
+// set.
+func (a *AWS) UploadFromReaderResumable(r io.Reader, bucket, key string, opts *chunked.Options) error {
+	var partSize int64
+	if opts != nil {
+		partSize = opts.PartSize
+	}
+	backend, err := newS3MultipartBackend(a.s3, bucket, key, partSize)
+	if err != nil {
+		return err
+	}
+	olog.Printf("[AWS] 🚀 Uploading image to S3: %s/%s", bucket, key)
+	return chunked.Upload(context.Background(), backend, r, -1, opts)
+}
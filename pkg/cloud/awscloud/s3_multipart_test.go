@@ -0,0 +1,152 @@
+package awscloud_test
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/pkg/cloud/awscloud"
+	"github.com/osbuild/images/pkg/upload/chunked"
+)
+
+// fakeS3Server implements the S3 multipart upload API for a single
+// object, uploads of the parts in failParts are rejected.
+type fakeS3Server struct {
+	mu        sync.Mutex
+	parts     map[int][]byte
+	partPuts  map[int]int
+	failParts map[int]bool
+	object    []byte
+	aborted   bool
+}
+
+func newFakeS3Server(t *testing.T) (*fakeS3Server, *httptest.Server) {
+	fs := &fakeS3Server{
+		parts:    map[int][]byte{},
+		partPuts: map[int]int{},
+	}
+	srv := httptest.NewServer(http.HandlerFunc(fs.handle))
+	t.Cleanup(srv.Close)
+	return fs, srv
+}
+
+func (fs *fakeS3Server) handle(w http.ResponseWriter, r *http.Request) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	query := r.URL.Query()
+	switch {
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		fmt.Fprint(w, `<InitiateMultipartUploadResult><Bucket>bucket</Bucket><Key>key</Key><UploadId>upload-id</UploadId></InitiateMultipartUploadResult>`)
+	case r.Method == http.MethodPut && query.Get("uploadId") == "upload-id":
+		number, err := strconv.Atoi(query.Get("partNumber"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		fs.partPuts[number]++
+		if fs.failParts[number] {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `<Error><Code>AccessDenied</Code><Message>injected failure</Message></Error>`)
+			return
+		}
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		fs.parts[number] = data
+		w.Header().Set("ETag", fmt.Sprintf(`"etag-%d"`, number))
+	case r.Method == http.MethodPost && query.Get("uploadId") == "upload-id":
+		var complete struct {
+			Parts []struct {
+				PartNumber int
+				ETag       string
+			} `xml:"Part"`
+		}
+		if err := xml.NewDecoder(r.Body).Decode(&complete); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var object []byte
+		for _, part := range complete.Parts {
+			if part.ETag != fmt.Sprintf(`"etag-%d"`, part.PartNumber) {
+				http.Error(w, "invalid etag", http.StatusBadRequest)
+				return
+			}
+			object = append(object, fs.parts[part.PartNumber]...)
+		}
+		fs.object = object
+		fmt.Fprint(w, `<CompleteMultipartUploadResult><Bucket>bucket</Bucket><Key>key</Key></CompleteMultipartUploadResult>`)
+	case r.Method == http.MethodDelete && query.Get("uploadId") == "upload-id":
+		fs.aborted = true
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "unexpected request", http.StatusBadRequest)
+	}
+}
+
+func TestUploadFromReaderResumable(t *testing.T) {
+	fs, srv := newFakeS3Server(t)
+	client, err := awscloud.NewForEndpoint(srv.URL, "us-east-1", "id", "secret", "", "", false)
+	require.NoError(t, err)
+
+	data := bytes.Repeat([]byte("0123456789abcdef"), 4*awscloud.S3MinPartSize/16)
+	opts := &chunked.Options{
+		PartSize:    awscloud.S3MinPartSize,
+		Concurrency: 1,
+		Retries:     -1,
+		StateFile:   filepath.Join(t.TempDir(), "upload.json"),
+	}
+
+	fs.failParts = map[int]bool{3: true}
+	err = client.UploadFromReaderResumable(bytes.NewReader(data), "bucket", "key", opts)
+	require.ErrorContains(t, err, "uploading part 3 failed: AccessDenied: injected failure")
+	assert.False(t, fs.aborted)
+	assert.FileExists(t, opts.StateFile)
+
+	fs.failParts = nil
+	err = client.UploadFromReaderResumable(bytes.NewReader(data), "bucket", "key", opts)
+	require.NoError(t, err)
+	assert.Equal(t, data, fs.object)
+	// the parts that were uploaded before the failure are not uploaded
+	// again
+	assert.Equal(t, map[int]int{1: 1, 2: 1, 3: 2, 4: 1}, fs.partPuts)
+	assert.NoFileExists(t, opts.StateFile)
+}
+
+func TestUploadFromReaderResumableAbort(t *testing.T) {
+	fs, srv := newFakeS3Server(t)
+	client, err := awscloud.NewForEndpoint(srv.URL, "us-east-1", "id", "secret", "", "", false)
+	require.NoError(t, err)
+
+	fs.failParts = map[int]bool{1: true}
+	err = client.UploadFromReaderResumable(bytes.NewReader(make([]byte, 1000)), "bucket", "key", &chunked.Options{
+		PartSize: awscloud.S3MinPartSize,
+		Retries:  -1,
+	})
+	require.ErrorContains(t, err, "uploading part 1 failed")
+	assert.True(t, fs.aborted)
+}
+
+func TestUploadFromReaderResumablePartSizeTooSmall(t *testing.T) {
+	fs, srv := newFakeS3Server(t)
+	client, err := awscloud.NewForEndpoint(srv.URL, "us-east-1", "id", "secret", "", "", false)
+	require.NoError(t, err)
+
+	err = client.UploadFromReaderResumable(bytes.NewReader(make([]byte, 1000)), "bucket", "key", &chunked.Options{
+		PartSize: awscloud.S3MinPartSize - 1,
+	})
+	require.EqualError(t, err, "part size 5242879 is smaller than the minimum part size of S3 multipart uploads (5242880)")
+	assert.Empty(t, fs.partPuts)
+}
@@ -0,0 +1,153 @@
+package awscloud
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+
+	"github.com/osbuild/images/pkg/cloud"
+	"github.com/osbuild/images/pkg/upload/chunked"
+)
+
+// s3Uploader uploads an image to an S3 compatible object storage without
+// registering it anywhere.
+type s3Uploader struct {
+	client s3Client
+
+	bucketName string
+	keyName    string
+	public     bool
+	upload     *chunked.Options
+}
+
+// S3UploaderOptions configure the connection to a generic, S3 compatible,
+// object storage.
+type S3UploaderOptions struct {
+	Endpoint        string `json:"endpoint"`
+	Region          string `json:"region,omitempty"`
+	AccessKeyID     string `json:"access_key_id"`
+	SecretAccessKey string `json:"secret_access_key"`
+	SessionToken    string `json:"session_token,omitempty"`
+	// Path to a CA bundle for the S3 server
+	CABundle            string `json:"ca_bundle,omitempty"`
+	SkipSSLVerification bool   `json:"skip_ssl_verification,omitempty"`
+	// Mark the uploaded object as public
+	Public bool `json:"public,omitempty"`
+	// Upload enables resumable chunked uploads with progress reporting,
+	// see chunked.Options. If nil, the image is uploaded in one go.
+	Upload *chunked.Options `json:"upload,omitempty"`
+}
+
+// testing support
+type s3Client interface {
+	Buckets() ([]string, error)
+	CheckBucketPermission(string, S3Permission) (bool, error)
+	UploadFromReader(io.Reader, string, string) (*s3manager.UploadOutput, error)
+	UploadFromReaderResumable(io.Reader, string, string, *chunked.Options) error
+	MarkS3ObjectAsPublic(string, string) error
+}
+
+var newS3Client = func(opts *S3UploaderOptions) (s3Client, error) {
+	return NewForEndpoint(opts.Endpoint, opts.Region, opts.AccessKeyID, opts.SecretAccessKey, opts.SessionToken, opts.CABundle, opts.SkipSSLVerification)
+}
+
+// NewS3Uploader returns an uploader that uploads the image to the given
+// bucket and key of an S3 compatible object storage.
+func NewS3Uploader(bucketName, keyName string, opts *S3UploaderOptions) (cloud.Uploader, error) {
+	if opts == nil {
+		opts = &S3UploaderOptions{}
+	}
+	client, err := newS3Client(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &s3Uploader{
+		client:     client,
+		bucketName: bucketName,
+		keyName:    keyName,
+		public:     opts.Public,
+		upload:     opts.Upload,
+	}, nil
+}
+
+var _ cloud.Uploader = &s3Uploader{}
+
+func (su *s3Uploader) Check(status io.Writer) error {
+	fmt.Fprintf(status, "Checking S3 bucket...\n")
+	buckets, err := su.client.Buckets()
+	if err != nil {
+		return fmt.Errorf("retrieving list of S3 buckets failed: %w", err)
+	}
+	if !slices.Contains(buckets, su.bucketName) {
+		return fmt.Errorf("bucket '%s' not found with the given credentials", su.bucketName)
+	}
+
+	fmt.Fprintf(status, "Checking S3 bucket permissions...\n")
+	writePermission, err := su.client.CheckBucketPermission(su.bucketName, S3PermissionWrite)
+	if err != nil {
+		return err
+	}
+	if !writePermission {
+		return fmt.Errorf("you don't have write permissions to bucket '%s' with the given credentials", su.bucketName)
+	}
+	fmt.Fprintf(status, "Upload conditions met.\n")
+	return nil
+}
+
+func (su *s3Uploader) UploadAndRegister(r io.Reader, status io.Writer) error {
+	fmt.Fprintf(status, "Uploading to %s:%s\n", su.bucketName, su.keyName)
+	location := fmt.Sprintf("%s:%s", su.bucketName, su.keyName)
+	var err error
+	if su.upload != nil {
+		err = su.client.UploadFromReaderResumable(r, su.bucketName, su.keyName, su.upload)
+	} else {
+		var res *s3manager.UploadOutput
+		res, err = su.client.UploadFromReader(r, su.bucketName, su.keyName)
+		if res != nil {
+			location = res.Location
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	if su.public {
+		fmt.Fprintf(status, "Marking %s:%s as public\n", su.bucketName, su.keyName)
+		if err := su.client.MarkS3ObjectAsPublic(su.bucketName, su.keyName); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(status, "File uploaded to %s\n", location)
+	return nil
+}
+
+// S3UploaderConfig is the configuration of the "s3" upload target, see
+// cloud.NewUploader(). The image name is used as the key when no key is
+// set.
+type S3UploaderConfig struct {
+	S3UploaderOptions
+	Bucket string `json:"bucket"`
+	Key    string `json:"key,omitempty"`
+}
+
+func newS3UploaderFromConfig(imageName string, data json.RawMessage) (cloud.Uploader, error) {
+	var config S3UploaderConfig
+	if err := cloud.UnmarshalUploaderConfig(data, &config); err != nil {
+		return nil, err
+	}
+	if config.Bucket == "" {
+		return nil, fmt.Errorf("bucket is required")
+	}
+	if config.Key == "" {
+		config.Key = imageName
+	}
+	return NewS3Uploader(config.Bucket, config.Key, &config.S3UploaderOptions)
+}
+
+func init() {
+	cloud.RegisterUploader("s3", newS3UploaderFromConfig)
+}
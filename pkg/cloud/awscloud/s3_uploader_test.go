@@ -0,0 +1,211 @@
+package awscloud_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/pkg/cloud"
+	"github.com/osbuild/images/pkg/cloud/awscloud"
+	"github.com/osbuild/images/pkg/upload/chunked"
+)
+
+type fakeS3Client struct {
+	buckets    []string
+	bucketsErr error
+
+	checkBucketPermission    bool
+	checkBucketPermissionErr error
+
+	uploadedData   []byte
+	uploadedBucket string
+	uploadedKey    string
+	uploadErr      error
+	uploadOpts     *chunked.Options
+
+	markedPublic []string
+}
+
+func (fs *fakeS3Client) Buckets() ([]string, error) {
+	return fs.buckets, fs.bucketsErr
+}
+
+func (fs *fakeS3Client) CheckBucketPermission(string, awscloud.S3Permission) (bool, error) {
+	return fs.checkBucketPermission, fs.checkBucketPermissionErr
+}
+
+func (fs *fakeS3Client) UploadFromReader(r io.Reader, bucket, key string) (*s3manager.UploadOutput, error) {
+	if fs.uploadErr != nil {
+		return nil, fs.uploadErr
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	fs.uploadedData = data
+	fs.uploadedBucket = bucket
+	fs.uploadedKey = key
+	return &s3manager.UploadOutput{Location: fmt.Sprintf("https://s3.example.com/%s/%s", bucket, key)}, nil
+}
+
+func (fs *fakeS3Client) UploadFromReaderResumable(r io.Reader, bucket, key string, opts *chunked.Options) error {
+	if _, err := fs.UploadFromReader(r, bucket, key); err != nil {
+		return err
+	}
+	fs.uploadOpts = opts
+	return nil
+}
+
+func (fs *fakeS3Client) MarkS3ObjectAsPublic(bucket, key string) error {
+	fs.markedPublic = append(fs.markedPublic, bucket+"/"+key)
+	return nil
+}
+
+func mockS3Client(t *testing.T, fs *fakeS3Client) {
+	restore := awscloud.MockNewS3Client(func(opts *awscloud.S3UploaderOptions) (awscloud.S3Client, error) {
+		return fs, nil
+	})
+	t.Cleanup(restore)
+}
+
+func TestS3UploaderCheck(t *testing.T) {
+	testCases := []struct {
+		name        string
+		client      *fakeS3Client
+		expectedErr string
+	}{
+		{
+			name:   "happy",
+			client: &fakeS3Client{buckets: []string{"bucket"}, checkBucketPermission: true},
+		},
+		{
+			name:        "no-bucket",
+			client:      &fakeS3Client{buckets: []string{"other"}, checkBucketPermission: true},
+			expectedErr: "bucket 'bucket' not found with the given credentials",
+		},
+		{
+			name:        "no-permission",
+			client:      &fakeS3Client{buckets: []string{"bucket"}},
+			expectedErr: "you don't have write permissions to bucket 'bucket' with the given credentials",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockS3Client(t, tc.client)
+			uploader, err := awscloud.NewS3Uploader("bucket", "key", nil)
+			require.NoError(t, err)
+
+			var status bytes.Buffer
+			err = uploader.Check(&status)
+			if tc.expectedErr != "" {
+				assert.EqualError(t, err, tc.expectedErr)
+			} else {
+				assert.NoError(t, err)
+				assert.Contains(t, status.String(), "Upload conditions met.")
+			}
+		})
+	}
+}
+
+func TestS3UploaderUploadAndRegister(t *testing.T) {
+	fs := &fakeS3Client{}
+	mockS3Client(t, fs)
+
+	uploader, err := awscloud.NewS3Uploader("bucket", "key", &awscloud.S3UploaderOptions{Public: true})
+	require.NoError(t, err)
+
+	var status bytes.Buffer
+	err = uploader.UploadAndRegister(bytes.NewBufferString("image-data"), &status)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("image-data"), fs.uploadedData)
+	assert.Equal(t, "bucket", fs.uploadedBucket)
+	assert.Equal(t, "key", fs.uploadedKey)
+	assert.Equal(t, []string{"bucket/key"}, fs.markedPublic)
+	assert.Equal(t, `Uploading to bucket:key
+Marking bucket:key as public
+File uploaded to https://s3.example.com/bucket/key
+`, status.String())
+}
+
+func TestS3UploaderUploadResumable(t *testing.T) {
+	fs := &fakeS3Client{}
+	mockS3Client(t, fs)
+
+	opts := &chunked.Options{PartSize: 5 * 1024 * 1024}
+	uploader, err := awscloud.NewS3Uploader("bucket", "key", &awscloud.S3UploaderOptions{Upload: opts})
+	require.NoError(t, err)
+
+	var status bytes.Buffer
+	err = uploader.UploadAndRegister(bytes.NewBufferString("image-data"), &status)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("image-data"), fs.uploadedData)
+	assert.Equal(t, opts, fs.uploadOpts)
+	assert.Equal(t, `Uploading to bucket:key
+File uploaded to bucket:key
+`, status.String())
+}
+
+func TestS3UploaderUploadError(t *testing.T) {
+	fs := &fakeS3Client{uploadErr: fmt.Errorf("upload-error")}
+	mockS3Client(t, fs)
+
+	uploader, err := awscloud.NewS3Uploader("bucket", "key", &awscloud.S3UploaderOptions{Public: true})
+	require.NoError(t, err)
+
+	err = uploader.UploadAndRegister(bytes.NewBufferString("image-data"), io.Discard)
+	assert.EqualError(t, err, "upload-error")
+	assert.Empty(t, fs.markedPublic)
+}
+
+func TestS3UploaderFromRegistry(t *testing.T) {
+	fs := &fakeS3Client{}
+	var gotOpts *awscloud.S3UploaderOptions
+	restore := awscloud.MockNewS3Client(func(opts *awscloud.S3UploaderOptions) (awscloud.S3Client, error) {
+		gotOpts = opts
+		return fs, nil
+	})
+	defer restore()
+
+	config := json.RawMessage(`{"endpoint": "https://minio.example.com", "access_key_id": "id", "secret_access_key": "secret", "bucket": "bucket", "upload": {"state_file": "/tmp/upload.json", "part_size": 16777216}}`)
+	uploader, err := cloud.NewUploader("s3", "disk.qcow2", config)
+	require.NoError(t, err)
+	assert.Equal(t, &awscloud.S3UploaderOptions{
+		Endpoint:        "https://minio.example.com",
+		AccessKeyID:     "id",
+		SecretAccessKey: "secret",
+		Upload: &chunked.Options{
+			StateFile: "/tmp/upload.json",
+			PartSize:  16 * 1024 * 1024,
+		},
+	}, gotOpts)
+
+	err = uploader.UploadAndRegister(bytes.NewBufferString("data"), io.Discard)
+	require.NoError(t, err)
+	assert.Equal(t, "disk.qcow2", fs.uploadedKey)
+
+	_, err = cloud.NewUploader("s3", "disk.qcow2", json.RawMessage(`{}`))
+	assert.EqualError(t, err, `cannot create uploader for target "s3": bucket is required`)
+}
+
+func TestAWSUploaderFromRegistry(t *testing.T) {
+	var gotRegion string
+	restore := awscloud.MockNewAwsClient(func(region string) (awscloud.AwsClient, error) {
+		gotRegion = region
+		return &fakeAWSClient{}, nil
+	})
+	defer restore()
+
+	_, err := cloud.NewUploader("aws", "ami", json.RawMessage(`{"region": "eu-central-1", "bucket": "bucket", "boot_mode": "uefi"}`))
+	require.NoError(t, err)
+	assert.Equal(t, "eu-central-1", gotRegion)
+
+	_, err = cloud.NewUploader("aws", "ami", json.RawMessage(`{"region": "eu-central-1", "bucket": "bucket", "boot_mode": "bios"}`))
+	assert.EqualError(t, err, `cannot create uploader for target "aws": invalid boot mode: bios`)
+}
@@ -1,6 +1,7 @@
 package awscloud
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -15,6 +16,7 @@ import (
 	"github.com/osbuild/images/pkg/arch"
 	"github.com/osbuild/images/pkg/cloud"
 	"github.com/osbuild/images/pkg/platform"
+	"github.com/osbuild/images/pkg/upload/chunked"
 )
 
 type awsUploader struct {
@@ -25,12 +27,16 @@ type awsUploader struct {
 	imageName  string
 	targetArch string
 	bootMode   *string
+	upload     *chunked.Options
 }
 
 type UploaderOptions struct {
 	TargetArch string
 	// BootMode to set for the AMI. If nil, no explicit boot mode will be set.
 	BootMode *platform.BootMode
+	// Upload enables resumable chunked uploads with progress reporting,
+	// see chunked.Options. If nil, the image is uploaded in one go.
+	Upload *chunked.Options
 }
 
 func (ou *UploaderOptions) ec2BootMode() (*string, error) {
@@ -56,6 +62,7 @@ type awsClient interface {
 	Buckets() ([]string, error)
 	CheckBucketPermission(string, S3Permission) (bool, error)
 	UploadFromReader(io.Reader, string, string) (*s3manager.UploadOutput, error)
+	UploadFromReaderResumable(io.Reader, string, string, *chunked.Options) error
 	Register(name, bucket, key string, shareWith []string, rpmArch string, bootMode, importRole *string) (*string, *string, error)
 	DeleteObject(string, string) error
 }
@@ -84,6 +91,7 @@ func NewUploader(region, bucketName, imageName string, opts *UploaderOptions) (c
 		imageName:  imageName,
 		targetArch: opts.TargetArch,
 		bootMode:   bootMode,
+		upload:     opts.Upload,
 	}, nil
 }
 
@@ -122,10 +130,24 @@ func (au *awsUploader) Check(status io.Writer) error {
 }
 
 func (au *awsUploader) UploadAndRegister(r io.Reader, status io.Writer) (err error) {
-	keyName := fmt.Sprintf("%s-%s", uuid.New().String(), au.imageName)
+	keyID := uuid.New()
+	if au.upload != nil && au.upload.StateFile != "" {
+		// a resumed upload must use the key of the interrupted one
+		keyID = uuid.NewSHA1(uuid.NameSpaceURL, []byte(fmt.Sprintf("s3://%s/%s", au.bucketName, au.imageName)))
+	}
+	keyName := fmt.Sprintf("%s-%s", keyID.String(), au.imageName)
 	fmt.Fprintf(status, "Uploading %s to %s:%s\n", au.imageName, au.bucketName, keyName)
 
-	res, err := au.client.UploadFromReader(r, au.bucketName, keyName)
+	location := fmt.Sprintf("%s:%s", au.bucketName, keyName)
+	if au.upload != nil {
+		err = au.client.UploadFromReaderResumable(r, au.bucketName, keyName, au.upload)
+	} else {
+		var res *s3manager.UploadOutput
+		res, err = au.client.UploadFromReader(r, au.bucketName, keyName)
+		if res != nil {
+			location = res.Location
+		}
+	}
 	if err != nil {
 		return err
 	}
@@ -136,7 +158,7 @@ func (au *awsUploader) UploadAndRegister(r io.Reader, status io.Writer) (err err
 			err = errors.Join(err, aErr)
 		}
 	}()
-	fmt.Fprintf(status, "File uploaded to %s\n", aws.StringValue(&res.Location))
+	fmt.Fprintf(status, "File uploaded to %s\n", location)
 	if au.targetArch == "" {
 		au.targetArch = arch.Current().String()
 	}
@@ -158,3 +180,48 @@ func (au *awsUploader) UploadAndRegister(r io.Reader, status io.Writer) (err err
 
 	return nil
 }
+
+// UploaderConfig is the configuration of the "aws" upload target, see
+// cloud.NewUploader().
+type UploaderConfig struct {
+	Region     string `json:"region"`
+	Bucket     string `json:"bucket"`
+	TargetArch string `json:"target_arch,omitempty"`
+	// BootMode is one of "legacy", "uefi" or "hybrid", unset means no
+	// explicit boot mode
+	BootMode string `json:"boot_mode,omitempty"`
+	// Upload makes the upload resumable, see chunked.Options
+	Upload *chunked.Options `json:"upload,omitempty"`
+}
+
+func newUploaderFromConfig(imageName string, data json.RawMessage) (cloud.Uploader, error) {
+	var config UploaderConfig
+	if err := cloud.UnmarshalUploaderConfig(data, &config); err != nil {
+		return nil, err
+	}
+	if config.Region == "" || config.Bucket == "" {
+		return nil, fmt.Errorf("region and bucket are required")
+	}
+
+	opts := &UploaderOptions{
+		TargetArch: config.TargetArch,
+		Upload:     config.Upload,
+	}
+	switch config.BootMode {
+	case "":
+	case "legacy":
+		opts.BootMode = common.ToPtr(platform.BOOT_LEGACY)
+	case "uefi":
+		opts.BootMode = common.ToPtr(platform.BOOT_UEFI)
+	case "hybrid":
+		opts.BootMode = common.ToPtr(platform.BOOT_HYBRID)
+	default:
+		return nil, fmt.Errorf("invalid boot mode: %s", config.BootMode)
+	}
+
+	return NewUploader(config.Region, config.Bucket, imageName, opts)
+}
+
+func init() {
+	cloud.RegisterUploader("aws", newUploaderFromConfig)
+}
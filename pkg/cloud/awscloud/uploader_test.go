@@ -14,6 +14,7 @@ import (
 	"github.com/osbuild/images/internal/common"
 	"github.com/osbuild/images/pkg/cloud/awscloud"
 	"github.com/osbuild/images/pkg/platform"
+	"github.com/osbuild/images/pkg/upload/chunked"
 )
 
 // XXX: put into a new "cloudtest" package?
@@ -34,6 +35,10 @@ type fakeAWSClient struct {
 	uploadFromReaderErr   error
 	uploadFromReaderCalls int
 
+	uploadFromReaderResumableOpts  *chunked.Options
+	uploadFromReaderResumableKey   string
+	uploadFromReaderResumableCalls int
+
 	registerErr        error
 	registerImageId    string
 	registerSnapshotId string
@@ -64,6 +69,13 @@ func (fa *fakeAWSClient) UploadFromReader(io.Reader, string, string) (*s3manager
 	return fa.uploadFromReader, fa.uploadFromReaderErr
 }
 
+func (fa *fakeAWSClient) UploadFromReaderResumable(r io.Reader, bucket, key string, opts *chunked.Options) error {
+	fa.uploadFromReaderResumableCalls++
+	fa.uploadFromReaderResumableKey = key
+	fa.uploadFromReaderResumableOpts = opts
+	return fa.uploadFromReaderErr
+}
+
 func (fa *fakeAWSClient) Register(name, bucket, key string, shareWith []string, rpmArch string, bootMode, importRole *string) (*string, *string, error) {
 	fa.registerCalls++
 	fa.registerBootMode = bootMode
@@ -226,3 +238,35 @@ func TestUploaderUploadButRegisterErrorAndDeleteError(t *testing.T) {
 	// XXX: this should probably have a context
 	assert.EqualError(t, err, "fake-register-err\nfake-delete-object-err")
 }
+
+func TestUploaderUploadResumable(t *testing.T) {
+	fa := &fakeAWSClient{
+		registerImageId:    "image-id",
+		registerSnapshotId: "snapshot-id",
+	}
+	restore := awscloud.MockNewAwsClient(func(string) (awscloud.AwsClient, error) {
+		return fa, nil
+	})
+	defer restore()
+
+	opts := &awscloud.UploaderOptions{
+		Upload: &chunked.Options{
+			StateFile: "/tmp/upload.json",
+		},
+	}
+	uploader, err := awscloud.NewUploader("region", "bucket", "ami", opts)
+	assert.NoError(t, err)
+	var uploadLog bytes.Buffer
+	err = uploader.UploadAndRegister(bytes.NewBufferString("fake-aws-image"), &uploadLog)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, fa.uploadFromReaderCalls)
+	assert.Equal(t, 1, fa.uploadFromReaderResumableCalls)
+	assert.Equal(t, opts.Upload, fa.uploadFromReaderResumableOpts)
+	// the key does not change, so an interrupted upload can be resumed
+	key := fa.uploadFromReaderResumableKey
+	assert.Regexp(t, "^[0-9a-f-]{36}-ami$", key)
+	err = uploader.UploadAndRegister(bytes.NewBufferString("fake-aws-image"), io.Discard)
+	assert.NoError(t, err)
+	assert.Equal(t, key, fa.uploadFromReaderResumableKey)
+	assert.Contains(t, uploadLog.String(), "File uploaded to bucket:"+key+"\n")
+}
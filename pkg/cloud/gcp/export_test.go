@@ -0,0 +1,13 @@
+package gcp
+
+type GCPClient = gcpClient
+
+func MockNewGCPClient(f func([]byte) (gcpClient, error)) (restore func()) {
+	saved := newGCPClient
+	newGCPClient = f
+	return func() {
+		newGCPClient = saved
+	}
+}
+
+var WriteObject = writeObject
@@ -56,11 +56,19 @@ func New(credentials []byte) (*GCP, error) {
 // NewFromFile loads the credentials from a file and returns an authenticated
 // *GCP object instance.
 func NewFromFile(path string) (*GCP, error) {
+	gcpCredentials, err := readCredentialsFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return New(gcpCredentials)
+}
+
+func readCredentialsFile(path string) ([]byte, error) {
 	gcpCredentials, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("cannot load GCP credentials from file %q: %v", path, err)
 	}
-	return New(gcpCredentials)
+	return gcpCredentials, nil
 }
 
 // GetProjectID returns a string with the Project ID of the project, used for
@@ -97,3 +97,66 @@ func (g *GCP) StorageObjectDelete(ctx context.Context, bucket, object string) er
 
 	return nil
 }
+
+// StorageObjectUploadFromReader uploads the data read from r to the
+// specified Cloud Storage bucket and object. The bucket must exist. Unlike
+// StorageObjectUpload() the data is streamed, so no MD5 sum is verified.
+//
+// Uses:
+//   - Storage API
+func (g *GCP) StorageObjectUploadFromReader(ctx context.Context, r io.Reader, bucket, object string, metadata map[string]string) error {
+	storageClient, err := storage.NewClient(ctx, option.WithCredentials(g.creds))
+	if err != nil {
+		return fmt.Errorf("failed to get Storage client: %v", err)
+	}
+	defer storageClient.Close()
+
+	_, err = writeObject(ctx, storageClient.Bucket(bucket).Object(object), r, metadata)
+	return err
+}
+
+// writeObject writes the data read from r to the given object and returns
+// its attributes. If reading fails, the upload is cancelled and no object
+// is created.
+func writeObject(ctx context.Context, obj *storage.ObjectHandle, r io.Reader, metadata map[string]string) (*storage.ObjectAttrs, error) {
+	// Closing the writer finalizes the object with the data written so
+	// far, so a failed upload must be aborted by cancelling the context.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	wc := obj.NewWriter(ctx)
+	if metadata != nil {
+		wc.ObjectAttrs.Metadata = metadata
+	}
+
+	if _, err := io.Copy(wc, r); err != nil {
+		cancel()
+		return nil, fmt.Errorf("uploading object %q failed: %v", obj.ObjectName(), err)
+	}
+
+	// The object will not be available until Close has been called.
+	if err := wc.Close(); err != nil {
+		return nil, fmt.Errorf("Writer.Close: %v", err)
+	}
+
+	return wc.Attrs(), nil
+}
+
+// StorageBucketTestPermissions returns the subset of the given permissions
+// that the credentials have on the bucket.
+//
+// Uses:
+//   - Storage API
+func (g *GCP) StorageBucketTestPermissions(ctx context.Context, bucket string, permissions []string) ([]string, error) {
+	storageClient, err := storage.NewClient(ctx, option.WithCredentials(g.creds))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Storage client: %v", err)
+	}
+	defer storageClient.Close()
+
+	granted, err := storageClient.Bucket(bucket).IAM().TestPermissions(ctx, permissions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to test permissions of bucket %q: %v", bucket, err)
+	}
+	return granted, nil
+}
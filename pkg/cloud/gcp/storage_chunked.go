@@ -0,0 +1,142 @@
+package gcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"cloud.google.com/go/storage"
+	"github.com/google/uuid"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+
+	"github.com/osbuild/images/pkg/upload/chunked"
+)
+
+// maxComposeSources is the maximum number of objects that can be composed
+// in a single request.
+// See https://cloud.google.com/storage/docs/composite-objects
+const maxComposeSources = 32
+
+// composeBackend uploads the parts of a chunked upload as temporary
+// objects that are composed into the final object once all of them are
+// uploaded.
+type composeBackend struct {
+	client   *storage.Client
+	bucket   string
+	object   string
+	metadata map[string]string
+}
+
+var _ chunked.Backend = &composeBackend{}
+
+func (b *composeBackend) Target() string {
+	return fmt.Sprintf("gs://%s/%s", b.bucket, b.object)
+}
+
+// tmpPrefix is the prefix of all temporary objects of an upload
+func (b *composeBackend) tmpPrefix(uploadID string) string {
+	return fmt.Sprintf("%s.%s.", b.object, uploadID)
+}
+
+func (b *composeBackend) Create(ctx context.Context) (string, error) {
+	return uuid.New().String(), nil
+}
+
+func (b *composeBackend) UploadPart(ctx context.Context, uploadID string, part chunked.Part, r io.ReadSeeker) (string, error) {
+	name := fmt.Sprintf("%spart-%05d", b.tmpPrefix(uploadID), part.Number)
+	attrs, err := writeObject(ctx, b.client.Bucket(b.bucket).Object(name), r, nil)
+	if err != nil {
+		return "", err
+	}
+	// the generation makes sure that exactly the uploaded data is composed
+	return strconv.FormatInt(attrs.Generation, 10), nil
+}
+
+func (b *composeBackend) Complete(ctx context.Context, uploadID string, parts []chunked.Part) error {
+	bucket := b.client.Bucket(b.bucket)
+
+	sources := make([]*storage.ObjectHandle, 0, len(parts))
+	for _, part := range parts {
+		generation, err := strconv.ParseInt(part.ETag, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid generation of part %d: %v", part.Number, err)
+		}
+		name := fmt.Sprintf("%spart-%05d", b.tmpPrefix(uploadID), part.Number)
+		sources = append(sources, bucket.Object(name).Generation(generation))
+	}
+
+	// compose the parts in levels of intermediate objects until they fit
+	// into a single request
+	for level := 0; len(sources) > maxComposeSources; level++ {
+		var composed []*storage.ObjectHandle
+		for idx := 0; idx < len(sources); idx += maxComposeSources {
+			name := fmt.Sprintf("%scompose-%d-%05d", b.tmpPrefix(uploadID), level, idx/maxComposeSources)
+			dst := bucket.Object(name)
+			attrs, err := dst.ComposerFrom(sources[idx:min(idx+maxComposeSources, len(sources))]...).Run(ctx)
+			if err != nil {
+				return fmt.Errorf("composing object %q failed: %v", name, err)
+			}
+			composed = append(composed, dst.Generation(attrs.Generation))
+		}
+		sources = composed
+	}
+
+	composer := bucket.Object(b.object).ComposerFrom(sources...)
+	composer.ObjectAttrs.Metadata = b.metadata
+	if _, err := composer.Run(ctx); err != nil {
+		return fmt.Errorf("composing object %q failed: %v", b.object, err)
+	}
+
+	return b.deleteTmpObjects(ctx, uploadID)
+}
+
+func (b *composeBackend) Abort(ctx context.Context, uploadID string) error {
+	return b.deleteTmpObjects(ctx, uploadID)
+}
+
+func (b *composeBackend) deleteTmpObjects(ctx context.Context, uploadID string) error {
+	bucket := b.client.Bucket(b.bucket)
+	it := bucket.Objects(ctx, &storage.Query{Prefix: b.tmpPrefix(uploadID)})
+	var errs []error
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to list temporary objects: %v", err)
+		}
+		if err := bucket.Object(attrs.Name).Delete(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("failed to delete temporary object %q: %v", attrs.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// StorageObjectUploadResumable uploads the data read from r to the
+// specified Cloud Storage bucket and object using a chunked upload, see
+// chunked.Upload(). The parts are uploaded as temporary objects next to
+// the object and composed into it at the end. Unlike
+// StorageObjectUploadFromReader() a failed upload can be resumed if
+// opts.StateFile is set.
+//
+// Uses:
+//   - Storage API
+func (g *GCP) StorageObjectUploadResumable(ctx context.Context, r io.Reader, bucket, object string, metadata map[string]string, opts *chunked.Options) error {
+	storageClient, err := storage.NewClient(ctx, option.WithCredentials(g.creds))
+	if err != nil {
+		return fmt.Errorf("failed to get Storage client: %v", err)
+	}
+	defer storageClient.Close()
+
+	backend := &composeBackend{
+		client:   storageClient,
+		bucket:   bucket,
+		object:   object,
+		metadata: metadata,
+	}
+	return chunked.Upload(ctx, backend, r, -1, opts)
+}
@@ -0,0 +1,121 @@
+package gcp_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"cloud.google.com/go/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/option"
+
+	"github.com/osbuild/images/pkg/cloud/gcp"
+)
+
+// fakeStorageServer implements the multipart upload of the Cloud Storage
+// JSON API and keeps the uploaded objects in memory.
+type fakeStorageServer struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func newFakeStorageClient(t *testing.T) (*fakeStorageServer, *storage.Client) {
+	fs := &fakeStorageServer{
+		objects: map[string][]byte{},
+	}
+	srv := httptest.NewServer(http.HandlerFunc(fs.handle))
+	t.Cleanup(srv.Close)
+
+	client, err := storage.NewClient(context.Background(), option.WithEndpoint(srv.URL+"/storage/v1/"), option.WithoutAuthentication())
+	require.NoError(t, err)
+	t.Cleanup(func() { client.Close() })
+	return fs, client
+}
+
+func (fs *fakeStorageServer) handle(w http.ResponseWriter, r *http.Request) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if r.Method != http.MethodPost || r.URL.Query().Get("uploadType") != "multipart" {
+		http.Error(w, fmt.Sprintf("unexpected request %s %s", r.Method, r.URL), http.StatusBadRequest)
+		return
+	}
+	_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	mr := multipart.NewReader(r.Body, params["boundary"])
+	var attrs struct {
+		Bucket string `json:"bucket"`
+		Name   string `json:"name"`
+	}
+	part, err := mr.NextPart()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := json.NewDecoder(part).Decode(&attrs); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	part, err = mr.NextPart()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	data, err := io.ReadAll(part)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	fs.objects[attrs.Bucket+"/"+attrs.Name] = data
+	fmt.Fprintf(w, `{"bucket": %q, "name": %q, "size": "%d", "generation": "1"}`, attrs.Bucket, attrs.Name, len(data))
+}
+
+// failingReader returns the data of r followed by err
+type failingReader struct {
+	r   io.Reader
+	err error
+}
+
+func (fr *failingReader) Read(p []byte) (int, error) {
+	n, err := fr.r.Read(p)
+	if err == io.EOF {
+		return n, fr.err
+	}
+	return n, err
+}
+
+func TestWriteObject(t *testing.T) {
+	fs, client := newFakeStorageClient(t)
+
+	data := []byte("image data")
+	attrs, err := gcp.WriteObject(context.Background(), client.Bucket("bucket").Object("object"), bytes.NewReader(data), nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), attrs.Generation)
+	assert.Equal(t, map[string][]byte{"bucket/object": data}, fs.objects)
+}
+
+func TestWriteObjectFailingReader(t *testing.T) {
+	fs, client := newFakeStorageClient(t)
+
+	r := &failingReader{
+		r:   bytes.NewReader([]byte("truncated image data")),
+		err: errors.New("read error"),
+	}
+	_, err := gcp.WriteObject(context.Background(), client.Bucket("bucket").Object("object"), r, nil)
+	require.EqualError(t, err, `uploading object "object" failed: read error`)
+	// the truncated data must not end up in an object
+	assert.Empty(t, fs.objects)
+}
@@ -0,0 +1,195 @@
+package gcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+
+	"cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/google/uuid"
+
+	"github.com/osbuild/images/pkg/cloud"
+	"github.com/osbuild/images/pkg/upload/chunked"
+)
+
+// storagePermissions are the permissions on the bucket that are needed to
+// upload the image and remove it once it is imported
+var storagePermissions = []string{
+	"storage.objects.create",
+	"storage.objects.delete",
+}
+
+type gcpUploader struct {
+	client gcpClient
+
+	bucket          string
+	imageName       string
+	regions         []string
+	guestOsFeatures []*computepb.GuestOsFeature
+	shareWith       []string
+	upload          *chunked.Options
+}
+
+type UploaderOptions struct {
+	// Regions where the image should be stored, see ComputeImageInsert()
+	Regions []string
+	// GuestOsFeatures of the image, see GuestOsFeaturesByDistro()
+	GuestOsFeatures []*computepb.GuestOsFeature
+	// ShareWith is the list of accounts the image is shared with, see
+	// ComputeImageShare()
+	ShareWith []string
+	// Credentials are the JSON credentials of a service account, the
+	// default credentials are used if not set
+	Credentials []byte
+	// Upload enables resumable chunked uploads with progress reporting,
+	// see chunked.Options. If nil, the image is uploaded in one go.
+	Upload *chunked.Options
+}
+
+// testing support
+type gcpClient interface {
+	StorageBucketTestPermissions(ctx context.Context, bucket string, permissions []string) ([]string, error)
+	StorageObjectUploadFromReader(ctx context.Context, r io.Reader, bucket, object string, metadata map[string]string) error
+	StorageObjectUploadResumable(ctx context.Context, r io.Reader, bucket, object string, metadata map[string]string, opts *chunked.Options) error
+	StorageObjectDelete(ctx context.Context, bucket, object string) error
+	ComputeImageInsert(ctx context.Context, bucket, object, imageName string, regions []string, guestOsFeatures []*computepb.GuestOsFeature) (*computepb.Image, error)
+	ComputeImageURL(imageName string) string
+	ComputeImageShare(ctx context.Context, imageName string, shareWith []string) error
+}
+
+var newGCPClient = func(credentials []byte) (gcpClient, error) {
+	return New(credentials)
+}
+
+// NewUploader returns an uploader that imports the image into Compute
+// Engine. The image is uploaded to the given bucket first, the data passed
+// to UploadAndRegister() must be a gzip compressed tar archive containing
+// a "disk.raw" file.
+func NewUploader(bucket, imageName string, opts *UploaderOptions) (cloud.Uploader, error) {
+	if opts == nil {
+		opts = &UploaderOptions{}
+	}
+	client, err := newGCPClient(opts.Credentials)
+	if err != nil {
+		return nil, err
+	}
+
+	return &gcpUploader{
+		client:          client,
+		bucket:          bucket,
+		imageName:       imageName,
+		regions:         opts.Regions,
+		guestOsFeatures: opts.GuestOsFeatures,
+		shareWith:       opts.ShareWith,
+		upload:          opts.Upload,
+	}, nil
+}
+
+var _ cloud.Uploader = &gcpUploader{}
+
+func (gu *gcpUploader) Check(status io.Writer) error {
+	fmt.Fprintf(status, "Checking Storage bucket permissions...\n")
+	granted, err := gu.client.StorageBucketTestPermissions(context.Background(), gu.bucket, storagePermissions)
+	if err != nil {
+		return err
+	}
+	for _, perm := range storagePermissions {
+		if !slices.Contains(granted, perm) {
+			return fmt.Errorf("missing permission %q on bucket '%s' with the given credentials", perm, gu.bucket)
+		}
+	}
+	fmt.Fprintf(status, "Upload conditions met.\n")
+	return nil
+}
+
+func (gu *gcpUploader) UploadAndRegister(r io.Reader, status io.Writer) (err error) {
+	ctx := context.Background()
+	objectID := uuid.New()
+	if gu.upload != nil && gu.upload.StateFile != "" {
+		// a resumed upload must use the object of the interrupted one
+		objectID = uuid.NewSHA1(uuid.NameSpaceURL, []byte(fmt.Sprintf("gs://%s/%s", gu.bucket, gu.imageName)))
+	}
+	object := fmt.Sprintf("%s-%s.tar.gz", objectID.String(), gu.imageName)
+
+	fmt.Fprintf(status, "Uploading %s to %s:%s\n", gu.imageName, gu.bucket, object)
+	metadata := map[string]string{
+		MetadataKeyImageName: gu.imageName,
+	}
+	if gu.upload != nil {
+		err = gu.client.StorageObjectUploadResumable(ctx, r, gu.bucket, object, metadata, gu.upload)
+	} else {
+		err = gu.client.StorageObjectUploadFromReader(ctx, r, gu.bucket, object, metadata)
+	}
+	if err != nil {
+		return err
+	}
+	defer func() {
+		fmt.Fprintf(status, "Deleting %s:%s\n", gu.bucket, object)
+		if deleteErr := gu.client.StorageObjectDelete(ctx, gu.bucket, object); deleteErr != nil && err == nil {
+			err = deleteErr
+		}
+	}()
+
+	fmt.Fprintf(status, "Importing image %s\n", gu.imageName)
+	image, err := gu.client.ComputeImageInsert(ctx, gu.bucket, object, gu.imageName, gu.regions, gu.guestOsFeatures)
+	if err != nil {
+		return err
+	}
+
+	if len(gu.shareWith) > 0 {
+		fmt.Fprintf(status, "Sharing image %s with %v\n", gu.imageName, gu.shareWith)
+		if err := gu.client.ComputeImageShare(ctx, image.GetName(), gu.shareWith); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintf(status, "Image %s imported: %s\n", image.GetName(), gu.client.ComputeImageURL(image.GetName()))
+	return nil
+}
+
+// UploaderConfig is the configuration of the "gcp" upload target, see
+// cloud.NewUploader().
+type UploaderConfig struct {
+	Bucket  string   `json:"bucket"`
+	Regions []string `json:"regions,omitempty"`
+	// Distro is used to pick the guest OS features, see
+	// GuestOsFeaturesByDistro()
+	Distro    string   `json:"distro,omitempty"`
+	ShareWith []string `json:"share_with,omitempty"`
+	// Path to the JSON credentials of a service account, the default
+	// credentials are used if not set
+	CredentialsFile string `json:"credentials_file,omitempty"`
+	// Upload makes the upload resumable, see chunked.Options
+	Upload *chunked.Options `json:"upload,omitempty"`
+}
+
+func newUploaderFromConfig(imageName string, data json.RawMessage) (cloud.Uploader, error) {
+	var config UploaderConfig
+	if err := cloud.UnmarshalUploaderConfig(data, &config); err != nil {
+		return nil, err
+	}
+	if config.Bucket == "" {
+		return nil, fmt.Errorf("bucket is required")
+	}
+
+	opts := &UploaderOptions{
+		Regions:         config.Regions,
+		GuestOsFeatures: GuestOsFeaturesByDistro(config.Distro),
+		ShareWith:       config.ShareWith,
+		Upload:          config.Upload,
+	}
+	if config.CredentialsFile != "" {
+		credentials, err := readCredentialsFile(config.CredentialsFile)
+		if err != nil {
+			return nil, err
+		}
+		opts.Credentials = credentials
+	}
+	return NewUploader(config.Bucket, imageName, opts)
+}
+
+func init() {
+	cloud.RegisterUploader("gcp", newUploaderFromConfig)
+}
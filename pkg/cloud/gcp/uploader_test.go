@@ -0,0 +1,219 @@
+package gcp_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/internal/common"
+	"github.com/osbuild/images/pkg/cloud"
+	"github.com/osbuild/images/pkg/cloud/gcp"
+	"github.com/osbuild/images/pkg/upload/chunked"
+)
+
+type fakeGCPClient struct {
+	grantedPermissions []string
+
+	uploadedData     []byte
+	uploadedBucket   string
+	uploadedObject   string
+	uploadedMetadata map[string]string
+	uploadErr        error
+	uploadOpts       *chunked.Options
+
+	insertErr      error
+	insertedImage  string
+	insertedObject string
+
+	deleted []string
+
+	sharedWith []string
+}
+
+func (fc *fakeGCPClient) StorageBucketTestPermissions(ctx context.Context, bucket string, permissions []string) ([]string, error) {
+	return fc.grantedPermissions, nil
+}
+
+func (fc *fakeGCPClient) StorageObjectUploadFromReader(ctx context.Context, r io.Reader, bucket, object string, metadata map[string]string) error {
+	if fc.uploadErr != nil {
+		return fc.uploadErr
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	fc.uploadedData = data
+	fc.uploadedBucket = bucket
+	fc.uploadedObject = object
+	fc.uploadedMetadata = metadata
+	return nil
+}
+
+func (fc *fakeGCPClient) StorageObjectUploadResumable(ctx context.Context, r io.Reader, bucket, object string, metadata map[string]string, opts *chunked.Options) error {
+	if err := fc.StorageObjectUploadFromReader(ctx, r, bucket, object, metadata); err != nil {
+		return err
+	}
+	fc.uploadOpts = opts
+	return nil
+}
+
+func (fc *fakeGCPClient) StorageObjectDelete(ctx context.Context, bucket, object string) error {
+	fc.deleted = append(fc.deleted, bucket+"/"+object)
+	return nil
+}
+
+func (fc *fakeGCPClient) ComputeImageInsert(ctx context.Context, bucket, object, imageName string, regions []string, guestOsFeatures []*computepb.GuestOsFeature) (*computepb.Image, error) {
+	if fc.insertErr != nil {
+		return nil, fc.insertErr
+	}
+	fc.insertedImage = imageName
+	fc.insertedObject = object
+	return &computepb.Image{Name: common.ToPtr(imageName)}, nil
+}
+
+func (fc *fakeGCPClient) ComputeImageURL(imageName string) string {
+	return "https://console.example.com/" + imageName
+}
+
+func (fc *fakeGCPClient) ComputeImageShare(ctx context.Context, imageName string, shareWith []string) error {
+	fc.sharedWith = shareWith
+	return nil
+}
+
+func mockGCPClient(t *testing.T, fc *fakeGCPClient) {
+	restore := gcp.MockNewGCPClient(func([]byte) (gcp.GCPClient, error) {
+		return fc, nil
+	})
+	t.Cleanup(restore)
+}
+
+func TestUploaderCheck(t *testing.T) {
+	testCases := []struct {
+		name        string
+		granted     []string
+		expectedErr string
+	}{
+		{
+			name:    "happy",
+			granted: []string{"storage.objects.create", "storage.objects.delete"},
+		},
+		{
+			name:        "missing-delete",
+			granted:     []string{"storage.objects.create"},
+			expectedErr: `missing permission "storage.objects.delete" on bucket 'bucket' with the given credentials`,
+		},
+		{
+			name:        "missing-all",
+			expectedErr: `missing permission "storage.objects.create" on bucket 'bucket' with the given credentials`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockGCPClient(t, &fakeGCPClient{grantedPermissions: tc.granted})
+			uploader, err := gcp.NewUploader("bucket", "image", nil)
+			require.NoError(t, err)
+
+			var status bytes.Buffer
+			err = uploader.Check(&status)
+			if tc.expectedErr != "" {
+				assert.EqualError(t, err, tc.expectedErr)
+			} else {
+				assert.NoError(t, err)
+				assert.Contains(t, status.String(), "Upload conditions met.")
+			}
+		})
+	}
+}
+
+func TestUploaderUploadAndRegister(t *testing.T) {
+	fc := &fakeGCPClient{}
+	mockGCPClient(t, fc)
+
+	uploader, err := gcp.NewUploader("bucket", "image", &gcp.UploaderOptions{
+		ShareWith: []string{"user:alice@example.com"},
+	})
+	require.NoError(t, err)
+
+	var status bytes.Buffer
+	err = uploader.UploadAndRegister(bytes.NewBufferString("image-data"), &status)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("image-data"), fc.uploadedData)
+	assert.Equal(t, "bucket", fc.uploadedBucket)
+	assert.True(t, strings.HasSuffix(fc.uploadedObject, "-image.tar.gz"))
+	assert.Equal(t, map[string]string{gcp.MetadataKeyImageName: "image"}, fc.uploadedMetadata)
+	assert.Equal(t, "image", fc.insertedImage)
+	assert.Equal(t, fc.uploadedObject, fc.insertedObject)
+	assert.Equal(t, []string{"bucket/" + fc.uploadedObject}, fc.deleted)
+	assert.Equal(t, []string{"user:alice@example.com"}, fc.sharedWith)
+	assert.Contains(t, status.String(), "Image image imported: https://console.example.com/image\n")
+}
+
+func TestUploaderUploadAndRegisterResumable(t *testing.T) {
+	fc := &fakeGCPClient{}
+	mockGCPClient(t, fc)
+
+	opts := &chunked.Options{StateFile: "/tmp/upload.json"}
+	uploader, err := gcp.NewUploader("bucket", "image", &gcp.UploaderOptions{Upload: opts})
+	require.NoError(t, err)
+
+	err = uploader.UploadAndRegister(bytes.NewBufferString("image-data"), io.Discard)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("image-data"), fc.uploadedData)
+	assert.Equal(t, opts, fc.uploadOpts)
+	// the object does not change, so an interrupted upload can be resumed
+	object := fc.uploadedObject
+	err = uploader.UploadAndRegister(bytes.NewBufferString("image-data"), io.Discard)
+	require.NoError(t, err)
+	assert.Equal(t, object, fc.uploadedObject)
+}
+
+func TestUploaderUploadAndRegisterInsertError(t *testing.T) {
+	fc := &fakeGCPClient{insertErr: fmt.Errorf("insert-error")}
+	mockGCPClient(t, fc)
+
+	uploader, err := gcp.NewUploader("bucket", "image", nil)
+	require.NoError(t, err)
+
+	err = uploader.UploadAndRegister(bytes.NewBufferString("image-data"), io.Discard)
+	assert.EqualError(t, err, "insert-error")
+	// the uploaded object is removed even when the import fails
+	assert.Equal(t, []string{"bucket/" + fc.uploadedObject}, fc.deleted)
+}
+
+func TestUploaderUploadAndRegisterUploadError(t *testing.T) {
+	fc := &fakeGCPClient{uploadErr: fmt.Errorf("upload-error")}
+	mockGCPClient(t, fc)
+
+	uploader, err := gcp.NewUploader("bucket", "image", nil)
+	require.NoError(t, err)
+
+	err = uploader.UploadAndRegister(bytes.NewBufferString("image-data"), io.Discard)
+	assert.EqualError(t, err, "upload-error")
+	assert.Empty(t, fc.deleted)
+	assert.Empty(t, fc.insertedImage)
+}
+
+func TestUploaderFromRegistry(t *testing.T) {
+	fc := &fakeGCPClient{}
+	mockGCPClient(t, fc)
+
+	uploader, err := cloud.NewUploader("gcp", "image", json.RawMessage(`{"bucket": "bucket", "distro": "rhel-9.6"}`))
+	require.NoError(t, err)
+	require.NoError(t, uploader.UploadAndRegister(bytes.NewBufferString("data"), io.Discard))
+	assert.Equal(t, "bucket", fc.uploadedBucket)
+
+	_, err = cloud.NewUploader("gcp", "image", json.RawMessage(`{}`))
+	assert.EqualError(t, err, `cannot create uploader for target "gcp": bucket is required`)
+
+	_, err = cloud.NewUploader("gcp", "image", json.RawMessage(`{"bucket": "bucket", "credentials_file": "/non-existing"}`))
+	assert.ErrorContains(t, err, `cannot load GCP credentials from file "/non-existing"`)
+}
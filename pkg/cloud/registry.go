@@ -0,0 +1,77 @@
+package cloud
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// NewUploaderFunc creates an Uploader for the image with the given name
+// from the target specific configuration. The configuration is a JSON
+// object, its keys are documented by the config type of each target.
+type NewUploaderFunc func(imageName string, config json.RawMessage) (Uploader, error)
+
+var (
+	uploadersMu sync.RWMutex
+	uploaders   = map[string]NewUploaderFunc{}
+)
+
+// RegisterUploader makes an uploader available under the given target
+// name. It is meant to be called from the init() function of the cloud
+// specific packages and panics if the target is already registered.
+func RegisterUploader(target string, newUploader NewUploaderFunc) {
+	uploadersMu.Lock()
+	defer uploadersMu.Unlock()
+
+	if _, ok := uploaders[target]; ok {
+		panic(fmt.Sprintf("uploader for target %q is already registered", target))
+	}
+	uploaders[target] = newUploader
+}
+
+// NewUploader creates an Uploader for the given target. The cloud specific
+// package of the target must be imported to register it, see
+// pkg/cloud/uploaders to import all of them.
+func NewUploader(target, imageName string, config json.RawMessage) (Uploader, error) {
+	uploadersMu.RLock()
+	newUploader, ok := uploaders[target]
+	uploadersMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown upload target %q (available: %v)", target, UploaderTargets())
+	}
+
+	uploader, err := newUploader(imageName, config)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create uploader for target %q: %w", target, err)
+	}
+	return uploader, nil
+}
+
+// UploaderTargets returns the sorted names of the registered targets.
+func UploaderTargets() []string {
+	uploadersMu.RLock()
+	defer uploadersMu.RUnlock()
+
+	targets := make([]string, 0, len(uploaders))
+	for target := range uploaders {
+		targets = append(targets, target)
+	}
+	slices.Sort(targets)
+	return targets
+}
+
+// UnmarshalUploaderConfig decodes the configuration of an uploader into
+// the given config struct, unknown keys are an error.
+func UnmarshalUploaderConfig(data json.RawMessage, config any) error {
+	if len(data) == 0 {
+		return nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(config); err != nil {
+		return fmt.Errorf("cannot decode uploader config: %w", err)
+	}
+	return nil
+}
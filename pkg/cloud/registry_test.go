@@ -0,0 +1,61 @@
+package cloud_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/pkg/cloud"
+)
+
+type fakeUploader struct {
+	imageName string
+	config    fakeConfig
+}
+
+type fakeConfig struct {
+	Bucket string `json:"bucket"`
+}
+
+func (fu *fakeUploader) Check(status io.Writer) error {
+	return nil
+}
+
+func (fu *fakeUploader) UploadAndRegister(r io.Reader, status io.Writer) error {
+	return nil
+}
+
+func TestUploaderRegistry(t *testing.T) {
+	cloud.RegisterUploader("test-fake", func(imageName string, config json.RawMessage) (cloud.Uploader, error) {
+		fu := &fakeUploader{imageName: imageName}
+		if err := cloud.UnmarshalUploaderConfig(config, &fu.config); err != nil {
+			return nil, err
+		}
+		if fu.config.Bucket == "" {
+			return nil, fmt.Errorf("bucket is required")
+		}
+		return fu, nil
+	})
+
+	assert.Contains(t, cloud.UploaderTargets(), "test-fake")
+	assert.Panics(t, func() {
+		cloud.RegisterUploader("test-fake", nil)
+	})
+
+	uploader, err := cloud.NewUploader("test-fake", "image", json.RawMessage(`{"bucket": "b"}`))
+	require.NoError(t, err)
+	assert.Equal(t, &fakeUploader{imageName: "image", config: fakeConfig{Bucket: "b"}}, uploader)
+
+	_, err = cloud.NewUploader("test-fake", "image", nil)
+	assert.EqualError(t, err, `cannot create uploader for target "test-fake": bucket is required`)
+
+	_, err = cloud.NewUploader("test-fake", "image", json.RawMessage(`{"bucket": "b", "unknown": 1}`))
+	assert.EqualError(t, err, `cannot create uploader for target "test-fake": cannot decode uploader config: json: unknown field "unknown"`)
+
+	_, err = cloud.NewUploader("test-missing", "image", nil)
+	assert.ErrorContains(t, err, `unknown upload target "test-missing"`)
+}
@@ -0,0 +1,12 @@
+// Package uploaders registers all upload targets that are supported by
+// cloud.NewUploader(). Import it for its side effects only:
+//
+//	import _ "github.com/osbuild/images/pkg/cloud/uploaders"
+package uploaders
+
+import (
+	_ "github.com/osbuild/images/pkg/cloud/awscloud"
+	_ "github.com/osbuild/images/pkg/cloud/gcp"
+	_ "github.com/osbuild/images/pkg/upload/azure"
+	_ "github.com/osbuild/images/pkg/upload/oci"
+)
@@ -0,0 +1,14 @@
+package uploaders_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/osbuild/images/pkg/cloud"
+	_ "github.com/osbuild/images/pkg/cloud/uploaders"
+)
+
+func TestUploaderTargets(t *testing.T) {
+	assert.Equal(t, []string{"aws", "azure", "gcp", "oci", "s3"}, cloud.UploaderTargets())
+}
@@ -0,0 +1,269 @@
+package container
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"slices"
+
+	"github.com/opencontainers/go-digest"
+	imgspecs "github.com/opencontainers/image-spec/specs-go"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// metadataBlobMaxSize is the size up to which blobs are kept in memory while
+// looking for the manifest and the config of an image. Both are small JSON
+// documents, layers are usually much larger and are only streamed.
+const metadataBlobMaxSize = 1024 * 1024
+
+type ociArchiveImage struct {
+	filename   string
+	descriptor imgspecv1.Descriptor
+	blobs      []string
+}
+
+// MergeOCIArchives writes an OCI archive to dst that contains an image index
+// with the images of all the given OCI archives. Each archive must contain a
+// single image, e.g. the per-architecture builds of the same container image
+// type. The platform of each image in the index is taken from its config, so
+// that the resulting archive can be pushed to a registry as a multi-arch
+// manifest list.
+//
+// The image index is stored as a blob that is referenced by the index.json of
+// the archive, which then refers to a single (multi-arch) image that can be
+// copied without choosing an image by name.
+//
+// Blobs that are shared between images are only stored once.
+func MergeOCIArchives(dst io.Writer, archives ...string) error {
+	if len(archives) == 0 {
+		return fmt.Errorf("no OCI archives to merge")
+	}
+
+	var images []ociArchiveImage
+	for _, filename := range archives {
+		img, err := readOCIArchiveImage(filename)
+		if err != nil {
+			return fmt.Errorf("cannot read OCI archive %q: %w", filename, err)
+		}
+		for _, other := range images {
+			if platformString(other.descriptor.Platform) == platformString(img.descriptor.Platform) {
+				return fmt.Errorf("OCI archives %q and %q are both for platform %s", other.filename, filename, platformString(img.descriptor.Platform))
+			}
+		}
+		images = append(images, img)
+	}
+
+	index := imgspecv1.Index{
+		Versioned: imgspecs.Versioned{SchemaVersion: 2},
+		MediaType: imgspecv1.MediaTypeImageIndex,
+	}
+	for _, img := range images {
+		index.Manifests = append(index.Manifests, img.descriptor)
+	}
+	indexBlob, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+	indexDigest := digest.FromBytes(indexBlob)
+	indexData, err := json.Marshal(imgspecv1.Index{
+		Versioned: imgspecs.Versioned{SchemaVersion: 2},
+		MediaType: imgspecv1.MediaTypeImageIndex,
+		Manifests: []imgspecv1.Descriptor{
+			{
+				MediaType: imgspecv1.MediaTypeImageIndex,
+				Digest:    indexDigest,
+				Size:      int64(len(indexBlob)),
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	layoutData, err := json.Marshal(imgspecv1.ImageLayout{Version: imgspecv1.ImageLayoutVersion})
+	if err != nil {
+		return err
+	}
+
+	tw := tar.NewWriter(dst)
+	if err := writeTarFile(tw, imgspecv1.ImageLayoutFile, layoutData); err != nil {
+		return err
+	}
+	if err := writeTarFile(tw, "index.json", indexData); err != nil {
+		return err
+	}
+	if err := writeTarDir(tw, "blobs"); err != nil {
+		return err
+	}
+	if err := writeTarDir(tw, "blobs/sha256"); err != nil {
+		return err
+	}
+
+	indexBlobName := path.Join("blobs", indexDigest.Algorithm().String(), indexDigest.Encoded())
+	if err := writeTarFile(tw, indexBlobName, indexBlob); err != nil {
+		return err
+	}
+
+	written := []string{indexBlobName}
+	for _, img := range images {
+		if err := copyOCIArchiveBlobs(tw, img.filename, &written); err != nil {
+			return fmt.Errorf("cannot copy blobs of OCI archive %q: %w", img.filename, err)
+		}
+	}
+
+	return tw.Close()
+}
+
+// walkOCIArchive calls fn for every regular file in the archive with its
+// cleaned path.
+func walkOCIArchive(filename string, fn func(name string, hdr *tar.Header, r io.Reader) error) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := fn(path.Clean(hdr.Name), hdr, tr); err != nil {
+			return err
+		}
+	}
+}
+
+func readOCIArchiveImage(filename string) (ociArchiveImage, error) {
+	img := ociArchiveImage{filename: filename}
+
+	var indexData []byte
+	metadata := make(map[string][]byte)
+	err := walkOCIArchive(filename, func(name string, hdr *tar.Header, r io.Reader) error {
+		var err error
+		switch {
+		case name == "index.json":
+			indexData, err = io.ReadAll(r)
+		case path.Dir(name) == "blobs/sha256":
+			img.blobs = append(img.blobs, name)
+			if hdr.Size <= metadataBlobMaxSize {
+				metadata[name], err = io.ReadAll(r)
+			}
+		}
+		return err
+	})
+	if err != nil {
+		return img, err
+	}
+	if indexData == nil {
+		return img, fmt.Errorf("missing index.json")
+	}
+
+	var index imgspecv1.Index
+	if err := json.Unmarshal(indexData, &index); err != nil {
+		return img, fmt.Errorf("cannot parse index.json: %w", err)
+	}
+	if len(index.Manifests) != 1 {
+		return img, fmt.Errorf("expected exactly one image, found %d", len(index.Manifests))
+	}
+	img.descriptor = index.Manifests[0]
+	if img.descriptor.MediaType != imgspecv1.MediaTypeImageManifest {
+		return img, fmt.Errorf("unsupported manifest media type %q", img.descriptor.MediaType)
+	}
+
+	var manifest imgspecv1.Manifest
+	if err := readOCIArchiveBlob(metadata, img.descriptor.Digest, &manifest); err != nil {
+		return img, fmt.Errorf("cannot read manifest: %w", err)
+	}
+	var config imgspecv1.Image
+	if err := readOCIArchiveBlob(metadata, manifest.Config.Digest, &config); err != nil {
+		return img, fmt.Errorf("cannot read config: %w", err)
+	}
+	if config.Architecture == "" || config.OS == "" {
+		return img, fmt.Errorf("config does not specify the platform")
+	}
+
+	// the reference name is only meaningful for the single archive
+	img.descriptor.Annotations = nil
+	img.descriptor.Platform = &imgspecv1.Platform{
+		Architecture: config.Architecture,
+		OS:           config.OS,
+		Variant:      config.Variant,
+	}
+	return img, nil
+}
+
+func readOCIArchiveBlob(metadata map[string][]byte, d digest.Digest, v interface{}) error {
+	if err := d.Validate(); err != nil {
+		return err
+	}
+	data, ok := metadata[path.Join("blobs", d.Algorithm().String(), d.Encoded())]
+	if !ok {
+		return fmt.Errorf("missing blob %s", d)
+	}
+	if d.Algorithm().FromBytes(data) != d {
+		return fmt.Errorf("digest mismatch for blob %s", d)
+	}
+	return json.Unmarshal(data, v)
+}
+
+func copyOCIArchiveBlobs(tw *tar.Writer, filename string, written *[]string) error {
+	return walkOCIArchive(filename, func(name string, hdr *tar.Header, r io.Reader) error {
+		if path.Dir(name) != "blobs/sha256" || slices.Contains(*written, name) {
+			return nil
+		}
+		if err := tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     name,
+			Size:     hdr.Size,
+			Mode:     0644,
+			ModTime:  hdr.ModTime,
+		}); err != nil {
+			return err
+		}
+		if _, err := io.Copy(tw, r); err != nil {
+			return err
+		}
+		*written = append(*written, name)
+		return nil
+	})
+}
+
+func writeTarFile(tw *tar.Writer, name string, data []byte) error {
+	if err := tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Size:     int64(len(data)),
+		Mode:     0644,
+	}); err != nil {
+		return err
+	}
+	_, err := io.Copy(tw, bytes.NewReader(data))
+	return err
+}
+
+func writeTarDir(tw *tar.Writer, name string) error {
+	return tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeDir,
+		Name:     name + "/",
+		Mode:     0755,
+	})
+}
+
+func platformString(p *imgspecv1.Platform) string {
+	if p.Variant != "" {
+		return fmt.Sprintf("%s/%s/%s", p.OS, p.Architecture, p.Variant)
+	}
+	return fmt.Sprintf("%s/%s", p.OS, p.Architecture)
+}
@@ -0,0 +1,184 @@
+package container_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/containers/image/v5/transports/alltransports"
+	"github.com/opencontainers/go-digest"
+	imgspecs "github.com/opencontainers/image-spec/specs-go"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/pkg/container"
+)
+
+type testBlob struct {
+	descriptor imgspecv1.Descriptor
+	data       []byte
+}
+
+func newTestBlob(t *testing.T, mediaType string, v interface{}) testBlob {
+	var data []byte
+	if raw, ok := v.([]byte); ok {
+		data = raw
+	} else {
+		var err error
+		data, err = json.Marshal(v)
+		require.NoError(t, err)
+	}
+	return testBlob{
+		descriptor: imgspecv1.Descriptor{
+			MediaType: mediaType,
+			Digest:    digest.FromBytes(data),
+			Size:      int64(len(data)),
+		},
+		data: data,
+	}
+}
+
+// writeTestOCIArchive writes a single image OCI archive with the given layer
+// and returns its path and the digest of the manifest
+func writeTestOCIArchive(t *testing.T, dir, architecture string, layer []byte) (string, digest.Digest) {
+	layerBlob := newTestBlob(t, imgspecv1.MediaTypeImageLayer, layer)
+	configBlob := newTestBlob(t, imgspecv1.MediaTypeImageConfig, imgspecv1.Image{
+		Platform: imgspecv1.Platform{Architecture: architecture, OS: "linux"},
+		RootFS:   imgspecv1.RootFS{Type: "layers", DiffIDs: []digest.Digest{layerBlob.descriptor.Digest}},
+	})
+	manifestBlob := newTestBlob(t, imgspecv1.MediaTypeImageManifest, imgspecv1.Manifest{
+		Versioned: imgspecs.Versioned{SchemaVersion: 2},
+		MediaType: imgspecv1.MediaTypeImageManifest,
+		Config:    configBlob.descriptor,
+		Layers:    []imgspecv1.Descriptor{layerBlob.descriptor},
+	})
+	manifestDescriptor := manifestBlob.descriptor
+	manifestDescriptor.Annotations = map[string]string{imgspecv1.AnnotationRefName: "latest"}
+	index, err := json.Marshal(imgspecv1.Index{
+		Versioned: imgspecs.Versioned{SchemaVersion: 2},
+		Manifests: []imgspecv1.Descriptor{manifestDescriptor},
+	})
+	require.NoError(t, err)
+
+	filename := filepath.Join(dir, architecture+".tar")
+	f, err := os.Create(filename)
+	require.NoError(t, err)
+	defer f.Close()
+	tw := tar.NewWriter(f)
+	files := map[string][]byte{"index.json": index, "oci-layout": []byte(`{"imageLayoutVersion":"1.0.0"}`)}
+	for _, blob := range []testBlob{layerBlob, configBlob, manifestBlob} {
+		files["blobs/sha256/"+blob.descriptor.Digest.Encoded()] = blob.data
+	}
+	for name, data := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: name, Size: int64(len(data)), Mode: 0644}))
+		_, err := tw.Write(data)
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+
+	return filename, manifestBlob.descriptor.Digest
+}
+
+func readTestTar(t *testing.T, r io.Reader) map[string][]byte {
+	files := make(map[string][]byte)
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return files
+		}
+		require.NoError(t, err)
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		data, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		files[hdr.Name] = data
+	}
+}
+
+func TestMergeOCIArchives(t *testing.T) {
+	dir := t.TempDir()
+	shared := []byte("shared layer")
+	amd64Archive, amd64Manifest := writeTestOCIArchive(t, dir, "amd64", shared)
+	arm64Archive, arm64Manifest := writeTestOCIArchive(t, dir, "arm64", shared)
+
+	var buf bytes.Buffer
+	require.NoError(t, container.MergeOCIArchives(&buf, amd64Archive, arm64Archive))
+	files := readTestTar(t, &buf)
+
+	// index.json refers to the multi-arch image index
+	var topIndex imgspecv1.Index
+	require.NoError(t, json.Unmarshal(files["index.json"], &topIndex))
+	require.Len(t, topIndex.Manifests, 1)
+	assert.Equal(t, imgspecv1.MediaTypeImageIndex, topIndex.Manifests[0].MediaType)
+
+	var index imgspecv1.Index
+	require.NoError(t, json.Unmarshal(files["blobs/sha256/"+topIndex.Manifests[0].Digest.Encoded()], &index))
+	assert.Equal(t, imgspecv1.MediaTypeImageIndex, index.MediaType)
+	require.Len(t, index.Manifests, 2)
+	assert.Equal(t, amd64Manifest, index.Manifests[0].Digest)
+	assert.Equal(t, &imgspecv1.Platform{Architecture: "amd64", OS: "linux"}, index.Manifests[0].Platform)
+	assert.Equal(t, arm64Manifest, index.Manifests[1].Digest)
+	assert.Equal(t, &imgspecv1.Platform{Architecture: "arm64", OS: "linux"}, index.Manifests[1].Platform)
+	assert.Nil(t, index.Manifests[0].Annotations)
+
+	assert.Contains(t, files, "oci-layout")
+	// one shared layer, two configs, two manifests and the index
+	blobs := 0
+	for name, data := range files {
+		if filepath.Dir(name) == "blobs/sha256" {
+			blobs++
+			assert.Equal(t, filepath.Base(name), digest.FromBytes(data).Encoded())
+		}
+	}
+	assert.Equal(t, 6, blobs)
+}
+
+func TestMergeOCIArchivesMultiArchImage(t *testing.T) {
+	dir := t.TempDir()
+	amd64Archive, _ := writeTestOCIArchive(t, dir, "amd64", []byte("amd64 layer"))
+	arm64Archive, _ := writeTestOCIArchive(t, dir, "arm64", []byte("arm64 layer"))
+
+	merged := filepath.Join(dir, "merged.tar")
+	f, err := os.Create(merged)
+	require.NoError(t, err)
+	require.NoError(t, container.MergeOCIArchives(f, amd64Archive, arm64Archive))
+	require.NoError(t, f.Close())
+
+	// the archive can be read as a single multi-arch image, which is
+	// what is copied to the registry on upload
+	ref, err := alltransports.ParseImageName("oci-archive:" + merged)
+	require.NoError(t, err)
+	src, err := ref.NewImageSource(context.Background(), nil)
+	require.NoError(t, err)
+	defer src.Close()
+	_, mimeType, err := src.GetManifest(context.Background(), nil)
+	require.NoError(t, err)
+	assert.Equal(t, imgspecv1.MediaTypeImageIndex, mimeType)
+}
+
+func TestMergeOCIArchivesErrors(t *testing.T) {
+	dir := t.TempDir()
+	amd64Archive, _ := writeTestOCIArchive(t, dir, "amd64", []byte("layer"))
+
+	err := container.MergeOCIArchives(io.Discard)
+	assert.EqualError(t, err, "no OCI archives to merge")
+
+	err = container.MergeOCIArchives(io.Discard, amd64Archive, amd64Archive)
+	assert.ErrorContains(t, err, "are both for platform linux/amd64")
+
+	err = container.MergeOCIArchives(io.Discard, filepath.Join(dir, "missing.tar"))
+	assert.ErrorContains(t, err, "missing.tar")
+
+	notArchive := filepath.Join(dir, "empty.tar")
+	require.NoError(t, os.WriteFile(notArchive, nil, 0644))
+	err = container.MergeOCIArchives(io.Discard, notArchive)
+	assert.EqualError(t, err, `cannot read OCI archive "`+notArchive+`": missing index.json`)
+}
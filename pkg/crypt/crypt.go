@@ -2,25 +2,112 @@ package crypt
 
 import (
 	"crypto/rand"
+	"encoding/json"
+	"fmt"
 	"math/big"
+	"slices"
 	"strings"
+
+	"github.com/osbuild/images/internal/common"
 )
 
-// CryptSHA512 encrypts the given password with SHA512 and a random salt.
+// Algorithm is a password hashing algorithm, named like the
+// ENCRYPT_METHOD values of login.defs(5).
+type Algorithm string
+
+const (
+	AlgorithmSHA256   Algorithm = "sha256"
+	AlgorithmSHA512   Algorithm = "sha512"
+	AlgorithmYescrypt Algorithm = "yescrypt"
+
+	// DefaultAlgorithm is used when no algorithm is selected
+	DefaultAlgorithm = AlgorithmSHA512
+)
+
+var algorithms = []Algorithm{AlgorithmSHA256, AlgorithmSHA512, AlgorithmYescrypt}
+
+// Validate returns an error if the algorithm is not supported. The empty
+// algorithm is valid, it selects the DefaultAlgorithm.
+func (a Algorithm) Validate() error {
+	if a != "" && !slices.Contains(algorithms, a) {
+		return fmt.Errorf("unsupported password hash algorithm %q (must be one of %v)", a, algorithms)
+	}
+	return nil
+}
+
+func (a *Algorithm) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	algo := Algorithm(s)
+	if err := algo.Validate(); err != nil {
+		return err
+	}
+	*a = algo
+	return nil
+}
+
+func (a *Algorithm) UnmarshalYAML(unmarshal func(any) error) error {
+	return common.UnmarshalYAMLviaJSON(a, unmarshal)
+}
+
+// Crypt encrypts the given password with the given algorithm and a random
+// salt. An empty algorithm selects the DefaultAlgorithm.
 //
 // Note that this function is not deterministic.
-func CryptSHA512(phrase string) (string, error) {
-	const SHA512SaltLength = 16
+func Crypt(phrase string, algo Algorithm) (string, error) {
+	const SHASaltLength = 16
+
+	if algo == "" {
+		algo = DefaultAlgorithm
+	}
 
-	salt, err := genSalt(SHA512SaltLength)
+	// Note: update "crypt_impl_non_cgo.go" if new hash types get added
+	// that are not implemented in Go
+	var prefix string
+	switch algo {
+	case AlgorithmSHA256:
+		prefix = "$5$"
+	case AlgorithmSHA512:
+		prefix = "$6$"
+	case AlgorithmYescrypt:
+		settings, err := yescryptSettings()
+		if err != nil {
+			return "", err
+		}
+		return yescrypt(phrase, settings)
+	default:
+		return "", fmt.Errorf("unsupported password hash algorithm %q", algo)
+	}
 
+	salt, err := genSalt(SHASaltLength)
 	if err != nil {
-		return "", nil
+		return "", err
 	}
+	return crypt(phrase, prefix+salt)
+}
 
-	// Note: update "crypt_impl_non_cgo.go" if new hash types get added
-	hashSettings := "$6$" + salt
-	return crypt(phrase, hashSettings)
+// CryptSHA512 encrypts the given password with SHA512 and a random salt.
+//
+// Note that this function is not deterministic.
+func CryptSHA512(phrase string) (string, error) {
+	return Crypt(phrase, AlgorithmSHA512)
+}
+
+// CryptSHA256 encrypts the given password with SHA256 and a random salt.
+//
+// Note that this function is not deterministic.
+func CryptSHA256(phrase string) (string, error) {
+	return Crypt(phrase, AlgorithmSHA256)
+}
+
+// CryptYescrypt encrypts the given password with yescrypt, using the
+// default parameters of libxcrypt, and a random salt.
+//
+// Note that this function is not deterministic.
+func CryptYescrypt(phrase string) (string, error) {
+	return Crypt(phrase, AlgorithmYescrypt)
 }
 
 func genSalt(length int) (string, error) {
@@ -42,13 +129,15 @@ func genSalt(length int) (string, error) {
 // PasswordIsCrypted returns true if the password appears to be an encrypted
 // one, according to a very simple heuristic.
 //
-// Any string starting with one of $2$, $6$ or $5$ is considered to be
-// encrypted. Any other string is consdirede to be unencrypted.
+// Any string starting with one of $2b$, $6$, $5$, $y$, $gy$ or $7$ is
+// considered to be encrypted. Any other string is consdirede to be
+// unencrypted.
 //
 // This functionality is taken from pylorax.
 func PasswordIsCrypted(s string) bool {
 	// taken from lorax src: src/pylorax/api/compose.py:533
-	prefixes := [...]string{"$2b$", "$6$", "$5$"}
+	// plus yescrypt ($y$ and $gy$) and scrypt ($7$) as supported by libxcrypt
+	prefixes := [...]string{"$2b$", "$6$", "$5$", "$y$", "$gy$", "$7$"}
 
 	for _, prefix := range prefixes {
 		if strings.HasPrefix(s, prefix) {
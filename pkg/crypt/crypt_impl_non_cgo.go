@@ -13,15 +13,22 @@ import (
 )
 
 func crypt(pass, salt string) (string, error) {
-	// we could extract the "hash-type" here and pass it to
-	// openssl instead of hardcoding -6 but lets do that once we
-	// actually move away from sha512crypt
-	if !strings.HasPrefix(salt, "$6$") {
-		return "", fmt.Errorf("only crypt type SHA512 supported, got %q", salt)
+	// yescrypt is implemented in Go, the SHA based types are
+	// generated by openssl
+	var hashType string
+	switch {
+	case strings.HasPrefix(salt, "$y$"):
+		return yescrypt(pass, salt)
+	case strings.HasPrefix(salt, "$5$"):
+		hashType = "-5"
+	case strings.HasPrefix(salt, "$6$"):
+		hashType = "-6"
+	default:
+		return "", fmt.Errorf("only crypt types SHA256, SHA512 and yescrypt supported, got %q", salt)
 	}
 	cmd := exec.Command(
-		"openssl", "passwd", "-6",
-		// strip the $6$
+		"openssl", "passwd", hashType,
+		// strip the $5$ or $6$
 		"-salt", salt[3:],
 		"-stdin",
 	)
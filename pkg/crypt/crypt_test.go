@@ -3,9 +3,12 @@
 package crypt
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
 )
 
 func Test_crypt_PasswordIsCrypted(t *testing.T) {
@@ -30,7 +33,15 @@ func Test_crypt_PasswordIsCrypted(t *testing.T) {
 		}, {
 			name:     "scrypt",
 			password: "$7$123456789012345", //not actual hash output from scrypt
-			want:     false,
+			want:     true,
+		}, {
+			name:     "yescrypt",
+			password: "$y$j9T$saltsaltsalt$xrDDuMAdFROKSJ/d7QkiP2yBt56ZAYp42AH3eU0XVBB",
+			want:     true,
+		}, {
+			name:     "gost-yescrypt",
+			password: "$gy$j9T$saltsaltsalt$3Mcz5Grscmjrx4J3WeZIh6uu92q7U1tmAon9/Ka3de3",
+			want:     true,
 		}, {
 			name:     "plain",
 			password: "password",
@@ -55,6 +66,34 @@ func TestCryptSHA512(t *testing.T) {
 	assert.NotEqual(t, retPassFirst, retPassSecond)
 }
 
+func TestCrypt(t *testing.T) {
+	for algo, prefix := range map[Algorithm]string{
+		"":                "$6$",
+		AlgorithmSHA256:   "$5$",
+		AlgorithmSHA512:   "$6$",
+		AlgorithmYescrypt: "$y$j9T$",
+	} {
+		crypted, err := Crypt("testPass", algo)
+		assert.NoError(t, err)
+		assert.True(t, strings.HasPrefix(crypted, prefix), crypted)
+		assert.True(t, PasswordIsCrypted(crypted))
+
+		// the salt is random
+		cryptedAgain, err := Crypt("testPass", algo)
+		assert.NoError(t, err)
+		assert.NotEqual(t, crypted, cryptedAgain)
+
+		// and the hash can be verified with it
+		salt := crypted[:strings.LastIndex(crypted, "$")]
+		verified, err := crypt("testPass", salt)
+		assert.NoError(t, err)
+		assert.Equal(t, crypted, verified)
+	}
+
+	_, err := Crypt("testPass", "md5")
+	assert.EqualError(t, err, `unsupported password hash algorithm "md5"`)
+}
+
 func TestGenSalt(t *testing.T) {
 	length := 10
 	retSaltFirst, err := genSalt(length)
@@ -80,9 +119,31 @@ func TestCryptItself(t *testing.T) {
 			"$6$rounds=10000$saltstringsaltstring",
 			"$6$rounds=10000$saltstringsaltst$OW1/O6BYHV6BcXZu8QVeXbDWra3Oeqh0sbHbbMCVNSnCM/UrjmM0Dp8vOuZeHBy/YTBmSK6H9qs/y3RnOaw5v.", // notsecret
 		},
+		{
+			"Hello world!",
+			"$5$saltstring",
+			"$5$saltstring$5B8vYYiY.CVt1RlTTf8KbXBH3hsxY/GNooZaBBGWEc5", // notsecret
+		},
+		{
+			"pleaseletmein",
+			"$y$j9T$saltsaltsalt",
+			"$y$j9T$saltsaltsalt$xrDDuMAdFROKSJ/d7QkiP2yBt56ZAYp42AH3eU0XVBB", // notsecret
+		},
 	} {
 		cryptedPass, err := crypt(tc.pass, tc.salt)
 		assert.NoError(t, err)
 		assert.Equal(t, tc.expected, cryptedPass)
 	}
 }
+
+func TestAlgorithmUnmarshalYAML(t *testing.T) {
+	var cnf struct {
+		Algorithm Algorithm `yaml:"password_hash_algorithm"`
+	}
+	err := yaml.Unmarshal([]byte("password_hash_algorithm: yescrypt"), &cnf)
+	require.NoError(t, err)
+	assert.Equal(t, AlgorithmYescrypt, cnf.Algorithm)
+
+	err = yaml.Unmarshal([]byte("password_hash_algorithm: md5"), &cnf)
+	assert.ErrorContains(t, err, `unsupported password hash algorithm "md5" (must be one of [sha256 sha512 yescrypt])`)
+}
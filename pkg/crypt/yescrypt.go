@@ -0,0 +1,550 @@
+package crypt
+
+// A pure Go implementation of yescrypt as used by libxcrypt for "$y$"
+// hashes. It follows the reference implementation (yescrypt-ref.c) but
+// only supports what crypt(3) uses: the read-write mode with the
+// standard pwxform settings, without ROM and without hash upgrades.
+//
+// Unlike the other hash types this does not need cgo (or openssl), so
+// it works the same in every build.
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+	"strings"
+)
+
+const (
+	yescryptRW              = 0x002
+	yescryptRWFlavorMask    = 0x3fc
+	yescryptSupportedFlavor = 0x0b4 // ROUNDS_6 | GATHER_4 | SIMPLE_2 | SBOX_12K
+	yescryptPrehash         = 0x10000000
+
+	pwxSimple = 2
+	pwxGather = 4
+	pwxRounds = 6
+	sWidth    = 8
+
+	pwxBytes = pwxGather * pwxSimple * 8
+	pwxWords = pwxBytes / 4
+	sBytes   = 3 * (1 << sWidth) * pwxSimple * 8
+	sWords   = sBytes / 4
+	sMask    = ((1 << sWidth) - 1) * pwxSimple * 8
+
+	// the parameters of libxcrypt's default "$y$j9T$" setting:
+	// YESCRYPT_DEFAULTS, N=4096, r=32
+	yescryptDefaultParams = "j9T"
+	yescryptSaltLength    = 16
+)
+
+const itoa64 = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+func atoi64(c byte) uint32 {
+	if idx := strings.IndexByte(itoa64, c); idx >= 0 {
+		return uint32(idx)
+	}
+	return 64
+}
+
+// decode64Uint32 decodes a variable length integer of the yescrypt
+// setting string and returns the remaining input.
+func decode64Uint32(src string, min uint32) (uint32, string, error) {
+	if src == "" {
+		return 0, "", fmt.Errorf("truncated yescrypt parameters")
+	}
+	c := atoi64(src[0])
+	src = src[1:]
+	if c > 63 {
+		return 0, "", fmt.Errorf("invalid character in yescrypt parameters")
+	}
+
+	start, end, chars, nbits := uint32(0), uint32(47), 1, uint32(0)
+	dst := min
+	for c > end {
+		dst += (end + 1 - start) << nbits
+		start = end + 1
+		end = start + (62-end)/2
+		chars++
+		nbits += 6
+	}
+	dst += (c - start) << nbits
+
+	for ; chars > 1; chars-- {
+		if src == "" {
+			return 0, "", fmt.Errorf("truncated yescrypt parameters")
+		}
+		c = atoi64(src[0])
+		src = src[1:]
+		if c > 63 {
+			return 0, "", fmt.Errorf("invalid character in yescrypt parameters")
+		}
+		nbits -= 6
+		dst += c << nbits
+	}
+	return dst, src, nil
+}
+
+// decode64 decodes the little-endian base64 variant used for yescrypt
+// salts.
+func decode64(src string) ([]byte, error) {
+	var dst []byte
+	for len(src) > 0 {
+		var value, nbits uint32
+		for len(src) > 0 && nbits < 24 {
+			c := atoi64(src[0])
+			if c > 63 {
+				return nil, fmt.Errorf("invalid character %q in yescrypt salt", src[0])
+			}
+			src = src[1:]
+			value |= c << nbits
+			nbits += 6
+		}
+		// there must be at least one full byte and no left over bits
+		if nbits < 12 {
+			return nil, fmt.Errorf("invalid yescrypt salt length")
+		}
+		for ; nbits >= 8; nbits -= 8 {
+			dst = append(dst, byte(value))
+			value >>= 8
+		}
+		if value != 0 {
+			return nil, fmt.Errorf("invalid yescrypt salt encoding")
+		}
+	}
+	return dst, nil
+}
+
+// encode64 is the inverse of decode64.
+func encode64(src []byte) string {
+	var dst strings.Builder
+	for i := 0; i < len(src); {
+		var value, nbits uint32
+		for ; nbits < 24 && i < len(src); i++ {
+			value |= uint32(src[i]) << nbits
+			nbits += 8
+		}
+		for b := uint32(0); b < nbits; b += 6 {
+			dst.WriteByte(itoa64[value&0x3f])
+			value >>= 6
+		}
+	}
+	return dst.String()
+}
+
+func hmacSHA256(key, msg []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(msg)
+	return mac.Sum(nil)
+}
+
+// pbkdf2SHA256 is PBKDF2-HMAC-SHA256 with a single iteration, which is
+// all yescrypt needs.
+func pbkdf2SHA256(password, salt []byte, keyLen int) []byte {
+	var dk []byte
+	for block := uint32(1); len(dk) < keyLen; block++ {
+		mac := hmac.New(sha256.New, password)
+		mac.Write(salt)
+		mac.Write(binary.BigEndian.AppendUint32(nil, block))
+		dk = mac.Sum(dk)
+	}
+	return dk[:keyLen]
+}
+
+func salsa20(b []uint32, rounds int) {
+	var x [16]uint32
+	// SIMD unshuffle
+	for i := 0; i < 16; i++ {
+		x[i*5%16] = b[i]
+	}
+	for i := 0; i < rounds; i += 2 {
+		// columns
+		x[4] ^= bits.RotateLeft32(x[0]+x[12], 7)
+		x[8] ^= bits.RotateLeft32(x[4]+x[0], 9)
+		x[12] ^= bits.RotateLeft32(x[8]+x[4], 13)
+		x[0] ^= bits.RotateLeft32(x[12]+x[8], 18)
+		x[9] ^= bits.RotateLeft32(x[5]+x[1], 7)
+		x[13] ^= bits.RotateLeft32(x[9]+x[5], 9)
+		x[1] ^= bits.RotateLeft32(x[13]+x[9], 13)
+		x[5] ^= bits.RotateLeft32(x[1]+x[13], 18)
+		x[14] ^= bits.RotateLeft32(x[10]+x[6], 7)
+		x[2] ^= bits.RotateLeft32(x[14]+x[10], 9)
+		x[6] ^= bits.RotateLeft32(x[2]+x[14], 13)
+		x[10] ^= bits.RotateLeft32(x[6]+x[2], 18)
+		x[3] ^= bits.RotateLeft32(x[15]+x[11], 7)
+		x[7] ^= bits.RotateLeft32(x[3]+x[15], 9)
+		x[11] ^= bits.RotateLeft32(x[7]+x[3], 13)
+		x[15] ^= bits.RotateLeft32(x[11]+x[7], 18)
+		// rows
+		x[1] ^= bits.RotateLeft32(x[0]+x[3], 7)
+		x[2] ^= bits.RotateLeft32(x[1]+x[0], 9)
+		x[3] ^= bits.RotateLeft32(x[2]+x[1], 13)
+		x[0] ^= bits.RotateLeft32(x[3]+x[2], 18)
+		x[6] ^= bits.RotateLeft32(x[5]+x[4], 7)
+		x[7] ^= bits.RotateLeft32(x[6]+x[5], 9)
+		x[4] ^= bits.RotateLeft32(x[7]+x[6], 13)
+		x[5] ^= bits.RotateLeft32(x[4]+x[7], 18)
+		x[11] ^= bits.RotateLeft32(x[10]+x[9], 7)
+		x[8] ^= bits.RotateLeft32(x[11]+x[10], 9)
+		x[9] ^= bits.RotateLeft32(x[8]+x[11], 13)
+		x[10] ^= bits.RotateLeft32(x[9]+x[8], 18)
+		x[12] ^= bits.RotateLeft32(x[15]+x[14], 7)
+		x[13] ^= bits.RotateLeft32(x[12]+x[15], 9)
+		x[14] ^= bits.RotateLeft32(x[13]+x[12], 13)
+		x[15] ^= bits.RotateLeft32(x[14]+x[13], 18)
+	}
+	// SIMD shuffle
+	for i := 0; i < 16; i++ {
+		b[i] += x[i*5%16]
+	}
+}
+
+func blkxor(dst, src []uint32) {
+	for i := range dst {
+		dst[i] ^= src[i]
+	}
+}
+
+func blockmixSalsa8(b, y []uint32, r int) {
+	var x [16]uint32
+	copy(x[:], b[(2*r-1)*16:])
+	for i := 0; i < 2*r; i++ {
+		blkxor(x[:], b[i*16:])
+		salsa20(x[:], 8)
+		copy(y[i*16:], x[:])
+	}
+	for i := 0; i < r; i++ {
+		copy(b[i*16:(i+1)*16], y[(i*2)*16:])
+		copy(b[(i+r)*16:(i+r+1)*16], y[(i*2+1)*16:])
+	}
+}
+
+// pwxformCtx holds the S-boxes, which are three consecutive parts of s
+// that rotate after every pwxform round. The offsets are in 32-bit
+// words, w is in 64-bit words.
+type pwxformCtx struct {
+	s          []uint32
+	s0, s1, s2 int
+	w          int
+}
+
+func (ctx *pwxformCtx) pwxform(b []uint32) {
+	s := ctx.s
+	s0, s1, s2, w := ctx.s0, ctx.s1, ctx.s2, ctx.w
+	for i := 0; i < pwxRounds; i++ {
+		for j := 0; j < pwxGather; j++ {
+			p0 := s0 + int(b[j*pwxSimple*2]&sMask)/4
+			p1 := s1 + int(b[j*pwxSimple*2+1]&sMask)/4
+			for k := 0; k < pwxSimple; k++ {
+				idx := (j*pwxSimple + k) * 2
+				v0 := uint64(s[p0+2*k+1])<<32 + uint64(s[p0+2*k])
+				v1 := uint64(s[p1+2*k+1])<<32 + uint64(s[p1+2*k])
+				x := uint64(b[idx+1]) * uint64(b[idx])
+				x += v0
+				x ^= v1
+				b[idx] = uint32(x)
+				b[idx+1] = uint32(x >> 32)
+				if i != 0 && i != pwxRounds-1 {
+					s[s2+2*w] = uint32(x)
+					s[s2+2*w+1] = uint32(x >> 32)
+					w++
+				}
+			}
+		}
+	}
+	ctx.s0, ctx.s1, ctx.s2 = s2, s0, s1
+	ctx.w = w & ((1<<sWidth)*pwxSimple - 1)
+}
+
+func (ctx *pwxformCtx) blockmix(b []uint32, r int) {
+	var x [pwxWords]uint32
+	r1 := 128 * r / pwxBytes
+	copy(x[:], b[(r1-1)*pwxWords:])
+	for i := 0; i < r1; i++ {
+		if r1 > 1 {
+			blkxor(x[:], b[i*pwxWords:])
+		}
+		ctx.pwxform(x[:])
+		copy(b[i*pwxWords:], x[:])
+	}
+	i := (r1 - 1) * pwxBytes / 64
+	salsa20(b[i*16:], 2)
+	for i++; i < 2*r; i++ {
+		blkxor(b[i*16:(i+1)*16], b[(i-1)*16:])
+		salsa20(b[i*16:], 2)
+	}
+}
+
+func integerify(b []uint32, r int) uint64 {
+	x := b[(2*r-1)*16:]
+	// word 1 is stored at index 13 in the shuffled layout
+	return uint64(x[13])<<32 + uint64(x[0])
+}
+
+func p2floor(x uint64) uint64 {
+	for y := x & (x - 1); y != 0; y = x & (x - 1) {
+		x = y
+	}
+	return x
+}
+
+func wrap(x, i uint64) uint64 {
+	n := p2floor(i)
+	return (x & (n - 1)) + (i - n)
+}
+
+// shuffle copies b to x in the SIMD shuffled layout of smix1/smix2,
+// unshuffle copies it back.
+func shuffle(x, b []uint32, r int) {
+	for k := 0; k < 2*r; k++ {
+		for i := 0; i < 16; i++ {
+			x[k*16+i] = b[k*16+(i*5%16)]
+		}
+	}
+}
+
+func unshuffle(b, x []uint32, r int) {
+	for k := 0; k < 2*r; k++ {
+		for i := 0; i < 16; i++ {
+			b[k*16+(i*5%16)] = x[k*16+i]
+		}
+	}
+}
+
+func smix1(b []uint32, r int, n uint64, flags uint32, v, xy []uint32, ctx *pwxformCtx) {
+	s := 32 * r
+	x, y := xy[:s], xy[s:]
+	shuffle(x, b, r)
+	for i := uint64(0); i < n; i++ {
+		copy(v[i*uint64(s):], x)
+		if flags&yescryptRW != 0 && i > 1 {
+			j := wrap(integerify(x, r), i)
+			blkxor(x, v[j*uint64(s):])
+		}
+		if ctx != nil {
+			ctx.blockmix(x, r)
+		} else {
+			blockmixSalsa8(x, y, r)
+		}
+	}
+	unshuffle(b, x, r)
+}
+
+func smix2(b []uint32, r int, n, nloop uint64, flags uint32, v, xy []uint32, ctx *pwxformCtx) {
+	s := 32 * r
+	x, y := xy[:s], xy[s:]
+	shuffle(x, b, r)
+	for i := uint64(0); i < nloop; i++ {
+		j := integerify(x, r) & (n - 1)
+		blkxor(x, v[j*uint64(s):])
+		if flags&yescryptRW != 0 {
+			copy(v[j*uint64(s):], x)
+		}
+		if ctx != nil {
+			ctx.blockmix(x, r)
+		} else {
+			blockmixSalsa8(x, y, r)
+		}
+	}
+	unshuffle(b, x, r)
+}
+
+func smix(b []uint32, r int, n uint64, p, t, flags uint32, v, xy, s []uint32, passwd []byte) []byte {
+	sw := 32 * r
+	nchunk := n / uint64(p)
+	nloopAll := nchunk
+	if flags&yescryptRW != 0 {
+		if t <= 1 {
+			if t != 0 {
+				nloopAll *= 2
+			}
+			nloopAll = (nloopAll + 2) / 3
+		} else {
+			nloopAll *= uint64(t - 1)
+		}
+	} else if t != 0 {
+		if t == 1 {
+			nloopAll += (nloopAll + 1) / 2
+		}
+		nloopAll *= uint64(t)
+	}
+
+	var nloopRW uint64
+	if flags&yescryptRW != 0 {
+		nloopRW = nloopAll / uint64(p)
+	}
+
+	nchunk &^= 1
+	nloopAll = (nloopAll + 1) &^ 1
+	nloopRW = (nloopRW + 1) &^ 1
+
+	ctxs := make([]*pwxformCtx, p)
+	vchunk := uint64(0)
+	for i := uint32(0); i < p; i, vchunk = i+1, vchunk+nchunk {
+		np := nchunk
+		if i == p-1 {
+			np = n - vchunk
+		}
+		bp := b[int(i)*sw:]
+		vp := v[vchunk*uint64(sw):]
+		if flags&yescryptRW != 0 {
+			si := s[int(i)*sWords : int(i+1)*sWords]
+			smix1(bp, 1, sBytes/128, 0, si, xy, nil)
+			ctxs[i] = &pwxformCtx{
+				s:  si,
+				s2: 0,
+				s1: (1 << sWidth) * pwxSimple * 2,
+				s0: 2 * (1 << sWidth) * pwxSimple * 2,
+			}
+			if i == 0 {
+				key := make([]byte, 64)
+				for k, word := range bp[sw-16 : sw] {
+					binary.LittleEndian.PutUint32(key[k*4:], word)
+				}
+				passwd = hmacSHA256(key, passwd)
+			}
+		}
+		smix1(bp, r, np, flags, vp, xy, ctxs[i])
+		smix2(bp, r, p2floor(np), nloopRW, flags, vp, xy, ctxs[i])
+	}
+
+	for i := uint32(0); i < p; i++ {
+		smix2(b[int(i)*sw:], r, n, nloopAll-nloopRW, flags&^yescryptRW, v, xy, ctxs[i])
+	}
+	return passwd
+}
+
+func yescryptKDFBody(passwd, salt []byte, flags uint32, n uint64, r, p, t uint32) []byte {
+	s := 32 * int(r)
+	b := make([]uint32, s*int(p))
+	v := make([]uint32, n*uint64(s))
+	xy := make([]uint32, 2*s)
+	var sbox []uint32
+	if flags&yescryptRW != 0 {
+		sbox = make([]uint32, sWords*int(p))
+	}
+
+	if flags != 0 {
+		key := "yescrypt"
+		if flags&yescryptPrehash != 0 {
+			key = "yescrypt-prehash"
+		}
+		passwd = hmacSHA256([]byte(key), passwd)
+	}
+
+	bbytes := pbkdf2SHA256(passwd, salt, len(b)*4)
+	for i := range b {
+		b[i] = binary.LittleEndian.Uint32(bbytes[i*4:])
+	}
+	if flags != 0 {
+		passwd = bbytes[:32]
+	}
+
+	passwd = smix(b, int(r), n, p, t, flags, v, xy, sbox, passwd)
+
+	for i, word := range b {
+		binary.LittleEndian.PutUint32(bbytes[i*4:], word)
+	}
+	dk := pbkdf2SHA256(passwd, bbytes, 32)
+
+	if flags != 0 && flags&yescryptPrehash == 0 {
+		clientKey := hmacSHA256(dk, []byte("Client Key"))
+		storedKey := sha256.Sum256(clientKey)
+		dk = storedKey[:]
+	}
+	return dk
+}
+
+func yescryptKDF(passwd, salt []byte, flags uint32, n uint64, r, p, t uint32) []byte {
+	if flags&yescryptRW != 0 && n/uint64(p) >= 0x100 && n/uint64(p)*uint64(r) >= 0x20000 {
+		passwd = yescryptKDFBody(passwd, salt, flags|yescryptPrehash, n>>6, r, p, 0)
+	}
+	return yescryptKDFBody(passwd, salt, flags, n, r, p, t)
+}
+
+// yescrypt computes the "$y$" hash of the given password, the settings
+// are a "$y$<params>$<salt>" string.
+func yescrypt(phrase, settings string) (string, error) {
+	if !strings.HasPrefix(settings, "$y$") {
+		return "", fmt.Errorf("invalid yescrypt settings %q", settings)
+	}
+	src := settings[3:]
+
+	flavor, src, err := decode64Uint32(src, 0)
+	if err != nil {
+		return "", err
+	}
+	var flags uint32
+	switch {
+	case flavor < yescryptRW:
+		flags = flavor
+	case flavor <= yescryptRW+(yescryptRWFlavorMask>>2):
+		flags = yescryptRW + ((flavor - yescryptRW) << 2)
+	default:
+		return "", fmt.Errorf("invalid yescrypt flavor %d", flavor)
+	}
+	if flags&yescryptRW == 0 || flags&yescryptRWFlavorMask != yescryptSupportedFlavor {
+		return "", fmt.Errorf("unsupported yescrypt flavor %d", flavor)
+	}
+
+	nLog2, src, err := decode64Uint32(src, 1)
+	if err != nil {
+		return "", err
+	}
+	if nLog2 > 32 {
+		return "", fmt.Errorf("yescrypt N too large")
+	}
+	r, src, err := decode64Uint32(src, 1)
+	if err != nil {
+		return "", err
+	}
+	p, t := uint32(1), uint32(0)
+	if src != "" && src[0] != '$' {
+		var have uint32
+		have, src, err = decode64Uint32(src, 1)
+		if err != nil {
+			return "", err
+		}
+		if have&^3 != 0 {
+			return "", fmt.Errorf("unsupported yescrypt parameters")
+		}
+		if have&1 != 0 {
+			if p, src, err = decode64Uint32(src, 2); err != nil {
+				return "", err
+			}
+		}
+		if have&2 != 0 {
+			if t, src, err = decode64Uint32(src, 1); err != nil {
+				return "", err
+			}
+		}
+	}
+	if src == "" || src[0] != '$' {
+		return "", fmt.Errorf("invalid yescrypt settings %q", settings)
+	}
+	saltStr, _, _ := strings.Cut(src[1:], "$")
+	salt, err := decode64(saltStr)
+	if err != nil {
+		return "", err
+	}
+	n := uint64(1) << nLog2
+	if r == 0 || uint64(r)*uint64(p) >= 1<<30 || n/uint64(p) <= 1 {
+		return "", fmt.Errorf("invalid yescrypt parameters")
+	}
+
+	dk := yescryptKDF([]byte(phrase), salt, flags, n, r, p, t)
+	return settings[:len(settings)-len(src)] + "$" + saltStr + "$" + encode64(dk), nil
+}
+
+// yescryptSettings returns libxcrypt's default yescrypt settings with a
+// random salt.
+func yescryptSettings() (string, error) {
+	salt := make([]byte, yescryptSaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	return "$y$" + yescryptDefaultParams + "$" + encode64(salt), nil
+}
@@ -0,0 +1,58 @@
+package crypt
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestYescrypt(t *testing.T) {
+	// test vectors generated with libxcrypt
+	for _, tc := range []struct {
+		pass, settings string
+		expected       string
+	}{
+		{"pleaseletmein", "$y$j9T$saltsaltsalt", "$y$j9T$saltsaltsalt$xrDDuMAdFROKSJ/d7QkiP2yBt56ZAYp42AH3eU0XVBB"},         // notsecret
+		{"", "$y$j9T$saltsaltsalt", "$y$j9T$saltsaltsalt$fngpNhGkx4DMa5D10p0zPG3eL.oKthDF.wsC./kslu9"},                      // notsecret
+		{"x", "$y$j9T$F5Jx5fExrKuPp53xLKQ..1", "$y$j9T$F5Jx5fExrKuPp53xLKQ..1$6foLM1JhGupouWKMU70wxK61Kw9ZnecbACjJwRadqM2"}, // notsecret
+		{"password", "$y$jD5$saltsaltsalt", "$y$jD5$saltsaltsalt$JKq5O4HBl0l5SBbAaUEFvIQSsRa1hxjWEaENMzqOWu6"},              // notsecret
+		{"password", "$y$j75$saltsalt", "$y$j75$saltsalt$eWasoMp7uF0.AeHT3DZ8Zkum32fPmPJWiMVRQdwiBb4"},                      // notsecret
+		{"p", "$y$j9T$...", "$y$j9T$...$JW4XMp3r3w.p22qnD./.VUSntUUBo2RhzbC9VVpZtyB"},                                       // notsecret
+		// an existing hash can be used as settings to verify a password
+		{"pleaseletmein", "$y$j9T$saltsaltsalt$xrDDuMAdFROKSJ/d7QkiP2yBt56ZAYp42AH3eU0XVBB", "$y$j9T$saltsaltsalt$xrDDuMAdFROKSJ/d7QkiP2yBt56ZAYp42AH3eU0XVBB"}, // notsecret
+	} {
+		crypted, err := yescrypt(tc.pass, tc.settings)
+		require.NoError(t, err, tc.settings)
+		assert.Equal(t, tc.expected, crypted)
+	}
+}
+
+func TestYescryptBadSettings(t *testing.T) {
+	for _, settings := range []string{
+		"$6$saltsaltsalt",
+		"$y$",
+		"$y$j9",
+		"$y$j9T",
+		"$y$j9Tsaltsaltsalt",
+		// classic scrypt flavor
+		"$y$.9T$saltsaltsalt",
+		// invalid salts
+		"$y$j9T$s",
+		"$y$j9T$s!lt",
+		"$y$j9T$..z",
+	} {
+		_, err := yescrypt("pass", settings)
+		assert.Error(t, err, settings)
+	}
+}
+
+func TestYescryptSettings(t *testing.T) {
+	settings, err := yescryptSettings()
+	require.NoError(t, err)
+	assert.Regexp(t, `^\$y\$j9T\$[./0-9A-Za-z]{22}$`, settings)
+
+	salt, err := decode64(settings[7:])
+	require.NoError(t, err)
+	assert.Len(t, salt, yescryptSaltLength)
+}
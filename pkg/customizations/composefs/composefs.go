@@ -0,0 +1,177 @@
+package composefs
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/ini.v1"
+
+	"github.com/osbuild/images/pkg/customizations/fsnode"
+)
+
+const (
+	// PrepareRootConfPath is the configuration of ostree-prepare-root(1),
+	// which mounts the root filesystem of an ostree deployment from the
+	// initramfs. The file is part of the commit and of the initramfs.
+	PrepareRootConfPath = "/usr/lib/ostree/prepare-root.conf"
+
+	// DefaultKeyPath is the file with the public keys that verify the
+	// signed composefs digests if no KeyPath is set in the Options.
+	DefaultKeyPath = "/etc/ostree/initramfs-root-binding.key"
+)
+
+// Mode is the value of the composefs.enabled key of prepare-root.conf.
+type Mode string
+
+const (
+	// Mount the root filesystem with composefs, without verifying the
+	// content of the files
+	ModeEnabled Mode = "yes"
+	// Require fs-verity for all the files of the deployment
+	ModeVerity Mode = "verity"
+	// Require fs-verity and a composefs digest that is signed with one of
+	// the public keys in the KeyPath. Commits are not signed by the build,
+	// they must be signed with "ostree sign" before they are deployed.
+	// Deployments of unsigned commits fail to boot.
+	ModeSigned Mode = "signed"
+)
+
+var modes = []Mode{ModeEnabled, ModeVerity, ModeSigned}
+
+// The Options configure the root filesystem of ostree and bootc deployments
+// to be mounted with composefs.
+//
+// The root filesystem of disk images is created with the fs-verity feature,
+// which the verity and signed modes require for all the files of the
+// deployment.
+type Options struct {
+	Mode Mode `json:"mode" yaml:"mode"`
+
+	// Path of the file with the base64 encoded ed25519 public keys that
+	// verify the signed composefs digests (optional, defaults to
+	// DefaultKeyPath). Only used in the signed mode.
+	KeyPath string `json:"keypath,omitempty" yaml:"keypath,omitempty"`
+
+	// Base64 encoded ed25519 public key that is written to the KeyPath. The
+	// commits must be signed with the matching private key.
+	PublicKey string `json:"public_key,omitempty" yaml:"public_key,omitempty"`
+
+	// Mount a transient, writable overlay over the root filesystem.
+	// Required to create mount points in the root directory when composefs
+	// is enabled.
+	TransientRoot bool `json:"transient_root,omitempty" yaml:"transient_root,omitempty"`
+}
+
+// GetKeyPath returns the path of the file with the public keys that verify
+// the signed composefs digests.
+func (o *Options) GetKeyPath() string {
+	if o.KeyPath == "" {
+		return DefaultKeyPath
+	}
+	return o.KeyPath
+}
+
+// FSVerity returns true if the files of the deployment must have fs-verity
+// enabled.
+func (o *Options) FSVerity() bool {
+	return o.Mode == ModeVerity || o.Mode == ModeSigned
+}
+
+func (o *Options) Validate() error {
+	if !slices.Contains(modes, o.Mode) {
+		return fmt.Errorf("invalid composefs mode %q (must be one of %v)", o.Mode, modes)
+	}
+
+	if o.Mode != ModeSigned {
+		if o.KeyPath != "" || o.PublicKey != "" {
+			return fmt.Errorf("composefs keypath and public key are only supported in the %q mode", ModeSigned)
+		}
+		return nil
+	}
+
+	if o.KeyPath != "" && !filepath.IsAbs(o.KeyPath) {
+		return fmt.Errorf("composefs keypath %q must be absolute", o.KeyPath)
+	}
+	if o.PublicKey == "" {
+		return fmt.Errorf("composefs mode %q requires a public key", ModeSigned)
+	}
+	key, err := base64.StdEncoding.DecodeString(o.PublicKey)
+	if err != nil {
+		return fmt.Errorf("cannot decode composefs public key: %w", err)
+	}
+	if len(key) != ed25519.PublicKeySize {
+		return fmt.Errorf("composefs public key is not an ed25519 public key: got %d bytes, expected %d", len(key), ed25519.PublicKeySize)
+	}
+
+	return nil
+}
+
+// PrepareRootConf returns the content of prepare-root.conf.
+func (o *Options) PrepareRootConf() string {
+	var data strings.Builder
+	data.WriteString("[composefs]\n")
+	fmt.Fprintf(&data, "enabled = %s\n", o.Mode)
+	if o.Mode == ModeSigned {
+		fmt.Fprintf(&data, "keypath = %s\n", o.GetKeyPath())
+	}
+	if o.TransientRoot {
+		data.WriteString("\n[root]\ntransient = true\n")
+	}
+	return data.String()
+}
+
+// ParsePrepareRootConf returns the Options of the given prepare-root.conf, or
+// nil if it does not require composefs. Only the keys that are written by
+// PrepareRootConf() are read.
+func ParsePrepareRootConf(data []byte) (*Options, error) {
+	cfg, err := ini.Load(data)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse prepare-root.conf: %w", err)
+	}
+
+	section := cfg.Section("composefs")
+	var mode Mode
+	switch enabled := section.Key("enabled").String(); enabled {
+	case "yes", "true":
+		mode = ModeEnabled
+	case string(ModeVerity), string(ModeSigned):
+		mode = Mode(enabled)
+	case "", "maybe", "no", "false":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("invalid composefs.enabled value %q in prepare-root.conf", enabled)
+	}
+
+	opts := &Options{
+		Mode:          mode,
+		TransientRoot: cfg.Section("root").Key("transient").MustBool(false),
+	}
+	if mode == ModeSigned {
+		opts.KeyPath = section.Key("keypath").String()
+	}
+	return opts, nil
+}
+
+// Files returns prepare-root.conf and, in the signed mode, the file with the
+// public key. Both must be in the tree before the initramfs is generated.
+func (o *Options) Files() ([]*fsnode.File, error) {
+	conf, err := fsnode.NewFile(PrepareRootConfPath, nil, nil, nil, []byte(o.PrepareRootConf()))
+	if err != nil {
+		return nil, err
+	}
+	files := []*fsnode.File{conf}
+
+	if o.Mode == ModeSigned {
+		key, err := fsnode.NewFile(o.GetKeyPath(), nil, nil, nil, []byte(o.PublicKey+"\n"))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, key)
+	}
+
+	return files, nil
+}
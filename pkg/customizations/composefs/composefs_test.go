@@ -0,0 +1,179 @@
+package composefs_test
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/pkg/customizations/composefs"
+)
+
+var testPublicKey = base64.StdEncoding.EncodeToString(make([]byte, ed25519.PublicKeySize))
+
+func TestValidate(t *testing.T) {
+	testCases := []struct {
+		name        string
+		options     composefs.Options
+		expectedErr string
+	}{
+		{
+			name:    "enabled",
+			options: composefs.Options{Mode: composefs.ModeEnabled, TransientRoot: true},
+		},
+		{
+			name:    "verity",
+			options: composefs.Options{Mode: composefs.ModeVerity},
+		},
+		{
+			name:    "signed",
+			options: composefs.Options{Mode: composefs.ModeSigned, PublicKey: testPublicKey},
+		},
+		{
+			name:    "signed-keypath",
+			options: composefs.Options{Mode: composefs.ModeSigned, KeyPath: "/etc/ostree/fleet.key", PublicKey: testPublicKey},
+		},
+		{
+			name:        "no-mode",
+			expectedErr: `invalid composefs mode "" (must be one of [yes verity signed])`,
+		},
+		{
+			name:        "bad-mode",
+			options:     composefs.Options{Mode: "maybe"},
+			expectedErr: `invalid composefs mode "maybe" (must be one of [yes verity signed])`,
+		},
+		{
+			name:        "key-not-signed",
+			options:     composefs.Options{Mode: composefs.ModeVerity, PublicKey: testPublicKey},
+			expectedErr: `composefs keypath and public key are only supported in the "signed" mode`,
+		},
+		{
+			name:        "signed-no-key",
+			options:     composefs.Options{Mode: composefs.ModeSigned},
+			expectedErr: `composefs mode "signed" requires a public key`,
+		},
+		{
+			name:        "signed-relative-keypath",
+			options:     composefs.Options{Mode: composefs.ModeSigned, KeyPath: "etc/key", PublicKey: testPublicKey},
+			expectedErr: `composefs keypath "etc/key" must be absolute`,
+		},
+		{
+			name:        "signed-bad-base64",
+			options:     composefs.Options{Mode: composefs.ModeSigned, PublicKey: "not base64"},
+			expectedErr: "cannot decode composefs public key: illegal base64 data at input byte 3",
+		},
+		{
+			name:        "signed-short-key",
+			options:     composefs.Options{Mode: composefs.ModeSigned, PublicKey: "AAAA"},
+			expectedErr: "composefs public key is not an ed25519 public key: got 3 bytes, expected 32",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.options.Validate()
+			if tc.expectedErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.expectedErr)
+			}
+		})
+	}
+}
+
+func TestFSVerity(t *testing.T) {
+	assert.False(t, (&composefs.Options{Mode: composefs.ModeEnabled}).FSVerity())
+	assert.True(t, (&composefs.Options{Mode: composefs.ModeVerity}).FSVerity())
+	assert.True(t, (&composefs.Options{Mode: composefs.ModeSigned}).FSVerity())
+}
+
+func TestFilesEnabled(t *testing.T) {
+	options := composefs.Options{Mode: composefs.ModeEnabled, TransientRoot: true}
+
+	files, err := options.Files()
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.Equal(t, composefs.PrepareRootConfPath, files[0].Path())
+	assert.Equal(t, "[composefs]\nenabled = yes\n\n[root]\ntransient = true\n", string(files[0].Data()))
+}
+
+func TestFilesSigned(t *testing.T) {
+	for _, tc := range []struct {
+		keyPath         string
+		expectedKeyPath string
+	}{
+		{"", composefs.DefaultKeyPath},
+		{"/etc/ostree/fleet.key", "/etc/ostree/fleet.key"},
+	} {
+		t.Run(tc.expectedKeyPath, func(t *testing.T) {
+			options := composefs.Options{Mode: composefs.ModeSigned, KeyPath: tc.keyPath, PublicKey: testPublicKey}
+
+			files, err := options.Files()
+			require.NoError(t, err)
+			require.Len(t, files, 2)
+			assert.Equal(t, composefs.PrepareRootConfPath, files[0].Path())
+			assert.Equal(t, "[composefs]\nenabled = signed\nkeypath = "+tc.expectedKeyPath+"\n", string(files[0].Data()))
+			assert.Equal(t, tc.expectedKeyPath, files[1].Path())
+			assert.Equal(t, testPublicKey+"\n", string(files[1].Data()))
+		})
+	}
+}
+
+func TestParsePrepareRootConf(t *testing.T) {
+	testCases := []struct {
+		name        string
+		conf        string
+		expected    *composefs.Options
+		expectedErr string
+	}{
+		{
+			name: "empty",
+			conf: "",
+		},
+		{
+			name: "maybe",
+			conf: "[composefs]\nenabled = maybe\n",
+		},
+		{
+			name:     "true",
+			conf:     "[composefs]\nenabled = true\n\n[root]\ntransient = true\n",
+			expected: &composefs.Options{Mode: composefs.ModeEnabled, TransientRoot: true},
+		},
+		{
+			name:     "verity",
+			conf:     "[composefs]\nenabled = verity\n",
+			expected: &composefs.Options{Mode: composefs.ModeVerity},
+		},
+		{
+			name:     "signed",
+			conf:     "[composefs]\nenabled = signed\nkeypath = /etc/ostree/fleet.key\n",
+			expected: &composefs.Options{Mode: composefs.ModeSigned, KeyPath: "/etc/ostree/fleet.key"},
+		},
+		{
+			name:        "invalid",
+			conf:        "[composefs]\nenabled = always\n",
+			expectedErr: `invalid composefs.enabled value "always" in prepare-root.conf`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			options, err := composefs.ParsePrepareRootConf([]byte(tc.conf))
+			if tc.expectedErr != "" {
+				assert.EqualError(t, err, tc.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, options)
+		})
+	}
+}
+
+func TestParsePrepareRootConfRoundtrip(t *testing.T) {
+	options := &composefs.Options{Mode: composefs.ModeEnabled, TransientRoot: true}
+	parsed, err := composefs.ParsePrepareRootConf([]byte(options.PrepareRootConf()))
+	require.NoError(t, err)
+	assert.Equal(t, options, parsed)
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net/url"
 	"os"
+	"regexp"
 
 	"github.com/osbuild/images/internal/common"
 )
@@ -15,6 +16,8 @@ type File struct {
 	data []byte
 
 	uri string
+	// checksum of the content of a remote file, "sha256:<hex digest>"
+	checksum string
 }
 
 func (f *File) Data() []byte {
@@ -49,14 +52,24 @@ func (f *File) URI() string {
 	return f.uri
 }
 
+// Checksum returns the checksum that pins the content of a file from a
+// remote URI ("sha256:<hex digest>"), see NewFileForURI(). It is empty for
+// all other files.
+func (f *File) Checksum() string {
+	return f.checksum
+}
+
 // NewFile creates a new file with the given path, data, mode, user and group.
 // user and group can be either a string (user name/group name), an int64 (UID/GID) or nil.
 func NewFile(path string, mode *os.FileMode, user interface{}, group interface{}, data []byte) (*File, error) {
 	return newFile(path, mode, user, group, data, "")
 }
 
-// NewFleForURI creates a new file from the given "URI" (currently
-// only local file are supported).
+// NewFleForURI creates a new file from the given "URI". Local files
+// (file://) and remote files (https://) are supported. The content of a
+// remote file is fetched when the image is built and must be pinned by its
+// sha256 digest in the fragment of the URI, e.g.
+// https://example.com/bundle.tar#sha256=<hex digest>.
 func NewFileForURI(targetPath string, mode *os.FileMode, user interface{}, group interface{}, uriStr string) (*File, error) {
 	uri, err := url.Parse(uriStr)
 	if err != nil {
@@ -65,11 +78,37 @@ func NewFileForURI(targetPath string, mode *os.FileMode, user interface{}, group
 	switch uri.Scheme {
 	case "", "file":
 		return newFileForURILocalFile(targetPath, mode, user, group, uri)
+	case "https":
+		return newFileForURIRemoteFile(targetPath, mode, user, group, uri)
 	default:
 		return nil, fmt.Errorf("unsupported scheme for %v (try file://)", uri)
 	}
 }
 
+var sha256FragmentRegex = regexp.MustCompile(`^sha256=([0-9a-f]{64})$`)
+
+func newFileForURIRemoteFile(targetPath string, mode *os.FileMode, user interface{}, group interface{}, uri *url.URL) (*File, error) {
+	if uri.Host == "" {
+		return nil, fmt.Errorf("remote file %v has no host", uri)
+	}
+	match := sha256FragmentRegex.FindStringSubmatch(uri.Fragment)
+	if match == nil {
+		return nil, fmt.Errorf("remote file %v must be pinned by its digest (add #sha256=<hex digest>)", uri)
+	}
+
+	// the fragment is not part of the download url
+	downloadURI := *uri
+	downloadURI.Fragment = ""
+	downloadURI.RawFragment = ""
+
+	file, err := newFile(targetPath, mode, user, group, nil, downloadURI.String())
+	if err != nil {
+		return nil, err
+	}
+	file.checksum = "sha256:" + match[1]
+	return file, nil
+}
+
 func newFileForURILocalFile(targetPath string, mode *os.FileMode, user interface{}, group interface{}, uri *url.URL) (*File, error) {
 	st, err := os.Stat(uri.Path)
 	if err != nil {
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/osbuild/images/internal/common"
@@ -108,3 +109,25 @@ func TestNewFileForURIBadURIs(t *testing.T) {
 		assert.EqualError(t, err, tc.expectedErr)
 	}
 }
+
+func TestNewFileForURIRemote(t *testing.T) {
+	digest := strings.Repeat("a", 64)
+	file, err := NewFileForURI("/target/path", nil, nil, nil, "https://example.com/bundle.tar#sha256="+digest)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.com/bundle.tar", file.URI())
+	assert.Equal(t, "sha256:"+digest, file.Checksum())
+	assert.Nil(t, file.Data())
+
+	for _, tc := range []struct {
+		ref         string
+		expectedErr string
+	}{
+		{"https://example.com/bundle.tar", "remote file https://example.com/bundle.tar must be pinned by its digest (add #sha256=<hex digest>)"},
+		{"https://example.com/bundle.tar#md5=" + strings.Repeat("a", 32), "remote file https://example.com/bundle.tar#md5=aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa must be pinned by its digest (add #sha256=<hex digest>)"},
+		{"https:///bundle.tar#sha256=" + digest, "remote file https:///bundle.tar#sha256=" + digest + " has no host"},
+		{"http://example.com/bundle.tar#sha256=" + digest, "unsupported scheme for http://example.com/bundle.tar#sha256=" + digest + " (try file://)"},
+	} {
+		_, err := NewFileForURI("/target/path", nil, nil, nil, tc.ref)
+		assert.EqualError(t, err, tc.expectedErr)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/osbuild/images/pkg/blueprint"
+	"github.com/osbuild/images/pkg/crypt"
 	"github.com/osbuild/images/pkg/customizations/users"
 )
 
@@ -51,9 +52,9 @@ type Options struct {
 	UserFile *File
 }
 
-func New(customizations *blueprint.Customizations) (*Options, error) {
+func New(customizations *blueprint.Customizations, passwordHashAlgorithm crypt.Algorithm) (*Options, error) {
 	options := &Options{
-		Users:  users.UsersFromBP(customizations.GetUsers()),
+		Users:  users.UsersFromBP(customizations.GetUsers(), passwordHashAlgorithm),
 		Groups: users.GroupsFromBP(customizations.GetGroups()),
 	}
 
@@ -0,0 +1,158 @@
+package network
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/osbuild/images/pkg/blueprint"
+	"github.com/osbuild/images/pkg/customizations/fsnode"
+)
+
+// KeyfileDir is the directory NetworkManager loads the persistent connection
+// profiles from.
+const KeyfileDir = "/etc/NetworkManager/system-connections"
+
+// NetworkManager ignores keyfiles that are readable by anyone but root
+var keyfileMode = os.FileMode(0600)
+
+// KeyfilePath returns the path of the keyfile for the connection with the
+// given name.
+func KeyfilePath(name string) string {
+	return filepath.Join(KeyfileDir, name+".nmconnection")
+}
+
+// KeyfilesFromBP renders the connections of the network customization as
+// NetworkManager keyfiles (see nm-settings-keyfile(5)). The customization is
+// expected to be validated.
+func KeyfilesFromBP(network *blueprint.NetworkCustomization) ([]*fsnode.File, error) {
+	if network == nil {
+		return nil, nil
+	}
+
+	files := make([]*fsnode.File, 0, len(network.Connections))
+	for _, conn := range network.Connections {
+		file, err := fsnode.NewFile(KeyfilePath(conn.Name), &keyfileMode, nil, nil, []byte(keyfile(network, conn)))
+		if err != nil {
+			return nil, fmt.Errorf("cannot create keyfile for network connection %q: %w", conn.Name, err)
+		}
+		files = append(files, file)
+	}
+
+	return files, nil
+}
+
+type keyfileSection struct {
+	name string
+	keys [][2]string
+}
+
+func (s *keyfileSection) set(key, value string) {
+	s.keys = append(s.keys, [2]string{key, value})
+}
+
+func keyfile(network *blueprint.NetworkCustomization, conn blueprint.NetworkConnectionCustomization) string {
+	connection := &keyfileSection{name: "connection"}
+	connection.set("id", conn.Name)
+	connection.set("type", conn.Type)
+	if conn.Interface != "" {
+		connection.set("interface-name", conn.Interface)
+	}
+	if conn.Autoconnect != nil {
+		connection.set("autoconnect", fmt.Sprint(*conn.Autoconnect))
+	}
+	if conn.Controller != "" {
+		// "master" and "slave-type" are understood by all NetworkManager
+		// versions, the newer "controller" and "port-type" are not
+		connection.set("master", conn.Controller)
+		connection.set("slave-type", network.ControllerType(conn.Controller))
+	}
+	sections := []*keyfileSection{connection}
+
+	// the MTU is a property of the device type specific setting
+	var device *keyfileSection
+	switch conn.Type {
+	case blueprint.NetworkConnectionTypeEthernet:
+		device = &keyfileSection{name: "ethernet"}
+	case blueprint.NetworkConnectionTypeBond:
+		device = &keyfileSection{name: "bond"}
+		device.set("mode", conn.Bond.Mode)
+		for _, opt := range slices.Sorted(maps.Keys(conn.Bond.Options)) {
+			device.set(opt, conn.Bond.Options[opt])
+		}
+	case blueprint.NetworkConnectionTypeVLAN:
+		device = &keyfileSection{name: "vlan"}
+		device.set("id", fmt.Sprint(conn.VLAN.ID))
+		device.set("parent", conn.VLAN.Parent)
+	case blueprint.NetworkConnectionTypeBridge:
+		device = &keyfileSection{name: "bridge"}
+		if conn.Bridge != nil && conn.Bridge.STP != nil {
+			device.set("stp", fmt.Sprint(*conn.Bridge.STP))
+		}
+	}
+	if conn.MTU != 0 {
+		if conn.Type == blueprint.NetworkConnectionTypeEthernet {
+			device.set("mtu", fmt.Sprint(conn.MTU))
+		} else {
+			// virtual devices don't have their own mtu property
+			sections = append(sections, &keyfileSection{
+				name: "ethernet",
+				keys: [][2]string{{"mtu", fmt.Sprint(conn.MTU)}},
+			})
+		}
+	}
+	if len(device.keys) > 0 {
+		sections = append(sections, device)
+	}
+
+	// ports of a bond or bridge have no IP configuration
+	if conn.Controller == "" {
+		sections = append(sections, ipSection("ipv4", conn.IPv4), ipSection("ipv6", conn.IPv6))
+	}
+
+	var sb strings.Builder
+	for idx, section := range sections {
+		if idx > 0 {
+			sb.WriteString("\n")
+		}
+		fmt.Fprintf(&sb, "[%s]\n", section.name)
+		for _, kv := range section.keys {
+			fmt.Fprintf(&sb, "%s=%s\n", kv[0], kv[1])
+		}
+	}
+	return sb.String()
+}
+
+func ipSection(name string, ip *blueprint.NetworkIPCustomization) *keyfileSection {
+	section := &keyfileSection{name: name}
+	if ip == nil {
+		section.set("method", blueprint.NetworkIPMethodAuto)
+		return section
+	}
+
+	method := ip.Method
+	if method == "" {
+		method = blueprint.NetworkIPMethodAuto
+	}
+	section.set("method", method)
+
+	for idx, addr := range ip.Addresses {
+		value := addr
+		// the gateway is set on the first address for compatibility with
+		// older NetworkManager versions that don't know the gateway key
+		if idx == 0 && ip.Gateway != "" {
+			value += "," + ip.Gateway
+		}
+		section.set(fmt.Sprintf("address%d", idx+1), value)
+	}
+	if len(ip.DNS) > 0 {
+		section.set("dns", strings.Join(ip.DNS, ";")+";")
+	}
+	if len(ip.DNSSearch) > 0 {
+		section.set("dns-search", strings.Join(ip.DNSSearch, ";")+";")
+	}
+	return section
+}
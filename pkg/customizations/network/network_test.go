@@ -0,0 +1,155 @@
+package network_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/internal/common"
+	"github.com/osbuild/images/pkg/blueprint"
+	"github.com/osbuild/images/pkg/customizations/network"
+)
+
+func TestKeyfilesFromBPNil(t *testing.T) {
+	files, err := network.KeyfilesFromBP(nil)
+	assert.NoError(t, err)
+	assert.Nil(t, files)
+}
+
+func TestKeyfilesFromBP(t *testing.T) {
+	nc := &blueprint.NetworkCustomization{
+		Connections: []blueprint.NetworkConnectionCustomization{
+			{
+				Name:      "bond0",
+				Type:      "bond",
+				Interface: "bond0",
+				MTU:       9000,
+				Bond: &blueprint.NetworkBondCustomization{
+					Mode:    "802.3ad",
+					Options: map[string]string{"xmit_hash_policy": "layer3+4", "miimon": "100"},
+				},
+				IPv4: &blueprint.NetworkIPCustomization{
+					Method:    "manual",
+					Addresses: []string{"192.168.1.10/24", "192.168.1.11/24"},
+					Gateway:   "192.168.1.1",
+					DNS:       []string{"192.168.1.1", "1.1.1.1"},
+					DNSSearch: []string{"example.com"},
+				},
+				IPv6: &blueprint.NetworkIPCustomization{Method: "disabled"},
+			},
+			{
+				Name:        "bond0-port-eth0",
+				Type:        "ethernet",
+				Interface:   "eth0",
+				Controller:  "bond0",
+				Autoconnect: common.ToPtr(true),
+			},
+			{
+				Name:      "vlan100",
+				Type:      "vlan",
+				Interface: "bond0.100",
+				VLAN:      &blueprint.NetworkVLANCustomization{ID: 100, Parent: "bond0"},
+			},
+			{
+				Name:      "br0",
+				Type:      "bridge",
+				Interface: "br0",
+				Bridge:    &blueprint.NetworkBridgeCustomization{STP: common.ToPtr(false)},
+			},
+			{
+				Name: "wired",
+				Type: "ethernet",
+				MTU:  1400,
+			},
+		},
+	}
+	require.NoError(t, nc.Validate())
+
+	files, err := network.KeyfilesFromBP(nc)
+	require.NoError(t, err)
+	require.Len(t, files, 5)
+
+	expected := map[string]string{
+		"/etc/NetworkManager/system-connections/bond0.nmconnection": `[connection]
+id=bond0
+type=bond
+interface-name=bond0
+
+[ethernet]
+mtu=9000
+
+[bond]
+mode=802.3ad
+miimon=100
+xmit_hash_policy=layer3+4
+
+[ipv4]
+method=manual
+address1=192.168.1.10/24,192.168.1.1
+address2=192.168.1.11/24
+dns=192.168.1.1;1.1.1.1;
+dns-search=example.com;
+
+[ipv6]
+method=disabled
+`,
+		"/etc/NetworkManager/system-connections/bond0-port-eth0.nmconnection": `[connection]
+id=bond0-port-eth0
+type=ethernet
+interface-name=eth0
+autoconnect=true
+master=bond0
+slave-type=bond
+`,
+		"/etc/NetworkManager/system-connections/vlan100.nmconnection": `[connection]
+id=vlan100
+type=vlan
+interface-name=bond0.100
+
+[vlan]
+id=100
+parent=bond0
+
+[ipv4]
+method=auto
+
+[ipv6]
+method=auto
+`,
+		"/etc/NetworkManager/system-connections/br0.nmconnection": `[connection]
+id=br0
+type=bridge
+interface-name=br0
+
+[bridge]
+stp=false
+
+[ipv4]
+method=auto
+
+[ipv6]
+method=auto
+`,
+		"/etc/NetworkManager/system-connections/wired.nmconnection": `[connection]
+id=wired
+type=ethernet
+
+[ethernet]
+mtu=1400
+
+[ipv4]
+method=auto
+
+[ipv6]
+method=auto
+`,
+	}
+
+	for _, file := range files {
+		assert.Equal(t, expected[file.Path()], string(file.Data()), file.Path())
+		require.NotNil(t, file.Mode())
+		assert.Equal(t, os.FileMode(0600), *file.Mode())
+	}
+}
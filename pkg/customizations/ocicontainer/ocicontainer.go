@@ -0,0 +1,93 @@
+package ocicontainer
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// The ImageOptions specify the configuration of an OCI container image and
+// how its filesystem is split into layers.
+//
+// The configuration fields map to the fields of the same name of the OCI
+// image configuration.
+type ImageOptions struct {
+	// Default command to run when a container is started from the image.
+	Cmd []string `json:"cmd,omitempty"`
+
+	// Environment variables, each in the form KEY=VALUE.
+	Env []string `json:"env,omitempty"`
+
+	// Ports to expose, each in the form PORT[/PROTOCOL] where the protocol
+	// is one of tcp, udp or sctp (default tcp).
+	ExposedPorts []string `json:"exposed_ports,omitempty"`
+
+	// User (and optionally group) that runs the Cmd, as a name or id.
+	User string `json:"user,omitempty"`
+
+	// Arbitrary metadata of the image.
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Signal that is sent to the container to stop it, as a name (e.g.
+	// SIGTERM) or a number.
+	StopSignal string `json:"stop_signal,omitempty"`
+
+	// Absolute paths of the mount points for volumes.
+	Volumes []string `json:"volumes,omitempty"`
+
+	// Absolute path of the working directory of the Cmd.
+	WorkingDir string `json:"working_dir,omitempty"`
+
+	// Put the files and directories from the blueprint customizations in a
+	// separate layer on top of the layer with the operating system, so that
+	// the operating system layer can be shared between images. Systemd unit
+	// files stay in the operating system layer, where they are enabled.
+	CustomizationsLayer bool `json:"customizations_layer,omitempty"`
+}
+
+var (
+	exposedPortRegex = regexp.MustCompile(`^([0-9]+)(/(tcp|udp|sctp))?$`)
+	stopSignalRegex  = regexp.MustCompile(`^(SIG[A-Z0-9+-]+|[0-9]+)$`)
+)
+
+// Validate checks the options and returns an error for the first invalid
+// field.
+func (o *ImageOptions) Validate() error {
+	if o == nil {
+		return nil
+	}
+
+	for _, env := range o.Env {
+		if key, _, found := strings.Cut(env, "="); !found || key == "" {
+			return fmt.Errorf("container environment variable %q must be in the form KEY=VALUE", env)
+		}
+	}
+
+	for _, port := range o.ExposedPorts {
+		match := exposedPortRegex.FindStringSubmatch(port)
+		if match == nil {
+			return fmt.Errorf("container exposed port %q must be in the form PORT[/PROTOCOL]", port)
+		}
+		if n, err := strconv.Atoi(match[1]); err != nil || n < 1 || n > 65535 {
+			return fmt.Errorf("container exposed port %q is out of range", port)
+		}
+	}
+
+	if o.StopSignal != "" && !stopSignalRegex.MatchString(o.StopSignal) {
+		return fmt.Errorf("container stop signal %q must be a signal name or number", o.StopSignal)
+	}
+
+	for _, volume := range o.Volumes {
+		if !filepath.IsAbs(volume) {
+			return fmt.Errorf("container volume %q must be an absolute path", volume)
+		}
+	}
+
+	if o.WorkingDir != "" && !filepath.IsAbs(o.WorkingDir) {
+		return fmt.Errorf("container working directory %q must be an absolute path", o.WorkingDir)
+	}
+
+	return nil
+}
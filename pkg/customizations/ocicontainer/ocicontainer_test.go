@@ -0,0 +1,79 @@
+package ocicontainer_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/osbuild/images/pkg/customizations/ocicontainer"
+)
+
+func TestValidate(t *testing.T) {
+	for name, tc := range map[string]struct {
+		options *ocicontainer.ImageOptions
+		err     string
+	}{
+		"nil": {
+			options: nil,
+		},
+		"empty": {
+			options: &ocicontainer.ImageOptions{},
+		},
+		"full": {
+			options: &ocicontainer.ImageOptions{
+				Cmd:          []string{"/usr/bin/httpd", "-DFOREGROUND"},
+				Env:          []string{"LANG=C.UTF-8", "EMPTY="},
+				ExposedPorts: []string{"80", "443/tcp", "53/udp", "9/sctp"},
+				User:         "1000:1000",
+				Labels:       map[string]string{"org.opencontainers.image.title": "httpd"},
+				StopSignal:   "SIGWINCH",
+				Volumes:      []string{"/var/www"},
+				WorkingDir:   "/var/www",
+			},
+		},
+		"numeric-stop-signal": {
+			options: &ocicontainer.ImageOptions{StopSignal: "28"},
+		},
+		"env-no-value": {
+			options: &ocicontainer.ImageOptions{Env: []string{"LANG"}},
+			err:     `container environment variable "LANG" must be in the form KEY=VALUE`,
+		},
+		"env-no-key": {
+			options: &ocicontainer.ImageOptions{Env: []string{"=C"}},
+			err:     `container environment variable "=C" must be in the form KEY=VALUE`,
+		},
+		"port-protocol": {
+			options: &ocicontainer.ImageOptions{ExposedPorts: []string{"80/http"}},
+			err:     `container exposed port "80/http" must be in the form PORT[/PROTOCOL]`,
+		},
+		"port-range": {
+			options: &ocicontainer.ImageOptions{ExposedPorts: []string{"65536"}},
+			err:     `container exposed port "65536" is out of range`,
+		},
+		"port-zero": {
+			options: &ocicontainer.ImageOptions{ExposedPorts: []string{"0/udp"}},
+			err:     `container exposed port "0/udp" is out of range`,
+		},
+		"stop-signal": {
+			options: &ocicontainer.ImageOptions{StopSignal: "TERM"},
+			err:     `container stop signal "TERM" must be a signal name or number`,
+		},
+		"volume-relative": {
+			options: &ocicontainer.ImageOptions{Volumes: []string{"data"}},
+			err:     `container volume "data" must be an absolute path`,
+		},
+		"workdir-relative": {
+			options: &ocicontainer.ImageOptions{WorkingDir: "www"},
+			err:     `container working directory "www" must be an absolute path`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			err := tc.options.Validate()
+			if tc.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.err)
+			}
+		})
+	}
+}
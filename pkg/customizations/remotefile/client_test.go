@@ -37,6 +37,16 @@ func TestClientResolve(t *testing.T) {
 	assert.Equal(t, expectedOutput, string(output))
 }
 
+func TestClientResolveNotFound(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+	url := server.URL + "/missing"
+
+	client := NewClient()
+	_, err := client.Resolve(url)
+	assert.EqualError(t, err, fmt.Sprintf("File resolver: %s returned 404 Not Found", url))
+}
+
 func TestInputSpecValidation(t *testing.T) {
 	server := makeTestServer()
 
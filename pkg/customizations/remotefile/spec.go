@@ -1,9 +1,35 @@
 package remotefile
 
-import "github.com/osbuild/images/internal/worker/clienterrors"
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/osbuild/images/internal/worker/clienterrors"
+)
 
 type Spec struct {
 	URL             string
 	Content         []byte
 	ResolutionError *clienterrors.Error
 }
+
+// SourceSpec is a remote file that is fetched when the image is built. Its
+// content is pinned by the checksum ("sha256:<hex digest>").
+type SourceSpec struct {
+	URL      string
+	Checksum string
+}
+
+var checksumRegex = regexp.MustCompile(`^sha256:[0-9a-f]{64}$`)
+
+// Validate checks that the source has a URL and is pinned by a valid
+// checksum.
+func (s SourceSpec) Validate() error {
+	if s.URL == "" {
+		return fmt.Errorf("remote file has no url")
+	}
+	if !checksumRegex.MatchString(s.Checksum) {
+		return fmt.Errorf("remote file %s has an invalid checksum %q (must be \"sha256:<hex digest>\")", s.URL, s.Checksum)
+	}
+	return nil
+}
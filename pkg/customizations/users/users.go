@@ -1,6 +1,9 @@
 package users
 
-import "github.com/osbuild/images/pkg/blueprint"
+import (
+	"github.com/osbuild/images/pkg/blueprint"
+	"github.com/osbuild/images/pkg/crypt"
+)
 
 type User struct {
 	Name               string
@@ -14,6 +17,10 @@ type User struct {
 	GID                *int
 	ExpireDate         *int
 	ForcePasswordReset *bool
+
+	// PasswordHashAlgorithm is used to hash a plain text Password, an
+	// empty value selects crypt.DefaultAlgorithm
+	PasswordHashAlgorithm crypt.Algorithm
 }
 
 type Group struct {
@@ -21,12 +28,27 @@ type Group struct {
 	GID  *int
 }
 
-func UsersFromBP(userCustomizations []blueprint.UserCustomization) []User {
+// UsersFromBP converts the blueprint user customizations, plain text
+// passwords of the users will be hashed with the given algorithm.
+func UsersFromBP(userCustomizations []blueprint.UserCustomization, passwordHashAlgorithm crypt.Algorithm) []User {
 	users := make([]User, len(userCustomizations))
-	for idx := range userCustomizations {
-		// currently, they have the same structure, so we convert directly
-		// this will fail to compile as soon as one of the two changes
-		users[idx] = User(userCustomizations[idx])
+	for idx, uc := range userCustomizations {
+		// User has all the fields of the blueprint user customization,
+		// TestUsersFromBPCopiesAllFields fails if one is not copied
+		users[idx] = User{
+			Name:                  uc.Name,
+			Description:           uc.Description,
+			Password:              uc.Password,
+			Key:                   uc.Key,
+			Home:                  uc.Home,
+			Shell:                 uc.Shell,
+			Groups:                uc.Groups,
+			UID:                   uc.UID,
+			GID:                   uc.GID,
+			ExpireDate:            uc.ExpireDate,
+			ForcePasswordReset:    uc.ForcePasswordReset,
+			PasswordHashAlgorithm: passwordHashAlgorithm,
+		}
 	}
 	return users
 }
@@ -0,0 +1,73 @@
+package users_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/internal/common"
+	"github.com/osbuild/images/pkg/blueprint"
+	"github.com/osbuild/images/pkg/crypt"
+	"github.com/osbuild/images/pkg/customizations/users"
+)
+
+func TestUsersFromBP(t *testing.T) {
+	uc := blueprint.UserCustomization{
+		Name:     "user",
+		Password: common.ToPtr("password"),
+		Groups:   []string{"wheel"},
+		UID:      common.ToPtr(1000),
+	}
+	expected := []users.User{
+		{
+			Name:                  "user",
+			Password:              common.ToPtr("password"),
+			Groups:                []string{"wheel"},
+			UID:                   common.ToPtr(1000),
+			PasswordHashAlgorithm: crypt.AlgorithmYescrypt,
+		},
+	}
+	assert.Equal(t, expected, users.UsersFromBP([]blueprint.UserCustomization{uc}, crypt.AlgorithmYescrypt))
+}
+
+// nonZero returns a non-zero value of the type t
+func nonZero(t *testing.T, typ reflect.Type) reflect.Value {
+	switch typ.Kind() {
+	case reflect.Pointer:
+		ptr := reflect.New(typ.Elem())
+		ptr.Elem().Set(nonZero(t, typ.Elem()))
+		return ptr
+	case reflect.Slice:
+		return reflect.Append(reflect.MakeSlice(typ, 0, 1), nonZero(t, typ.Elem()))
+	case reflect.String:
+		return reflect.ValueOf("value").Convert(typ)
+	case reflect.Int:
+		return reflect.ValueOf(42).Convert(typ)
+	case reflect.Bool:
+		return reflect.ValueOf(true).Convert(typ)
+	default:
+		require.FailNow(t, "unsupported field type", "add a non-zero value for %s", typ)
+		return reflect.Value{}
+	}
+}
+
+// UsersFromBP does not convert the user customizations directly, so a
+// field that is added to them has to be added to User and copied as well
+func TestUsersFromBPCopiesAllFields(t *testing.T) {
+	var uc blueprint.UserCustomization
+	ucValue := reflect.ValueOf(&uc).Elem()
+	for idx := 0; idx < ucValue.NumField(); idx++ {
+		ucValue.Field(idx).Set(nonZero(t, ucValue.Field(idx).Type()))
+	}
+
+	user := users.UsersFromBP([]blueprint.UserCustomization{uc}, "")[0]
+	userValue := reflect.ValueOf(user)
+	for idx := 0; idx < ucValue.NumField(); idx++ {
+		name := ucValue.Type().Field(idx).Name
+		field := userValue.FieldByName(name)
+		require.True(t, field.IsValid(), "users.User has no field %s", name)
+		assert.Equal(t, ucValue.Field(idx).Interface(), field.Interface(), "field %s is not copied", name)
+	}
+}
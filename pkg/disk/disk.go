@@ -66,6 +66,11 @@ const (
 	UsrPartitionPpc64leGUID = "15BB03AF-77E7-4D4A-B12B-C0D084F7491C" // SD_GPT_USR_PPC64_LE
 	UsrPartitionS390xGUID   = "8A4F5770-50AA-4ED3-874A-99B710DB6FEA" // SD_GPT_USR_S390X
 
+	RootVerityPartitionX86_64GUID  = "2C7357ED-EBD2-46D9-AEC1-23D437EC2BF5" // SD_GPT_ROOT_X86_64_VERITY
+	RootVerityPartitionAarch64GUID = "DF3300CE-D69F-4C92-978C-9BFB0F38D820" // SD_GPT_ROOT_ARM64_VERITY
+	RootVerityPartitionPpc64leGUID = "906BD944-4589-4AAE-A4E4-DD983917446A" // SD_GPT_ROOT_PPC64_LE_VERITY
+	RootVerityPartitionS390xGUID   = "B325BFBE-C7BE-4AB8-8357-139E652D2F6B" // SD_GPT_ROOT_S390X_VERITY
+
 	// Partition type IDs for DOS disks
 
 	// Partition type ID for BIOS boot partition on dos.
@@ -167,6 +172,21 @@ func getPartitionTypeIDfor(ptType PartitionTableType, partTypeName string, archi
 			default:
 				return "", fmt.Errorf("unknown or unsupported architecture enum value: %d", architecture)
 			}
+		case "root-verity":
+			switch architecture {
+			case arch.ARCH_X86_64:
+				return RootVerityPartitionX86_64GUID, nil
+			case arch.ARCH_AARCH64:
+				return RootVerityPartitionAarch64GUID, nil
+			case arch.ARCH_PPC64LE:
+				return RootVerityPartitionPpc64leGUID, nil
+			case arch.ARCH_S390X:
+				return RootVerityPartitionS390xGUID, nil
+			case arch.ARCH_UNSET:
+				return "", fmt.Errorf("architecture must be specified for selecting GUID for %q partition", partTypeName)
+			default:
+				return "", fmt.Errorf("unknown or unsupported architecture enum value: %d", architecture)
+			}
 		default:
 			return "", fmt.Errorf("unknown or unsupported partition type name: %s", partTypeName)
 		}
@@ -187,6 +207,7 @@ const (
 	FS_EXT4
 	FS_XFS
 	FS_BTRFS
+	FS_EROFS
 )
 
 func (f FSType) String() string {
@@ -201,6 +222,8 @@ func (f FSType) String() string {
 		return "xfs"
 	case FS_BTRFS:
 		return "btrfs"
+	case FS_EROFS:
+		return "erofs"
 	default:
 		panic(fmt.Sprintf("unknown or unsupported filesystem type with enum value %d", f))
 	}
@@ -236,11 +259,26 @@ func NewFSType(s string) (FSType, error) {
 		return FS_XFS, nil
 	case "btrfs":
 		return FS_BTRFS, nil
+	case "erofs":
+		return FS_EROFS, nil
 	default:
 		return FS_NONE, fmt.Errorf("unknown or unsupported filesystem type name: %s", s)
 	}
 }
 
+// ReadOnly returns true for filesystems that are created from a tree when
+// the image is built and cannot be written to afterwards.
+func (f FSType) ReadOnly() bool {
+	return f == FS_EROFS
+}
+
+// IsReadOnlyFSType returns true if the filesystem type name is the name of a
+// read-only filesystem, see FSType.ReadOnly().
+func IsReadOnlyFSType(s string) bool {
+	fsType, err := NewFSType(s)
+	return err == nil && fsType.ReadOnly()
+}
+
 // PartitionTableType is the partition table type enum.
 type PartitionTableType uint64
 
@@ -85,6 +85,7 @@ func TestEnumFSType(t *testing.T) {
 		"ext4":  disk.FS_EXT4,
 		"xfs":   disk.FS_XFS,
 		"btrfs": disk.FS_BTRFS,
+		"erofs": disk.FS_EROFS,
 	}
 
 	assert := assert.New(t)
@@ -99,10 +100,20 @@ func TestEnumFSType(t *testing.T) {
 	}
 
 	// error test: bad value
-	badFst := disk.FSType(5)
-	assert.PanicsWithValue("unknown or unsupported filesystem type with enum value 5", func() { _ = badFst.String() })
+	badFst := disk.FSType(6)
+	assert.PanicsWithValue("unknown or unsupported filesystem type with enum value 6", func() { _ = badFst.String() })
 
 	// error test: bad name
 	_, err := disk.NewFSType("not-a-type")
 	assert.EqualError(err, "unknown or unsupported filesystem type name: not-a-type")
 }
+
+func TestFSTypeReadOnly(t *testing.T) {
+	assert.True(t, disk.FS_EROFS.ReadOnly())
+	assert.True(t, disk.IsReadOnlyFSType("erofs"))
+	for _, fsType := range []disk.FSType{disk.FS_NONE, disk.FS_VFAT, disk.FS_EXT4, disk.FS_XFS, disk.FS_BTRFS} {
+		assert.False(t, fsType.ReadOnly())
+		assert.False(t, disk.IsReadOnlyFSType(fsType.String()))
+	}
+	assert.False(t, disk.IsReadOnlyFSType("not-a-type"))
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"math/rand"
 	"reflect"
+	"strings"
 
 	"github.com/google/uuid"
 )
@@ -129,13 +130,32 @@ func (fs *Filesystem) GetFSTabOptions() (FSTabOptions, error) {
 	if fs == nil {
 		return FSTabOptions{}, nil
 	}
+	mntops := fs.FSTabOptions
+	if IsReadOnlyFSType(fs.Type) {
+		mntops = readOnlyMntOps(mntops)
+	}
 	return FSTabOptions{
-		MntOps: fs.FSTabOptions,
+		MntOps: mntops,
 		Freq:   fs.FSTabFreq,
 		PassNo: fs.FSTabPassNo,
 	}, nil
 }
 
+// readOnlyMntOps returns the mount options with "ro" instead of "rw" (or
+// "defaults", which implies "rw") so the filesystem is not remounted
+// writable.
+func readOnlyMntOps(mntops string) string {
+	opts := []string{"ro"}
+	for _, opt := range strings.Split(mntops, ",") {
+		switch opt {
+		case "", "ro", "rw", "defaults":
+		default:
+			opts = append(opts, opt)
+		}
+	}
+	return strings.Join(opts, ",")
+}
+
 func (fs *Filesystem) GenUUID(rng *rand.Rand) {
 	if fs.Type == "vfat" && fs.UUID == "" {
 		// vfat has no uuids, it has "serial numbers" (volume IDs)
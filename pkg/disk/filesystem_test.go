@@ -45,3 +45,29 @@ func TestMkfsOptionUnmarshalSad(t *testing.T) {
 	err := json.Unmarshal([]byte(`"invalid-mkfs-option"`), &opt)
 	assert.EqualError(t, err, `invalid mkfsoption: invalid-mkfs-option`)
 }
+
+func TestFilesystemReadOnlyFSTabOptions(t *testing.T) {
+	for _, tc := range []struct {
+		mntops   string
+		expected string
+	}{
+		{"", "ro"},
+		{"defaults", "ro"},
+		{"ro", "ro"},
+		{"rw,noatime", "ro,noatime"},
+		{"defaults,x-systemd.growfs", "ro,x-systemd.growfs"},
+	} {
+		t.Run(tc.mntops, func(t *testing.T) {
+			fs := &disk.Filesystem{Type: "erofs", Mountpoint: "/", FSTabOptions: tc.mntops}
+			options, err := fs.GetFSTabOptions()
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, options.MntOps)
+		})
+	}
+
+	// writable filesystems keep their options
+	fs := &disk.Filesystem{Type: "ext4", Mountpoint: "/", FSTabOptions: "defaults"}
+	options, err := fs.GetFSTabOptions()
+	assert.NoError(t, err)
+	assert.Equal(t, "defaults", options.MntOps)
+}
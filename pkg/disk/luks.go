@@ -1,6 +1,7 @@
 package disk
 
 import (
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"math/rand"
@@ -9,6 +10,7 @@ import (
 	"github.com/google/uuid"
 
 	"github.com/osbuild/images/internal/common"
+	"github.com/osbuild/images/pkg/blueprint"
 	"github.com/osbuild/images/pkg/datasizes"
 )
 
@@ -52,6 +54,59 @@ type LUKSContainer struct {
 	Payload Entity `json:"payload,omitempty" yaml:"payload,omitempty"`
 }
 
+// newLUKSContainer returns a LUKSContainer for the given payload that is
+// configured according to the encryption customization. Random passphrases
+// are generated from rng. The customization must already be validated.
+func newLUKSContainer(enc *blueprint.EncryptionCustomization, payload Entity, rng *rand.Rand) *LUKSContainer {
+	lc := &LUKSContainer{
+		Passphrase: enc.Passphrase,
+		Cipher:     enc.Cipher,
+		// The cryptsetup default memory cost and parallelism. The
+		// iterations are not benchmarked on the build host like
+		// cryptsetup does to keep the manifest reproducible.
+		PBKDF: Argon2id{
+			Iterations:  4,
+			Memory:      1048576,
+			Parallelism: 4,
+		},
+		Payload: payload,
+	}
+	if enc.PassphraseSource == blueprint.PassphraseSourceRandom {
+		buf := make([]byte, 32)
+		// (*rand.Rand).Read always returns len(buf) and a nil error
+		_, _ = rng.Read(buf)
+		lc.Passphrase = hex.EncodeToString(buf)
+		// the passphrase is removed at the end of the build, so
+		// there is no point in making it expensive to brute-force
+		lc.PBKDF = Argon2id{
+			Iterations:  4,
+			Memory:      32,
+			Parallelism: 1,
+		}
+	}
+	if enc.Clevis != nil {
+		policy := enc.Clevis.Policy
+		if policy == "" {
+			policy = "{}"
+		}
+		lc.Clevis = &ClevisBind{
+			Pin:              enc.Clevis.Pin,
+			Policy:           policy,
+			RemovePassphrase: enc.Clevis.RemovePassphrase,
+		}
+	}
+	return lc
+}
+
+// unwrapLUKS returns the payload of a LUKSContainer, or the entity itself if
+// it is not a LUKSContainer.
+func unwrapLUKS(e Entity) Entity {
+	if lc, ok := e.(*LUKSContainer); ok {
+		return lc.Payload
+	}
+	return e
+}
+
 func init() {
 	payloadEntityMap["luks"] = reflect.TypeOf(LUKSContainer{})
 }
@@ -1,9 +1,11 @@
 package disk
 
 import (
+	"encoding/json"
 	"fmt"
 	"math/rand"
 	"path/filepath"
+	"slices"
 
 	"github.com/google/uuid"
 
@@ -113,6 +115,13 @@ const DefaultBootPartitionSize = 1 * datasizes.GiB
 func NewPartitionTable(basePT *PartitionTable, mountpoints []blueprint.FilesystemCustomization, imageSize uint64, mode PartitioningMode, architecture arch.Arch, requiredSizes map[string]uint64, rng *rand.Rand) (*PartitionTable, error) {
 	newPT := basePT.Clone().(*PartitionTable)
 
+	if err := basePT.validateVerity(); err != nil {
+		return nil, err
+	}
+	if err := basePT.validateReadOnlyFilesystems(); err != nil {
+		return nil, err
+	}
+
 	if basePT.features().LVM && (mode == RawPartitioningMode || mode == BtrfsPartitioningMode) {
 		return nil, fmt.Errorf("%s partitioning mode set for a base partition table with LVM, this is unsupported", mode)
 	}
@@ -167,6 +176,12 @@ func NewPartitionTable(basePT *PartitionTable, mountpoints []blueprint.Filesyste
 	// Calculate partition table offsets and sizes
 	newPT.relayout(imageSize)
 
+	// The hash partitions are sized from the final size of their data
+	// partitions, which can only shrink on the second pass
+	if newPT.ensureVerityHashSizes() {
+		newPT.relayout(imageSize)
+	}
+
 	// Generate new UUIDs for filesystems and partitions
 	newPT.GenerateUUIDs(rng)
 
@@ -662,6 +677,72 @@ func (pt *PartitionTable) FindMountable(mountpoint string) Mountable {
 	return path[0].(Mountable)
 }
 
+// ReadOnlyRoot returns true if the root filesystem is a read-only
+// filesystem (see FSType.ReadOnly()) that is created from the OS tree
+// instead of having the tree copied onto it.
+func (pt *PartitionTable) ReadOnlyRoot() bool {
+	root := pt.FindMountable("/")
+	return root != nil && IsReadOnlyFSType(root.GetFSType())
+}
+
+// ReadOnlyRootPartition returns the partition of the read-only root
+// filesystem, or nil if the root filesystem is not read-only. The
+// filesystem is created from the tree without a stable UUID, so the system
+// finds it by the UUID of the partition instead.
+func (pt *PartitionTable) ReadOnlyRootPartition() *Partition {
+	if !pt.ReadOnlyRoot() {
+		return nil
+	}
+	path := entityPath(pt, "/")
+	if len(path) < 2 {
+		return nil
+	}
+	part, _ := path[1].(*Partition)
+	return part
+}
+
+// EnableRootFSVerity creates the root filesystem with the fs-verity feature,
+// e.g. for the files of a composefs deployment. Only ext4 root filesystems
+// support the feature.
+func (pt *PartitionTable) EnableRootFSVerity() error {
+	root := pt.FindMountable("/")
+	if root == nil {
+		return fmt.Errorf("root filesystem not found in partition table")
+	}
+	fs, ok := root.(*Filesystem)
+	if !ok || fs.Type != "ext4" {
+		return fmt.Errorf("fs-verity requires an ext4 root filesystem, got %s", root.GetFSType())
+	}
+	if !slices.Contains(fs.MkfsOptions, MkfsVerity) {
+		// the options can be shared with the original of a cloned table
+		fs.MkfsOptions = append(slices.Clip(fs.MkfsOptions), MkfsVerity)
+	}
+	return nil
+}
+
+// validateReadOnlyFilesystems checks that read-only filesystems are only used
+// for the root filesystem, that the boot loader can find the kernels on a
+// separate /boot filesystem and that the system can find the root
+// filesystem by the UUID of its partition (see ReadOnlyRootPartition()).
+func (pt *PartitionTable) validateReadOnlyFilesystems() error {
+	return pt.ForEachMountable(func(mnt Mountable, path []Entity) error {
+		if !IsReadOnlyFSType(mnt.GetFSType()) {
+			return nil
+		}
+		if mnt.GetMountpoint() != "/" {
+			return fmt.Errorf("read-only filesystem %s is only supported for the root filesystem, not for %q", mnt.GetFSType(), mnt.GetMountpoint())
+		}
+		boot := pt.FindMountable("/boot")
+		if boot == nil || IsReadOnlyFSType(boot.GetFSType()) {
+			return fmt.Errorf("read-only root filesystem %s requires a separate writable /boot filesystem", mnt.GetFSType())
+		}
+		if _, ok := path[1].(*Partition); !ok || pt.Type != PT_GPT {
+			return fmt.Errorf("read-only root filesystem %s must be on a partition of a gpt partition table", mnt.GetFSType())
+		}
+		return nil
+	})
+}
+
 func clampFSSize(mountpoint string, size uint64) uint64 {
 	// set a minimum size of 1GB for all mountpoints
 	// with the exception for '/boot' (= 500 MB)
@@ -866,13 +947,15 @@ func (pt *PartitionTable) ensureBtrfs(architecture arch.Arch) error {
 }
 
 type partitionTableFeatures struct {
-	LVM   bool
-	Btrfs bool
-	XFS   bool
-	FAT   bool
-	EXT4  bool
-	LUKS  bool
-	Swap  bool
+	LVM    bool
+	Btrfs  bool
+	XFS    bool
+	FAT    bool
+	EXT4   bool
+	EROFS  bool
+	LUKS   bool
+	Swap   bool
+	Verity bool
 }
 
 // features examines all of the PartitionTable entities and returns a struct
@@ -900,11 +983,15 @@ func (pt *PartitionTable) features() partitionTableFeatures {
 				ptFeatures.XFS = true
 			case "ext4":
 				ptFeatures.EXT4 = true
+			case "erofs":
+				ptFeatures.EROFS = true
 			}
 		case *Swap:
 			ptFeatures.Swap = true
 		case *LUKSContainer:
 			ptFeatures.LUKS = true
+		case *Verity:
+			ptFeatures.Verity = true
 		case *PartitionTable, *Partition:
 			// nothing to do
 		default:
@@ -938,6 +1025,9 @@ func (pt *PartitionTable) GetBuildPackages() []string {
 	if features.EXT4 {
 		packages = append(packages, "e2fsprogs")
 	}
+	if features.EROFS {
+		packages = append(packages, "erofs-utils")
+	}
 	if features.LUKS {
 		packages = append(packages,
 			"clevis",
@@ -945,10 +1035,70 @@ func (pt *PartitionTable) GetBuildPackages() []string {
 			"cryptsetup",
 		)
 	}
+	if features.Verity {
+		packages = append(packages, "veritysetup")
+	}
 
 	return packages
 }
 
+// GetOSPackages returns an array of packages that the OS needs to boot from
+// the PartitionTable, in addition to the ones from GetBuildPackages(). LUKS
+// containers that are bound with clevis are unlocked in the initramfs, which
+// needs the clevis dracut module and the clevis pins of the bindings.
+func (pt *PartitionTable) GetOSPackages() []string {
+	packages := []string{}
+
+	_ = pt.ForEachEntity(func(e Entity, path []Entity) error {
+		lc, ok := e.(*LUKSContainer)
+		if !ok || lc.Clevis == nil {
+			return nil
+		}
+		if !slices.Contains(packages, "clevis-dracut") {
+			packages = append(packages, "clevis-dracut")
+		}
+		for _, pin := range clevisPins(lc.Clevis) {
+			pkg := "clevis-pin-" + pin
+			if !slices.Contains(packages, pkg) {
+				packages = append(packages, pkg)
+			}
+		}
+		return nil
+	})
+
+	return packages
+}
+
+// clevisPins returns the pins that the clevis binding uses. The "sss" pin is
+// part of clevis itself and combines the pins that are listed in its policy.
+func clevisPins(cb *ClevisBind) []string {
+	if cb.Pin != "sss" {
+		return []string{cb.Pin}
+	}
+	var policy struct {
+		Pins map[string]json.RawMessage `json:"pins"`
+	}
+	if err := json.Unmarshal([]byte(cb.Policy), &policy); err != nil {
+		return nil
+	}
+	var pins []string
+	for pin, nested := range policy.Pins {
+		if pin == "sss" {
+			// nested sss policies are a list of policies
+			var nestedPolicies []json.RawMessage
+			if err := json.Unmarshal(nested, &nestedPolicies); err == nil {
+				for _, np := range nestedPolicies {
+					pins = append(pins, clevisPins(&ClevisBind{Pin: pin, Policy: string(np)})...)
+				}
+			}
+			continue
+		}
+		pins = append(pins, pin)
+	}
+	slices.Sort(pins)
+	return pins
+}
+
 // GetMountpointSize takes a mountpoint and returns the size of the entity this
 // mountpoint belongs to.
 func (pt *PartitionTable) GetMountpointSize(mountpoint string) (uint64, error) {
@@ -1000,7 +1150,7 @@ func EnsureRootFilesystem(pt *PartitionTable, defaultFsType FSType, architecture
 	}
 
 	for _, part := range pt.Partitions {
-		switch payload := part.Payload.(type) {
+		switch payload := unwrapLUKS(part.Payload).(type) {
 		case *LVMVolumeGroup:
 			if defaultFsType == FS_NONE {
 				return fmt.Errorf("error creating root logical volume: no default filesystem type")
@@ -1338,15 +1488,15 @@ func NewCustomPartitionTable(customizations *blueprint.DiskCustomization, option
 
 		switch part.Type {
 		case "plain", "":
-			if err := addPlainPartition(pt, part, options); err != nil {
+			if err := addPlainPartition(pt, part, options, rng); err != nil {
 				return nil, fmt.Errorf("%s %w", errPrefix, err)
 			}
 		case "lvm":
-			if err := addLVMPartition(pt, part, options); err != nil {
+			if err := addLVMPartition(pt, part, options, rng); err != nil {
 				return nil, fmt.Errorf("%s %w", errPrefix, err)
 			}
 		case "btrfs":
-			if err := addBtrfsPartition(pt, part); err != nil {
+			if err := addBtrfsPartition(pt, part, rng); err != nil {
 				return nil, fmt.Errorf("%s %w", errPrefix, err)
 			}
 		default:
@@ -1378,7 +1528,7 @@ func NewCustomPartitionTable(customizations *blueprint.DiskCustomization, option
 	return pt, nil
 }
 
-func addPlainPartition(pt *PartitionTable, partition blueprint.PartitionCustomization, options *CustomPartitionTableOptions) error {
+func addPlainPartition(pt *PartitionTable, partition blueprint.PartitionCustomization, options *CustomPartitionTableOptions, rng *rand.Rand) error {
 	fstype, err := options.getfstype(partition.FSType)
 	if err != nil {
 		return fmt.Errorf("error creating partition with mountpoint %q: %w", partition.Mountpoint, err)
@@ -1426,6 +1576,10 @@ func addPlainPartition(pt *PartitionTable, partition blueprint.PartitionCustomiz
 		}
 	}
 
+	if partition.Encryption != nil {
+		payload = newLUKSContainer(partition.Encryption, payload, rng)
+	}
+
 	newpart := Partition{
 		Type:    partType,
 		UUID:    partition.PartUUID,
@@ -1437,13 +1591,13 @@ func addPlainPartition(pt *PartitionTable, partition blueprint.PartitionCustomiz
 	return nil
 }
 
-func addLVMPartition(pt *PartitionTable, partition blueprint.PartitionCustomization, options *CustomPartitionTableOptions) error {
+func addLVMPartition(pt *PartitionTable, partition blueprint.PartitionCustomization, options *CustomPartitionTableOptions, rng *rand.Rand) error {
 	vgname := partition.Name
 	if vgname == "" {
 		// get existing volume groups and generate unique name
 		existing := make(map[string]bool)
 		for _, part := range pt.Partitions {
-			vg, ok := part.Payload.(*LVMVolumeGroup)
+			vg, ok := unwrapLUKS(part.Payload).(*LVMVolumeGroup)
 			if !ok {
 				continue
 			}
@@ -1500,19 +1654,24 @@ func addLVMPartition(pt *PartitionTable, partition blueprint.PartitionCustomizat
 		}
 	}
 
+	var payload PayloadEntity = newvg
+	if partition.Encryption != nil {
+		payload = newLUKSContainer(partition.Encryption, newvg, rng)
+	}
+
 	newpart := Partition{
 		Type:     partType,
 		UUID:     partition.PartUUID,
 		Label:    partition.PartLabel,
 		Size:     partition.MinSize,
 		Bootable: false,
-		Payload:  newvg,
+		Payload:  payload,
 	}
 	pt.Partitions = append(pt.Partitions, newpart)
 	return nil
 }
 
-func addBtrfsPartition(pt *PartitionTable, partition blueprint.PartitionCustomization) error {
+func addBtrfsPartition(pt *PartitionTable, partition blueprint.PartitionCustomization, rng *rand.Rand) error {
 	subvols := make([]BtrfsSubvolume, len(partition.Subvolumes))
 	for idx, subvol := range partition.Subvolumes {
 		newsubvol := BtrfsSubvolume{
@@ -1535,12 +1694,17 @@ func addBtrfsPartition(pt *PartitionTable, partition blueprint.PartitionCustomiz
 			return fmt.Errorf("error creating btrfs partition: %w", err)
 		}
 	}
+	var payload PayloadEntity = newvol
+	if partition.Encryption != nil {
+		payload = newLUKSContainer(partition.Encryption, newvol, rng)
+	}
+
 	newpart := Partition{
 		Type:     partType,
 		UUID:     partition.PartUUID,
 		Label:    partition.PartLabel,
 		Bootable: false,
-		Payload:  newvol,
+		Payload:  payload,
 		Size:     partition.MinSize,
 	}
 
@@ -1552,6 +1716,7 @@ func addBtrfsPartition(pt *PartitionTable, partition blueprint.PartitionCustomiz
 // partition is needed if any of the following conditions apply:
 //   - / is on LVM or btrfs and /boot is not defined.
 //   - / is not defined and btrfs or lvm volumes are defined.
+//   - / is on an encrypted plain partition and /boot is not defined.
 //
 // In the second case, a root partition will be created automatically on either
 // btrfs or lvm.
@@ -1560,12 +1725,17 @@ func needsBoot(disk *blueprint.DiskCustomization) bool {
 		return false
 	}
 
-	var foundBtrfsOrLVM bool
+	var foundBtrfsOrLVM, foundEncryptedRoot bool
 	for _, part := range disk.Partitions {
 		switch part.Type {
 		case "plain", "":
 			if part.Mountpoint == "/" {
-				return false
+				if part.Encryption == nil {
+					return false
+				}
+				// the bootloader cannot read the kernel from an
+				// encrypted root
+				foundEncryptedRoot = true
 			}
 			if part.Mountpoint == "/boot" {
 				return false
@@ -1590,5 +1760,5 @@ func needsBoot(disk *blueprint.DiskCustomization) bool {
 			// NOTE: invalid types should be validated elsewhere
 		}
 	}
-	return foundBtrfsOrLVM
+	return foundBtrfsOrLVM || foundEncryptedRoot
 }
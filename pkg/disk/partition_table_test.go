@@ -2777,6 +2777,191 @@ func TestNewCustomPartitionTableErrors(t *testing.T) {
 	}
 }
 
+func TestNewCustomPartitionTableEncryption(t *testing.T) {
+	options := &disk.CustomPartitionTableOptions{
+		DefaultFSType:      disk.FS_XFS,
+		BootMode:           platform.BOOT_UEFI,
+		PartitionTableType: disk.PT_GPT,
+		Architecture:       arch.ARCH_X86_64,
+	}
+
+	t.Run("plain-root", func(t *testing.T) {
+		customizations := &blueprint.DiskCustomization{
+			Partitions: []blueprint.PartitionCustomization{
+				{
+					MinSize: 5 * datasizes.GiB,
+					Encryption: &blueprint.EncryptionCustomization{
+						Cipher:     "aes-xts-plain64",
+						Passphrase: "secret",
+						Clevis: &blueprint.ClevisCustomization{
+							Pin: "tpm2",
+						},
+					},
+					FilesystemTypedCustomization: blueprint.FilesystemTypedCustomization{
+						Mountpoint: "/",
+						FSType:     "ext4",
+					},
+				},
+			},
+		}
+
+		/* #nosec G404 */
+		pt, err := disk.NewCustomPartitionTable(customizations, options, rand.New(rand.NewSource(0)))
+		require.NoError(t, err)
+
+		// the kernel cannot be read from the encrypted root
+		require.NotNil(t, pt.FindMountable("/boot"))
+
+		root := pt.Partitions[len(pt.Partitions)-1]
+		luks, ok := root.Payload.(*disk.LUKSContainer)
+		require.True(t, ok, "root payload is %T, not a LUKS container", root.Payload)
+		assert.Equal(t, "secret", luks.Passphrase)
+		assert.Equal(t, "aes-xts-plain64", luks.Cipher)
+		assert.Equal(t, &disk.ClevisBind{Pin: "tpm2", Policy: "{}"}, luks.Clevis)
+		assert.Equal(t, disk.Argon2id{Iterations: 4, Memory: 1048576, Parallelism: 4}, luks.PBKDF)
+		assert.NotEmpty(t, luks.UUID)
+
+		fs, ok := luks.Payload.(*disk.Filesystem)
+		require.True(t, ok, "LUKS payload is %T, not a filesystem", luks.Payload)
+		assert.Equal(t, "/", fs.Mountpoint)
+		assert.Equal(t, "ext4", fs.Type)
+
+		assert.GreaterOrEqual(t, root.Size, uint64(5*datasizes.GiB))
+	})
+
+	t.Run("plain-root-with-boot", func(t *testing.T) {
+		customizations := &blueprint.DiskCustomization{
+			Partitions: []blueprint.PartitionCustomization{
+				{
+					MinSize: 5 * datasizes.GiB,
+					Encryption: &blueprint.EncryptionCustomization{
+						Passphrase: "secret",
+					},
+					FilesystemTypedCustomization: blueprint.FilesystemTypedCustomization{
+						Mountpoint: "/",
+						FSType:     "ext4",
+					},
+				},
+				{
+					MinSize: 1 * datasizes.GiB,
+					FilesystemTypedCustomization: blueprint.FilesystemTypedCustomization{
+						Mountpoint: "/boot",
+						Label:      "myboot",
+						FSType:     "ext4",
+					},
+				},
+			},
+		}
+
+		/* #nosec G404 */
+		pt, err := disk.NewCustomPartitionTable(customizations, options, rand.New(rand.NewSource(0)))
+		require.NoError(t, err)
+
+		// no extra /boot partition is added
+		assert.Len(t, pt.Partitions, 3)
+		assert.Equal(t, "myboot", pt.FindMountable("/boot").GetFSSpec().Label)
+	})
+
+	t.Run("lvm-random-passphrase", func(t *testing.T) {
+		customizations := &blueprint.DiskCustomization{
+			Partitions: []blueprint.PartitionCustomization{
+				{
+					Type:    "lvm",
+					MinSize: 10 * datasizes.GiB,
+					Encryption: &blueprint.EncryptionCustomization{
+						PassphraseSource: blueprint.PassphraseSourceRandom,
+						Clevis: &blueprint.ClevisCustomization{
+							Pin:              "tang",
+							Policy:           `{"url":"http://tang.example.com"}`,
+							RemovePassphrase: true,
+						},
+					},
+					VGCustomization: blueprint.VGCustomization{
+						Name: "rootvg",
+						LogicalVolumes: []blueprint.LVCustomization{
+							{
+								Name:    "homelv",
+								MinSize: 2 * datasizes.GiB,
+								FilesystemTypedCustomization: blueprint.FilesystemTypedCustomization{
+									Mountpoint: "/home",
+									FSType:     "xfs",
+								},
+							},
+						},
+					},
+				},
+			},
+		}
+
+		/* #nosec G404 */
+		pt, err := disk.NewCustomPartitionTable(customizations, options, rand.New(rand.NewSource(0)))
+		require.NoError(t, err)
+
+		require.NotNil(t, pt.FindMountable("/boot"))
+
+		last := pt.Partitions[len(pt.Partitions)-1]
+		luks, ok := last.Payload.(*disk.LUKSContainer)
+		require.True(t, ok, "partition payload is %T, not a LUKS container", last.Payload)
+		assert.Regexp(t, "^[0-9a-f]{64}$", luks.Passphrase)
+		assert.Equal(t, &disk.ClevisBind{
+			Pin:              "tang",
+			Policy:           `{"url":"http://tang.example.com"}`,
+			RemovePassphrase: true,
+		}, luks.Clevis)
+
+		vg, ok := luks.Payload.(*disk.LVMVolumeGroup)
+		require.True(t, ok, "LUKS payload is %T, not a volume group", luks.Payload)
+		assert.Equal(t, "rootvg", vg.Name)
+
+		// the root logical volume is created in the encrypted volume group
+		var mountpoints []string
+		for _, lv := range vg.LogicalVolumes {
+			mountpoints = append(mountpoints, lv.Payload.(disk.Mountable).GetMountpoint())
+		}
+		assert.ElementsMatch(t, []string{"/home", "/"}, mountpoints)
+
+		// the passphrase is the same for the same seed
+		/* #nosec G404 */
+		pt2, err := disk.NewCustomPartitionTable(customizations, options, rand.New(rand.NewSource(0)))
+		require.NoError(t, err)
+		assert.Equal(t, luks.Passphrase, pt2.Partitions[len(pt2.Partitions)-1].Payload.(*disk.LUKSContainer).Passphrase)
+	})
+
+	t.Run("btrfs", func(t *testing.T) {
+		customizations := &blueprint.DiskCustomization{
+			Partitions: []blueprint.PartitionCustomization{
+				{
+					Type:    "btrfs",
+					MinSize: 10 * datasizes.GiB,
+					Encryption: &blueprint.EncryptionCustomization{
+						Passphrase: "secret",
+					},
+					BtrfsVolumeCustomization: blueprint.BtrfsVolumeCustomization{
+						Subvolumes: []blueprint.BtrfsSubvolumeCustomization{
+							{
+								Name:       "home",
+								Mountpoint: "/home",
+							},
+						},
+					},
+				},
+			},
+		}
+
+		/* #nosec G404 */
+		pt, err := disk.NewCustomPartitionTable(customizations, options, rand.New(rand.NewSource(0)))
+		require.NoError(t, err)
+
+		last := pt.Partitions[len(pt.Partitions)-1]
+		luks, ok := last.Payload.(*disk.LUKSContainer)
+		require.True(t, ok, "partition payload is %T, not a LUKS container", last.Payload)
+		btrfs, ok := luks.Payload.(*disk.Btrfs)
+		require.True(t, ok, "LUKS payload is %T, not a btrfs volume", luks.Payload)
+		assert.Len(t, btrfs.Subvolumes, 2)
+		assert.True(t, disk.GetPartitionTableFeatures(*pt).LUKS)
+	})
+}
+
 func TestPartitionTableFeatures(t *testing.T) {
 	require := require.New(t)
 
@@ -2799,6 +2984,40 @@ func TestPartitionTableFeatures(t *testing.T) {
 	}
 }
 
+func TestPartitionTableGetOSPackages(t *testing.T) {
+	testCases := map[string]struct {
+		clevis   *disk.ClevisBind
+		expected []string
+	}{
+		"no-clevis": {
+			expected: []string{},
+		},
+		"tpm2": {
+			clevis:   &disk.ClevisBind{Pin: "tpm2", Policy: "{}"},
+			expected: []string{"clevis-dracut", "clevis-pin-tpm2"},
+		},
+		"sss": {
+			clevis: &disk.ClevisBind{
+				Pin:    "sss",
+				Policy: `{"t": 1, "pins": {"tpm2": {}, "sss": [{"t": 1, "pins": {"tang": [{"url": "http://tang.example.com"}]}}]}}`,
+			},
+			expected: []string{"clevis-dracut", "clevis-pin-tang", "clevis-pin-tpm2"},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			pt := testdisk.TestPartitionTables()["luks"]
+			for _, part := range pt.Partitions {
+				if lc, ok := part.Payload.(*disk.LUKSContainer); ok {
+					lc.Clevis = tc.clevis
+				}
+			}
+			assert.Equal(t, tc.expected, pt.GetOSPackages())
+		})
+	}
+}
+
 func TestUnmarshalSizeUnitStringPartitionTable(t *testing.T) {
 	testCases := []struct {
 		name     string
@@ -2852,3 +3071,66 @@ func TestUnmarshalSizeUnitStringPartitionTable(t *testing.T) {
 		})
 	}
 }
+
+func TestReadOnlyRoot(t *testing.T) {
+	pt := testdisk.MakeFakeEROFSPartitionTable("/", "/boot", "/var")
+	assert.True(t, pt.ReadOnlyRoot())
+	assert.Contains(t, pt.GetBuildPackages(), "erofs-utils")
+
+	assert.False(t, testdisk.MakeFakePartitionTable("/", "/boot").ReadOnlyRoot())
+	assert.False(t, testdisk.MakeFakePartitionTable("/boot").ReadOnlyRoot())
+}
+
+func TestEnableRootFSVerity(t *testing.T) {
+	pt := testdisk.MakeFakePartitionTable("/", "/boot")
+	clone := pt.Clone().(*disk.PartitionTable)
+	require.NoError(t, clone.EnableRootFSVerity())
+	require.NoError(t, clone.EnableRootFSVerity())
+	assert.Equal(t, []disk.MkfsOption{disk.MkfsVerity}, clone.FindMountable("/").(*disk.Filesystem).MkfsOptions)
+	assert.Nil(t, clone.FindMountable("/boot").(*disk.Filesystem).MkfsOptions)
+	assert.Nil(t, pt.FindMountable("/").(*disk.Filesystem).MkfsOptions)
+
+	err := testdisk.MakeFakeBtrfsPartitionTable("/", "/boot").EnableRootFSVerity()
+	assert.EqualError(t, err, "fs-verity requires an ext4 root filesystem, got btrfs")
+
+	err = testdisk.MakeFakePartitionTable("/boot").EnableRootFSVerity()
+	assert.EqualError(t, err, "root filesystem not found in partition table")
+}
+
+func TestNewPartitionTableReadOnly(t *testing.T) {
+	// math/rand is good enough in this case
+	/* #nosec G404 */
+	rng := rand.New(rand.NewSource(0))
+
+	basePT := testdisk.MakeFakeEROFSPartitionTable("/boot/efi", "/boot", "/")
+	pt, err := disk.NewPartitionTable(basePT, []blueprint.FilesystemCustomization{{Mountpoint: "/var", MinSize: 2 * datasizes.GiB}}, 0, disk.RawPartitioningMode, arch.ARCH_X86_64, nil, rng)
+	require.NoError(t, err)
+	assert.True(t, pt.ReadOnlyRoot())
+	// new mountpoints are writable
+	assert.Equal(t, "xfs", pt.FindMountable("/var").GetFSType())
+
+	for name, tc := range map[string]struct {
+		pt          *disk.PartitionTable
+		expectedErr string
+	}{
+		"no-boot": {
+			pt:          testdisk.MakeFakeEROFSPartitionTable("/boot/efi", "/"),
+			expectedErr: "read-only root filesystem erofs requires a separate writable /boot filesystem",
+		},
+		"not-root": {
+			pt: &disk.PartitionTable{
+				Type: disk.PT_GPT,
+				Partitions: []disk.Partition{
+					{Size: 1 * datasizes.GiB, Payload: &disk.Filesystem{Type: "ext4", Mountpoint: "/"}},
+					{Size: 1 * datasizes.GiB, Payload: &disk.Filesystem{Type: "erofs", Mountpoint: "/usr"}},
+				},
+			},
+			expectedErr: `read-only filesystem erofs is only supported for the root filesystem, not for "/usr"`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := disk.NewPartitionTable(tc.pt, nil, 0, disk.RawPartitioningMode, arch.ARCH_X86_64, nil, rng)
+			assert.EqualError(t, err, tc.expectedErr)
+		})
+	}
+}
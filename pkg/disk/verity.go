@@ -0,0 +1,139 @@
+package disk
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Verity defines the payload of a dm-verity hash partition. The partition
+// holds the hash tree of the partition of the filesystem at Mountpoint,
+// which must be a read-only filesystem (see FSType.ReadOnly()) directly on a
+// partition. The root hash of the tree is computed when the image is built.
+type Verity struct {
+	// Mountpoint of the protected filesystem, only "/" is supported.
+	Mountpoint string `json:"mountpoint" yaml:"mountpoint"`
+}
+
+func init() {
+	payloadEntityMap["verity"] = reflect.TypeOf(Verity{})
+}
+
+func (v *Verity) EntityName() string {
+	return "verity"
+}
+
+func (v *Verity) Clone() Entity {
+	if v == nil {
+		return nil
+	}
+
+	return &Verity{
+		Mountpoint: v.Mountpoint,
+	}
+}
+
+const (
+	// dm-verity defaults of veritysetup(8)
+	verityBlockSize = 4096
+	verityHashSize  = 32 // sha256
+)
+
+// VerityHashTreeSize returns the size (in bytes) of the hash device that
+// veritysetup(8) needs for a data device of dataSize bytes with the default
+// parameters: the superblock plus all levels of the hash tree.
+func VerityHashTreeSize(dataSize uint64) uint64 {
+	hashesPerBlock := uint64(verityBlockSize / verityHashSize)
+
+	size := uint64(verityBlockSize) // superblock
+	blocks := (dataSize + verityBlockSize - 1) / verityBlockSize
+	for blocks > 1 {
+		blocks = (blocks + hashesPerBlock - 1) / hashesPerBlock
+		size += blocks * verityBlockSize
+	}
+	return size
+}
+
+// VerityPartitions returns the data partition of the filesystem at the given
+// mountpoint and the hash partition that protects it, or nil for both if the
+// filesystem is not protected by dm-verity.
+func (pt *PartitionTable) VerityPartitions(mountpoint string) (data *Partition, hash *Partition) {
+	for idx := range pt.Partitions {
+		part := &pt.Partitions[idx]
+		if verity, ok := part.Payload.(*Verity); ok && verity.Mountpoint == mountpoint {
+			hash = part
+		}
+		if mnt, ok := part.Payload.(Mountable); ok && mnt.GetMountpoint() == mountpoint {
+			data = part
+		}
+	}
+	if data == nil || hash == nil {
+		return nil, nil
+	}
+	return data, hash
+}
+
+// VerityRoot returns true if the root filesystem is protected by dm-verity.
+func (pt *PartitionTable) VerityRoot() bool {
+	data, _ := pt.VerityPartitions("/")
+	return data != nil
+}
+
+// validateVerity checks that dm-verity hash partitions protect a read-only
+// root filesystem on a partition of a GPT partition table.
+func (pt *PartitionTable) validateVerity() error {
+	var found bool
+	return pt.ForEachEntity(func(e Entity, path []Entity) error {
+		verity, ok := e.(*Verity)
+		if !ok {
+			return nil
+		}
+		if found {
+			return fmt.Errorf("only one dm-verity hash partition is supported")
+		}
+		found = true
+
+		if pt.Type != PT_GPT {
+			return fmt.Errorf("dm-verity requires a gpt partition table")
+		}
+		if len(path) != 3 {
+			return fmt.Errorf("dm-verity hash tree must be on a partition")
+		}
+		if verity.Mountpoint != "/" {
+			return fmt.Errorf("dm-verity is only supported for the root filesystem, not for %q", verity.Mountpoint)
+		}
+		mnt := pt.FindMountable(verity.Mountpoint)
+		if mnt == nil {
+			return fmt.Errorf("dm-verity protected filesystem %q not found in partition table", verity.Mountpoint)
+		}
+		if !IsReadOnlyFSType(mnt.GetFSType()) {
+			return fmt.Errorf("dm-verity protected filesystem %q must be read-only, not %s", verity.Mountpoint, mnt.GetFSType())
+		}
+		if data, _ := pt.VerityPartitions(verity.Mountpoint); data == nil {
+			return fmt.Errorf("dm-verity protected filesystem %q must be on a partition", verity.Mountpoint)
+		}
+		return nil
+	})
+}
+
+// ensureVerityHashSizes grows the dm-verity hash partitions so that they can
+// hold the hash tree of their data partition. Returns true if a partition was
+// resized.
+func (pt *PartitionTable) ensureVerityHashSizes() bool {
+	var resized bool
+	for idx := range pt.Partitions {
+		part := &pt.Partitions[idx]
+		verity, ok := part.Payload.(*Verity)
+		if !ok {
+			continue
+		}
+		data, _ := pt.VerityPartitions(verity.Mountpoint)
+		if data == nil {
+			continue
+		}
+		if size := pt.AlignUp(VerityHashTreeSize(data.Size)); part.Size < size {
+			part.Size = size
+			resized = true
+		}
+	}
+	return resized
+}
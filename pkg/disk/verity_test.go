@@ -0,0 +1,109 @@
+package disk_test
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/internal/testdisk"
+	"github.com/osbuild/images/pkg/arch"
+	"github.com/osbuild/images/pkg/datasizes"
+	"github.com/osbuild/images/pkg/disk"
+)
+
+func TestVerityHashTreeSize(t *testing.T) {
+	// superblock only
+	assert.Equal(t, uint64(4096), disk.VerityHashTreeSize(4096))
+	// superblock and a single hash block for up to 128 data blocks
+	assert.Equal(t, uint64(2*4096), disk.VerityHashTreeSize(128*4096))
+	// 1 GiB: 262144 data blocks, 2048 + 16 + 1 hash blocks
+	assert.Equal(t, uint64((1+2048+16+1)*4096), disk.VerityHashTreeSize(1*datasizes.GiB))
+}
+
+func TestVerityPartitions(t *testing.T) {
+	pt := testdisk.MakeFakeVerityPartitionTable("/boot", "/")
+	data, hash := pt.VerityPartitions("/")
+	require.NotNil(t, data)
+	require.NotNil(t, hash)
+	assert.Equal(t, &pt.Partitions[1], data)
+	assert.Equal(t, &pt.Partitions[2], hash)
+	assert.True(t, pt.VerityRoot())
+
+	data, hash = pt.VerityPartitions("/boot")
+	assert.Nil(t, data)
+	assert.Nil(t, hash)
+
+	assert.False(t, testdisk.MakeFakeEROFSPartitionTable("/boot", "/").VerityRoot())
+	assert.Contains(t, pt.GetBuildPackages(), "veritysetup")
+}
+
+func TestNewPartitionTableVerity(t *testing.T) {
+	// math/rand is good enough in this case
+	/* #nosec G404 */
+	rng := rand.New(rand.NewSource(0))
+
+	basePT := testdisk.MakeFakeVerityPartitionTable("/boot/efi", "/boot", "/")
+	basePT.Partitions[3].Size = 1 * datasizes.MiB
+	pt, err := disk.NewPartitionTable(basePT, nil, 20*datasizes.GiB, disk.RawPartitioningMode, arch.ARCH_X86_64, nil, rng)
+	require.NoError(t, err)
+
+	// the hash partition grows with the root partition
+	data, hash := pt.VerityPartitions("/")
+	require.NotNil(t, data)
+	assert.Greater(t, data.Size, uint64(18*datasizes.GiB))
+	assert.Equal(t, pt.AlignUp(disk.VerityHashTreeSize(data.Size)), hash.Size)
+	assert.Less(t, hash.Start, data.Start)
+
+	for name, tc := range map[string]struct {
+		pt          *disk.PartitionTable
+		expectedErr string
+	}{
+		"dos": {
+			pt: func() *disk.PartitionTable {
+				pt := testdisk.MakeFakeVerityPartitionTable("/boot", "/")
+				pt.Type = disk.PT_DOS
+				return pt
+			}(),
+			expectedErr: "dm-verity requires a gpt partition table",
+		},
+		"writable": {
+			pt: func() *disk.PartitionTable {
+				pt := testdisk.MakeFakeVerityPartitionTable("/boot", "/")
+				pt.FindMountable("/").(*disk.Filesystem).Type = "ext4"
+				return pt
+			}(),
+			expectedErr: `dm-verity protected filesystem "/" must be read-only, not ext4`,
+		},
+		"not-root": {
+			pt: func() *disk.PartitionTable {
+				pt := testdisk.MakeFakeVerityPartitionTable("/boot", "/")
+				pt.Partitions[2].Payload = &disk.Verity{Mountpoint: "/boot"}
+				return pt
+			}(),
+			expectedErr: `dm-verity is only supported for the root filesystem, not for "/boot"`,
+		},
+		"two": {
+			pt: func() *disk.PartitionTable {
+				pt := testdisk.MakeFakeVerityPartitionTable("/boot", "/")
+				pt.Partitions = append(pt.Partitions, pt.Partitions[2])
+				return pt
+			}(),
+			expectedErr: "only one dm-verity hash partition is supported",
+		},
+		"in-luks": {
+			pt: func() *disk.PartitionTable {
+				pt := testdisk.MakeFakeVerityPartitionTable("/boot", "/")
+				pt.Partitions[2].Payload = &disk.LUKSContainer{Payload: &disk.Verity{Mountpoint: "/"}}
+				return pt
+			}(),
+			expectedErr: "dm-verity hash tree must be on a partition",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := disk.NewPartitionTable(tc.pt, nil, 0, disk.RawPartitioningMode, arch.ARCH_X86_64, nil, rng)
+			assert.EqualError(t, err, tc.expectedErr)
+		})
+	}
+}
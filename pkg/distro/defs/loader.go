@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"io/fs"
+	"maps"
 	"os"
 	"path/filepath"
 	"slices"
@@ -396,6 +397,10 @@ type ImageTypeYAML struct {
 	InternalPlatforms []platform.PlatformConf `yaml:"platforms"`
 	PlatformsOverride *platformsOverride      `yaml:"platforms_override"`
 
+	// Bootloaders that can be selected with the bootloader customization
+	// instead of the bootloader of the platforms, keyed by bootloader name
+	Bootloaders map[string]*bootloaderYAML `yaml:"bootloaders"`
+
 	NameAliases []string `yaml:"name_aliases"`
 
 	InstallWeakDeps *bool `yaml:"install_weak_deps"`
@@ -480,9 +485,64 @@ func (it *ImageTypeYAML) runTemplates(distro *DistroYAML) error {
 			}
 		}
 	}
+	for _, bl := range it.Bootloaders {
+		for idx := range bl.Platforms {
+			newVendor, err := subs(bl.Platforms[idx].UEFIVendor)
+			if err != nil {
+				return err
+			}
+			bl.Platforms[idx].UEFIVendor = newVendor
+		}
+	}
 	return nil
 }
 
+// bootloaderYAML describes what an image type needs to boot with an
+// alternative bootloader
+type bootloaderYAML struct {
+	// Platforms replace the platform of the image type with the same
+	// arch, the image format of the image type is kept
+	Platforms []platform.PlatformConf `yaml:"platforms"`
+	// ImageConfig is shallow merged into the image config of the image
+	// type
+	ImageConfig *distro.ImageConfig `yaml:"image_config,omitempty"`
+	// PartitionTables replace the partition tables of the image type
+	PartitionTables map[string]*disk.PartitionTable `yaml:"partition_table"`
+	// PackageSets are appended to the package sets of the image type
+	PackageSets map[string][]packageSet `yaml:"package_sets"`
+}
+
+// WithBootloader returns the image type with the given alternative
+// bootloader and the platform of the bootloader for the arch.
+func (it *ImageTypeYAML) WithBootloader(name, archName string) (*ImageTypeYAML, *platform.PlatformConf, error) {
+	bl, ok := it.Bootloaders[name]
+	if !ok {
+		return nil, nil, fmt.Errorf("bootloader %q is not supported for image type %q", name, it.Name())
+	}
+	idx := slices.IndexFunc(bl.Platforms, func(pl platform.PlatformConf) bool {
+		return pl.Arch.String() == archName
+	})
+	if idx < 0 {
+		return nil, nil, fmt.Errorf("bootloader %q is not supported for image type %q on %s", name, it.Name(), archName)
+	}
+	pl := bl.Platforms[idx]
+
+	newIt := *it
+	newIt.Bootloaders = nil
+	if bl.ImageConfig != nil {
+		newIt.ImageConfigYAML.ImageConfig = bl.ImageConfig.InheritFrom(it.ImageConfigYAML.ImageConfig)
+	}
+	if bl.PartitionTables != nil {
+		newIt.PartitionTables = bl.PartitionTables
+		newIt.PartitionTablesOverrides = nil
+	}
+	newIt.PackageSetsYAML = maps.Clone(it.PackageSetsYAML)
+	for key, pkgSets := range bl.PackageSets {
+		newIt.PackageSetsYAML[key] = append(slices.Clone(it.PackageSetsYAML[key]), pkgSets...)
+	}
+	return &newIt, &pl, nil
+}
+
 type platformsOverride struct {
 	Conditions map[string]*conditionsPlatforms `yaml:"conditions,omitempty"`
 }
@@ -4,6 +4,7 @@ import (
 	"math/rand"
 
 	"github.com/osbuild/images/pkg/blueprint"
+	"github.com/osbuild/images/pkg/customizations/ocicontainer"
 	"github.com/osbuild/images/pkg/customizations/subscription"
 	"github.com/osbuild/images/pkg/disk"
 	"github.com/osbuild/images/pkg/manifest"
@@ -138,6 +139,7 @@ type ImageOptions struct {
 	Subscription     *subscription.ImageOptions `json:"subscription,omitempty"`
 	Facts            *facts.ImageOptions        `json:"facts,omitempty"`
 	PartitioningMode disk.PartitioningMode      `json:"partitioning-mode,omitempty"`
+	Container        *ocicontainer.ImageOptions `json:"container,omitempty"`
 
 	UseBootstrapContainer bool `json:"use_bootstrap_container,omitempty"`
 }
@@ -10,11 +10,13 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/osbuild/images/pkg/blueprint"
+	"github.com/osbuild/images/pkg/customizations/ocicontainer"
 	"github.com/osbuild/images/pkg/disk"
 	"github.com/osbuild/images/pkg/distro"
 	"github.com/osbuild/images/pkg/distro/distro_test_common"
 	"github.com/osbuild/images/pkg/distro/generic"
 	"github.com/osbuild/images/pkg/ostree"
+	"github.com/osbuild/images/pkg/platform"
 )
 
 var fedoraFamilyDistros = []distro.Distro{
@@ -515,7 +517,7 @@ func TestFedoraDistro_ManifestError(t *testing.T) {
 					} else if imgTypeName == "workstation-live-installer" {
 						assert.EqualError(t, err, fmt.Sprintf(distro.NoCustomizationsAllowedError, imgTypeName))
 					} else if imgTypeName == "iot-raw-xz" || imgTypeName == "iot-qcow2" {
-						assert.EqualError(t, err, fmt.Sprintf(distro.UnsupportedCustomizationError, imgTypeName, "User, Group, Directories, Files, Services, FIPS"))
+						assert.EqualError(t, err, fmt.Sprintf(distro.UnsupportedCustomizationError, imgTypeName, "User, Group, Directories, Files, Services, FIPS, Composefs"))
 					} else {
 						assert.NoError(t, err)
 					}
@@ -710,7 +712,7 @@ func TestFedoraDistro_CustomFileSystemManifestError(t *testing.T) {
 				if imgTypeName == "iot-commit" || imgTypeName == "iot-container" || imgTypeName == "iot-bootable-container" {
 					assert.EqualError(t, err, "Custom mountpoints and partitioning are not supported for ostree types")
 				} else if imgTypeName == "iot-raw-xz" || imgTypeName == "iot-qcow2" {
-					assert.EqualError(t, err, fmt.Sprintf(distro.UnsupportedCustomizationError, imgTypeName, "User, Group, Directories, Files, Services, FIPS"))
+					assert.EqualError(t, err, fmt.Sprintf(distro.UnsupportedCustomizationError, imgTypeName, "User, Group, Directories, Files, Services, FIPS, Composefs"))
 				} else if imgTypeName == "iot-installer" || imgTypeName == "iot-simplified-installer" || imgTypeName == "minimal-installer" {
 					continue
 				} else if imgTypeName == "workstation-live-installer" {
@@ -743,7 +745,7 @@ func TestFedoraDistro_TestRootMountPoint(t *testing.T) {
 				if imgTypeName == "iot-commit" || imgTypeName == "iot-container" || imgTypeName == "iot-bootable-container" {
 					assert.EqualError(t, err, "Custom mountpoints and partitioning are not supported for ostree types")
 				} else if imgTypeName == "iot-raw-xz" || imgTypeName == "iot-qcow2" {
-					assert.EqualError(t, err, fmt.Sprintf(distro.UnsupportedCustomizationError, imgTypeName, "User, Group, Directories, Files, Services, FIPS"))
+					assert.EqualError(t, err, fmt.Sprintf(distro.UnsupportedCustomizationError, imgTypeName, "User, Group, Directories, Files, Services, FIPS, Composefs"))
 				} else if imgTypeName == "iot-installer" || imgTypeName == "iot-simplified-installer" || imgTypeName == "minimal-installer" {
 					continue
 				} else if imgTypeName == "workstation-live-installer" {
@@ -887,7 +889,7 @@ func TestFedoraDistro_CustomUsrPartitionNotLargeEnough(t *testing.T) {
 				if imgTypeName == "iot-commit" || imgTypeName == "iot-container" || imgTypeName == "iot-bootable-container" {
 					assert.EqualError(t, err, "Custom mountpoints and partitioning are not supported for ostree types")
 				} else if imgTypeName == "iot-raw-xz" || imgTypeName == "iot-qcow2" {
-					assert.EqualError(t, err, fmt.Sprintf(distro.UnsupportedCustomizationError, imgTypeName, "User, Group, Directories, Files, Services, FIPS"))
+					assert.EqualError(t, err, fmt.Sprintf(distro.UnsupportedCustomizationError, imgTypeName, "User, Group, Directories, Files, Services, FIPS, Composefs"))
 				} else if imgTypeName == "iot-installer" || imgTypeName == "iot-simplified-installer" || imgTypeName == "minimal-installer" {
 					continue
 				} else if imgTypeName == "workstation-live-installer" {
@@ -931,7 +933,7 @@ func TestFedoraDistro_PartitioningConflict(t *testing.T) {
 				if imgTypeName == "iot-commit" || imgTypeName == "iot-container" || imgTypeName == "iot-bootable-container" {
 					assert.EqualError(t, err, "Custom mountpoints and partitioning are not supported for ostree types")
 				} else if imgTypeName == "iot-raw-xz" || imgTypeName == "iot-qcow2" {
-					assert.EqualError(t, err, fmt.Sprintf(distro.UnsupportedCustomizationError, imgTypeName, "User, Group, Directories, Files, Services, FIPS"))
+					assert.EqualError(t, err, fmt.Sprintf(distro.UnsupportedCustomizationError, imgTypeName, "User, Group, Directories, Files, Services, FIPS, Composefs"))
 				} else if imgTypeName == "iot-installer" || imgTypeName == "iot-simplified-installer" || imgTypeName == "minimal-installer" {
 					continue
 				} else if imgTypeName == "workstation-live-installer" {
@@ -1088,3 +1090,153 @@ func TestFedoraDistro_PartioningModeConstraints(t *testing.T) {
 		}
 	}
 }
+
+func TestFedoraDistro_ContainerOptions(t *testing.T) {
+	fedoraDistro := fedoraFamilyDistros[0]
+	arch, err := fedoraDistro.GetArch("x86_64")
+	require.NoError(t, err)
+
+	bp := &blueprint.Blueprint{
+		Customizations: &blueprint.Customizations{
+			Files: []blueprint.FileCustomization{
+				{
+					Path: "/etc/example.conf",
+					Data: "example",
+				},
+			},
+		},
+	}
+	imgOpts := distro.ImageOptions{
+		Container: &ocicontainer.ImageOptions{
+			Cmd:                 []string{"/usr/bin/bash"},
+			CustomizationsLayer: true,
+		},
+	}
+
+	imgType, err := arch.GetImageType("container")
+	require.NoError(t, err)
+	_, _, err = imgType.Manifest(bp, imgOpts, nil, nil)
+	assert.NoError(t, err)
+
+	bp.Customizations.Files[0].User = "root"
+	_, _, err = imgType.Manifest(bp, imgOpts, nil, nil)
+	assert.EqualError(t, err, `user of "/etc/example.conf" must be numeric when the customizations are in a separate container layer`)
+
+	// units stay in the tree of the OS, where they are enabled
+	bp.Customizations.Files = []blueprint.FileCustomization{
+		{
+			Path: "/etc/systemd/system/example.service",
+			User: "root",
+			Data: "[Service]\nExecStart=/usr/bin/true\n",
+		},
+	}
+	bp.Customizations.Services = &blueprint.ServicesCustomization{
+		Enabled: []string{"example.service"},
+	}
+	_, _, err = imgType.Manifest(bp, imgOpts, nil, nil)
+	assert.NoError(t, err)
+
+	_, _, err = imgType.Manifest(&blueprint.Blueprint{}, distro.ImageOptions{
+		Container: &ocicontainer.ImageOptions{WorkingDir: "relative"},
+	}, nil, nil)
+	assert.EqualError(t, err, `container working directory "relative" must be an absolute path`)
+
+	imgType, err = arch.GetImageType("server-qcow2")
+	require.NoError(t, err)
+	_, _, err = imgType.Manifest(&blueprint.Blueprint{}, imgOpts, nil, nil)
+	assert.EqualError(t, err, `container options are not supported for "server-qcow2"`)
+}
+
+func TestFedoraDistro_Composefs(t *testing.T) {
+	fedoraDistro := fedoraFamilyDistros[0]
+	arch, err := fedoraDistro.GetArch("x86_64")
+	require.NoError(t, err)
+
+	bp := &blueprint.Blueprint{
+		Customizations: &blueprint.Customizations{
+			Composefs: &blueprint.ComposefsCustomization{
+				Mode:      "signed",
+				PublicKey: "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=",
+			},
+		},
+	}
+
+	imgType, err := arch.GetImageType("iot-commit")
+	require.NoError(t, err)
+	_, _, err = imgType.Manifest(bp, distro.ImageOptions{}, nil, nil)
+	assert.NoError(t, err)
+
+	imgType, err = arch.GetImageType("iot-qcow2")
+	require.NoError(t, err)
+	_, _, err = imgType.Manifest(bp, distro.ImageOptions{
+		OSTree: &ostree.ImageOptions{URL: "https://ostree.example.com/repo"},
+	}, nil, nil)
+	assert.NoError(t, err)
+
+	imgType, err = arch.GetImageType("server-qcow2")
+	require.NoError(t, err)
+	_, _, err = imgType.Manifest(bp, distro.ImageOptions{}, nil, nil)
+	assert.EqualError(t, err, `composefs customizations are only supported for ostree image types, not "server-qcow2"`)
+
+	bp.Customizations.Composefs.PublicKey = ""
+	imgType, err = arch.GetImageType("iot-commit")
+	require.NoError(t, err)
+	_, _, err = imgType.Manifest(bp, distro.ImageOptions{}, nil, nil)
+	assert.EqualError(t, err, `composefs mode "signed" requires a public key`)
+}
+
+func TestFedoraDistro_Bootloader(t *testing.T) {
+	fedoraDistro := fedoraFamilyDistros[0]
+
+	for _, tc := range []struct {
+		arch       string
+		bootloader string
+		kernel     string
+		pkgs       []string
+		expectErr  string
+	}{
+		{"x86_64", "", "", []string{"grub2-efi-x64", "kernel"}, ""},
+		{"x86_64", "grub2", "", []string{"grub2-efi-x64", "kernel"}, ""},
+		{"x86_64", "uki", "", []string{"shim-x64", "uki-direct", "kernel-uki-virt"}, ""},
+		{"aarch64", "uki", "", []string{"shim-aa64", "uki-direct", "kernel-uki-virt"}, ""},
+		{"aarch64", "systemd-boot", "", []string{"systemd-boot-unsigned", "uki-direct", "kernel-uki-virt"}, ""},
+		{"x86_64", "uki", "debug", nil, `kernel append customization is not supported for "server-qcow2" with the "uki" bootloader: command line addons for UKIs are not implemented yet`},
+		{"ppc64le", "uki", "", nil, `bootloader "uki" is not supported for image type "server-qcow2" on ppc64le`},
+		{"x86_64", "lilo", "", nil, `unsupported bootloader "lilo"`},
+	} {
+		t.Run(tc.arch+"/"+tc.bootloader, func(t *testing.T) {
+			arch, err := fedoraDistro.GetArch(tc.arch)
+			require.NoError(t, err)
+			imgType, err := arch.GetImageType("server-qcow2")
+			require.NoError(t, err)
+
+			bp := blueprint.Blueprint{
+				Customizations: &blueprint.Customizations{
+					Bootloader: tc.bootloader,
+				},
+			}
+			if tc.kernel != "" {
+				bp.Customizations.Kernel = &blueprint.KernelCustomization{Append: tc.kernel}
+			}
+			mf, _, err := imgType.Manifest(&bp, distro.ImageOptions{}, nil, nil)
+			if tc.expectErr != "" {
+				assert.EqualError(t, err, tc.expectErr)
+				return
+			}
+			require.NoError(t, err)
+			osPkgs := mf.GetPackageSetChains()["os"][0]
+			assert.Subset(t, osPkgs.Include, tc.pkgs)
+			if tc.bootloader == "uki" || tc.bootloader == "systemd-boot" {
+				assert.Contains(t, osPkgs.Exclude, "grubby")
+				assert.NotContains(t, osPkgs.Include, "grub2-efi-x64")
+			}
+		})
+	}
+
+	// the image type of the architecture is not changed
+	arch, err := fedoraDistro.GetArch("x86_64")
+	require.NoError(t, err)
+	imgType, err := arch.GetImageType("server-qcow2")
+	require.NoError(t, err)
+	assert.Equal(t, platform.BOOT_HYBRID, imgType.BootMode())
+}
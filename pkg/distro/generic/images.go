@@ -10,10 +10,12 @@ import (
 	"github.com/osbuild/images/pkg/container"
 	"github.com/osbuild/images/pkg/customizations/anaconda"
 	"github.com/osbuild/images/pkg/customizations/bootc"
+	"github.com/osbuild/images/pkg/customizations/composefs"
 	"github.com/osbuild/images/pkg/customizations/fdo"
 	"github.com/osbuild/images/pkg/customizations/fsnode"
 	"github.com/osbuild/images/pkg/customizations/ignition"
 	"github.com/osbuild/images/pkg/customizations/kickstart"
+	"github.com/osbuild/images/pkg/customizations/network"
 	"github.com/osbuild/images/pkg/customizations/oscap"
 	"github.com/osbuild/images/pkg/customizations/subscription"
 	"github.com/osbuild/images/pkg/customizations/users"
@@ -75,7 +77,7 @@ func osCustomizations(t *imageType, osPackageSet rpmmd.PackageSet, options distr
 		// add them via kickstart instead
 		osc.Groups = users.GroupsFromBP(c.GetGroups())
 
-		osc.Users = users.UsersFromBP(c.GetUsers())
+		osc.Users = users.UsersFromBP(c.GetUsers(), imageConfig.GetPasswordHashAlgorithm())
 		osc.Users = append(osc.Users, imageConfig.Users...)
 	}
 
@@ -259,16 +261,61 @@ func osCustomizations(t *imageType, osPackageSet rpmmd.PackageSet, options distr
 	osc.Sysconfig = imageConfig.SysconfigStageOptions()
 	osc.SystemdLogind = imageConfig.SystemdLogind
 	osc.CloudInit = imageConfig.CloudInit
-	osc.Modprobe = imageConfig.Modprobe
+	kernelModules, err := c.GetKernelModules()
+	if err != nil {
+		return manifest.OSCustomizations{}, err
+	}
+	osc.Modprobe = imageConfig.ModprobeStageOptions(kernelModules)
+	if kernelModules != nil {
+		kernelModulesFiles, err := kernelModules.FsNodeFiles()
+		if err != nil {
+			return manifest.OSCustomizations{}, err
+		}
+		osc.Files = append(osc.Files, kernelModulesFiles...)
+		osc.InitramfsOmitDrivers = kernelModules.Blacklist
+	}
+
+	networkConnections, err := c.GetNetwork()
+	if err != nil {
+		return manifest.OSCustomizations{}, err
+	}
+	osc.NetworkConnections, err = network.KeyfilesFromBP(networkConnections)
+	if err != nil {
+		return manifest.OSCustomizations{}, err
+	}
+
+	journald, err := c.GetJournald()
+	if err != nil {
+		return manifest.OSCustomizations{}, err
+	}
+	if journald != nil {
+		journaldFile, err := journald.FsNodeFile()
+		if err != nil {
+			return manifest.OSCustomizations{}, err
+		}
+		osc.Files = append(osc.Files, journaldFile)
+	}
 	osc.DracutConf = imageConfig.DracutConf
 	osc.SystemdDropin = imageConfig.SystemdDropin
 	osc.SystemdUnit = imageConfig.SystemdUnit
 	osc.Authselect = imageConfig.Authselect
 	osc.SELinuxConfig = imageConfig.SELinuxConfig
 	osc.Tuned = imageConfig.Tuned
+	tuned, err := c.GetTuned()
+	if err != nil {
+		return manifest.OSCustomizations{}, err
+	}
+	if tuned != nil {
+		osc.Tuned = osbuild.NewTunedStageOptions(tuned.Profiles...)
+		osc.InstallTuned = true
+	}
 	osc.Tmpfilesd = imageConfig.Tmpfilesd
 	osc.PamLimitsConf = imageConfig.PamLimitsConf
-	osc.Sysctld = imageConfig.Sysctld
+	sysctl, err := c.GetSysctl()
+	if err != nil {
+		return manifest.OSCustomizations{}, err
+	}
+	osc.Sysctld = imageConfig.SysctldStageOptions(sysctl)
 	osc.DNFConfig = imageConfig.DNFConfigOptions(t.arch.distro.OsVersion())
 	osc.DNFAutomaticConfig = imageConfig.DNFAutomaticConfig
 	osc.YUMConfig = imageConfig.YumConfig
@@ -348,7 +395,7 @@ func ostreeDeploymentCustomizations(
 
 	deploymentConf.FIPS = c.GetFIPS()
 
-	deploymentConf.Users = users.UsersFromBP(c.GetUsers())
+	deploymentConf.Users = users.UsersFromBP(c.GetUsers(), imageConfig.GetPasswordHashAlgorithm())
 	deploymentConf.Groups = users.GroupsFromBP(c.GetGroups())
 
 	var err error
@@ -385,9 +432,34 @@ func ostreeDeploymentCustomizations(
 		deploymentConf.CustomFileSystems = append(deploymentConf.CustomFileSystems, fs.Mountpoint)
 	}
 
+	deploymentConf.Composefs, err = composefsOptions(imageConfig, c)
+	if err != nil {
+		return manifest.OSTreeDeploymentCustomizations{}, err
+	}
+
 	return deploymentConf, nil
 }
 
+// composefsOptions returns the composefs options of the blueprint or, if the
+// blueprint has none, of the image config.
+func composefsOptions(imageConfig *distro.ImageConfig, c *blueprint.Customizations) (*composefs.Options, error) {
+	bpComposefs, err := c.GetComposefs()
+	if err != nil {
+		return nil, err
+	}
+	if bpComposefs != nil {
+		return bpComposefs.Options(), nil
+	}
+
+	if imageConfig == nil || imageConfig.Composefs == nil {
+		return nil, nil
+	}
+	if err := imageConfig.Composefs.Validate(); err != nil {
+		return nil, err
+	}
+	return imageConfig.Composefs, nil
+}
+
 // IMAGES
 
 func diskImage(workload workload.Workload,
@@ -488,6 +560,7 @@ func containerImage(workload workload.Workload,
 	img.Workload = workload
 
 	img.Filename = t.Filename()
+	img.OCIConfig = options.Container
 
 	return img, nil
 }
@@ -567,7 +640,7 @@ func imageInstallerImage(workload workload.Workload,
 
 	img.UseLegacyAnacondaConfig = t.ImageTypeYAML.UseLegacyAnacondaConfig
 
-	img.Kickstart, err = kickstart.New(customizations)
+	img.Kickstart, err = kickstart.New(customizations, t.getDefaultImageConfig().GetPasswordHashAlgorithm())
 	if err != nil {
 		return nil, err
 	}
@@ -662,6 +735,10 @@ func iotCommitImage(workload workload.Workload,
 	if err != nil {
 		return nil, err
 	}
+	img.OSCustomizations.Composefs, err = composefsOptions(t.getDefaultImageConfig(), bp.Customizations)
+	if err != nil {
+		return nil, err
+	}
 
 	imgConfig := t.getDefaultImageConfig()
 	if imgConfig != nil {
@@ -700,6 +777,10 @@ func bootableContainerImage(workload workload.Workload,
 	if err != nil {
 		return nil, err
 	}
+	img.OSCustomizations.Composefs, err = composefsOptions(t.getDefaultImageConfig(), bp.Customizations)
+	if err != nil {
+		return nil, err
+	}
 
 	img.Environment = &t.ImageTypeYAML.Environment
 	img.Workload = workload
@@ -738,6 +819,10 @@ func iotContainerImage(workload workload.Workload,
 	if err != nil {
 		return nil, err
 	}
+	img.OSCustomizations.Composefs, err = composefsOptions(t.getDefaultImageConfig(), bp.Customizations)
+	if err != nil {
+		return nil, err
+	}
 
 	imgConfig := t.getDefaultImageConfig()
 	if imgConfig != nil {
@@ -781,7 +866,7 @@ func iotInstallerImage(workload workload.Workload,
 	img.ExtraBasePackages = packageSets[installerPkgsKey]
 	img.UseLegacyAnacondaConfig = t.ImageTypeYAML.UseLegacyAnacondaConfig
 
-	img.Kickstart, err = kickstart.New(customizations)
+	img.Kickstart, err = kickstart.New(customizations, t.getDefaultImageConfig().GetPasswordHashAlgorithm())
 	if err != nil {
 		return nil, err
 	}
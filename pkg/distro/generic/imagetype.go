@@ -195,6 +195,22 @@ func (t *imageType) Manifest(bp *blueprint.Blueprint,
 	seedp *int64) (*manifest.Manifest, []string, error) {
 	seed := distro.SeedFrom(seedp)
 
+	if bootloader := bp.Customizations.GetBootloader(); bootloader != "" {
+		var err error
+		t, err = t.withBootloader(bootloader)
+		if err != nil {
+			return nil, nil, err
+		}
+		// The kernel command line is part of the signed UKI. It can only
+		// be extended with an addon UKI, but osbuild has no stage that
+		// builds one (e.g. with ukify), so kernel.append cannot be
+		// honored. Note that image types with a UKI platform (azure-cvm)
+		// have always ignored kernel.append.
+		if t.platform.GetBootloader().IsUKI() && bp.Customizations.GetKernel().Append != "" {
+			return nil, nil, fmt.Errorf("kernel append customization is not supported for %q with the %q bootloader: command line addons for UKIs are not implemented yet", t.Name(), bootloader)
+		}
+	}
+
 	warnings, err := t.checkOptions(bp, options)
 	if err != nil {
 		return nil, nil, err
@@ -306,6 +322,33 @@ func (t *imageType) Manifest(bp *blueprint.Blueprint,
 	return &mf, warnings, err
 }
 
+// withBootloader returns the image type with the given bootloader instead of
+// the bootloader of its platform.
+func (t *imageType) withBootloader(name string) (*imageType, error) {
+	bootloader, err := platform.FromString(name)
+	if err != nil {
+		return nil, err
+	}
+	if bootloader == t.platform.GetBootloader() {
+		return t, nil
+	}
+
+	imgYAML, pl, err := t.ImageTypeYAML.WithBootloader(name, t.arch.name)
+	if err != nil {
+		return nil, err
+	}
+	pl.Bootloader = bootloader
+	// the image format is a property of the image type
+	pl.ImageFormat = t.platform.GetImageFormat()
+	pl.QCOW2Compat = t.platform.GetQCOW2Compat()
+
+	newT := newImageTypeFrom(t.arch.distro, t.arch, *imgYAML)
+	newT.arch = t.arch
+	newT.platform = pl
+	newT.workload = t.workload
+	return &newT, nil
+}
+
 // checkOptions checks the validity and compatibility of options and customizations for the image type.
 // Returns ([]string, error) where []string, if non-nil, will hold any generated warnings (e.g. deprecation notices).
 func (t *imageType) checkOptions(bp *blueprint.Blueprint, options distro.ImageOptions) ([]string, error) {
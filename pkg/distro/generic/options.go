@@ -21,6 +21,39 @@ func checkOptionsCommon(t *imageType, bp *blueprint.Blueprint, options distro.Im
 	if len(t.ImageTypeYAML.SupportedPartitioningModes) > 0 && !slices.Contains(t.ImageTypeYAML.SupportedPartitioningModes, options.PartitioningMode) {
 		return nil, fmt.Errorf("partitioning mode %s not supported for %q", options.PartitioningMode, t.Name())
 	}
+
+	if options.Container != nil {
+		if t.ImageTypeYAML.Image != "container" {
+			return nil, fmt.Errorf("container options are not supported for %q", t.Name())
+		}
+		if err := options.Container.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := bp.Customizations.GetSysctl(); err != nil {
+		return nil, err
+	}
+	if _, err := bp.Customizations.GetKernelModules(); err != nil {
+		return nil, err
+	}
+	if _, err := bp.Customizations.GetNetwork(); err != nil {
+		return nil, err
+	}
+	if _, err := bp.Customizations.GetTuned(); err != nil {
+		return nil, err
+	}
+	if _, err := bp.Customizations.GetJournald(); err != nil {
+		return nil, err
+	}
+
+	cfs, err := bp.Customizations.GetComposefs()
+	if err != nil {
+		return nil, err
+	}
+	if cfs != nil && !t.RPMOSTree {
+		return nil, fmt.Errorf("composefs customizations are only supported for ostree image types, not %q", t.Name())
+	}
 	return nil, nil
 }
 
@@ -349,7 +382,7 @@ func checkOptionsFedora(t *imageType, bp *blueprint.Blueprint, options distro.Im
 	}
 
 	if t.Name() == "iot-raw-xz" || t.Name() == "iot-qcow2" {
-		allowed := []string{"User", "Group", "Directories", "Files", "Services", "FIPS"}
+		allowed := []string{"User", "Group", "Directories", "Files", "Services", "FIPS", "Composefs"}
 		if err := customizations.CheckAllowed(allowed...); err != nil {
 			return warnings, fmt.Errorf(distro.UnsupportedCustomizationError, t.Name(), strings.Join(allowed, ", "))
 		}
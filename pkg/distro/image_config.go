@@ -3,8 +3,12 @@ package distro
 import (
 	"fmt"
 	"reflect"
+	"strings"
 
 	"github.com/osbuild/images/internal/common"
+	"github.com/osbuild/images/pkg/blueprint"
+	"github.com/osbuild/images/pkg/crypt"
+	"github.com/osbuild/images/pkg/customizations/composefs"
 	"github.com/osbuild/images/pkg/customizations/fsnode"
 	"github.com/osbuild/images/pkg/customizations/shell"
 	"github.com/osbuild/images/pkg/customizations/subscription"
@@ -122,6 +126,12 @@ type ImageConfig struct {
 
 	IgnitionPlatform *string `yaml:"ignition_platform,omitempty"`
 
+	// Mount the root filesystem of the ostree deployments with composefs.
+	// The composefs blueprint customization takes precedence. The build
+	// does not sign ostree commits, the commits of the "signed" mode must
+	// be signed with "ostree sign" before they are deployed.
+	Composefs *composefs.Options `yaml:"composefs,omitempty"`
+
 	// InstallWeakDeps enables installation of weak dependencies for packages
 	// that are statically defined for the pipeline.
 	InstallWeakDeps *bool `yaml:"install_weak_deps,omitempty"`
@@ -147,6 +157,11 @@ type ImageConfig struct {
 	// This is only supported for distributions that use dnf4, because osbuild
 	// only has a stage for dnf4 version locking.
 	VersionlockPackages []string `yaml:"versionlock_packages,omitempty"`
+
+	// PasswordHashAlgorithm is used to hash plain text passwords of
+	// blueprint users, it should match ENCRYPT_METHOD in the
+	// /etc/login.defs of the distribution
+	PasswordHashAlgorithm *crypt.Algorithm `yaml:"password_hash_algorithm,omitempty"`
 }
 
 // shallowMerge creates a new struct by merging a child and a parent.
@@ -189,6 +204,15 @@ func (c *ImageConfig) InheritFrom(parentConfig *ImageConfig) *ImageConfig {
 	return shallowMerge(c, parentConfig)
 }
 
+// GetPasswordHashAlgorithm returns the PasswordHashAlgorithm or an empty
+// algorithm, which selects the crypt default, when it is unset.
+func (c *ImageConfig) GetPasswordHashAlgorithm() crypt.Algorithm {
+	if c.PasswordHashAlgorithm == nil {
+		return ""
+	}
+	return *c.PasswordHashAlgorithm
+}
+
 func (c *ImageConfig) DNFConfigOptions(osVersion string) []*osbuild.DNFConfigStageOptions {
 	if c.DNFConfig == nil {
 		return nil
@@ -221,6 +245,99 @@ func (c *ImageConfig) DNFConfigOptions(osVersion string) []*osbuild.DNFConfigSta
 	}
 }
 
+// BlueprintSysctlFilename is the name of the sysctl.d file that holds the
+// kernel parameters set via the blueprint sysctl customization.
+const BlueprintSysctlFilename = "99-blueprint.conf"
+
+// SysctldStageOptions merges the sysctl.d configuration of the image config
+// with the blueprint sysctl customizations. Settings from the blueprint take
+// precedence: any key that is also set by the blueprint is removed from the
+// default configuration files and the blueprint settings are written to a
+// separate file.
+func (c *ImageConfig) SysctldStageOptions(custom blueprint.SysctlCustomization) []*osbuild.SysctldStageOptions {
+	if len(custom) == 0 {
+		return c.Sysctld
+	}
+
+	overridden := make(map[string]bool, len(custom))
+	bpLines := make([]osbuild.SysctldConfigLine, 0, len(custom))
+	for _, key := range custom.Keys() {
+		overridden[blueprint.NormalizeSysctlKey(strings.TrimPrefix(key, "-"))] = true
+		bpLines = append(bpLines, osbuild.SysctldConfigLine{Key: key, Value: custom[key]})
+	}
+
+	var opts []*osbuild.SysctldStageOptions
+	for _, defaults := range c.Sysctld {
+		var lines []osbuild.SysctldConfigLine
+		for _, line := range defaults.Config {
+			if overridden[blueprint.NormalizeSysctlKey(strings.TrimPrefix(line.Key, "-"))] {
+				continue
+			}
+			lines = append(lines, line)
+		}
+		// the stage requires at least one line, drop files that are
+		// fully overridden by the blueprint
+		if len(lines) == 0 {
+			continue
+		}
+		opts = append(opts, osbuild.NewSysctldStageOptions(defaults.Filename, lines))
+	}
+
+	return append(opts, osbuild.NewSysctldStageOptions(BlueprintSysctlFilename, bpLines))
+}
+
+// BlueprintModprobeBlacklistFilename is the name of the modprobe.d file
+// that holds the kernel modules blacklisted via the blueprint.
+const BlueprintModprobeBlacklistFilename = "blacklist-blueprint.conf"
+
+// ModprobeStageOptions merges the modprobe configuration of the image config
+// with the blueprint kernel modules customizations. Modules blacklisted in
+// the blueprint are written to a separate file. Modules that the blueprint
+// explicitly loads are removed from the blacklists of the defaults.
+func (c *ImageConfig) ModprobeStageOptions(modules *blueprint.KernelModulesCustomization) []*osbuild.ModprobeStageOptions {
+	if modules == nil || len(modules.Load)+len(modules.Blacklist) == 0 {
+		return c.Modprobe
+	}
+
+	loaded := make(map[string]bool, len(modules.Load))
+	for _, name := range modules.Load {
+		loaded[blueprint.NormalizeKernelModuleName(name)] = true
+	}
+
+	var opts []*osbuild.ModprobeStageOptions
+	for _, defaults := range c.Modprobe {
+		var cmds osbuild.ModprobeConfigCmdList
+		for _, cmd := range defaults.Commands {
+			if blacklist, ok := cmd.(*osbuild.ModprobeConfigCmdBlacklist); ok && loaded[blueprint.NormalizeKernelModuleName(blacklist.Modulename)] {
+				continue
+			}
+			cmds = append(cmds, cmd)
+		}
+		// the stage requires at least one command, drop files that only
+		// blacklist modules loaded by the blueprint
+		if len(cmds) == 0 {
+			continue
+		}
+		opts = append(opts, &osbuild.ModprobeStageOptions{
+			Filename: defaults.Filename,
+			Commands: cmds,
+		})
+	}
+
+	if len(modules.Blacklist) > 0 {
+		cmds := make(osbuild.ModprobeConfigCmdList, 0, len(modules.Blacklist))
+		for _, name := range modules.Blacklist {
+			cmds = append(cmds, osbuild.NewModprobeConfigCmdBlacklist(name))
+		}
+		opts = append(opts, &osbuild.ModprobeStageOptions{
+			Filename: BlueprintModprobeBlacklistFilename,
+			Commands: cmds,
+		})
+	}
+
+	return opts
+}
+
 type Sysconfig struct {
 	Networking bool `yaml:"networking,omitempty"`
 	NoZeroConf bool `yaml:"no_zero_conf,omitempty"`
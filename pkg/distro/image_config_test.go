@@ -6,6 +6,8 @@ import (
 	"github.com/stretchr/testify/assert"
 
 	"github.com/osbuild/images/internal/common"
+	"github.com/osbuild/images/pkg/blueprint"
+	"github.com/osbuild/images/pkg/crypt"
 	"github.com/osbuild/images/pkg/osbuild"
 )
 
@@ -167,6 +169,14 @@ func TestImageConfigInheritFrom(t *testing.T) {
 	}
 }
 
+func TestImageConfigGetPasswordHashAlgorithm(t *testing.T) {
+	cnf := &ImageConfig{}
+	assert.Equal(t, crypt.Algorithm(""), cnf.GetPasswordHashAlgorithm())
+
+	cnf.PasswordHashAlgorithm = common.ToPtr(crypt.AlgorithmYescrypt)
+	assert.Equal(t, crypt.AlgorithmYescrypt, cnf.GetPasswordHashAlgorithm())
+}
+
 func TestImageConfigDNFSetReleaseVerNotSet(t *testing.T) {
 	var expected []*osbuild.DNFConfigStageOptions
 	cnf := &ImageConfig{}
@@ -199,3 +209,86 @@ func TestImageConfigDNFConfigOptionsPreExisting(t *testing.T) {
 		cnf.DNFConfigOptions("9-stream")
 	})
 }
+
+func TestImageConfigSysctldStageOptions(t *testing.T) {
+	cnf := &ImageConfig{
+		Sysctld: []*osbuild.SysctldStageOptions{
+			osbuild.NewSysctldStageOptions("sap.conf", []osbuild.SysctldConfigLine{
+				{Key: "kernel.pid_max", Value: "4194304"},
+				{Key: "vm.max_map_count", Value: "2147483647"},
+			}),
+			osbuild.NewSysctldStageOptions("forward.conf", []osbuild.SysctldConfigLine{
+				{Key: "net.ipv4.ip_forward", Value: "0"},
+			}),
+		},
+	}
+
+	// no customizations, defaults are returned as they are
+	assert.Equal(t, cnf.Sysctld, cnf.SysctldStageOptions(nil))
+
+	custom := blueprint.SysctlCustomization{
+		"vm/max_map_count":    "65530",
+		"net.ipv4.ip_forward": "1",
+		"vm.swappiness":       "10",
+	}
+	expected := []*osbuild.SysctldStageOptions{
+		osbuild.NewSysctldStageOptions("sap.conf", []osbuild.SysctldConfigLine{
+			{Key: "kernel.pid_max", Value: "4194304"},
+		}),
+		osbuild.NewSysctldStageOptions(BlueprintSysctlFilename, []osbuild.SysctldConfigLine{
+			{Key: "net.ipv4.ip_forward", Value: "1"},
+			{Key: "vm.swappiness", Value: "10"},
+			{Key: "vm/max_map_count", Value: "65530"},
+		}),
+	}
+	assert.Equal(t, expected, cnf.SysctldStageOptions(custom))
+
+	// the defaults must not be modified
+	assert.Len(t, cnf.Sysctld[0].Config, 2)
+	assert.Len(t, cnf.Sysctld[1].Config, 1)
+}
+
+func TestImageConfigModprobeStageOptions(t *testing.T) {
+	cnf := &ImageConfig{
+		Modprobe: []*osbuild.ModprobeStageOptions{
+			{
+				Filename: "blacklist-amdgpu.conf",
+				Commands: osbuild.ModprobeConfigCmdList{
+					osbuild.NewModprobeConfigCmdBlacklist("amdgpu"),
+				},
+			},
+			{
+				Filename: "blacklist-floppy.conf",
+				Commands: osbuild.ModprobeConfigCmdList{
+					osbuild.NewModprobeConfigCmdBlacklist("floppy"),
+				},
+			},
+		},
+	}
+
+	// no customizations, defaults are returned as they are
+	assert.Equal(t, cnf.Modprobe, cnf.ModprobeStageOptions(nil))
+
+	modules := &blueprint.KernelModulesCustomization{
+		Load:      []string{"amdgpu"},
+		Blacklist: []string{"nouveau", "i2c_piix4"},
+	}
+	expected := []*osbuild.ModprobeStageOptions{
+		{
+			Filename: "blacklist-floppy.conf",
+			Commands: osbuild.ModprobeConfigCmdList{
+				osbuild.NewModprobeConfigCmdBlacklist("floppy"),
+			},
+		},
+		{
+			Filename: BlueprintModprobeBlacklistFilename,
+			Commands: osbuild.ModprobeConfigCmdList{
+				osbuild.NewModprobeConfigCmdBlacklist("nouveau"),
+				osbuild.NewModprobeConfigCmdBlacklist("i2c_piix4"),
+			},
+		},
+	}
+	assert.Equal(t, expected, cnf.ModprobeStageOptions(modules))
+	// the defaults must not be modified
+	assert.Len(t, cnf.Modprobe, 2)
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/osbuild/images/pkg/customizations/fsnode"
 	"github.com/osbuild/images/pkg/customizations/ignition"
 	"github.com/osbuild/images/pkg/customizations/kickstart"
+	"github.com/osbuild/images/pkg/customizations/network"
 	"github.com/osbuild/images/pkg/customizations/oscap"
 	"github.com/osbuild/images/pkg/customizations/subscription"
 	"github.com/osbuild/images/pkg/customizations/users"
@@ -83,7 +84,7 @@ func osCustomizations(
 		// add them via kickstart instead
 		osc.Groups = users.GroupsFromBP(c.GetGroups())
 
-		osc.Users = users.UsersFromBP(c.GetUsers())
+		osc.Users = users.UsersFromBP(c.GetUsers(), imageConfig.GetPasswordHashAlgorithm())
 		osc.Users = append(osc.Users, imageConfig.Users...)
 	}
 
@@ -261,16 +262,61 @@ func osCustomizations(
 	osc.Sysconfig = imageConfig.SysconfigStageOptions()
 	osc.SystemdLogind = imageConfig.SystemdLogind
 	osc.CloudInit = imageConfig.CloudInit
-	osc.Modprobe = imageConfig.Modprobe
+	kernelModules, err := c.GetKernelModules()
+	if err != nil {
+		return manifest.OSCustomizations{}, err
+	}
+	osc.Modprobe = imageConfig.ModprobeStageOptions(kernelModules)
+	if kernelModules != nil {
+		kernelModulesFiles, err := kernelModules.FsNodeFiles()
+		if err != nil {
+			return manifest.OSCustomizations{}, err
+		}
+		osc.Files = append(osc.Files, kernelModulesFiles...)
+		osc.InitramfsOmitDrivers = kernelModules.Blacklist
+	}
+
+	networkConnections, err := c.GetNetwork()
+	if err != nil {
+		return manifest.OSCustomizations{}, err
+	}
+	osc.NetworkConnections, err = network.KeyfilesFromBP(networkConnections)
+	if err != nil {
+		return manifest.OSCustomizations{}, err
+	}
+
+	journald, err := c.GetJournald()
+	if err != nil {
+		return manifest.OSCustomizations{}, err
+	}
+	if journald != nil {
+		journaldFile, err := journald.FsNodeFile()
+		if err != nil {
+			return manifest.OSCustomizations{}, err
+		}
+		osc.Files = append(osc.Files, journaldFile)
+	}
 	osc.DracutConf = imageConfig.DracutConf
 	osc.SystemdDropin = imageConfig.SystemdDropin
 	osc.SystemdUnit = imageConfig.SystemdUnit
 	osc.Authselect = imageConfig.Authselect
 	osc.SELinuxConfig = imageConfig.SELinuxConfig
 	osc.Tuned = imageConfig.Tuned
+	tuned, err := c.GetTuned()
+	if err != nil {
+		return manifest.OSCustomizations{}, err
+	}
+	if tuned != nil {
+		osc.Tuned = osbuild.NewTunedStageOptions(tuned.Profiles...)
+		osc.InstallTuned = true
+	}
 	osc.Tmpfilesd = imageConfig.Tmpfilesd
 	osc.PamLimitsConf = imageConfig.PamLimitsConf
-	osc.Sysctld = imageConfig.Sysctld
+	sysctl, err := c.GetSysctl()
+	if err != nil {
+		return manifest.OSCustomizations{}, err
+	}
+	osc.Sysctld = imageConfig.SysctldStageOptions(sysctl)
 	osc.DNFConfig = imageConfig.DNFConfigOptions(t.arch.distro.osVersion)
 	osc.DNFAutomaticConfig = imageConfig.DNFAutomaticConfig
 	osc.YUMConfig = imageConfig.YumConfig
@@ -350,7 +396,7 @@ func ostreeDeploymentCustomizations(
 
 	deploymentConf.FIPS = c.GetFIPS()
 
-	deploymentConf.Users = users.UsersFromBP(c.GetUsers())
+	deploymentConf.Users = users.UsersFromBP(c.GetUsers(), imageConfig.GetPasswordHashAlgorithm())
 	deploymentConf.Groups = users.GroupsFromBP(c.GetGroups())
 
 	var err error
@@ -520,7 +566,7 @@ func EdgeInstallerImage(workload workload.Workload,
 		img.UseLegacyAnacondaConfig = true
 	}
 
-	img.Kickstart, err = kickstart.New(customizations)
+	img.Kickstart, err = kickstart.New(customizations, t.getDefaultImageConfig().GetPasswordHashAlgorithm())
 	if err != nil {
 		return nil, err
 	}
@@ -734,7 +780,7 @@ func ImageInstallerImage(workload workload.Workload,
 		img.UseLegacyAnacondaConfig = true
 	}
 
-	img.Kickstart, err = kickstart.New(customizations)
+	img.Kickstart, err = kickstart.New(customizations, t.getDefaultImageConfig().GetPasswordHashAlgorithm())
 	if err != nil {
 		return nil, err
 	}
@@ -421,6 +421,14 @@ func (t *ImageType) checkOptions(bp *blueprint.Blueprint, options distro.ImageOp
 		return nil, fmt.Errorf("OSTree is not supported for %q", t.Name())
 	}
 
+	if options.Container != nil {
+		return nil, fmt.Errorf("container options are not supported for %q", t.Name())
+	}
+
+	if bp.Customizations != nil && bp.Customizations.Composefs != nil {
+		return nil, fmt.Errorf("composefs customizations are not supported for %q", t.Name())
+	}
+
 	if t.arch.distro.CheckOptions != nil {
 		return t.arch.distro.CheckOptions(t, bp, options)
 	}
@@ -138,6 +138,26 @@ func checkOptions(t *rhel.ImageType, bp *blueprint.Blueprint, options distro.Ima
 		return warnings, err
 	}
 
+	if _, err := customizations.GetSysctl(); err != nil {
+		return warnings, err
+	}
+
+	if _, err := customizations.GetKernelModules(); err != nil {
+		return warnings, err
+	}
+
+	if _, err := customizations.GetNetwork(); err != nil {
+		return warnings, err
+	}
+
+	if _, err := customizations.GetTuned(); err != nil {
+		return warnings, err
+	}
+
+	if _, err := customizations.GetJournald(); err != nil {
+		return warnings, err
+	}
+
 	if osc := customizations.GetOpenSCAP(); osc != nil {
 		if t.Arch().Distro().OsVersion() == "9.0" {
 			return warnings, fmt.Errorf("OpenSCAP unsupported os version: %s", t.Arch().Distro().OsVersion())
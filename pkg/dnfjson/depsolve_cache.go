@@ -0,0 +1,367 @@
+package dnfjson
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// depsolveCache is a persistent on-disk cache of the results of depsolve
+// requests. The entries are keyed by the hash of the request and the
+// checksums of the repomd.xml of all the repositories of the request, so
+// they are invalidated when the metadata of any of the repositories
+// changes.
+type depsolveCache struct {
+	// root path for the cache
+	root string
+
+	// max cache size
+	maxSize uint64
+
+	// timeout for fetching the repomd.xml of a repository
+	timeout time.Duration
+}
+
+func newDepsolveCache(path string, maxSize uint64) *depsolveCache {
+	absPath, err := filepath.Abs(path) // convert to abs if it's not already
+	if err != nil {
+		panic(err) // can only happen if the CWD does not exist and the path isn't already absolute
+	}
+	return &depsolveCache{
+		root:    absPath,
+		maxSize: maxSize,
+		timeout: 30 * time.Second,
+	}
+}
+
+// key returns the cache key of the depsolve request. An error is returned
+// if the repomd.xml of any of the repositories cannot be fetched, the
+// request cannot be cached then.
+//
+//nolint:errcheck
+func (c *depsolveCache) key(req *Request) (string, error) {
+	if req.Arguments.RootDir != "" {
+		// the root dir can define additional repositories
+		return "", fmt.Errorf("requests with a root dir cannot be cached")
+	}
+
+	reqHash, err := req.Hash()
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	h.Write([]byte(reqHash))
+	for _, repo := range req.Arguments.Repos {
+		checksum, err := c.repomdChecksum(req, repo)
+		if err != nil {
+			return "", fmt.Errorf("cannot get repomd.xml checksum of repository %q: %w", repo.Name, err)
+		}
+		h.Write([]byte(checksum))
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+func (c *depsolveCache) path(key string) string {
+	return filepath.Join(c.root, key+".json")
+}
+
+// get returns the cached output of osbuild-depsolve-dnf for the key and
+// marks the entry as recently used
+func (c *depsolveCache) get(key string) ([]byte, bool) {
+	output, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return nil, false
+	}
+	// ignore errors, the entry is just evicted earlier
+	now := time.Now().Local()
+	_ = os.Chtimes(c.path(key), now, now)
+	return output, true
+}
+
+// store saves the output of osbuild-depsolve-dnf for the key
+func (c *depsolveCache) store(key string, output []byte) error {
+	if err := os.MkdirAll(c.root, 0750); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(c.root, ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(output)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), c.path(key))
+}
+
+// shrink deletes the least recently used entries until the total size of
+// the cache falls below the maximum size
+func (c *depsolveCache) shrink() error {
+	entries, err := os.ReadDir(c.root)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	type entryInfo struct {
+		path  string
+		size  uint64
+		mtime time.Time
+	}
+	var infos []entryInfo
+	var size uint64
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			// skip it
+			continue
+		}
+		infos = append(infos, entryInfo{
+			path:  filepath.Join(c.root, entry.Name()),
+			size:  uint64(info.Size()),
+			mtime: info.ModTime(),
+		})
+		size += uint64(info.Size())
+	}
+	// oldest first
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].mtime.Before(infos[j].mtime)
+	})
+
+	for idx := 0; idx < len(infos) && size >= c.maxSize; idx++ {
+		if err := os.Remove(infos[idx].path); err != nil {
+			return err
+		}
+		size -= infos[idx].size
+	}
+	return nil
+}
+
+// substituteVars replaces the dnf variables that are defined by the
+// request in s. An error is returned if s contains any other variables.
+func substituteVars(req *Request, s string) (string, error) {
+	s = strings.NewReplacer(
+		"${releasever}", req.Releasever,
+		"$releasever", req.Releasever,
+		"${basearch}", req.Arch,
+		"$basearch", req.Arch,
+		"${arch}", req.Arch,
+		"$arch", req.Arch,
+	).Replace(s)
+	if strings.Contains(s, "$") {
+		return "", fmt.Errorf("unsupported variable in %q", s)
+	}
+	return s, nil
+}
+
+// repoHTTPClient returns the client to fetch metadata of the repository
+// with. Local repositories with file:// urls are supported as well.
+func repoHTTPClient(req *Request, repo repoConfig, timeout time.Duration) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+	transport.RegisterProtocol("file", http.NewFileTransport(http.Dir("/")))
+	if req.Proxy != "" {
+		proxyURL, err := url.Parse(req.Proxy)
+		if err != nil {
+			return nil, err
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	if repo.SSLVerify != nil && !*repo.SSLVerify {
+		transport.TLSClientConfig.InsecureSkipVerify = true
+	}
+	if repo.SSLCACert != "" {
+		caCert, err := os.ReadFile(repo.SSLCACert)
+		if err != nil {
+			return nil, err
+		}
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("no certificates found in %s", repo.SSLCACert)
+		}
+		transport.TLSClientConfig.RootCAs = roots
+	}
+	if repo.SSLClientCert != "" {
+		cert, err := tls.LoadX509KeyPair(repo.SSLClientCert, repo.SSLClientKey)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig.Certificates = []tls.Certificate{cert}
+	}
+	return &http.Client{
+		Transport: transport,
+		Timeout:   timeout,
+	}, nil
+}
+
+func fetch(client *http.Client, url string) ([]byte, error) {
+	body, err := open(client, url)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return io.ReadAll(body)
+}
+
+// open returns the body of the url, which has to be closed by the caller
+func open(client *http.Client, url string) (io.ReadCloser, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("fetching %s failed: unexpected status %q", url, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// metalink is the part of a metalink with the checksums and the mirrors of
+// repomd.xml
+type metalink struct {
+	Files []struct {
+		Name   string `xml:"name,attr"`
+		Hashes []struct {
+			Type  string `xml:"type,attr"`
+			Value string `xml:",chardata"`
+		} `xml:"verification>hash"`
+		URLs []struct {
+			Protocol string `xml:"protocol,attr"`
+			Value    string `xml:",chardata"`
+		} `xml:"resources>url"`
+	} `xml:"files>file"`
+}
+
+// parseMetalink fetches and parses the metalink of the repository
+func parseMetalink(client *http.Client, req *Request, repo repoConfig) (*metalink, error) {
+	metalinkURL, err := substituteVars(req, repo.Metalink)
+	if err != nil {
+		return nil, err
+	}
+	data, err := fetch(client, metalinkURL)
+	if err != nil {
+		return nil, err
+	}
+	var ml metalink
+	if err := xml.Unmarshal(data, &ml); err != nil {
+		return nil, fmt.Errorf("cannot parse metalink %s: %w", metalinkURL, err)
+	}
+	return &ml, nil
+}
+
+// repoBaseURLs returns the baseurls of the repository, the mirrors of
+// metalinks and mirrorlists are resolved
+func repoBaseURLs(client *http.Client, req *Request, repo repoConfig) ([]string, error) {
+	var baseURLs []string
+	switch {
+	case repo.Metalink != "":
+		ml, err := parseMetalink(client, req, repo)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range ml.Files {
+			if file.Name != "repomd.xml" {
+				continue
+			}
+			for _, u := range file.URLs {
+				if u.Protocol != "http" && u.Protocol != "https" {
+					continue
+				}
+				baseURLs = append(baseURLs, strings.TrimSuffix(strings.TrimSpace(u.Value), "repodata/repomd.xml"))
+			}
+		}
+	case repo.MirrorList != "":
+		mirrorListURL, err := substituteVars(req, repo.MirrorList)
+		if err != nil {
+			return nil, err
+		}
+		data, err := fetch(client, mirrorListURL)
+		if err != nil {
+			return nil, err
+		}
+		scanner := bufio.NewScanner(strings.NewReader(string(data)))
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line != "" && !strings.HasPrefix(line, "#") {
+				baseURLs = append(baseURLs, line)
+			}
+		}
+	default:
+		for _, baseURL := range repo.BaseURLs {
+			baseURL, err := substituteVars(req, baseURL)
+			if err != nil {
+				return nil, err
+			}
+			baseURLs = append(baseURLs, baseURL)
+		}
+	}
+	if len(baseURLs) == 0 {
+		return nil, fmt.Errorf("no baseurl")
+	}
+	return baseURLs, nil
+}
+
+// repomdChecksum returns the sha256 checksum of the current repomd.xml of
+// the repository. For metalinks the checksum is taken from the metalink,
+// for mirrorlists the repomd.xml of the first reachable mirror is used.
+func (c *depsolveCache) repomdChecksum(req *Request, repo repoConfig) (string, error) {
+	client, err := repoHTTPClient(req, repo, c.timeout)
+	if err != nil {
+		return "", err
+	}
+
+	if repo.Metalink != "" {
+		ml, err := parseMetalink(client, req, repo)
+		if err != nil {
+			return "", err
+		}
+		for _, file := range ml.Files {
+			if file.Name != "repomd.xml" {
+				continue
+			}
+			for _, hash := range file.Hashes {
+				if hash.Type == "sha256" {
+					return strings.TrimSpace(hash.Value), nil
+				}
+			}
+		}
+		return "", fmt.Errorf("no repomd.xml checksum in metalink %s", repo.Metalink)
+	}
+
+	baseURLs, err := repoBaseURLs(client, req, repo)
+	if err != nil {
+		return "", err
+	}
+	var data []byte
+	for _, baseURL := range baseURLs {
+		data, err = fetch(client, strings.TrimSuffix(baseURL, "/")+"/repodata/repomd.xml")
+		if err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(data)), nil
+}
@@ -0,0 +1,173 @@
+package dnfjson
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/pkg/rpmmd"
+	"github.com/osbuild/images/pkg/sbom"
+)
+
+// fakeDepsolver returns a solver that uses a fake osbuild-depsolve-dnf,
+// which counts its calls in the returned file, and caches depsolve results
+func fakeDepsolver(t *testing.T) (*Solver, string) {
+	tmpdir := t.TempDir()
+	fakeSolver := `#!/bin/sh -e
+cat - > /dev/null
+echo call >> "$0".calls
+echo '{"solver": "dnf5"}'
+`
+	fakeSolverPath := filepath.Join(tmpdir, "fake-solver")
+	err := os.WriteFile(fakeSolverPath, []byte(fakeSolver), 0755) //nolint:gosec
+	require.NoError(t, err)
+
+	solver := NewSolver("platform:f38", "38", "x86_64", "fedora-38", filepath.Join(tmpdir, "rpmmd"))
+	solver.SetDNFJSONPath(fakeSolverPath)
+	solver.SetDepsolveCache(filepath.Join(tmpdir, "depsolve"), 1024*1024)
+	return solver, fakeSolverPath + ".calls"
+}
+
+func countCalls(t *testing.T, path string) int {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return 0
+	}
+	require.NoError(t, err)
+	return strings.Count(string(data), "call\n")
+}
+
+func TestDepsolveCache(t *testing.T) {
+	repomd := "<repomd>1</repomd>"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/38/x86_64/repodata/repomd.xml":
+			fmt.Fprint(w, repomd)
+		case "/metalink":
+			fmt.Fprintf(w, `<?xml version="1.0" encoding="utf-8"?>
+<metalink version="3.0" xmlns="http://www.metalinker.org/">
+  <files>
+    <file name="repomd.xml">
+      <verification>
+        <hash type="md5">1234</hash>
+        <hash type="sha256">%x</hash>
+      </verification>
+    </file>
+  </files>
+</metalink>`, sha256.Sum256([]byte(repomd)))
+		case "/mirrorlist":
+			fmt.Fprintf(w, "# mirrors\nhttp://%s/unreachable/\nhttp://%s/38/x86_64/\n", r.Host, r.Host)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	for name, repo := range map[string]rpmmd.RepoConfig{
+		"baseurl":    {Name: "baseurl", BaseURLs: []string{srv.URL + "/$releasever/$basearch/"}},
+		"metalink":   {Name: "metalink", Metalink: srv.URL + "/metalink"},
+		"mirrorlist": {Name: "mirrorlist", MirrorList: srv.URL + "/mirrorlist"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			repomd = "<repomd>1</repomd>"
+			solver, calls := fakeDepsolver(t)
+			pkgSets := []rpmmd.PackageSet{
+				{Include: []string{"kernel"}, Repositories: []rpmmd.RepoConfig{repo}},
+			}
+
+			res, err := solver.Depsolve(pkgSets, sbom.StandardTypeNone)
+			require.NoError(t, err)
+			assert.Equal(t, "dnf5", res.Solver)
+			assert.Equal(t, 1, countCalls(t, calls))
+
+			// cached
+			cached, err := solver.Depsolve(pkgSets, sbom.StandardTypeNone)
+			require.NoError(t, err)
+			assert.Equal(t, res, cached)
+			assert.Equal(t, 1, countCalls(t, calls))
+
+			// different package sets are not
+			_, err = solver.Depsolve([]rpmmd.PackageSet{
+				{Include: []string{"bash"}, Repositories: []rpmmd.RepoConfig{repo}},
+			}, sbom.StandardTypeNone)
+			require.NoError(t, err)
+			assert.Equal(t, 2, countCalls(t, calls))
+
+			// changed repository metadata invalidates the cache
+			repomd = "<repomd>22</repomd>"
+			_, err = solver.Depsolve(pkgSets, sbom.StandardTypeNone)
+			require.NoError(t, err)
+			assert.Equal(t, 3, countCalls(t, calls))
+		})
+	}
+}
+
+func TestDepsolveCacheUncacheable(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	for name, repo := range map[string]rpmmd.RepoConfig{
+		"unreachable":      {Name: "unreachable", BaseURLs: []string{srv.URL}},
+		"unknown-variable": {Name: "unknown-variable", BaseURLs: []string{srv.URL + "/$unknown"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			solver, calls := fakeDepsolver(t)
+			pkgSets := []rpmmd.PackageSet{
+				{Include: []string{"kernel"}, Repositories: []rpmmd.RepoConfig{repo}},
+			}
+			for i := 1; i <= 2; i++ {
+				_, err := solver.Depsolve(pkgSets, sbom.StandardTypeNone)
+				require.NoError(t, err)
+				assert.Equal(t, i, countCalls(t, calls))
+			}
+			assert.NoDirExists(t, solver.depsolveCache.root)
+		})
+	}
+}
+
+func TestDepsolveCacheShrink(t *testing.T) {
+	root := t.TempDir()
+	for idx, name := range []string{"a.json", "b.json", "c.json", "unknown"} {
+		path := filepath.Join(root, name)
+		require.NoError(t, os.WriteFile(path, make([]byte, 100), 0600))
+		mtime := time.Unix(int64(idx), 0)
+		require.NoError(t, os.Chtimes(path, mtime, mtime))
+	}
+
+	cache := newDepsolveCache(root, 250)
+	require.NoError(t, cache.shrink())
+
+	entries, err := os.ReadDir(root)
+	require.NoError(t, err)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	// the oldest entry is removed, unknown files are kept
+	assert.Equal(t, []string{"b.json", "c.json", "unknown"}, names)
+
+	// a missing cache directory is fine
+	require.NoError(t, newDepsolveCache(filepath.Join(root, "missing"), 0).shrink())
+}
+
+func TestDepsolveCacheCleanCache(t *testing.T) {
+	solver, _ := fakeDepsolver(t)
+	require.NoError(t, solver.depsolveCache.store("key", []byte("{}")))
+	output, ok := solver.depsolveCache.get("key")
+	assert.True(t, ok)
+	assert.Equal(t, "{}", string(output))
+
+	solver.depsolveCache.maxSize = 1
+	require.NoError(t, solver.CleanCache())
+	_, ok = solver.depsolveCache.get("key")
+	assert.False(t, ok)
+}
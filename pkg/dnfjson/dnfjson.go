@@ -9,6 +9,9 @@
 // Solver. This type can't be used for depsolving, but can be used to create
 // configured Solver instances sharing the same cache directory.
 //
+// The experimental RepodataSolver implements the same Depsolver interface in
+// pure Go, without the dnf-json script.
+//
 // This package relies on the types defined in rpmmd to describe RPM package
 // metadata.
 package dnfjson
@@ -45,6 +48,9 @@ type BaseSolver struct {
 	dnfJsonCmd []string
 
 	resultCache *dnfCache
+
+	// optional persistent cache of depsolve results
+	depsolveCache *depsolveCache
 }
 
 // Find the osbuild-depsolve-dnf script. This checks the default location in
@@ -87,6 +93,15 @@ func (s *BaseSolver) SetMaxCacheSize(size uint64) {
 	s.cache.maxSize = size
 }
 
+// SetDepsolveCache enables the persistent cache of depsolve results in
+// dir. Cached results are reused as long as the repomd.xml of all the
+// repositories of a depsolve request stays the same, which costs one
+// metadata request per repository instead of a full depsolve. maxSize is
+// the maximum size of the cache after a CleanCache() call.
+func (s *BaseSolver) SetDepsolveCache(dir string, maxSize uint64) {
+	s.depsolveCache = newDepsolveCache(dir, maxSize)
+}
+
 // SetDNFJSONPath sets the path to the dnf-json binary and optionally any command line arguments.
 func (s *BaseSolver) SetDNFJSONPath(cmd string, args ...string) {
 	s.dnfJsonCmd = append([]string{cmd}, args...)
@@ -108,9 +123,15 @@ func (bs *BaseSolver) NewWithConfig(modulePlatformID, releaseVer, arch, distro s
 
 // CleanCache deletes the least recently used repository metadata caches until
 // the total size of the cache falls below the configured maximum size (see
-// SetMaxCacheSize()).
+// SetMaxCacheSize()). The same is done for the depsolve cache, if enabled
+// (see SetDepsolveCache()).
 func (bs *BaseSolver) CleanCache() error {
 	bs.resultCache.CleanCache()
+	if bs.depsolveCache != nil {
+		if err := bs.depsolveCache.shrink(); err != nil {
+			return err
+		}
+	}
 	return bs.cache.shrink()
 }
 
@@ -174,6 +195,15 @@ type DepsolveResult struct {
 	Solver   string
 }
 
+// Depsolver resolves the dependencies of a chain of package sets, see
+// Solver.Depsolve(). Solver uses osbuild-depsolve-dnf, RepodataSolver is an
+// experimental implementation that doesn't need dnf.
+type Depsolver interface {
+	Depsolve(pkgSets []rpmmd.PackageSet, sbomType sbom.StandardType) (*DepsolveResult, error)
+}
+
+var _ Depsolver = &Solver{}
+
 // Create a new Solver with the given configuration. Initialising a Solver also loads system subscription information.
 func NewSolver(modulePlatformID, releaseVer, arch, distro, cacheDir string) *Solver {
 	s := NewBaseSolver(cacheDir)
@@ -213,6 +243,13 @@ func (s *Solver) SetProxy(proxy string) error {
 // transactions in a chain.  It returns a list of all packages (with solved
 // dependencies) that will be installed into the system.
 func (s *Solver) Depsolve(pkgSets []rpmmd.PackageSet, sbomType sbom.StandardType) (*DepsolveResult, error) {
+	// osbuild-depsolve-dnf only generates SPDX documents. A CycloneDX
+	// document describes an image or a pipeline that is not known here, it
+	// is generated from the depsolved packages by the caller.
+	if sbomType == sbom.StandardTypeCycloneDX {
+		return nil, fmt.Errorf("the depsolver does not generate %s SBOMs, see sbom.NewCycloneDXDocument()", sbomType)
+	}
+
 	req, rhsmMap, err := s.makeDepsolveRequest(pkgSets, sbomType)
 	if err != nil {
 		return nil, fmt.Errorf("makeDepsolveRequest failed: %w", err)
@@ -222,17 +259,10 @@ func (s *Solver) Depsolve(pkgSets []rpmmd.PackageSet, sbomType sbom.StandardType
 	s.cache.locker.RLock()
 	defer s.cache.locker.RUnlock()
 
-	output, err := run(s.dnfJsonCmd, req, s.Stderr)
+	output, err := s.runDepsolve(req)
 	if err != nil {
-		return nil, fmt.Errorf("running osbuild-depsolve-dnf failed:\n%w", err)
-	}
-	// touch repos to now
-	now := time.Now().Local()
-	for _, r := range req.Arguments.Repos {
-		// ignore errors
-		_ = s.cache.touchRepo(r.Hash(), now)
+		return nil, err
 	}
-	s.cache.updateInfo()
 
 	var result depsolveResult
 	dec := json.NewDecoder(bytes.NewReader(output))
@@ -260,6 +290,40 @@ func (s *Solver) Depsolve(pkgSets []rpmmd.PackageSet, sbomType sbom.StandardType
 	}, nil
 }
 
+// runDepsolve runs the depsolve request, or returns its cached output if the
+// depsolve cache is enabled and has a result for it
+func (s *Solver) runDepsolve(req *Request) ([]byte, error) {
+	var cacheKey string
+	if s.depsolveCache != nil {
+		// requests that can't be checked for changed repositories are
+		// just not cached
+		cacheKey, _ = s.depsolveCache.key(req)
+	}
+	if cacheKey != "" {
+		if output, ok := s.depsolveCache.get(cacheKey); ok {
+			return output, nil
+		}
+	}
+
+	output, err := run(s.dnfJsonCmd, req, s.Stderr)
+	if err != nil {
+		return nil, fmt.Errorf("running osbuild-depsolve-dnf failed:\n%w", err)
+	}
+	// touch repos to now
+	now := time.Now().Local()
+	for _, r := range req.Arguments.Repos {
+		// ignore errors
+		_ = s.cache.touchRepo(r.Hash(), now)
+	}
+	s.cache.updateInfo()
+
+	if cacheKey != "" {
+		// a failure to cache the result is not a failure to depsolve
+		_ = s.depsolveCache.store(cacheKey, output)
+	}
+	return output, nil
+}
+
 // FetchMetadata returns the list of all the available packages in repos and
 // their info.
 func (s *Solver) FetchMetadata(repos []rpmmd.RepoConfig) (rpmmd.PackageList, error) {
@@ -268,12 +332,17 @@ func (s *Solver) FetchMetadata(repos []rpmmd.RepoConfig) (rpmmd.PackageList, err
 		return nil, err
 	}
 
+	reqHash, err := req.Hash()
+	if err != nil {
+		return nil, err
+	}
+
 	// get non-exclusive read lock
 	s.cache.locker.RLock()
 	defer s.cache.locker.RUnlock()
 
 	// Is this cached?
-	if pkgs, ok := s.resultCache.Get(req.Hash()); ok {
+	if pkgs, ok := s.resultCache.Get(reqHash); ok {
 		return pkgs, nil
 	}
 
@@ -303,7 +372,7 @@ func (s *Solver) FetchMetadata(repos []rpmmd.RepoConfig) (rpmmd.PackageList, err
 	})
 
 	// Cache the results
-	s.resultCache.Store(req.Hash(), pkgs)
+	s.resultCache.Store(reqHash, pkgs)
 	return pkgs, nil
 }
 
@@ -314,12 +383,17 @@ func (s *Solver) SearchMetadata(repos []rpmmd.RepoConfig, packages []string) (rp
 		return nil, err
 	}
 
+	reqHash, err := req.Hash()
+	if err != nil {
+		return nil, err
+	}
+
 	// get non-exclusive read lock
 	s.cache.locker.RLock()
 	defer s.cache.locker.RUnlock()
 
 	// Is this cached?
-	if pkgs, ok := s.resultCache.Get(req.Hash()); ok {
+	if pkgs, ok := s.resultCache.Get(reqHash); ok {
 		return pkgs, nil
 	}
 
@@ -349,7 +423,7 @@ func (s *Solver) SearchMetadata(repos []rpmmd.RepoConfig, packages []string) (rp
 	})
 
 	// Cache the results
-	s.resultCache.Store(req.Hash(), pkgs)
+	s.resultCache.Store(reqHash, pkgs)
 	return pkgs, nil
 }
 
@@ -684,19 +758,33 @@ type Request struct {
 // Hash returns a hash of the unique aspects of the Request
 //
 //nolint:errcheck
-func (r *Request) Hash() string {
+func (r *Request) Hash() (string, error) {
 	h := sha256.New()
 
 	h.Write([]byte(r.Command))
 	h.Write([]byte(r.ModulePlatformID))
 	h.Write([]byte(r.Arch))
+	h.Write([]byte(r.Releasever))
 	for _, repo := range r.Arguments.Repos {
 		h.Write([]byte(repo.Hash()))
+		// the name and the (rhsm) secrets are part of depsolve results
+		h.Write([]byte(repo.Name))
+		h.Write([]byte(repo.SSLCACert + repo.SSLClientKey + repo.SSLClientCert))
 	}
 	h.Write([]byte(fmt.Sprintf("%T", r.Arguments.Search.Latest)))
 	h.Write([]byte(strings.Join(r.Arguments.Search.Packages, "")))
+	for _, transaction := range r.Arguments.Transactions {
+		if err := json.NewEncoder(h).Encode(transaction); err != nil {
+			return "", fmt.Errorf("cannot hash the depsolve transactions: %w", err)
+		}
+	}
+	h.Write([]byte(r.Arguments.RootDir))
+	h.Write([]byte(strings.Join(r.Arguments.OptionalMetadata, "")))
+	if r.Arguments.Sbom != nil {
+		h.Write([]byte(r.Arguments.Sbom.Type))
+	}
 
-	return fmt.Sprintf("%x", h.Sum(nil))
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
 }
 
 type sbomRequest struct {
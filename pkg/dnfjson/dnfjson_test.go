@@ -833,13 +833,16 @@ func TestRequestHash(t *testing.T) {
 
 	req, err := solver.makeDumpRequest(repos)
 	assert.Nil(t, err)
-	hash := req.Hash()
+	hash, err := req.Hash()
+	assert.Nil(t, err)
 	assert.Equal(t, 64, len(hash))
 
 	req, err = solver.makeSearchRequest(repos, []string{"package0*"})
 	assert.Nil(t, err)
-	assert.Equal(t, 64, len(req.Hash()))
-	assert.NotEqual(t, hash, req.Hash())
+	searchHash, err := req.Hash()
+	assert.Nil(t, err)
+	assert.Equal(t, 64, len(searchHash))
+	assert.NotEqual(t, hash, searchHash)
 }
 
 func TestRepoConfigMarshalAlsmostEmpty(t *testing.T) {
@@ -862,6 +865,12 @@ exit 1
 	assert.EqualError(t, err, `DNF error occurred: InternalError: dnf-json output was empty`)
 }
 
+func TestDepsolveCycloneDXUnsupported(t *testing.T) {
+	solver := NewSolver("platform:el9", "9", "x86_64", "centos-9", t.TempDir())
+	_, err := solver.Depsolve([]rpmmd.PackageSet{{Include: []string{"bash"}}}, sbom.StandardTypeCycloneDX)
+	assert.EqualError(t, err, "the depsolver does not generate cyclonedx SBOMs, see sbom.NewCycloneDXDocument()")
+}
+
 func TestSolverRunWithSolverNoError(t *testing.T) {
 	tmpdir := t.TempDir()
 	fakeSolver := `#!/bin/sh -e
@@ -0,0 +1,493 @@
+package dnfjson
+
+import (
+	"compress/bzip2"
+	"compress/gzip"
+	"crypto/sha1" //nolint:gosec
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+
+	"github.com/osbuild/images/pkg/rpmmd"
+	"github.com/osbuild/images/pkg/sbom"
+)
+
+// RepodataSolverName is the name of the solver in the results of the
+// RepodataSolver
+const RepodataSolverName = "repodata"
+
+// RepodataSolver is an experimental Depsolver that resolves dependencies in
+// pure Go from the repomd.xml, primary.xml and comps metadata of the
+// repositories, so it can be used on hosts without python3-dnf.
+//
+// Only basic requires/provides resolution is done: the newest package that
+// provides a requirement is picked, weak dependencies are installed when
+// possible and package groups install their mandatory and default packages.
+// Conflicts, obsoletes, modules and repository priorities are ignored, so
+// the results can differ from the ones of dnf.
+type RepodataSolver struct {
+	// the Solver is only used to create the depsolve requests and for its
+	// repository metadata cache, osbuild-depsolve-dnf is never run
+	solver *Solver
+
+	// timeout for fetching the metadata of a repository
+	timeout time.Duration
+
+	mu sync.Mutex
+	// parsed metadata, keyed by the repository ID and the checksum of its
+	// repomd.xml
+	metadata map[string]*repoMetadata
+}
+
+var _ Depsolver = &RepodataSolver{}
+
+// NewRepodataSolver creates a new RepodataSolver with the given
+// configuration. The downloaded metadata is kept in cacheDir, next to the
+// metadata of the dnf based Solver.
+func NewRepodataSolver(modulePlatformID, releaseVer, arch, distro, cacheDir string) *RepodataSolver {
+	return &RepodataSolver{
+		solver:   NewSolver(modulePlatformID, releaseVer, arch, distro, cacheDir),
+		timeout:  5 * time.Minute,
+		metadata: make(map[string]*repoMetadata),
+	}
+}
+
+// SetProxy sets the proxy to use for fetching the repository metadata
+func (s *RepodataSolver) SetProxy(proxy string) error {
+	return s.solver.SetProxy(proxy)
+}
+
+// Depsolve the chain of package sets like Solver.Depsolve(). SBOMs are not
+// supported, CycloneDX documents can be generated from the depsolved
+// packages with sbom.NewCycloneDXDocument().
+func (s *RepodataSolver) Depsolve(pkgSets []rpmmd.PackageSet, sbomType sbom.StandardType) (*DepsolveResult, error) {
+	if sbomType != sbom.StandardTypeNone {
+		return nil, fmt.Errorf("the %s solver does not support %s SBOMs", RepodataSolverName, sbomType)
+	}
+	req, rhsmMap, err := s.solver.makeDepsolveRequest(pkgSets, sbom.StandardTypeNone)
+	if err != nil {
+		return nil, fmt.Errorf("makeDepsolveRequest failed: %w", err)
+	}
+
+	// get non-exclusive read lock
+	s.solver.cache.locker.RLock()
+	defer s.solver.cache.locker.RUnlock()
+
+	repos := make(map[string]repoConfig, len(req.Arguments.Repos))
+	metadata := make(map[string]*repoMetadata, len(req.Arguments.Repos))
+	for _, repo := range req.Arguments.Repos {
+		md, err := s.loadRepo(req, repo)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load metadata of repository %q: %w", repo.Name, err)
+		}
+		repos[repo.ID] = repo
+		metadata[repo.ID] = md
+	}
+	s.solver.cache.updateInfo()
+
+	var installed []*rpmPackage
+	for idx, trans := range req.Arguments.Transactions {
+		if len(trans.ModuleEnableSpecs) > 0 {
+			return nil, fmt.Errorf("the %s solver does not support modules", RepodataSolverName)
+		}
+		var transRepos []*repoMetadata
+		for _, id := range trans.RepoIDs {
+			transRepos = append(transRepos, metadata[id])
+		}
+		t := newRepodataTransaction(newRepodataPool(req.Arch, transRepos, trans.ExcludeSpecs, installed), installed)
+		if err := t.installSpecs(trans.PackageSpecs); err != nil {
+			return nil, fmt.Errorf("depsolving package set %d failed: %w", idx, err)
+		}
+		if trans.InstallWeakDeps {
+			t.installWeakDeps()
+		}
+		installed = t.installed
+	}
+
+	sort.Slice(installed, func(i, j int) bool {
+		if installed[i].name != installed[j].name {
+			return installed[i].name < installed[j].name
+		}
+		return installed[i].arch < installed[j].arch
+	})
+	result := depsolveResult{
+		Repos:  repos,
+		Solver: RepodataSolverName,
+	}
+	for _, pkg := range installed {
+		result.Packages = append(result.Packages, PackageSpec{
+			Name:           pkg.name,
+			Epoch:          pkg.evr.epoch,
+			Version:        pkg.evr.version,
+			Release:        pkg.evr.release,
+			Arch:           pkg.arch,
+			RepoID:         pkg.repo.id,
+			Path:           pkg.location,
+			RemoteLocation: pkg.repo.baseURL + pkg.location,
+			Checksum:       pkg.checksum,
+		})
+	}
+	packages, modules, rpmRepos := result.toRPMMD(rhsmMap)
+
+	return &DepsolveResult{
+		Packages: packages,
+		Modules:  modules,
+		Repos:    rpmRepos,
+		Solver:   RepodataSolverName,
+	}, nil
+}
+
+// repoMetadata is the parsed metadata of a repository
+type repoMetadata struct {
+	id string
+	// baseURL of the mirror the metadata was fetched from, with a trailing
+	// slash
+	baseURL  string
+	packages []*rpmPackage
+	// the mandatory and default packages of the groups
+	groups map[string][]string
+}
+
+type repomdXML struct {
+	Data []repomdData `xml:"data"`
+}
+
+type repomdData struct {
+	Type     string `xml:"type,attr"`
+	Checksum struct {
+		Type  string `xml:"type,attr"`
+		Value string `xml:",chardata"`
+	} `xml:"checksum"`
+	Location struct {
+		Href string `xml:"href,attr"`
+	} `xml:"location"`
+}
+
+type primaryEntry struct {
+	Name  string `xml:"name,attr"`
+	Flags string `xml:"flags,attr"`
+	Epoch string `xml:"epoch,attr"`
+	Ver   string `xml:"ver,attr"`
+	Rel   string `xml:"rel,attr"`
+}
+
+type primaryPackage struct {
+	Type    string `xml:"type,attr"`
+	Name    string `xml:"name"`
+	Arch    string `xml:"arch"`
+	Version struct {
+		Epoch string `xml:"epoch,attr"`
+		Ver   string `xml:"ver,attr"`
+		Rel   string `xml:"rel,attr"`
+	} `xml:"version"`
+	Checksum struct {
+		Type  string `xml:"type,attr"`
+		Value string `xml:",chardata"`
+	} `xml:"checksum"`
+	Location struct {
+		Href string `xml:"href,attr"`
+	} `xml:"location"`
+	Provides   []primaryEntry `xml:"format>provides>entry"`
+	Requires   []primaryEntry `xml:"format>requires>entry"`
+	Recommends []primaryEntry `xml:"format>recommends>entry"`
+	Files      []string       `xml:"format>file"`
+}
+
+type compsXML struct {
+	Groups []struct {
+		ID       string `xml:"id"`
+		Packages []struct {
+			Type string `xml:"type,attr"`
+			Name string `xml:",chardata"`
+		} `xml:"packagelist>packagereq"`
+	} `xml:"group"`
+}
+
+// loadRepo returns the metadata of the repository, it is only downloaded
+// and parsed again when its repomd.xml changed
+func (s *RepodataSolver) loadRepo(req *Request, repo repoConfig) (*repoMetadata, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	client, err := repoHTTPClient(req, repo, s.timeout)
+	if err != nil {
+		return nil, err
+	}
+	baseURLs, err := repoBaseURLs(client, req, repo)
+	if err != nil {
+		return nil, err
+	}
+	var baseURL string
+	var data []byte
+	for _, baseURL = range baseURLs {
+		baseURL = strings.TrimSuffix(baseURL, "/") + "/"
+		data, err = fetch(client, baseURL+"repodata/repomd.xml")
+		if err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key := fmt.Sprintf("%s-%x", repo.Hash(), sha256.Sum256(data))
+	if md, ok := s.metadata[key]; ok {
+		return md, nil
+	}
+
+	var repomd repomdXML
+	if err := xml.Unmarshal(data, &repomd); err != nil {
+		return nil, fmt.Errorf("cannot parse repomd.xml: %w", err)
+	}
+	entries := make(map[string]repomdData)
+	for _, entry := range repomd.Data {
+		entries[entry.Type] = entry
+	}
+
+	md := &repoMetadata{
+		id:      repo.Hash(),
+		baseURL: baseURL,
+		groups:  make(map[string][]string),
+	}
+	primary, ok := entries["primary"]
+	if !ok {
+		return nil, fmt.Errorf("no primary metadata in repomd.xml")
+	}
+	if err := s.readMetadata(client, md, primary, md.readPrimary); err != nil {
+		return nil, err
+	}
+	// the compressed comps are preferred, like dnf does
+	if group, ok := entries["group_gz"]; ok {
+		err = s.readMetadata(client, md, group, md.readComps)
+	} else if group, ok := entries["group"]; ok {
+		err = s.readMetadata(client, md, group, md.readComps)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// ignore errors, the cache is just cleaned earlier
+	_ = s.solver.cache.touchRepo(md.id, time.Now().Local())
+	s.metadata[key] = md
+	return md, nil
+}
+
+// readMetadata downloads the metadata file of the repomd.xml entry into the
+// cache, if needed, and parses it with read
+func (s *RepodataSolver) readMetadata(client *http.Client, md *repoMetadata, entry repomdData, read func(r io.Reader) error) error {
+	href := entry.Location.Href
+	if _, err := hex.DecodeString(entry.Checksum.Value); err != nil || entry.Checksum.Value == "" {
+		return fmt.Errorf("invalid checksum %q of %s", entry.Checksum.Value, href)
+	}
+	// the repository ID prefix makes the files part of the rpm cache
+	dir := filepath.Join(s.solver.GetCacheDir(), md.id+"-repodata")
+	cachePath := filepath.Join(dir, entry.Checksum.Value+compressionExt(href))
+	if _, err := os.Stat(cachePath); err != nil {
+		if err := downloadMetadata(client, md.baseURL+href, entry.Checksum.Type, entry.Checksum.Value, cachePath); err != nil {
+			return err
+		}
+	}
+
+	f, err := os.Open(cachePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r, err := decompress(f, href)
+	if err != nil {
+		return fmt.Errorf("cannot decompress %s: %w", href, err)
+	}
+	defer r.Close()
+	if err := read(r); err != nil {
+		return fmt.Errorf("cannot parse %s: %w", href, err)
+	}
+	return nil
+}
+
+// downloadMetadata downloads the url to dest and verifies its checksum
+func downloadMetadata(client *http.Client, url, checksumType, checksum, dest string) error {
+	var h hash.Hash
+	switch checksumType {
+	case "sha", "sha1":
+		h = sha1.New() //nolint:gosec
+	case "sha256":
+		h = sha256.New()
+	case "sha384":
+		h = sha512.New384()
+	case "sha512":
+		h = sha512.New()
+	default:
+		return fmt.Errorf("unsupported checksum type %q of %s", checksumType, url)
+	}
+
+	body, err := open(client, url)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dest), 0750); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(dest), ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = io.Copy(io.MultiWriter(tmp, h), body)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("fetching %s failed: %w", url, err)
+	}
+	if actual := hex.EncodeToString(h.Sum(nil)); actual != checksum {
+		return fmt.Errorf("checksum mismatch for %s: expected %s, got %s", url, checksum, actual)
+	}
+	return os.Rename(tmp.Name(), dest)
+}
+
+// compressionExt returns the extension of the compression of the file
+func compressionExt(name string) string {
+	switch ext := path.Ext(name); ext {
+	case ".gz", ".xz", ".zst", ".bz2":
+		return ext
+	}
+	return ""
+}
+
+func decompress(r io.Reader, name string) (io.ReadCloser, error) {
+	switch compressionExt(name) {
+	case ".gz":
+		return gzip.NewReader(r)
+	case ".xz":
+		xr, err := xz.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return io.NopCloser(xr), nil
+	case ".zst":
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return zr.IOReadCloser(), nil
+	case ".bz2":
+		return io.NopCloser(bzip2.NewReader(r)), nil
+	}
+	return io.NopCloser(r), nil
+}
+
+// readPrimary parses the packages of primary.xml
+func (md *repoMetadata) readPrimary(r io.Reader) error {
+	dec := xml.NewDecoder(r)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "package" {
+			continue
+		}
+		var p primaryPackage
+		if err := dec.DecodeElement(&p, &start); err != nil {
+			return err
+		}
+		if p.Type != "rpm" || p.Arch == "src" || p.Arch == "nosrc" {
+			continue
+		}
+		epoch, err := parseEpoch(p.Version.Epoch)
+		if err != nil {
+			return fmt.Errorf("package %s: %w", p.Name, err)
+		}
+		pkg := &rpmPackage{
+			name:     p.Name,
+			arch:     p.Arch,
+			evr:      evr{epoch: epoch, version: p.Version.Ver, release: p.Version.Rel},
+			checksum: p.Checksum.Type + ":" + strings.TrimSpace(p.Checksum.Value),
+			location: p.Location.Href,
+			repo:     md,
+			files:    p.Files,
+		}
+		for _, entries := range []struct {
+			from []primaryEntry
+			to   *[]rpmDep
+		}{
+			{p.Provides, &pkg.provides},
+			{p.Requires, &pkg.requires},
+			{p.Recommends, &pkg.recommends},
+		} {
+			for _, entry := range entries.from {
+				dep, err := entry.dep()
+				if err != nil {
+					return fmt.Errorf("package %s: %w", p.Name, err)
+				}
+				*entries.to = append(*entries.to, dep)
+			}
+		}
+		md.packages = append(md.packages, pkg)
+	}
+}
+
+// readComps parses the packages of the groups of the comps
+func (md *repoMetadata) readComps(r io.Reader) error {
+	var comps compsXML
+	if err := xml.NewDecoder(r).Decode(&comps); err != nil {
+		return err
+	}
+	for _, group := range comps.Groups {
+		var names []string
+		for _, pkg := range group.Packages {
+			if pkg.Type == "mandatory" || pkg.Type == "default" {
+				names = append(names, strings.TrimSpace(pkg.Name))
+			}
+		}
+		md.groups[group.ID] = names
+	}
+	return nil
+}
+
+func (e primaryEntry) dep() (rpmDep, error) {
+	dep := rpmDep{
+		name:  e.Name,
+		flags: e.Flags,
+	}
+	if e.Flags != "" {
+		epoch, err := parseEpoch(e.Epoch)
+		if err != nil {
+			return dep, err
+		}
+		dep.evr = evr{epoch: epoch, version: e.Ver, release: e.Rel}
+	}
+	return dep, nil
+}
+
+func parseEpoch(epoch string) (uint, error) {
+	if epoch == "" {
+		return 0, nil
+	}
+	e, err := strconv.ParseUint(epoch, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid epoch %q", epoch)
+	}
+	return uint(e), nil
+}
@@ -0,0 +1,626 @@
+package dnfjson
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// rpmPackage is a package in the metadata of a repository
+type rpmPackage struct {
+	name     string
+	arch     string
+	evr      evr
+	checksum string
+	location string
+	repo     *repoMetadata
+
+	provides   []rpmDep
+	requires   []rpmDep
+	recommends []rpmDep
+	files      []string
+}
+
+func (p *rpmPackage) String() string {
+	if p.evr.epoch == 0 {
+		return fmt.Sprintf("%s-%s-%s.%s", p.name, p.evr.version, p.evr.release, p.arch)
+	}
+	return fmt.Sprintf("%s-%d:%s-%s.%s", p.name, p.evr.epoch, p.evr.version, p.evr.release, p.arch)
+}
+
+// evr is the epoch, version and release of a package or dependency, the
+// release of dependencies is optional
+type evr struct {
+	epoch   uint
+	version string
+	release string
+}
+
+// compareEVR compares two EVRs like rpm does, the releases are only
+// compared if both are set
+func compareEVR(a, b evr) int {
+	if a.epoch != b.epoch {
+		if a.epoch < b.epoch {
+			return -1
+		}
+		return 1
+	}
+	if c := rpmvercmp(a.version, b.version); c != 0 {
+		return c
+	}
+	if a.release == "" || b.release == "" {
+		return 0
+	}
+	return rpmvercmp(a.release, b.release)
+}
+
+func parseEVR(s string) evr {
+	var e evr
+	if idx := strings.Index(s, ":"); idx >= 0 {
+		// invalid epochs are just ignored
+		e.epoch, _ = parseEpoch(s[:idx])
+		s = s[idx+1:]
+	}
+	if idx := strings.LastIndex(s, "-"); idx >= 0 {
+		e.release = s[idx+1:]
+		s = s[:idx]
+	}
+	e.version = s
+	return e
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isAlnum(c byte) bool {
+	return isDigit(c) || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// rpmvercmp compares two version or release strings like rpm does
+func rpmvercmp(a, b string) int {
+	if a == b {
+		return 0
+	}
+	for {
+		for len(a) > 0 && !isAlnum(a[0]) && a[0] != '~' && a[0] != '^' {
+			a = a[1:]
+		}
+		for len(b) > 0 && !isAlnum(b[0]) && b[0] != '~' && b[0] != '^' {
+			b = b[1:]
+		}
+
+		// a tilde sorts before everything, even the end of the string
+		if strings.HasPrefix(a, "~") || strings.HasPrefix(b, "~") {
+			if !strings.HasPrefix(a, "~") {
+				return 1
+			}
+			if !strings.HasPrefix(b, "~") {
+				return -1
+			}
+			a, b = a[1:], b[1:]
+			continue
+		}
+		// a caret sorts after the end of the string but before everything
+		// else
+		if strings.HasPrefix(a, "^") || strings.HasPrefix(b, "^") {
+			if a == "" {
+				return -1
+			}
+			if b == "" {
+				return 1
+			}
+			if !strings.HasPrefix(a, "^") {
+				return 1
+			}
+			if !strings.HasPrefix(b, "^") {
+				return -1
+			}
+			a, b = a[1:], b[1:]
+			continue
+		}
+		if a == "" || b == "" {
+			break
+		}
+
+		numeric := isDigit(a[0])
+		segment := func(s string) (string, string) {
+			idx := 0
+			for idx < len(s) && ((numeric && isDigit(s[idx])) || (!numeric && isAlnum(s[idx]) && !isDigit(s[idx]))) {
+				idx++
+			}
+			return s[:idx], s[idx:]
+		}
+		var segA, segB string
+		segA, a = segment(a)
+		segB, b = segment(b)
+		if segB == "" {
+			// segments of different types, numeric ones are newer
+			if numeric {
+				return 1
+			}
+			return -1
+		}
+		if numeric {
+			segA = strings.TrimLeft(segA, "0")
+			segB = strings.TrimLeft(segB, "0")
+			if len(segA) != len(segB) {
+				if len(segA) < len(segB) {
+					return -1
+				}
+				return 1
+			}
+		}
+		if c := strings.Compare(segA, segB); c != 0 {
+			return c
+		}
+	}
+	// the version with characters left is newer
+	switch {
+	case a == "" && b == "":
+		return 0
+	case a == "":
+		return -1
+	}
+	return 1
+}
+
+// rpmDep is a dependency or provide of a package, the flags are the ones
+// of the repository metadata (EQ, LT, LE, GT or GE), an empty flag matches
+// any version
+type rpmDep struct {
+	name  string
+	flags string
+	evr   evr
+}
+
+func (d rpmDep) String() string {
+	op, ok := map[string]string{"EQ": "=", "LT": "<", "LE": "<=", "GT": ">", "GE": ">="}[d.flags]
+	if !ok {
+		return d.name
+	}
+	v := d.evr.version
+	if d.evr.release != "" {
+		v += "-" + d.evr.release
+	}
+	if d.evr.epoch != 0 {
+		v = fmt.Sprintf("%d:%s", d.evr.epoch, v)
+	}
+	return fmt.Sprintf("%s %s %s", d.name, op, v)
+}
+
+// overlaps returns true if the version ranges of the two dependencies with
+// the same name overlap
+func (d rpmDep) overlaps(other rpmDep) bool {
+	if d.flags == "" || other.flags == "" {
+		return true
+	}
+	less := func(flags string) bool { return flags == "LT" || flags == "LE" }
+	greater := func(flags string) bool { return flags == "GT" || flags == "GE" }
+	equal := func(flags string) bool { return flags == "EQ" || flags == "LE" || flags == "GE" }
+
+	switch c := compareEVR(d.evr, other.evr); {
+	case c < 0:
+		return greater(d.flags) || less(other.flags)
+	case c > 0:
+		return less(d.flags) || greater(other.flags)
+	}
+	return (equal(d.flags) && equal(other.flags)) ||
+		(less(d.flags) && less(other.flags)) ||
+		(greater(d.flags) && greater(other.flags))
+}
+
+// richDep is a boolean dependency like "(a if b)", plain dependencies
+// have no op
+type richDep struct {
+	op   string
+	dep  rpmDep
+	args []*richDep
+}
+
+// parseRichDep parses a boolean dependency
+func parseRichDep(s string) (*richDep, error) {
+	dep, rest, err := parseRichTerm(tokenizeRichDep(s))
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse dependency %q: %w", s, err)
+	}
+	if len(rest) > 0 {
+		return nil, fmt.Errorf("cannot parse dependency %q: unexpected %q", s, rest[0])
+	}
+	return dep, nil
+}
+
+// tokenizeRichDep splits a boolean dependency into parentheses, operators
+// and names, names can contain balanced parentheses like "foo(x86-64)"
+func tokenizeRichDep(s string) []string {
+	var tokens []string
+	for idx := 0; idx < len(s); {
+		switch s[idx] {
+		case ' ':
+			idx++
+		case '(', ')':
+			tokens = append(tokens, s[idx:idx+1])
+			idx++
+		default:
+			start, depth := idx, 0
+			for ; idx < len(s) && s[idx] != ' ' && (s[idx] != ')' || depth > 0); idx++ {
+				switch s[idx] {
+				case '(':
+					depth++
+				case ')':
+					depth--
+				}
+			}
+			tokens = append(tokens, s[start:idx])
+		}
+	}
+	return tokens
+}
+
+func parseRichTerm(tokens []string) (*richDep, []string, error) {
+	if len(tokens) == 0 {
+		return nil, nil, fmt.Errorf("unexpected end")
+	}
+	if tokens[0] != "(" {
+		dep := rpmDep{name: tokens[0]}
+		tokens = tokens[1:]
+		if len(tokens) >= 2 {
+			flags, ok := map[string]string{"=": "EQ", "<": "LT", "<=": "LE", ">": "GT", ">=": "GE"}[tokens[0]]
+			if ok {
+				dep.flags = flags
+				dep.evr = parseEVR(tokens[1])
+				tokens = tokens[2:]
+			}
+		}
+		return &richDep{dep: dep}, tokens, nil
+	}
+
+	first, tokens, err := parseRichTerm(tokens[1:])
+	if err != nil {
+		return nil, nil, err
+	}
+	dep := &richDep{args: []*richDep{first}}
+	for {
+		if len(tokens) == 0 {
+			return nil, nil, fmt.Errorf("missing )")
+		}
+		op := tokens[0]
+		if op == ")" {
+			break
+		}
+		conditional := dep.op == "if" || dep.op == "unless"
+		switch {
+		case dep.op == "" && op != "else":
+			dep.op = op
+		case op == "else" && conditional && len(dep.args) == 2:
+		case op != dep.op || conditional:
+			return nil, nil, fmt.Errorf("unexpected %q", op)
+		}
+		switch op {
+		case "and", "or", "if", "unless", "with", "without", "else":
+		default:
+			return nil, nil, fmt.Errorf("unknown operator %q", op)
+		}
+		var arg *richDep
+		arg, tokens, err = parseRichTerm(tokens[1:])
+		if err != nil {
+			return nil, nil, err
+		}
+		dep.args = append(dep.args, arg)
+	}
+	if dep.op == "" {
+		// just parentheses
+		return first, tokens[1:], nil
+	}
+	return dep, tokens[1:], nil
+}
+
+// repodataPool are the packages that are available for a transaction
+type repodataPool struct {
+	byName map[string][]*rpmPackage
+	// the packages by the names of their provides and files
+	providers map[string][]*rpmPackage
+	// the order of the repositories, lower is preferred
+	repoOrder map[*repoMetadata]int
+	// the groups of all repositories
+	groups map[string][]string
+}
+
+// newRepodataPool creates a pool with the packages of the repos that are
+// compatible with the arch and not excluded, and the installed packages
+func newRepodataPool(arch string, repos []*repoMetadata, excludes []string, installed []*rpmPackage) *repodataPool {
+	pool := &repodataPool{
+		byName:    make(map[string][]*rpmPackage),
+		providers: make(map[string][]*rpmPackage),
+		repoOrder: make(map[*repoMetadata]int),
+		groups:    make(map[string][]string),
+	}
+	add := func(pkg *rpmPackage) {
+		pool.byName[pkg.name] = append(pool.byName[pkg.name], pkg)
+		for _, prov := range pkg.provides {
+			pool.providers[prov.name] = append(pool.providers[prov.name], pkg)
+		}
+		for _, file := range pkg.files {
+			pool.providers[file] = append(pool.providers[file], pkg)
+		}
+	}
+
+	seen := make(map[*rpmPackage]bool)
+	for _, pkg := range installed {
+		seen[pkg] = true
+		add(pkg)
+	}
+	for idx := len(repos) - 1; idx >= 0; idx-- {
+		pool.repoOrder[repos[idx]] = idx
+		for id, names := range repos[idx].groups {
+			pool.groups[id] = names
+		}
+	}
+	for _, repo := range repos {
+		for _, pkg := range repo.packages {
+			if seen[pkg] || (pkg.arch != arch && pkg.arch != "noarch") || matchesAny(pkg.name, excludes) {
+				continue
+			}
+			add(pkg)
+		}
+	}
+	return pool
+}
+
+func matchesAny(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// best returns the preferred package of the candidates: packages named
+// like the dependency first, then the package with the shortest name, then
+// the newest version, then the one of the first repository
+func (pool *repodataPool) best(name string, candidates []*rpmPackage) *rpmPackage {
+	if len(candidates) == 0 {
+		return nil
+	}
+	sorted := append([]*rpmPackage(nil), candidates...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if (a.name == name) != (b.name == name) {
+			return a.name == name
+		}
+		if len(a.name) != len(b.name) {
+			return len(a.name) < len(b.name)
+		}
+		if a.name != b.name {
+			return a.name < b.name
+		}
+		if c := compareEVR(a.evr, b.evr); c != 0 {
+			return c > 0
+		}
+		return pool.repoOrder[a.repo] < pool.repoOrder[b.repo]
+	})
+	return sorted[0]
+}
+
+// repodataTransaction resolves the dependencies of the packages that are
+// installed by a transaction
+type repodataTransaction struct {
+	pool *repodataPool
+	// in the order of installation
+	installed   []*rpmPackage
+	isInstalled map[*rpmPackage]bool
+}
+
+func newRepodataTransaction(pool *repodataPool, installed []*rpmPackage) *repodataTransaction {
+	t := &repodataTransaction{
+		pool:        pool,
+		installed:   append([]*rpmPackage(nil), installed...),
+		isInstalled: make(map[*rpmPackage]bool),
+	}
+	for _, pkg := range installed {
+		t.isInstalled[pkg] = true
+	}
+	return t
+}
+
+// providers returns the packages of the pool that provide the dependency
+func (t *repodataTransaction) providers(dep rpmDep) []*rpmPackage {
+	var providers []*rpmPackage
+	for _, pkg := range t.pool.providers[dep.name] {
+		if strings.HasPrefix(dep.name, "/") {
+			providers = append(providers, pkg)
+			continue
+		}
+		for _, prov := range pkg.provides {
+			if prov.name == dep.name && prov.overlaps(dep) {
+				providers = append(providers, pkg)
+				break
+			}
+		}
+	}
+	return providers
+}
+
+func (t *repodataTransaction) satisfied(dep *richDep) bool {
+	switch dep.op {
+	case "":
+		for _, pkg := range t.providers(dep.dep) {
+			if t.isInstalled[pkg] {
+				return true
+			}
+		}
+		return false
+	case "and", "with":
+		for _, arg := range dep.args {
+			if !t.satisfied(arg) {
+				return false
+			}
+		}
+		return true
+	case "or":
+		for _, arg := range dep.args {
+			if t.satisfied(arg) {
+				return true
+			}
+		}
+		return false
+	case "if", "unless":
+		if t.satisfied(dep.args[1]) == (dep.op == "if") {
+			return t.satisfied(dep.args[0])
+		}
+		return len(dep.args) < 3 || t.satisfied(dep.args[2])
+	case "without":
+		return t.satisfied(dep.args[0])
+	}
+	return false
+}
+
+// install installs the package and its dependencies
+func (t *repodataTransaction) install(pkg *rpmPackage) error {
+	if t.isInstalled[pkg] {
+		return nil
+	}
+	t.isInstalled[pkg] = true
+	t.installed = append(t.installed, pkg)
+	for _, req := range pkg.requires {
+		if err := t.require(req, pkg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// try installs the dependency and rolls back all changes if that fails
+func (t *repodataTransaction) try(dep *richDep, by *rpmPackage) error {
+	mark := len(t.installed)
+	err := t.requireRich(dep, by)
+	if err != nil {
+		for _, pkg := range t.installed[mark:] {
+			delete(t.isInstalled, pkg)
+		}
+		t.installed = t.installed[:mark]
+	}
+	return err
+}
+
+func (t *repodataTransaction) require(req rpmDep, by *rpmPackage) error {
+	if strings.HasPrefix(req.name, "rpmlib(") {
+		// provided by rpm itself
+		return nil
+	}
+	if strings.HasPrefix(req.name, "(") {
+		dep, err := parseRichDep(req.name)
+		if err != nil {
+			return fmt.Errorf("package %s: %w", by, err)
+		}
+		return t.requireRich(dep, by)
+	}
+	return t.requireRich(&richDep{dep: req}, by)
+}
+
+func (t *repodataTransaction) requireRich(dep *richDep, by *rpmPackage) error {
+	if t.satisfied(dep) {
+		return nil
+	}
+	switch dep.op {
+	case "":
+		pkg := t.pool.best(dep.dep.name, t.providers(dep.dep))
+		if pkg == nil {
+			return fmt.Errorf("nothing provides %s needed by %s", dep.dep, by)
+		}
+		return t.install(pkg)
+	case "and", "with":
+		for _, arg := range dep.args {
+			if err := t.requireRich(arg, by); err != nil {
+				return err
+			}
+		}
+	case "or":
+		var err error
+		for _, arg := range dep.args {
+			if err = t.try(arg, by); err == nil {
+				return nil
+			}
+		}
+		return err
+	case "if", "unless":
+		if t.satisfied(dep.args[1]) == (dep.op == "if") {
+			return t.requireRich(dep.args[0], by)
+		}
+		if len(dep.args) == 3 {
+			return t.requireRich(dep.args[2], by)
+		}
+	case "without":
+		return t.requireRich(dep.args[0], by)
+	}
+	return nil
+}
+
+// installSpecs installs the packages that match the specs: package names
+// (globs are supported), provides, files and "@group"s
+func (t *repodataTransaction) installSpecs(specs []string) error {
+	for _, spec := range specs {
+		if group, ok := strings.CutPrefix(spec, "@"); ok {
+			names, ok := t.pool.groups[group]
+			if !ok {
+				return fmt.Errorf("no group matches %q", spec)
+			}
+			for _, name := range names {
+				// like dnf, unavailable packages of groups are skipped
+				if pkg := t.pool.best(name, t.pool.byName[name]); pkg != nil {
+					if err := t.install(pkg); err != nil {
+						return err
+					}
+				}
+			}
+			continue
+		}
+
+		var names []string
+		for name := range t.pool.byName {
+			if ok, _ := path.Match(spec, name); ok {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		var pkgs []*rpmPackage
+		for _, name := range names {
+			pkgs = append(pkgs, t.pool.best(name, t.pool.byName[name]))
+		}
+		if len(pkgs) == 0 {
+			if pkg := t.pool.best(spec, t.providers(rpmDep{name: spec})); pkg != nil {
+				pkgs = append(pkgs, pkg)
+			}
+		}
+		if len(pkgs) == 0 {
+			return fmt.Errorf("no package matches %q", spec)
+		}
+		for _, pkg := range pkgs {
+			if err := t.install(pkg); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// installWeakDeps installs the recommended packages of all installed
+// packages, unless they cannot be installed
+func (t *repodataTransaction) installWeakDeps() {
+	for idx := 0; idx < len(t.installed); idx++ {
+		pkg := t.installed[idx]
+		for _, rec := range pkg.recommends {
+			dep := &richDep{dep: rec}
+			if strings.HasPrefix(rec.name, "(") {
+				var err error
+				if dep, err = parseRichDep(rec.name); err != nil {
+					continue
+				}
+			}
+			// errors are fine, weak dependencies are optional
+			_ = t.try(dep, pkg)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package dnfjson
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/internal/mocks/rpmrepo"
+	"github.com/osbuild/images/pkg/rpmmd"
+	"github.com/osbuild/images/pkg/sbom"
+)
+
+func packageNames(pkgs []rpmmd.PackageSpec) []string {
+	var names []string
+	for _, pkg := range pkgs {
+		names = append(names, pkg.Name)
+	}
+	return names
+}
+
+func TestRepodataSolver(t *testing.T) {
+	s := rpmrepo.NewTestServer()
+	defer s.Close()
+
+	cacheDir := t.TempDir()
+	solver := NewRepodataSolver("platform:el9", "9", "x86_64", "centos-9", cacheDir)
+	res, err := solver.Depsolve([]rpmmd.PackageSet{
+		{
+			Include:      []string{"bash"},
+			Repositories: []rpmmd.RepoConfig{s.RepoConfig},
+		},
+		{
+			Include:         []string{"@core", "/usr/bin/tar"},
+			Exclude:         []string{"dracut-config-rescue"},
+			Repositories:    []rpmmd.RepoConfig{s.RepoConfig},
+			InstallWeakDeps: true,
+		},
+	}, sbom.StandardTypeNone)
+	require.NoError(t, err)
+	assert.Equal(t, RepodataSolverName, res.Solver)
+	assert.Nil(t, res.SBOM)
+
+	names := packageNames(res.Packages)
+	// the requested packages, their dependencies and the group packages
+	for _, name := range []string{"bash", "glibc", "filesystem", "tar", "systemd", "openssh-server", "NetworkManager"} {
+		assert.Contains(t, names, name)
+	}
+	assert.NotContains(t, names, "dracut-config-rescue")
+
+	repoID := s.RepoConfig.Hash()
+	for _, pkg := range res.Packages {
+		assert.Equal(t, repoID, pkg.RepoID)
+		assert.True(t, strings.HasPrefix(pkg.Checksum, "sha256:"), pkg.Checksum)
+		assert.Equal(t, s.Server.URL+"/"+pkg.Path, pkg.RemoteLocation)
+		assert.False(t, pkg.CheckGPG)
+		assert.True(t, pkg.IgnoreSSL)
+	}
+	require.Len(t, res.Repos, 1)
+	assert.Equal(t, repoID, res.Repos[0].Id)
+
+	// the metadata is cached next to the dnf metadata
+	assert.FileExists(t, filepath.Join(solver.solver.GetCacheDir(), repoID+"-repodata", "130494033cb9bffa40828ebc814d016920d9ce5d60041467af283ffcf38adef0.gz"))
+}
+
+func TestRepodataSolverErrors(t *testing.T) {
+	s := rpmrepo.NewTestServer()
+	defer s.Close()
+
+	solver := NewRepodataSolver("platform:el9", "9", "x86_64", "centos-9", t.TempDir())
+	for name, tc := range map[string]struct {
+		pkgSet   rpmmd.PackageSet
+		sbomType sbom.StandardType
+		err      string
+	}{
+		"unknown-package": {
+			pkgSet: rpmmd.PackageSet{Include: []string{"does-not-exist"}},
+			err:    `no package matches "does-not-exist"`,
+		},
+		"unknown-group": {
+			pkgSet: rpmmd.PackageSet{Include: []string{"@does-not-exist"}},
+			err:    `no group matches "@does-not-exist"`,
+		},
+		"excluded-dependency": {
+			pkgSet: rpmmd.PackageSet{Include: []string{"bash"}, Exclude: []string{"glibc"}},
+			err:    "nothing provides ",
+		},
+		"modules": {
+			pkgSet: rpmmd.PackageSet{Include: []string{"bash"}, EnabledModules: []string{"nodejs:18"}},
+			err:    "the repodata solver does not support modules",
+		},
+		"spdx": {
+			pkgSet:   rpmmd.PackageSet{Include: []string{"bash"}},
+			sbomType: sbom.StandardTypeSpdx,
+			err:      "the repodata solver does not support spdx SBOMs",
+		},
+		"cyclonedx": {
+			pkgSet:   rpmmd.PackageSet{Include: []string{"bash"}},
+			sbomType: sbom.StandardTypeCycloneDX,
+			err:      "the repodata solver does not support cyclonedx SBOMs",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			tc.pkgSet.Repositories = []rpmmd.RepoConfig{s.RepoConfig}
+			_, err := solver.Depsolve([]rpmmd.PackageSet{tc.pkgSet}, tc.sbomType)
+			assert.ErrorContains(t, err, tc.err)
+		})
+	}
+}
+
+func TestRepodataSolverLocalRepo(t *testing.T) {
+	repoDir, err := filepath.Abs("../../test/data/testrepo")
+	require.NoError(t, err)
+	solver := NewRepodataSolver("platform:el9", "9", "x86_64", "centos-9", t.TempDir())
+	res, err := solver.Depsolve([]rpmmd.PackageSet{
+		{
+			Include:      []string{"bash"},
+			Repositories: []rpmmd.RepoConfig{{Name: "local", BaseURLs: []string{"file://" + repoDir}}},
+		},
+	}, sbom.StandardTypeNone)
+	require.NoError(t, err)
+	assert.Contains(t, packageNames(res.Packages), "bash")
+	assert.Nil(t, res.SBOM)
+}
+
+func TestRepodataSolverChecksumMismatch(t *testing.T) {
+	repoDir := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(repoDir, "repodata"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(repoDir, "repodata", "repomd.xml"), []byte(`<repomd xmlns="http://linux.duke.edu/metadata/repo">
+  <data type="primary">
+    <checksum type="sha256">0000000000000000000000000000000000000000000000000000000000000000</checksum>
+    <location href="repodata/primary.xml"/>
+  </data>
+</repomd>`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(repoDir, "repodata", "primary.xml"), []byte(`<metadata/>`), 0644))
+
+	solver := NewRepodataSolver("platform:el9", "9", "x86_64", "centos-9", t.TempDir())
+	_, err := solver.Depsolve([]rpmmd.PackageSet{
+		{
+			Include:      []string{"bash"},
+			Repositories: []rpmmd.RepoConfig{{Name: "local", BaseURLs: []string{"file://" + repoDir}}},
+		},
+	}, sbom.StandardTypeNone)
+	assert.ErrorContains(t, err, "checksum mismatch for file://"+repoDir+"/repodata/primary.xml")
+}
+
+func TestRPMVerCmp(t *testing.T) {
+	for _, tc := range []struct {
+		a, b     string
+		expected int
+	}{
+		{"1.0", "1.0", 0},
+		{"1.0", "2.0", -1},
+		{"2.0.1", "2.0", 1},
+		{"1.10", "1.9", 1},
+		{"1.010", "1.10", 0},
+		{"1.0a", "1.0", 1},
+		{"1.0", "1.a", 1},
+		{"1.0~rc1", "1.0", -1},
+		{"1.0~rc1", "1.0~rc2", -1},
+		{"1.0^git1", "1.0", 1},
+		{"1.0^git1", "1.0.1", -1},
+		{"5.1.8-6.el9", "5.1.8-6.el9_1", -1},
+		{"1_0", "1.0", 0},
+	} {
+		assert.Equal(t, tc.expected, rpmvercmp(tc.a, tc.b), "%s <=> %s", tc.a, tc.b)
+		assert.Equal(t, -tc.expected, rpmvercmp(tc.b, tc.a), "%s <=> %s", tc.b, tc.a)
+	}
+}
+
+func TestRPMDepOverlaps(t *testing.T) {
+	dep := func(s string) rpmDep {
+		d, err := parseRichDep(s)
+		require.NoError(t, err)
+		return d.dep
+	}
+	for _, tc := range []struct {
+		a, b     string
+		expected bool
+	}{
+		{"foo", "foo = 1.0-1", true},
+		{"foo = 1.0-1", "foo = 1.0-1", true},
+		{"foo = 1.0-1", "foo = 1.0-2", false},
+		{"foo = 1.0", "foo = 1.0-2", true},
+		{"foo >= 1.0", "foo = 1.0-2", true},
+		{"foo > 1.0", "foo = 1.0-2", false},
+		{"foo < 2", "foo = 1.0-2", true},
+		{"foo >= 2", "foo = 1:1.0-2", true},
+		{"foo >= 2", "foo <= 3", true},
+		{"foo < 2", "foo > 3", false},
+	} {
+		assert.Equal(t, tc.expected, dep(tc.a).overlaps(dep(tc.b)), "%s overlaps %s", tc.a, tc.b)
+		assert.Equal(t, tc.expected, dep(tc.b).overlaps(dep(tc.a)), "%s overlaps %s", tc.b, tc.a)
+	}
+}
+
+func TestParseRichDep(t *testing.T) {
+	dep, err := parseRichDep("(selinux-policy >= 34.1.22-1.el9 if (selinux-policy-targeted or foo(x86-64)) else bar)")
+	require.NoError(t, err)
+	assert.Equal(t, "if", dep.op)
+	require.Len(t, dep.args, 3)
+	assert.Equal(t, rpmDep{name: "selinux-policy", flags: "GE", evr: evr{version: "34.1.22", release: "1.el9"}}, dep.args[0].dep)
+	assert.Equal(t, "or", dep.args[1].op)
+	assert.Equal(t, "foo(x86-64)", dep.args[1].args[1].dep.name)
+	assert.Equal(t, "bar", dep.args[2].dep.name)
+
+	for _, bad := range []string{"(a or", "(a or b and c)", "(a xor b)", "(a or b))", "(a else b)", "(a if b if c)"} {
+		_, err := parseRichDep(bad)
+		assert.Error(t, err, bad)
+	}
+}
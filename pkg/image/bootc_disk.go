@@ -5,6 +5,7 @@ import (
 	"math/rand"
 
 	"github.com/osbuild/images/pkg/container"
+	"github.com/osbuild/images/pkg/customizations/composefs"
 	"github.com/osbuild/images/pkg/customizations/fsnode"
 	"github.com/osbuild/images/pkg/disk"
 	"github.com/osbuild/images/pkg/manifest"
@@ -26,6 +27,13 @@ type BootcDiskImage struct {
 
 	// Customizations
 	OSCustomizations manifest.OSCustomizations
+
+	// Composefs creates the root filesystem with fs-verity for a container
+	// that mounts its deployments with composefs in a mode that requires
+	// fs-verity. The composefs mode itself is set by the prepare-root.conf
+	// of the container, which is part of its initramfs and cannot be
+	// changed here, see osinfo.Info.Composefs.
+	Composefs *composefs.Options
 }
 
 func NewBootcDiskImage(container container.SourceSpec, buildContainer container.SourceSpec) *BootcDiskImage {
@@ -99,8 +107,17 @@ func (img *BootcDiskImage) InstantiateManifestFromContainers(m *manifest.Manifes
 	// this is signified by passing nil to the below pipelines.
 	var hostPipeline manifest.Build
 
+	pt := img.PartitionTable
+	if img.Composefs != nil && img.Composefs.FSVerity() {
+		var err error
+		pt, err = rootFSVerityPartitionTable(pt)
+		if err != nil {
+			return err
+		}
+	}
+
 	rawImage := manifest.NewRawBootcImage(buildPipeline, containers, img.Platform)
-	rawImage.PartitionTable = img.PartitionTable
+	rawImage.PartitionTable = pt
 	rawImage.Users = img.OSCustomizations.Users
 	rawImage.Groups = img.OSCustomizations.Groups
 	rawImage.Files = img.OSCustomizations.Files
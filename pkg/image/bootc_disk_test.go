@@ -11,6 +11,7 @@ import (
 
 	"github.com/osbuild/images/internal/testdisk"
 	"github.com/osbuild/images/pkg/container"
+	"github.com/osbuild/images/pkg/customizations/composefs"
 	"github.com/osbuild/images/pkg/customizations/fsnode"
 	"github.com/osbuild/images/pkg/customizations/users"
 	"github.com/osbuild/images/pkg/image"
@@ -45,6 +46,7 @@ type bootcDiskImageTestOpts struct {
 	Groups      []users.Group
 	Directories []*fsnode.Directory
 	Files       []*fsnode.File
+	Composefs   *composefs.Options
 
 	KernelOptionsAppend []string
 }
@@ -82,6 +84,7 @@ func makeBootcDiskImageOsbuildManifest(t *testing.T, opts *bootcDiskImageTestOpt
 	img.OSCustomizations.SELinux = opts.SELinux
 	img.OSCustomizations.Files = opts.Files
 	img.OSCustomizations.Directories = opts.Directories
+	img.Composefs = opts.Composefs
 
 	m := &manifest.Manifest{}
 	runi := &runner.Fedora{}
@@ -323,3 +326,35 @@ func TestBootcDiskImageBuildpipelineHonorsSELinuxPolicy(t *testing.T) {
 	selinuxOptions := selinuxStage["options"].(map[string]interface{})
 	assert.Equal(t, "etc/selinux/custom/contexts/files/file_contexts", selinuxOptions["file_contexts"])
 }
+
+func TestBootcDiskImageComposefsRootFSVerity(t *testing.T) {
+	for _, tc := range []struct {
+		composefs *composefs.Options
+		fsverity  bool
+	}{
+		{nil, false},
+		{&composefs.Options{Mode: composefs.ModeEnabled}, false},
+		{&composefs.Options{Mode: composefs.ModeVerity}, true},
+	} {
+		opts := &bootcDiskImageTestOpts{Composefs: tc.composefs}
+		osbuildManifest := makeBootcDiskImageOsbuildManifest(t, opts)
+		imagePipeline := findPipelineFromOsbuildManifest(t, osbuildManifest, "image")
+		require.NotNil(t, imagePipeline)
+
+		var verity []interface{}
+		for _, stageIf := range imagePipeline["stages"].([]interface{}) {
+			stage := stageIf.(map[string]interface{})
+			if stage["type"] != "org.osbuild.mkfs.ext4" {
+				continue
+			}
+			options := stage["options"].(map[string]interface{})
+			verity = append(verity, options["verity"])
+		}
+		// the root filesystem is the first one of the fake partition table
+		if tc.fsverity {
+			assert.Equal(t, []interface{}{true, nil}, verity)
+		} else {
+			assert.Equal(t, []interface{}{nil, nil}, verity)
+		}
+	}
+}
@@ -1,11 +1,16 @@
 package image
 
 import (
+	"fmt"
 	"math/rand"
+	"slices"
+	"strings"
 
 	"github.com/osbuild/images/internal/environment"
 	"github.com/osbuild/images/internal/workload"
 	"github.com/osbuild/images/pkg/artifact"
+	"github.com/osbuild/images/pkg/customizations/fsnode"
+	"github.com/osbuild/images/pkg/customizations/ocicontainer"
 	"github.com/osbuild/images/pkg/manifest"
 	"github.com/osbuild/images/pkg/platform"
 	"github.com/osbuild/images/pkg/rpmmd"
@@ -19,6 +24,7 @@ type BaseContainer struct {
 	Environment      environment.Environment
 	Workload         workload.Workload
 	Filename         string
+	OCIConfig        *ocicontainer.ImageOptions
 }
 
 func NewBaseContainer() *BaseContainer {
@@ -39,9 +45,102 @@ func (img *BaseContainer) InstantiateManifest(m *manifest.Manifest,
 	osPipeline.Environment = img.Environment
 	osPipeline.Workload = img.Workload
 
+	var filesLayer *manifest.FilesLayer
+	if img.OCIConfig != nil && img.OCIConfig.CustomizationsLayer {
+		osDirs, osFiles, layerDirs, layerFiles := splitLayerNodes(img.OSCustomizations.Directories, img.OSCustomizations.Files)
+		if len(layerDirs) > 0 || len(layerFiles) > 0 {
+			if err := checkNumericOwnership(layerDirs, layerFiles); err != nil {
+				return nil, err
+			}
+			filesLayer = manifest.NewFilesLayer(buildPipeline, img.Platform, "container-files")
+			filesLayer.Directories = layerDirs
+			filesLayer.Files = layerFiles
+			osPipeline.OSCustomizations.Directories = osDirs
+			osPipeline.OSCustomizations.Files = osFiles
+		}
+	}
+
 	ociPipeline := manifest.NewOCIContainer(buildPipeline, osPipeline)
 	ociPipeline.SetFilename(img.Filename)
+	if filesLayer != nil {
+		ociPipeline.AddLayer(filesLayer)
+	}
+	if cfg := img.OCIConfig; cfg != nil {
+		ociPipeline.Cmd = cfg.Cmd
+		ociPipeline.Env = cfg.Env
+		ociPipeline.ExposedPorts = cfg.ExposedPorts
+		ociPipeline.User = cfg.User
+		ociPipeline.Labels = cfg.Labels
+		ociPipeline.StopSignal = cfg.StopSignal
+		ociPipeline.Volumes = cfg.Volumes
+		ociPipeline.WorkingDir = cfg.WorkingDir
+	}
 	artifact := ociPipeline.Export()
 
 	return artifact, nil
 }
+
+// systemdUnitDirs are the directories that systemd units are loaded from.
+var systemdUnitDirs = []string{
+	"/etc/systemd/system",
+	"/etc/systemd/user",
+	"/usr/lib/systemd/system",
+	"/usr/lib/systemd/user",
+}
+
+// splitLayerNodes splits the customization directories and files into the
+// ones that must stay in the tree of the OS pipeline and the ones that can go
+// into a separate layer. The stages of the OS pipeline enable, disable and
+// mask systemd units, so unit files (and the directories leading to them)
+// stay in the OS tree.
+func splitLayerNodes(dirs []*fsnode.Directory, files []*fsnode.File) (osDirs []*fsnode.Directory, osFiles []*fsnode.File, layerDirs []*fsnode.Directory, layerFiles []*fsnode.File) {
+	inUnitDir := func(path string) bool {
+		return slices.ContainsFunc(systemdUnitDirs, func(dir string) bool {
+			return strings.HasPrefix(path, dir+"/")
+		})
+	}
+
+	for _, file := range files {
+		if inUnitDir(file.Path()) {
+			osFiles = append(osFiles, file)
+		} else {
+			layerFiles = append(layerFiles, file)
+		}
+	}
+	for _, dir := range dirs {
+		keep := inUnitDir(dir.Path()) || slices.ContainsFunc(osFiles, func(file *fsnode.File) bool {
+			return strings.HasPrefix(file.Path(), dir.Path()+"/")
+		})
+		if keep {
+			osDirs = append(osDirs, dir)
+		} else {
+			layerDirs = append(layerDirs, dir)
+		}
+	}
+	return osDirs, osFiles, layerDirs, layerFiles
+}
+
+// checkNumericOwnership returns an error if the owner of any of the nodes is
+// a user or group name. Names can't be resolved in a separate layer.
+func checkNumericOwnership(dirs []*fsnode.Directory, files []*fsnode.File) error {
+	check := func(path string, user, group interface{}) error {
+		if _, ok := user.(string); ok {
+			return fmt.Errorf("user of %q must be numeric when the customizations are in a separate container layer", path)
+		}
+		if _, ok := group.(string); ok {
+			return fmt.Errorf("group of %q must be numeric when the customizations are in a separate container layer", path)
+		}
+		return nil
+	}
+	for _, dir := range dirs {
+		if err := check(dir.Path(), dir.User(), dir.Group()); err != nil {
+			return err
+		}
+	}
+	for _, file := range files {
+		if err := check(file.Path(), file.User(), file.Group()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package image
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/pkg/customizations/fsnode"
+)
+
+func TestSplitLayerNodes(t *testing.T) {
+	newDir := func(path string) *fsnode.Directory {
+		dir, err := fsnode.NewDirectory(path, nil, nil, nil, true)
+		require.NoError(t, err)
+		return dir
+	}
+	newFile := func(path string) *fsnode.File {
+		file, err := fsnode.NewFile(path, nil, nil, nil, []byte("data"))
+		require.NoError(t, err)
+		return file
+	}
+
+	unitDir := newDir("/etc/systemd/system/custom.service.d")
+	parentDir := newDir("/usr/lib/custom")
+	dataDir := newDir("/srv/data")
+	unit := newFile("/etc/systemd/system/custom.service")
+	dropIn := newFile("/etc/systemd/system/custom.service.d/override.conf")
+	script := newFile("/usr/lib/custom/systemd/user/custom.service")
+	conf := newFile("/etc/custom.conf")
+
+	osDirs, osFiles, layerDirs, layerFiles := splitLayerNodes(
+		[]*fsnode.Directory{unitDir, parentDir, dataDir},
+		[]*fsnode.File{unit, dropIn, script, conf},
+	)
+	assert.Equal(t, []*fsnode.Directory{unitDir}, osDirs)
+	assert.Equal(t, []*fsnode.File{unit, dropIn}, osFiles)
+	assert.Equal(t, []*fsnode.Directory{parentDir, dataDir}, layerDirs)
+	assert.Equal(t, []*fsnode.File{script, conf}, layerFiles)
+}
@@ -49,6 +49,14 @@ func (img *DiskImage) InstantiateManifest(m *manifest.Manifest,
 	runner runner.Runner,
 	rng *rand.Rand) (*artifact.Artifact, error) {
 
+	// The root hash of a dm-verity protected root filesystem is only known
+	// once the image is built, so it cannot be part of the kernel command
+	// line in the boot entries or the UKI of the image. It must be passed
+	// by whatever boots the kernel.
+	if img.PartitionTable.VerityRoot() && img.Platform.GetBootloader() != platform.BOOTLOADER_NONE {
+		return nil, fmt.Errorf("dm-verity protected root filesystems are only supported for images without a bootloader, the root hash cannot be added to the kernel command line of the image")
+	}
+
 	buildPipeline := addBuildBootstrapPipelines(m, runner, repos, nil)
 	buildPipeline.Checkpoint()
 
@@ -61,7 +69,13 @@ func (img *DiskImage) InstantiateManifest(m *manifest.Manifest,
 	osPipeline.OSVersion = img.OSVersion
 	osPipeline.OSNick = img.OSNick
 
+	var rootfsPipeline *manifest.ReadOnlyRootfs
+	if img.PartitionTable.ReadOnlyRoot() {
+		rootfsPipeline = manifest.NewReadOnlyRootfs(buildPipeline, osPipeline)
+	}
+
 	rawImagePipeline := manifest.NewRawImage(buildPipeline, osPipeline)
+	rawImagePipeline.ReadOnlyRootfs = rootfsPipeline
 	rawImagePipeline.PartTool = img.PartTool
 
 	var imagePipeline manifest.FilePipeline
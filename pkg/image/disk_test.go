@@ -0,0 +1,52 @@
+package image_test
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/osbuild/images/internal/testdisk"
+	"github.com/osbuild/images/pkg/arch"
+	"github.com/osbuild/images/pkg/image"
+	"github.com/osbuild/images/pkg/manifest"
+	"github.com/osbuild/images/pkg/platform"
+	"github.com/osbuild/images/pkg/runner"
+)
+
+func TestDiskImageVerityRootBootloader(t *testing.T) {
+	for name, tc := range map[string]struct {
+		bootloader platform.Bootloader
+		err        string
+	}{
+		"none": {
+			bootloader: platform.BOOTLOADER_NONE,
+		},
+		"grub2": {
+			bootloader: platform.BOOTLOADER_GRUB2,
+			err:        "dm-verity protected root filesystems are only supported for images without a bootloader, the root hash cannot be added to the kernel command line of the image",
+		},
+		"uki": {
+			bootloader: platform.BOOTLOADER_UKI,
+			err:        "dm-verity protected root filesystems are only supported for images without a bootloader, the root hash cannot be added to the kernel command line of the image",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			img := image.NewDiskImage()
+			img.Platform = &platform.PlatformConf{
+				Arch:        arch.ARCH_X86_64,
+				ImageFormat: platform.FORMAT_RAW,
+				Bootloader:  tc.bootloader,
+			}
+			img.PartitionTable = testdisk.MakeFakeVerityPartitionTable("/", "/boot", "/boot/efi")
+
+			mf := manifest.New()
+			_, err := img.InstantiateManifest(&mf, nil, &runner.Fedora{Version: 41}, rand.New(rand.NewSource(0))) // nolint:gosec
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -59,11 +59,20 @@ type baseRawOstreeImageOpts struct {
 	useBootupd bool
 }
 
-func baseRawOstreeImage(img *OSTreeDiskImage, buildPipeline manifest.Build, opts *baseRawOstreeImageOpts) *manifest.RawOSTreeImage {
+func baseRawOstreeImage(img *OSTreeDiskImage, buildPipeline manifest.Build, opts *baseRawOstreeImageOpts) (*manifest.RawOSTreeImage, error) {
 	if opts == nil {
 		opts = &baseRawOstreeImageOpts{}
 	}
 
+	pt := img.PartitionTable
+	if cfs := img.OSTreeDeploymentCustomizations.Composefs; cfs != nil && cfs.FSVerity() {
+		var err error
+		pt, err = rootFSVerityPartitionTable(pt)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	var osPipeline *manifest.OSTreeDeployment
 	switch {
 	case img.CommitSource != nil:
@@ -74,7 +83,7 @@ func baseRawOstreeImage(img *OSTreeDiskImage, buildPipeline manifest.Build, opts
 		panic("no content source defined for ostree image")
 	}
 
-	osPipeline.PartitionTable = img.PartitionTable
+	osPipeline.PartitionTable = pt
 	osPipeline.Remote = img.Remote
 	osPipeline.OSTreeDeploymentCustomizations = img.OSTreeDeploymentCustomizations
 	osPipeline.UseBootupd = opts.useBootupd
@@ -84,7 +93,21 @@ func baseRawOstreeImage(img *OSTreeDiskImage, buildPipeline manifest.Build, opts
 		osPipeline.EnabledServices = img.Workload.GetServices()
 		osPipeline.DisabledServices = img.Workload.GetDisabledServices()
 	}
-	return manifest.NewRawOStreeImage(buildPipeline, osPipeline, img.Platform)
+	return manifest.NewRawOStreeImage(buildPipeline, osPipeline, img.Platform), nil
+}
+
+// rootFSVerityPartitionTable returns a copy of the partition table with
+// fs-verity enabled on the root filesystem, for the files of composefs
+// deployments.
+func rootFSVerityPartitionTable(pt *disk.PartitionTable) (*disk.PartitionTable, error) {
+	if pt == nil {
+		return nil, fmt.Errorf("composefs requires a partition table")
+	}
+	pt = pt.Clone().(*disk.PartitionTable)
+	if err := pt.EnableRootFSVerity(); err != nil {
+		return nil, fmt.Errorf("cannot use composefs: %w", err)
+	}
+	return pt, nil
 }
 
 // replaced in testing
@@ -107,7 +130,10 @@ func (img *OSTreeDiskImage) InstantiateManifest(m *manifest.Manifest,
 		panic(fmt.Sprintf("no compression is allowed with %q format for %q", imgFormat, img.name))
 	}
 
-	baseImage := baseRawOstreeImage(img, buildPipeline, nil)
+	baseImage, err := baseRawOstreeImage(img, buildPipeline, nil)
+	if err != nil {
+		return nil, err
+	}
 	switch img.Platform.GetImageFormat() {
 	case platform.FORMAT_VMDK:
 		vmdkPipeline := manifest.NewVMDK(buildPipeline, baseImage)
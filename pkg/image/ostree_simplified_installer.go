@@ -80,7 +80,11 @@ func (img *OSTreeSimplifiedInstaller) InstantiateManifest(m *manifest.Manifest,
 	imageFilename := "image.raw.xz"
 
 	// image in simplified installer is always compressed
-	compressedImage := manifest.NewXZ(buildPipeline, baseRawOstreeImage(img.rawImage, buildPipeline, nil))
+	rawImage, err := baseRawOstreeImage(img.rawImage, buildPipeline, nil)
+	if err != nil {
+		return nil, err
+	}
+	compressedImage := manifest.NewXZ(buildPipeline, rawImage)
 	compressedImage.SetFilename(imageFilename)
 
 	coiPipeline := manifest.NewCoreOSInstaller(
@@ -36,6 +36,18 @@ func (p *Zstd) Serialize() osbuild.Pipeline {
 	return p.serialize()
 }
 
+func (p *OCIContainer) Serialize() osbuild.Pipeline {
+	return p.serialize()
+}
+
+func (p *FilesLayer) Serialize() osbuild.Pipeline {
+	return p.serialize()
+}
+
+func (p *FilesLayer) GetInline() []string {
+	return p.getInline()
+}
+
 func (p *OS) Serialize() osbuild.Pipeline {
 	repos := []rpmmd.RepoConfig{}
 	packages := []rpmmd.PackageSpec{
@@ -109,3 +121,15 @@ func (p *OSTreeDeployment) GetInline() []string {
 func (p *Vagrant) GetMacAddress() string {
 	return p.macAddress
 }
+
+func (p *RawImage) Serialize() osbuild.Pipeline {
+	return p.serialize()
+}
+
+func (p *RawOSTreeImage) Serialize() osbuild.Pipeline {
+	return p.serialize()
+}
+
+func (p *ReadOnlyRootfs) Serialize() osbuild.Pipeline {
+	return p.serialize()
+}
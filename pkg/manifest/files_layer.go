@@ -0,0 +1,109 @@
+package manifest
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/osbuild/images/internal/common"
+	"github.com/osbuild/images/pkg/customizations/fsnode"
+	"github.com/osbuild/images/pkg/customizations/remotefile"
+	"github.com/osbuild/images/pkg/osbuild"
+	"github.com/osbuild/images/pkg/platform"
+)
+
+// A FilesLayer represents a filesystem tree that only contains the given
+// directories and files. It is used to ship customizations in a separate
+// container layer, on top of the tree of another pipeline.
+//
+// The tree starts out empty, so ownership of the files and directories must
+// be numeric as there are no users or groups to resolve names against. The
+// parent directories of all nodes are created with mode 0755 and root
+// ownership, which also applies to them when the layer is unpacked.
+type FilesLayer struct {
+	Base
+
+	Directories []*fsnode.Directory
+	Files       []*fsnode.File
+
+	platform   platform.Platform
+	inlineData []string
+}
+
+func NewFilesLayer(buildPipeline Build, platform platform.Platform, name string) *FilesLayer {
+	p := &FilesLayer{
+		Base:     NewBase(name, buildPipeline),
+		platform: platform,
+	}
+	buildPipeline.addDependent(p)
+	return p
+}
+
+func (p *FilesLayer) Platform() platform.Platform {
+	return p.platform
+}
+
+func (p *FilesLayer) getInline() []string {
+	return p.inlineData
+}
+
+func (p *FilesLayer) fileRefs() []string {
+	return fileRefs(p.Files)
+}
+
+func (p *FilesLayer) getRemoteFileSources() []remotefile.SourceSpec {
+	return remoteFileSources(p.Files)
+}
+
+func (p *FilesLayer) serialize() osbuild.Pipeline {
+	pipeline := p.Base.serialize()
+
+	if parents := p.parentDirectories(); len(parents) > 0 {
+		var paths []osbuild.MkdirStagePath
+		for _, parent := range parents {
+			paths = append(paths, osbuild.MkdirStagePath{
+				Path:    parent,
+				Mode:    common.ToPtr(os.FileMode(0755)),
+				Parents: true,
+				ExistOk: true,
+			})
+		}
+		pipeline.AddStage(osbuild.NewMkdirStage(&osbuild.MkdirStageOptions{Paths: paths}))
+	}
+	pipeline.AddStages(osbuild.GenDirectoryNodesStages(p.Directories)...)
+	pipeline.AddStages(osbuild.GenFileNodesStages(p.Files)...)
+
+	p.inlineData = nil
+	for _, file := range p.Files {
+		if file.URI() == "" {
+			p.inlineData = append(p.inlineData, string(file.Data()))
+		}
+	}
+
+	return pipeline
+}
+
+// parentDirectories returns the sorted, unique parent directories of all the
+// directories and files of the layer, excluding the directories of the layer
+// itself which are created with their own options.
+func (p *FilesLayer) parentDirectories() []string {
+	var parents []string
+	addParent := func(path string) {
+		parent := filepath.Dir(path)
+		if parent == "/" || slices.Contains(parents, parent) {
+			return
+		}
+		if slices.ContainsFunc(p.Directories, func(dir *fsnode.Directory) bool { return dir.Path() == parent }) {
+			return
+		}
+		parents = append(parents, parent)
+	}
+	for _, dir := range p.Directories {
+		addParent(dir.Path())
+	}
+	for _, file := range p.Files {
+		addParent(file.Path())
+	}
+	slices.Sort(parents)
+	return parents
+}
@@ -18,6 +18,7 @@ import (
 
 	"github.com/osbuild/images/internal/common"
 	"github.com/osbuild/images/pkg/container"
+	"github.com/osbuild/images/pkg/customizations/remotefile"
 	"github.com/osbuild/images/pkg/dnfjson"
 	"github.com/osbuild/images/pkg/osbuild"
 	"github.com/osbuild/images/pkg/ostree"
@@ -83,7 +84,8 @@ func (m *OSBuildManifest) UnmarshalJSON(payload []byte) error {
 
 // Manifest represents a manifest initialised with all the information required
 // to generate the pipelines but no content. The content type sources
-// (PackageSetChains, ContainerSourceSpecs, OSTreeSourceSpecs) must be
+// (PackageSetChains, ContainerSourceSpecs, OSTreeSourceSpecs,
+// RemoteFileSourceSpecs) must be
 // retrieved through their corresponding Getters and resolved before
 // serializing.
 type Manifest struct {
@@ -168,6 +170,16 @@ func (m Manifest) GetOSTreeSourceSpecs() map[string][]ostree.SourceSpec {
 	return ostreeSpecs
 }
 
+func (m Manifest) GetRemoteFileSourceSpecs() map[string][]remotefile.SourceSpec {
+	remoteFileSpecs := make(map[string][]remotefile.SourceSpec)
+	for _, pipeline := range m.pipelines {
+		if remoteFiles := pipeline.getRemoteFileSources(); len(remoteFiles) > 0 {
+			remoteFileSpecs[pipeline.Name()] = remoteFiles
+		}
+	}
+	return remoteFileSpecs
+}
+
 type SerializeOptions struct {
 	RpmDownloader osbuild.RpmDownloader
 }
@@ -195,6 +207,7 @@ func (m Manifest) Serialize(depsolvedSets map[string]dnfjson.DepsolveResult, con
 		mergedInputs.Containers = append(mergedInputs.Containers, pipeline.getContainerSpecs()...)
 		mergedInputs.InlineData = append(mergedInputs.InlineData, pipeline.getInline()...)
 		mergedInputs.FileRefs = append(mergedInputs.FileRefs, pipeline.fileRefs()...)
+		mergedInputs.RemoteFiles = append(mergedInputs.RemoteFiles, pipeline.getRemoteFileSources()...)
 	}
 	for _, pipeline := range m.pipelines {
 		pipeline.serializeEnd()
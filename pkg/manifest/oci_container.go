@@ -6,14 +6,22 @@ import (
 )
 
 // An OCIContainer represents an OCI container, containing a filesystem
-// tree created by another Pipeline.
+// tree created by another Pipeline. Additional layers can be stacked on top of
+// the base tree, each one created by its own Pipeline.
 type OCIContainer struct {
 	Base
 	filename     string
 	Cmd          []string
+	Env          []string
 	ExposedPorts []string
+	User         string
+	Labels       map[string]string
+	StopSignal   string
+	Volumes      []string
+	WorkingDir   string
 
-	treePipeline TreePipeline
+	treePipeline   TreePipeline
+	layerPipelines []TreePipeline
 }
 
 func (p OCIContainer) Filename() string {
@@ -34,6 +42,16 @@ func NewOCIContainer(buildPipeline Build, treePipeline TreePipeline) *OCIContain
 	return p
 }
 
+// AddLayer adds the tree of the given pipeline as a layer on top of the
+// previously added layers. The whole tree becomes the layer, so it should only
+// contain the content that is added on top of the layers below it.
+func (p *OCIContainer) AddLayer(layerPipeline TreePipeline) {
+	if layerPipeline.Manifest() != p.Manifest() {
+		panic("layer pipeline from different manifest")
+	}
+	p.layerPipelines = append(p.layerPipelines, layerPipeline)
+}
+
 func (p *OCIContainer) serialize() osbuild.Pipeline {
 	pipeline := p.Base.serialize()
 
@@ -42,11 +60,20 @@ func (p *OCIContainer) serialize() osbuild.Pipeline {
 		Filename:     p.Filename(),
 		Config: &osbuild.OCIArchiveConfig{
 			Cmd:          p.Cmd,
+			Env:          p.Env,
 			ExposedPorts: p.ExposedPorts,
+			User:         p.User,
+			Labels:       p.Labels,
+			StopSignal:   p.StopSignal,
+			Volumes:      p.Volumes,
+			WorkingDir:   p.WorkingDir,
 		},
 	}
 	baseInput := osbuild.NewTreeInput("name:" + p.treePipeline.Name())
 	inputs := &osbuild.OCIArchiveStageInputs{Base: baseInput}
+	for _, layerPipeline := range p.layerPipelines {
+		inputs.Layers = append(inputs.Layers, *osbuild.NewTreeInput("name:" + layerPipeline.Name()))
+	}
 	pipeline.AddStage(osbuild.NewOCIArchiveStage(options, inputs))
 
 	return pipeline
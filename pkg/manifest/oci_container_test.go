@@ -0,0 +1,92 @@
+package manifest_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/pkg/customizations/fsnode"
+	"github.com/osbuild/images/pkg/manifest"
+	"github.com/osbuild/images/pkg/osbuild"
+)
+
+func TestOCIContainerConfig(t *testing.T) {
+	os := manifest.NewTestOS()
+	container := manifest.NewOCIContainer(os.BuildPipeline(), os)
+	container.Cmd = []string{"/usr/bin/httpd"}
+	container.Env = []string{"LANG=C.UTF-8"}
+	container.ExposedPorts = []string{"80"}
+	container.User = "1000"
+	container.Labels = map[string]string{"org.opencontainers.image.title": "httpd"}
+	container.StopSignal = "SIGWINCH"
+	container.Volumes = []string{"/var/www"}
+	container.WorkingDir = "/var/www"
+
+	pipeline := container.Serialize()
+	stage := manifest.FindStage("org.osbuild.oci-archive", pipeline.Stages)
+	require.NotNil(t, stage)
+	assert.Equal(t, &osbuild.OCIArchiveConfig{
+		Cmd:          []string{"/usr/bin/httpd"},
+		Env:          []string{"LANG=C.UTF-8"},
+		ExposedPorts: []string{"80"},
+		User:         "1000",
+		Labels:       map[string]string{"org.opencontainers.image.title": "httpd"},
+		StopSignal:   "SIGWINCH",
+		Volumes:      []string{"/var/www"},
+		WorkingDir:   "/var/www",
+	}, stage.Options.(*osbuild.OCIArchiveStageOptions).Config)
+}
+
+func TestOCIContainerLayers(t *testing.T) {
+	os := manifest.NewTestOS()
+	layer := manifest.NewFilesLayer(os.BuildPipeline(), os.Platform(), "container-files")
+	container := manifest.NewOCIContainer(os.BuildPipeline(), os)
+	container.AddLayer(layer)
+
+	pipeline := container.Serialize()
+	stage := manifest.FindStage("org.osbuild.oci-archive", pipeline.Stages)
+	require.NotNil(t, stage)
+	inputs := stage.Inputs.(*osbuild.OCIArchiveStageInputs)
+	assert.Equal(t, []string{"name:os"}, inputs.Base.References)
+	require.Len(t, inputs.Layers, 1)
+	assert.Equal(t, []string{"name:container-files"}, inputs.Layers[0].References)
+
+	data, err := json.Marshal(inputs)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"layer.1":`)
+}
+
+func TestFilesLayer(t *testing.T) {
+	os := manifest.NewTestOS()
+	layer := manifest.NewFilesLayer(os.BuildPipeline(), os.Platform(), "container-files")
+
+	dir, err := fsnode.NewDirectory("/etc/httpd/conf.d", nil, nil, nil, false)
+	require.NoError(t, err)
+	file, err := fsnode.NewFile("/etc/httpd/conf.d/site.conf", nil, int64(48), int64(48), []byte("ServerName localhost"))
+	require.NoError(t, err)
+	otherFile, err := fsnode.NewFile("/var/www/html/index.html", nil, nil, nil, []byte("hello"))
+	require.NoError(t, err)
+	layer.Directories = []*fsnode.Directory{dir}
+	layer.Files = []*fsnode.File{file, otherFile}
+
+	pipeline := layer.Serialize()
+	require.NotEmpty(t, pipeline.Stages)
+
+	// the parents of all nodes are created first, except for the
+	// directories of the layer itself
+	mkdirStage := pipeline.Stages[0]
+	require.Equal(t, "org.osbuild.mkdir", mkdirStage.Type)
+	var parents []string
+	for _, path := range mkdirStage.Options.(*osbuild.MkdirStageOptions).Paths {
+		parents = append(parents, path.Path)
+		assert.True(t, path.ExistOk)
+		assert.True(t, path.Parents)
+	}
+	assert.Equal(t, []string{"/etc/httpd", "/var/www/html"}, parents)
+
+	assert.Equal(t, []string{"tree:///etc/httpd/conf.d/site.conf", "tree:///var/www/html/index.html"}, collectCopyDestinationPaths(pipeline.Stages))
+	assert.NotNil(t, manifest.FindStage("org.osbuild.chown", pipeline.Stages))
+	assert.ElementsMatch(t, []string{"ServerName localhost", "hello"}, layer.GetInline())
+}
@@ -14,8 +14,11 @@ import (
 	"github.com/osbuild/images/pkg/arch"
 	"github.com/osbuild/images/pkg/container"
 	"github.com/osbuild/images/pkg/customizations/bootc"
+	"github.com/osbuild/images/pkg/customizations/composefs"
 	"github.com/osbuild/images/pkg/customizations/fsnode"
+	"github.com/osbuild/images/pkg/customizations/network"
 	"github.com/osbuild/images/pkg/customizations/oscap"
+	"github.com/osbuild/images/pkg/customizations/remotefile"
 	"github.com/osbuild/images/pkg/customizations/shell"
 	"github.com/osbuild/images/pkg/customizations/subscription"
 	"github.com/osbuild/images/pkg/customizations/users"
@@ -60,6 +63,12 @@ type OSCustomizations struct {
 	// KernelOptionsAppend are appended to the kernel commandline
 	KernelOptionsAppend []string
 
+	// InitramfsOmitDrivers are kernel modules that are kept out of the
+	// initramfs. The modules are added to the dracut configuration of the
+	// image and the initramfs is regenerated after all customizations are
+	// applied.
+	InitramfsOmitDrivers []string
+
 	// KernelOptionsBootloader controls whether kernel command line options
 	// should be specified in the bootloader grubenv configuration. Otherwise
 	// they are specified in /etc/kernel/cmdline (default).
@@ -144,10 +153,19 @@ type OSCustomizations struct {
 	RHSMConfig *subscription.RHSMConfig
 	RHSMFacts  *facts.ImageOptions
 
+	// Mount the root filesystem of the deployments of the ostree commit with
+	// composefs. Only supported for ostree commits.
+	Composefs *composefs.Options
+
 	// Custom directories to create in the image. The stages for the
 	// directories defined here are always added at the end of the pipeline.
 	Directories []*fsnode.Directory
 
+	// NetworkManager connection profiles (keyfiles) to create in the image.
+	// NetworkManager is installed when profiles are set and the profiles
+	// are created after the custom files.
+	NetworkConnections []*fsnode.File
+
 	// Custom files to create in the image. The stages for the files defined
 	// here are always added at the end of the pipeline.
 	Files []*fsnode.File
@@ -173,6 +191,11 @@ type OSCustomizations struct {
 	// instead of writing to /etc/fstab
 	MountUnits bool
 
+	// InstallTuned adds tuned to the packages of the image to apply the
+	// profiles of the Tuned configuration. Image types that configure tuned
+	// in their image config already install it with their package set.
+	InstallTuned bool
+
 	// VersionlockPackges uses dnf versionlock to lock a package to the version
 	// that is installed during image build, preventing it from being updated.
 	// This is only supported for distributions that use dnf4, because osbuild
@@ -249,7 +272,7 @@ func (p *OS) getPackageSetChain(Distro) []rpmmd.PackageSet {
 
 	var partitionTablePackages []string
 	if p.PartitionTable != nil {
-		partitionTablePackages = p.PartitionTable.GetBuildPackages()
+		partitionTablePackages = append(p.PartitionTable.GetBuildPackages(), p.PartitionTable.GetOSPackages()...)
 	}
 
 	if p.OSCustomizations.KernelName != "" {
@@ -299,6 +322,18 @@ func (p *OS) getPackageSetChain(Distro) []rpmmd.PackageSet {
 		customizationPackages = append(customizationPackages, "firewalld")
 	}
 
+	if len(p.OSCustomizations.NetworkConnections) > 0 {
+		// the connection profiles are plain keyfiles, make sure
+		// NetworkManager is installed to load them
+		customizationPackages = append(customizationPackages, "NetworkManager")
+	}
+
+	if p.OSCustomizations.Tuned != nil && p.OSCustomizations.InstallTuned {
+		// org.osbuild.tuned only writes the configuration, make sure
+		// tuned is installed to apply the profiles
+		customizationPackages = append(customizationPackages, "tuned")
+	}
+
 	if len(p.OSCustomizations.VersionlockPackages) > 0 {
 		// versionlocking packages requires dnf and the dnf plugin
 		customizationPackages = append(customizationPackages, "dnf", "python3-dnf-plugin-versionlock")
@@ -416,7 +451,7 @@ func (p *OS) getBuildPackages(distro Distro) []string {
 		packages = append(packages, tomlPkgsFor(distro)...)
 	}
 
-	if p.platform.GetBootloader() == platform.BOOTLOADER_UKI {
+	if p.platform.GetBootloader().IsUKI() {
 		// Only required if the hmac stage is added, which depends on the
 		// version of uki-direct. Add it conditioned just on the bootloader
 		// type for now, until we find a better way to decide.
@@ -440,6 +475,27 @@ func (p *OS) getOSTreeCommitSources() []ostree.SourceSpec {
 	}
 }
 
+// getRemoteFileSources returns the files from customizations that are fetched
+// from a remote URI, pinned by their checksum
+func (p *OS) getRemoteFileSources() []remotefile.SourceSpec {
+	return remoteFileSources(p.OSCustomizations.Files)
+}
+
+// remoteFileSources returns the sources of the files that are fetched from a
+// remote location and pinned by their checksum.
+func remoteFileSources(files []*fsnode.File) []remotefile.SourceSpec {
+	var remoteFiles []remotefile.SourceSpec
+	for _, file := range files {
+		if file.Checksum() != "" {
+			remoteFiles = append(remoteFiles, remotefile.SourceSpec{
+				URL:      file.URI(),
+				Checksum: file.Checksum(),
+			})
+		}
+	}
+	return remoteFiles
+}
+
 func (p *OS) getOSTreeCommits() []ostree.CommitSpec {
 	if p.ostreeParentSpec == nil {
 		return nil
@@ -522,7 +578,7 @@ func (p *OS) serialize() osbuild.Pipeline {
 		// https://github.com/osbuild/images/issues/624
 		rpmOptions.DisableDracut = true
 	}
-	if p.platform.GetBootloader() == platform.BOOTLOADER_UKI && p.PartitionTable != nil {
+	if p.platform.GetBootloader().IsUKI() && p.PartitionTable != nil {
 		espMountpoint, err := findESPMountpoint(p.PartitionTable)
 		if err != nil {
 			panic(err)
@@ -729,11 +785,19 @@ func (p *OS) serialize() osbuild.Pipeline {
 		}
 		kernelOptions = append(kernelOptions, p.OSCustomizations.KernelOptionsAppend...)
 
+		var dracutModules []string
 		if p.OSCustomizations.FIPS {
 			kernelOptions = append(kernelOptions, osbuild.GenFIPSKernelOptions(p.PartitionTable)...)
+			dracutModules = append(dracutModules, "fips")
+		}
+		if pt.VerityRoot() {
+			// opens the dm-verity protected root filesystem in the initrd
+			dracutModules = append(dracutModules, "systemd-veritysetup")
+		}
+		if len(dracutModules) > 0 {
 			pipeline.AddStage(osbuild.NewDracutStage(&osbuild.DracutStageOptions{
 				Kernel:     []string{p.kernelVer},
-				AddModules: []string{"fips"},
+				AddModules: dracutModules,
 			}))
 		}
 
@@ -752,16 +816,26 @@ func (p *OS) serialize() osbuild.Pipeline {
 		case platform.BOOTLOADER_ZIPL:
 			pipeline.AddStage(osbuild.NewZiplStage(new(osbuild.ZiplStageOptions)))
 			pipeline = prependKernelCmdlineStage(pipeline, rootUUID, kernelOptions)
-		case platform.BOOTLOADER_UKI:
+		case platform.BOOTLOADER_UKI, platform.BOOTLOADER_SYSTEMD_BOOT:
 			espMountpoint, err := findESPMountpoint(pt)
 			if err != nil {
 				panic(err)
 			}
-			csvfile, err := ukiBootCSVfile(espMountpoint, p.platform.GetArch(), p.kernelVer, p.platform.GetUEFIVendor())
-			if err != nil {
-				panic(err)
+			if p.platform.GetBootloader() == platform.BOOTLOADER_SYSTEMD_BOOT {
+				// systemd-boot is copied into the fallback boot path of
+				// the ESP by the image pipeline, see systemdBootFile()
+				bootDir, err := fsnode.NewDirectory(filepath.Join(espMountpoint, "EFI", "BOOT"), nil, nil, nil, true)
+				if err != nil {
+					panic(err)
+				}
+				pipeline.AddStages(osbuild.GenDirectoryNodesStages([]*fsnode.Directory{bootDir})...)
+			} else {
+				csvfile, err := ukiBootCSVfile(espMountpoint, p.platform.GetArch(), p.kernelVer, p.platform.GetUEFIVendor())
+				if err != nil {
+					panic(err)
+				}
+				p.addStagesForAllFilesAndInlineData(&pipeline, []*fsnode.File{csvfile})
 			}
-			p.addStagesForAllFilesAndInlineData(&pipeline, []*fsnode.File{csvfile})
 
 			stages, err := maybeAddHMACandDirStage(p.packageSpecs, espMountpoint, p.kernelVer)
 			if err != nil {
@@ -789,6 +863,19 @@ func (p *OS) serialize() osbuild.Pipeline {
 		}))
 	}
 
+	if cfs := p.OSCustomizations.Composefs; cfs != nil {
+		if p.OSTreeRef == "" {
+			panic("composefs is only supported for ostree commits, this is a programming error")
+		}
+		// prepare-root.conf and the public key must be in the tree when
+		// rpm-ostree generates the initramfs
+		cfsFiles, err := cfs.Files()
+		if err != nil {
+			panic(err)
+		}
+		p.addStagesForAllFilesAndInlineData(&pipeline, cfsFiles)
+	}
+
 	if p.OSTreeRef != "" {
 		pipeline.AddStage(osbuild.NewSystemdJournaldStage(
 			&osbuild.SystemdJournaldStageOptions{
@@ -840,6 +927,15 @@ func (p *OS) serialize() osbuild.Pipeline {
 		p.addStagesForAllFilesAndInlineData(&pipeline, p.OSCustomizations.Files)
 	}
 
+	if len(p.OSCustomizations.NetworkConnections) > 0 {
+		keyfileDir, err := fsnode.NewDirectory(network.KeyfileDir, nil, nil, nil, true)
+		if err != nil {
+			panic(fmt.Errorf("failed to create NetworkManager keyfile directory: %w", err))
+		}
+		pipeline.AddStages(osbuild.GenDirectoryNodesStages([]*fsnode.Directory{keyfileDir})...)
+		p.addStagesForAllFilesAndInlineData(&pipeline, p.OSCustomizations.NetworkConnections)
+	}
+
 	enabledServices := []string{}
 	disabledServices := []string{}
 	maskedServices := []string{}
@@ -888,6 +984,21 @@ func (p *OS) serialize() osbuild.Pipeline {
 		p.addStagesForAllFilesAndInlineData(&pipeline, osbuild.GenFIPSFiles())
 	}
 
+	if len(p.OSCustomizations.InitramfsOmitDrivers) > 0 {
+		dracutConf, err := omitDriversDracutConfFile(p.OSCustomizations.InitramfsOmitDrivers)
+		if err != nil {
+			panic(err)
+		}
+		p.addStagesForAllFilesAndInlineData(&pipeline, []*fsnode.File{dracutConf})
+		// ostree based images get their initramfs generated when the
+		// commit is composed, the configuration file is enough there
+		if p.kernelVer != "" && p.OSTreeRef == "" {
+			pipeline.AddStage(osbuild.NewDracutStage(&osbuild.DracutStageOptions{
+				Kernel: []string{p.kernelVer},
+			}))
+		}
+	}
+
 	// NOTE: We need to run the OpenSCAP stages as the last stage before SELinux
 	// since the remediation may change file permissions and other aspects of the
 	// hardened image
@@ -968,6 +1079,15 @@ func (p *OS) serialize() osbuild.Pipeline {
 	return pipeline
 }
 
+// omitDriversDracutConfFile creates a dracut configuration file that keeps
+// the given kernel modules out of the initramfs. The org.osbuild.dracut.conf
+// stage has no support for the omit_drivers option, so the file is written
+// directly.
+func omitDriversDracutConfFile(drivers []string) (*fsnode.File, error) {
+	data := fmt.Sprintf("omit_drivers+=\" %s \"\n", strings.Join(drivers, " "))
+	return fsnode.NewFile("/etc/dracut.conf.d/90-blueprint-omit-drivers.conf", nil, nil, nil, []byte(data))
+}
+
 func prependKernelCmdlineStage(pipeline osbuild.Pipeline, rootUUID string, kernelOptions []string) osbuild.Pipeline {
 	kernelStage := osbuild.NewKernelCmdlineStage(osbuild.NewKernelCmdlineStageOptions(rootUUID, strings.Join(kernelOptions, " ")))
 	pipeline.Stages = append([]*osbuild.Stage{kernelStage}, pipeline.Stages...)
@@ -1070,17 +1190,12 @@ func grubStage(p *OS, pt *disk.PartitionTable, kernelOptions []string) *osbuild.
 // command from the python3-virt-firmware package will gain the ability to
 // write these files offline during the RHEL 9.7 / 10.1 development cycle.
 func ukiBootCSVfile(espMountpoint string, architecture arch.Arch, kernelVer, vendor string) (*fsnode.File, error) {
-	shortArch := ""
-	switch architecture {
-	case arch.ARCH_AARCH64:
-		shortArch = "aa64"
-	case arch.ARCH_X86_64:
-		shortArch = "x64"
-	default:
+	shortArch, err := efiArchName(architecture)
+	if err != nil {
 		return nil, fmt.Errorf("ukiBootCSVfile: UKIs are only supported for x86_64 and aarch64")
 	}
 
-	kernelFilename := fmt.Sprintf("ffffffffffffffffffffffffffffffff-%s.efi", kernelVer)
+	kernelFilename := filepath.Base(ukiPath("/", kernelVer))
 	data := fmt.Sprintf("shim%s.efi,%s,\\EFI\\Linux\\%s ,UKI bootentry\n", shortArch, vendor, kernelFilename)
 
 	csvPath := filepath.Join(espMountpoint, "EFI", vendor, fmt.Sprintf("BOOT%s.CSV", strings.ToUpper(shortArch)))
@@ -1088,6 +1203,39 @@ func ukiBootCSVfile(espMountpoint string, architecture arch.Arch, kernelVer, ven
 	return fsnode.NewFile(csvPath, nil, nil, nil, common.EncodeUTF16le(data))
 }
 
+// efiArchName returns the short architecture name that is used in the file
+// names of EFI binaries, e.g. "x64" for shimx64.efi.
+func efiArchName(architecture arch.Arch) (string, error) {
+	switch architecture {
+	case arch.ARCH_AARCH64:
+		return "aa64", nil
+	case arch.ARCH_X86_64:
+		return "x64", nil
+	default:
+		return "", fmt.Errorf("no EFI binaries for architecture %q", architecture)
+	}
+}
+
+// ukiPath returns the path of the UKI of the kernel version in the ESP, as
+// installed by kernel-install(8) without a machine-id.
+func ukiPath(espMountpoint, kernelVer string) string {
+	return filepath.Join(espMountpoint, "EFI", "Linux", fmt.Sprintf("ffffffffffffffffffffffffffffffff-%s.efi", kernelVer))
+}
+
+// systemdBootFile returns the path of the systemd-boot binary in the tree
+// and the fallback boot path of the ESP it is copied to. systemd-boot finds
+// the UKIs in EFI/Linux of the ESP without any further configuration.
+func systemdBootFile(espMountpoint string, architecture arch.Arch) ([2]string, error) {
+	efiArch, err := efiArchName(architecture)
+	if err != nil {
+		return [2]string{}, err
+	}
+	return [2]string{
+		fmt.Sprintf("/usr/lib/systemd/boot/efi/systemd-boot%s.efi", efiArch),
+		filepath.Join(espMountpoint, "EFI", "BOOT", fmt.Sprintf("BOOT%s.EFI", strings.ToUpper(efiArch))),
+	}, nil
+}
+
 func findESPMountpoint(pt *disk.PartitionTable) (string, error) {
 	// the ESP in our images is always at /boot/efi, but let's make this more
 	// flexible and future proof by finding the ESP mountpoint from the
@@ -1141,8 +1289,7 @@ func maybeAddHMACandDirStage(packages []rpmmd.PackageSpec, espMountpoint, kernel
 
 	if common.VersionLessThan(ukiDirect.Version, "25.3") {
 		// generate hmac file using stage
-		kernelFilename := fmt.Sprintf("ffffffffffffffffffffffffffffffff-%s.efi", kernelVer)
-		kernelPath := filepath.Join(espMountpoint, "EFI", "Linux", kernelFilename)
+		kernelPath := ukiPath(espMountpoint, kernelVer)
 		hmacStage := osbuild.NewHMACStage(&osbuild.HMACStageOptions{
 			Paths:     []string{kernelPath},
 			Algorithm: "sha512",
@@ -1191,10 +1338,16 @@ func (p *OS) addStagesForAllFilesAndInlineData(pipeline *osbuild.Pipeline, files
 //
 // Note that the actual copy/chmod/... stages are generated via addStagesForAllFilesAndInlineData
 func (p *OS) fileRefs() []string {
+	return fileRefs(p.OSCustomizations.Files)
+}
+
+// fileRefs returns the local paths of the files that come via an URI
+func fileRefs(files []*fsnode.File) []string {
 	var fileRefs []string
 
-	for _, file := range p.OSCustomizations.Files {
-		if uriStr := file.URI(); uriStr != "" {
+	for _, file := range files {
+		// remote files are added via "getRemoteFileSources()"
+		if uriStr := file.URI(); uriStr != "" && file.Checksum() == "" {
 			uri, err := url.Parse(uriStr)
 			if err != nil {
 				panic(fmt.Errorf("internal error: file customizations is not a valid URL: %w", err))
@@ -12,8 +12,11 @@ import (
 	"github.com/osbuild/images/internal/testdisk"
 	"github.com/osbuild/images/pkg/container"
 	"github.com/osbuild/images/pkg/customizations/bootc"
+	"github.com/osbuild/images/pkg/customizations/composefs"
 	"github.com/osbuild/images/pkg/customizations/fsnode"
+	"github.com/osbuild/images/pkg/customizations/network"
 	"github.com/osbuild/images/pkg/customizations/subscription"
+	"github.com/osbuild/images/pkg/disk"
 	"github.com/osbuild/images/pkg/dnfjson"
 	"github.com/osbuild/images/pkg/manifest"
 	"github.com/osbuild/images/pkg/osbuild"
@@ -158,6 +161,48 @@ func TestRhcInsightsPackages(t *testing.T) {
 	CheckPkgSetInclude(t, os.GetPackageSetChain(manifest.DISTRO_NULL), []string{"rhc", "subscription-manager", "insights-client"})
 }
 
+func TestTunedPackages(t *testing.T) {
+	os := manifest.NewTestOS()
+	os.OSCustomizations.Tuned = osbuild.NewTunedStageOptions("sap-hana")
+	// image types with tuned in their image config install it themselves
+	for _, ps := range os.GetPackageSetChain(manifest.DISTRO_NULL) {
+		assert.NotContains(t, ps.Include, "tuned")
+	}
+
+	os.OSCustomizations.InstallTuned = true
+	CheckPkgSetInclude(t, os.GetPackageSetChain(manifest.DISTRO_NULL), []string{"tuned"})
+}
+
+func TestLUKSClevisPackages(t *testing.T) {
+	pt := testdisk.TestPartitionTables()["luks"]
+	for _, part := range pt.Partitions {
+		if lc, ok := part.Payload.(*disk.LUKSContainer); ok {
+			lc.Clevis = &disk.ClevisBind{Pin: "tang", Policy: `{"url": "http://tang.example.com"}`}
+		}
+	}
+
+	os := manifest.NewTestOS()
+	os.PartitionTable = &pt
+	CheckPkgSetInclude(t, os.GetPackageSetChain(manifest.DISTRO_NULL), []string{"cryptsetup", "clevis-luks", "clevis-dracut", "clevis-pin-tang"})
+}
+
+func TestNetworkConnections(t *testing.T) {
+	keyfile, err := fsnode.NewFile(network.KeyfilePath("eth0"), nil, nil, nil, []byte("[connection]\nid=eth0\n"))
+	require.NoError(t, err)
+
+	os := manifest.NewTestOS()
+	os.OSCustomizations.NetworkConnections = []*fsnode.File{keyfile}
+	CheckPkgSetInclude(t, os.GetPackageSetChain(manifest.DISTRO_NULL), []string{"NetworkManager"})
+
+	pipeline := os.Serialize()
+	mkdir := manifest.FindStage("org.osbuild.mkdir", pipeline.Stages)
+	require.NotNil(t, mkdir)
+	assert.Equal(t, []osbuild.MkdirStagePath{
+		{Path: network.KeyfileDir, Parents: true, ExistOk: true},
+	}, mkdir.Options.(*osbuild.MkdirStageOptions).Paths)
+	assert.Equal(t, []string{"tree://" + network.KeyfilePath("eth0")}, collectCopyDestinationPaths(pipeline.Stages))
+}
+
 func TestBootupdStage(t *testing.T) {
 	os := manifest.NewTestOS()
 	os.OSTreeRef = "some/ref"
@@ -167,6 +212,33 @@ func TestBootupdStage(t *testing.T) {
 	require.NotNil(t, st)
 }
 
+func TestComposefsPrepareRootConf(t *testing.T) {
+	os := manifest.NewTestOS()
+	os.OSTreeRef = "some/ref"
+	os.OSCustomizations.Composefs = &composefs.Options{
+		Mode:      composefs.ModeSigned,
+		PublicKey: "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=",
+	}
+	pipeline := os.Serialize()
+
+	assert.Equal(t, []string{
+		"tree://" + composefs.PrepareRootConfPath,
+		"tree://" + composefs.DefaultKeyPath,
+	}, collectCopyDestinationPaths(pipeline.Stages))
+	assert.ElementsMatch(t, []string{
+		"[composefs]\nenabled = signed\nkeypath = " + composefs.DefaultKeyPath + "\n",
+		"AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=\n",
+	}, os.GetInline())
+}
+
+func TestComposefsRequiresOSTree(t *testing.T) {
+	os := manifest.NewTestOS()
+	os.OSCustomizations.Composefs = &composefs.Options{Mode: composefs.ModeEnabled}
+	assert.PanicsWithValue(t, "composefs is only supported for ostree commits, this is a programming error", func() {
+		os.Serialize()
+	})
+}
+
 func TestInsightsClientConfigStage(t *testing.T) {
 	os := manifest.NewTestOS()
 	os.OSCustomizations.InsightsClientConfig = &osbuild.InsightsClientConfigStageOptions{
@@ -569,6 +641,78 @@ func TestHMACStageInclusion(t *testing.T) {
 	})
 }
 
+func TestUKIBootloaders(t *testing.T) {
+	repos := []rpmmd.RepoConfig{}
+	runner := &runner.CentOS{Version: 10}
+	pt := testdisk.TestPartitionTables()["plain"]
+	inputs := manifest.Inputs{
+		Depsolved: dnfjson.DepsolveResult{
+			Packages: []rpmmd.PackageSpec{
+				{
+					Name:     "kernel-uki-virt",
+					Version:  "6.12.0",
+					Release:  "55.el10",
+					Arch:     "x86_64",
+					Checksum: "sha256:7777777777777777777777777777777777777777777777777777777777777777",
+				},
+			},
+		},
+	}
+
+	for name, tc := range map[string]struct {
+		platform platform.Platform
+		csvPath  string
+		bootDir  string
+	}{
+		"x86_64-uki": {
+			platform: &platform.X86{Bootloader: platform.BOOTLOADER_UKI, UEFIVendor: "centos"},
+			csvPath:  "/boot/efi/EFI/centos/BOOTX64.CSV",
+		},
+		"aarch64-uki": {
+			platform: &platform.Aarch64{Bootloader: platform.BOOTLOADER_UKI, UEFIVendor: "centos"},
+			csvPath:  "/boot/efi/EFI/centos/BOOTAA64.CSV",
+		},
+		"x86_64-systemd-boot": {
+			platform: &platform.X86{Bootloader: platform.BOOTLOADER_SYSTEMD_BOOT, UEFIVendor: "centos"},
+			bootDir:  "/boot/efi/EFI/BOOT",
+		},
+		"aarch64-systemd-boot": {
+			platform: &platform.Aarch64{Bootloader: platform.BOOTLOADER_SYSTEMD_BOOT, UEFIVendor: "centos"},
+			bootDir:  "/boot/efi/EFI/BOOT",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			m := manifest.New()
+			build := manifest.NewBuild(&m, runner, repos, nil)
+			os := manifest.NewOS(build, tc.platform, repos)
+			os.PartitionTable = &pt
+			os.OSCustomizations.KernelName = "kernel-uki-virt"
+			// the command line of the UKI is part of the signed binary
+			os.OSCustomizations.KernelOptionsAppend = []string{"debug"}
+			pipeline := os.SerializeWith(inputs)
+
+			// kernel-install puts the UKI into the ESP
+			rpmStage := manifest.FindStage("org.osbuild.rpm", pipeline.Stages)
+			require.NotNil(t, rpmStage)
+			assert.Equal(t, "/boot/efi", rpmStage.Options.(*osbuild.RPMStageOptions).KernelInstallEnv.BootRoot)
+
+			assert.Nil(t, manifest.FindStage("org.osbuild.grub2", pipeline.Stages))
+			assert.Nil(t, manifest.FindStage("org.osbuild.kernel-cmdline", pipeline.Stages))
+
+			mkdirStage := manifest.FindStage("org.osbuild.mkdir", pipeline.Stages)
+			if tc.bootDir != "" {
+				// systemd-boot is copied there by the image pipeline
+				require.NotNil(t, mkdirStage)
+				assert.Equal(t, tc.bootDir, mkdirStage.Options.(*osbuild.MkdirStageOptions).Paths[0].Path)
+				assert.Empty(t, collectCopyDestinationPaths(pipeline.Stages))
+			} else {
+				assert.Nil(t, mkdirStage)
+				assert.Contains(t, collectCopyDestinationPaths(pipeline.Stages), "tree://"+tc.csvPath)
+			}
+		})
+	}
+}
+
 func TestShimVersionLock(t *testing.T) {
 	repos := []rpmmd.RepoConfig{}
 	runner := &runner.CentOS{Version: 9}
@@ -638,3 +782,31 @@ func TestShimVersionLock(t *testing.T) {
 
 	assert.Equal(t, []string{"shim-x64-0:15.8-3"}, stageOptions.Add)
 }
+
+func TestInitramfsOmitDrivers(t *testing.T) {
+	os := manifest.NewTestOS()
+	os.OSCustomizations.KernelName = "kernel"
+	os.OSCustomizations.InitramfsOmitDrivers = []string{"nouveau", "amdgpu"}
+
+	pipeline := os.SerializeWith(manifest.Inputs{
+		Depsolved: dnfjson.DepsolveResult{
+			Packages: []rpmmd.PackageSpec{
+				{Name: "kernel", Version: "6.9.1", Release: "1.fc40", Arch: "x86_64", Checksum: "sha1:c02524e2bd19490f2a7167958f792262754c5f46"},
+			},
+		},
+	})
+
+	assert.Contains(t, os.GetInline(), "omit_drivers+=\" nouveau amdgpu \"\n")
+	st := manifest.FindStage("org.osbuild.dracut", pipeline.Stages)
+	require.NotNil(t, st)
+	assert.Equal(t, []string{"6.9.1-1.fc40.x86_64"}, st.Options.(*osbuild.DracutStageOptions).Kernel)
+}
+
+func TestInitramfsOmitDriversNoKernel(t *testing.T) {
+	os := manifest.NewTestOS()
+	os.OSCustomizations.InitramfsOmitDrivers = []string{"nouveau"}
+
+	pipeline := os.Serialize()
+	assert.Contains(t, os.GetInline(), "omit_drivers+=\" nouveau \"\n")
+	assert.Nil(t, manifest.FindStage("org.osbuild.dracut", pipeline.Stages))
+}
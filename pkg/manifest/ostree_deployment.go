@@ -7,6 +7,7 @@ import (
 
 	"github.com/osbuild/images/internal/common"
 	"github.com/osbuild/images/pkg/container"
+	"github.com/osbuild/images/pkg/customizations/composefs"
 	"github.com/osbuild/images/pkg/customizations/fsnode"
 	"github.com/osbuild/images/pkg/customizations/users"
 	"github.com/osbuild/images/pkg/disk"
@@ -46,6 +47,12 @@ type OSTreeDeploymentCustomizations struct {
 	// MountUnits creates systemd .mount units to describe the filesystem
 	// instead of writing to /etc/fstab
 	MountUnits bool
+
+	// Composefs configures the repo of the deployment for the composefs
+	// mode of the commit. The prepare-root.conf that enables composefs is
+	// part of the commit (see OSCustomizations.Composefs) because the
+	// initramfs reads it before the deployment is mounted.
+	Composefs *composefs.Options
 }
 
 // OSTreeDeployment represents the filesystem tree of a target image based
@@ -329,15 +336,27 @@ func (p *OSTreeDeployment) serialize() osbuild.Pipeline {
 		panic("no content source defined for ostree deployment")
 	}
 
+	ostreeConfig := &osbuild.OSTreeConfig{
+		Sysroot: &osbuild.SysrootOptions{
+			ReadOnly:   &p.SysrootReadOnly,
+			Bootloader: "none",
+		},
+	}
+	if p.Composefs != nil {
+		// fs-verity cannot be enabled in the tree of the pipeline, it is
+		// enabled on the objects after they are copied onto the root
+		// filesystem of the image (see RawOSTreeImage)
+		ostreeConfig.Integrity = &osbuild.IntegrityOptions{
+			FSVerity: "maybe",
+		}
+		if p.Composefs.FSVerity() {
+			ostreeConfig.Integrity.FSVerity = "true"
+		}
+	}
 	configStage := osbuild.NewOSTreeConfigStage(
 		&osbuild.OSTreeConfigStageOptions{
-			Repo: repoPath,
-			Config: &osbuild.OSTreeConfig{
-				Sysroot: &osbuild.SysrootOptions{
-					ReadOnly:   &p.SysrootReadOnly,
-					Bootloader: "none",
-				},
-			},
+			Repo:   repoPath,
+			Config: ostreeConfig,
 		},
 	)
 	configStage.MountOSTree(p.osName, ref, 0)
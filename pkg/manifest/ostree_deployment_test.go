@@ -1,14 +1,18 @@
 package manifest_test
 
 import (
+	"math/rand"
 	"testing"
 
 	"github.com/osbuild/images/internal/testdisk"
+	"github.com/osbuild/images/pkg/customizations/composefs"
 	"github.com/osbuild/images/pkg/manifest"
+	"github.com/osbuild/images/pkg/osbuild"
 	"github.com/osbuild/images/pkg/ostree"
 	"github.com/osbuild/images/pkg/platform"
 	"github.com/osbuild/images/pkg/rpmmd"
 	"github.com/osbuild/images/pkg/runner"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -87,3 +91,45 @@ func TestAddInlineOSTreeDeployment(t *testing.T) {
 	// order doesn't matter
 	require.ElementsMatch(expectedContents, fileContents)
 }
+
+func TestOSTreeDeploymentComposefs(t *testing.T) {
+	for name, tc := range map[string]struct {
+		composefs         *composefs.Options
+		expectedIntegrity *osbuild.IntegrityOptions
+	}{
+		"no-composefs": {},
+		"enabled": {
+			composefs:         &composefs.Options{Mode: composefs.ModeEnabled},
+			expectedIntegrity: &osbuild.IntegrityOptions{FSVerity: "maybe"},
+		},
+		"verity": {
+			composefs:         &composefs.Options{Mode: composefs.ModeVerity},
+			expectedIntegrity: &osbuild.IntegrityOptions{FSVerity: "true"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			deployment := NewTestOSTreeDeployment()
+			pt := testdisk.TestPartitionTables()["plain"]
+			/* #nosec G404 */
+			pt.GenerateUUIDs(rand.New(rand.NewSource(0)))
+			deployment.PartitionTable = &pt
+			deployment.Composefs = tc.composefs
+
+			st := manifest.FindStage("org.osbuild.ostree.config", deployment.Serialize().Stages)
+			require.NotNil(t, st)
+			options := st.Options.(*osbuild.OSTreeConfigStageOptions)
+			assert.Equal(t, tc.expectedIntegrity, options.Config.Integrity)
+
+			// the objects get fs-verity once they are on the filesystem of
+			// the image
+			raw := manifest.NewRawOStreeImage(deployment.BuildPipeline(), deployment, &platform.X86{})
+			postCopy := manifest.FindStage("org.osbuild.ostree.post-copy", raw.Serialize().Stages)
+			if tc.composefs != nil {
+				require.NotNil(t, postCopy)
+				assert.NotEmpty(t, postCopy.Mounts)
+			} else {
+				assert.Nil(t, postCopy)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package manifest
 import (
 	"github.com/osbuild/images/pkg/artifact"
 	"github.com/osbuild/images/pkg/container"
+	"github.com/osbuild/images/pkg/customizations/remotefile"
 	"github.com/osbuild/images/pkg/osbuild"
 	"github.com/osbuild/images/pkg/ostree"
 	"github.com/osbuild/images/pkg/platform"
@@ -52,6 +53,11 @@ type Pipeline interface {
 	// resolved and added to the pipeline. Each source should be resolved to
 	// its full Spec. See the ostree package for more details.
 	getOSTreeCommitSources() []ostree.SourceSpec
+	// getRemoteFileSources returns the list of remote files that are fetched
+	// by the pipeline. The files are pinned by their checksums, resolving
+	// them only verifies the checksums. See the remotefile package for more
+	// details.
+	getRemoteFileSources() []remotefile.SourceSpec
 
 	serializeStart(Inputs)
 	serializeEnd()
@@ -135,6 +141,10 @@ func (p Base) getOSTreeCommitSources() []ostree.SourceSpec {
 	return nil
 }
 
+func (p Base) getRemoteFileSources() []remotefile.SourceSpec {
+	return nil
+}
+
 func (p Base) getPackageSpecs() []rpmmd.PackageSpec {
 	return []rpmmd.PackageSpec{}
 }
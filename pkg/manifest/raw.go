@@ -2,10 +2,12 @@ package manifest
 
 import (
 	"fmt"
+	"slices"
 
 	"github.com/osbuild/images/pkg/arch"
 	"github.com/osbuild/images/pkg/artifact"
 	"github.com/osbuild/images/pkg/osbuild"
+	"github.com/osbuild/images/pkg/platform"
 )
 
 // A RawImage represents a raw image file which can be booted in a
@@ -15,6 +17,10 @@ type RawImage struct {
 	treePipeline *OS
 	filename     string
 	PartTool     osbuild.PartTool
+
+	// ReadOnlyRootfs creates the root filesystem if it is read-only, see
+	// disk.PartitionTable.ReadOnlyRoot()
+	ReadOnlyRootfs *ReadOnlyRootfs
 }
 
 func (p RawImage) Filename() string {
@@ -56,12 +62,33 @@ func (p *RawImage) serialize() osbuild.Pipeline {
 		pipeline.AddStage(stage)
 	}
 
+	if pt.ReadOnlyRoot() {
+		if p.ReadOnlyRootfs == nil {
+			panic("read-only root filesystem without a rootfs pipeline")
+		}
+		pipeline.AddStage(osbuild.GenImageReadOnlyRootStage(pt, p.Filename(), p.ReadOnlyRootfs.Name(), p.ReadOnlyRootfs.Filename()))
+	}
+
 	inputName := "root-tree"
 	copyOptions, copyDevices, copyMounts := osbuild.GenCopyFSTreeOptions(inputName, p.treePipeline.Name(), p.Filename(), pt)
 	copyInputs := osbuild.NewPipelineTreeInputs(inputName, p.treePipeline.Name())
 	pipeline.AddStage(osbuild.NewCopyStage(copyOptions, copyInputs, copyDevices, copyMounts))
 
 	bootFiles := p.treePipeline.platform.GetBootFiles()
+	if p.treePipeline.platform.GetBootloader() == platform.BOOTLOADER_SYSTEMD_BOOT {
+		espMountpoint, err := findESPMountpoint(pt)
+		if err != nil {
+			panic(err)
+		}
+		bootFile, err := systemdBootFile(espMountpoint, p.treePipeline.platform.GetArch())
+		if err != nil {
+			panic(err)
+		}
+		bootFiles = append(slices.Clone(bootFiles), bootFile)
+	}
+	if len(bootFiles) > 0 && pt.ReadOnlyRoot() {
+		panic("boot files cannot be copied to a read-only root filesystem")
+	}
 	if len(bootFiles) > 0 {
 		// we ignore the bootcopyoptions as they contain a full tree copy instead we make our own, we *do* still want all the other
 		// information such as mountpoints and devices
@@ -97,6 +124,11 @@ func (p *RawImage) serialize() osbuild.Pipeline {
 		pipeline.AddStage(stage)
 	}
 
+	// the hash tree is computed once the root filesystem is written
+	if verityStage := osbuild.GenImageVerityStage(pt, p.Filename()); verityStage != nil {
+		pipeline.AddStage(verityStage)
+	}
+
 	switch p.treePipeline.platform.GetArch() {
 	case arch.ARCH_S390X:
 		loopback := osbuild.NewLoopbackDevice(&osbuild.LoopbackDeviceOptions{Filename: p.Filename()})
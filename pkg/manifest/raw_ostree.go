@@ -106,6 +106,12 @@ func (p *RawOSTreeImage) serialize() osbuild.Pipeline {
 		pipeline.AddStage(osbuild.NewCopyStage(bootCopyOptions, bootCopyInputs, bootCopyDevices, bootCopyMounts))
 	}
 
+	if p.treePipeline.Composefs != nil {
+		// enable fs-verity on the objects and composefs images that were
+		// copied onto the root filesystem
+		pipeline.AddStage(osbuild.NewOSTreePostCopyStage(treeCopyDevices, treeCopyMounts))
+	}
+
 	for _, stage := range osbuild.GenImageFinishStages(pt, p.Filename()) {
 		pipeline.AddStage(stage)
 	}
@@ -0,0 +1,65 @@
+package manifest_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/internal/testdisk"
+	"github.com/osbuild/images/pkg/disk"
+	"github.com/osbuild/images/pkg/manifest"
+	"github.com/osbuild/images/pkg/osbuild"
+	"github.com/osbuild/images/pkg/platform"
+	"github.com/osbuild/images/pkg/rpmmd"
+	"github.com/osbuild/images/pkg/runner"
+)
+
+func newTestRawImage(pt *disk.PartitionTable) *manifest.RawImage {
+	repos := []rpmmd.RepoConfig{}
+	m := manifest.New()
+	build := manifest.NewBuild(&m, &runner.Fedora{Version: 41}, repos, nil)
+	build.Checkpoint()
+
+	os := manifest.NewOS(build, &platform.X86{UEFIVendor: "fedora"}, repos)
+	os.PartitionTable = pt
+	return manifest.NewRawImage(build, os)
+}
+
+func stageTypes(stages []*osbuild.Stage) []string {
+	var types []string
+	for _, stage := range stages {
+		types = append(types, stage.Type)
+	}
+	return types
+}
+
+func TestRawImageSerialize(t *testing.T) {
+	raw := newTestRawImage(testdisk.MakeFakePartitionTable("/boot/efi", "/"))
+	pipeline := raw.Serialize()
+	require.NotEmpty(t, pipeline.Stages)
+	assert.Equal(t, "org.osbuild.truncate", pipeline.Stages[0].Type)
+	assert.Contains(t, stageTypes(pipeline.Stages), "org.osbuild.copy")
+}
+
+func TestRawImageSystemdBoot(t *testing.T) {
+	repos := []rpmmd.RepoConfig{}
+	m := manifest.New()
+	build := manifest.NewBuild(&m, &runner.Fedora{Version: 41}, repos, nil)
+	os := manifest.NewOS(build, &platform.Aarch64{Bootloader: platform.BOOTLOADER_SYSTEMD_BOOT, UEFIVendor: "fedora"}, repos)
+	pt := testdisk.TestPartitionTables()["plain"]
+	os.PartitionTable = &pt
+	raw := manifest.NewRawImage(build, os)
+
+	pipeline := raw.Serialize()
+	var copyPaths []osbuild.CopyStagePath
+	for _, stage := range pipeline.Stages {
+		if stage.Type == "org.osbuild.copy" {
+			copyPaths = append(copyPaths, stage.Options.(*osbuild.CopyStageOptions).Paths...)
+		}
+	}
+	assert.Contains(t, copyPaths, osbuild.CopyStagePath{
+		From: "input://root-tree/usr/lib/systemd/boot/efi/systemd-bootaa64.efi",
+		To:   "mount://-/boot/efi/EFI/BOOT/BOOTAA64.EFI",
+	})
+}
@@ -0,0 +1,77 @@
+package manifest
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/osbuild/images/pkg/disk"
+	"github.com/osbuild/images/pkg/osbuild"
+)
+
+// A ReadOnlyRootfs creates the read-only root filesystem of a disk image
+// from an OS tree. The content of the other filesystems of the partition
+// table is excluded, it is copied onto them by the RawImage pipeline.
+type ReadOnlyRootfs struct {
+	Base
+	filename string
+
+	// Compression of the EROFS filesystem, defaults to lz4hc which is fast
+	// to decompress
+	Compression *osbuild.ErofsCompression
+
+	treePipeline *OS
+}
+
+func (p ReadOnlyRootfs) Filename() string {
+	return p.filename
+}
+
+func NewReadOnlyRootfs(buildPipeline Build, treePipeline *OS) *ReadOnlyRootfs {
+	p := &ReadOnlyRootfs{
+		Base:         NewBase("rootfs", buildPipeline),
+		treePipeline: treePipeline,
+		filename:     "rootfs.img",
+	}
+	buildPipeline.addDependent(p)
+	return p
+}
+
+func (p *ReadOnlyRootfs) getBuildPackages(Distro) []string {
+	return []string{"erofs-utils"}
+}
+
+func (p *ReadOnlyRootfs) serialize() osbuild.Pipeline {
+	pipeline := p.Base.serialize()
+
+	pt := p.treePipeline.PartitionTable
+	if pt == nil {
+		panic("no partition table for read-only root filesystem")
+	}
+	if !pt.ReadOnlyRoot() {
+		panic("the root filesystem of the partition table is not read-only")
+	}
+
+	// keep the mountpoints but exclude their content
+	var excludePaths []string
+	_ = pt.ForEachMountable(func(mnt disk.Mountable, _ []disk.Entity) error {
+		if mountpoint := mnt.GetMountpoint(); mountpoint != "/" && mountpoint != "" {
+			excludePaths = append(excludePaths, strings.TrimPrefix(mountpoint, "/")+"/.*")
+		}
+		return nil
+	})
+	slices.Sort(excludePaths)
+
+	compression := p.Compression
+	if compression == nil {
+		compression = &osbuild.ErofsCompression{Method: "lz4hc"}
+	}
+
+	options := &osbuild.ErofsStageOptions{
+		Filename:     p.Filename(),
+		ExcludePaths: excludePaths,
+		Compression:  compression,
+	}
+	pipeline.AddStage(osbuild.NewErofsStage(options, p.treePipeline.Name()))
+
+	return pipeline
+}
@@ -0,0 +1,88 @@
+package manifest_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/internal/testdisk"
+	"github.com/osbuild/images/pkg/arch"
+	"github.com/osbuild/images/pkg/manifest"
+	"github.com/osbuild/images/pkg/osbuild"
+	"github.com/osbuild/images/pkg/platform"
+	"github.com/osbuild/images/pkg/rpmmd"
+	"github.com/osbuild/images/pkg/runner"
+)
+
+func TestReadOnlyRootfsSerialize(t *testing.T) {
+	repos := []rpmmd.RepoConfig{}
+	m := manifest.New()
+	build := manifest.NewBuild(&m, &runner.Fedora{Version: 41}, repos, nil)
+	build.Checkpoint()
+
+	os := manifest.NewOS(build, &platform.X86{UEFIVendor: "fedora"}, repos)
+	os.PartitionTable = testdisk.MakeFakeEROFSPartitionTable("/", "/boot", "/boot/efi", "/var")
+	rootfs := manifest.NewReadOnlyRootfs(build, os)
+	raw := manifest.NewRawImage(build, os)
+	raw.ReadOnlyRootfs = rootfs
+
+	pipeline := rootfs.Serialize()
+	assert.Equal(t, "rootfs", pipeline.Name)
+	require.Len(t, pipeline.Stages, 1)
+	assert.Equal(t, osbuild.NewErofsStage(&osbuild.ErofsStageOptions{
+		Filename:     "rootfs.img",
+		ExcludePaths: []string{"boot/.*", "boot/efi/.*", "var/.*"},
+		Compression:  &osbuild.ErofsCompression{Method: "lz4hc"},
+	}, os.Name()), pipeline.Stages[0])
+
+	imagePipeline := raw.Serialize()
+	assert.Equal(t, []string{
+		"org.osbuild.truncate",
+		"org.osbuild.sfdisk",
+		"org.osbuild.mkfs.ext4",
+		"org.osbuild.mkfs.fat",
+		"org.osbuild.mkfs.ext4",
+		"org.osbuild.write-device",
+		"org.osbuild.copy",
+	}, stageTypes(imagePipeline.Stages))
+	writeDevice := manifest.FindStage("org.osbuild.write-device", imagePipeline.Stages)
+	assert.Equal(t, osbuild.NewPipelineTreeInputs("tree", "rootfs"), writeDevice.Inputs)
+}
+
+func TestRawImageReadOnlyRootWithoutRootfs(t *testing.T) {
+	raw := newTestRawImage(testdisk.MakeFakeEROFSPartitionTable("/", "/boot"))
+	assert.PanicsWithValue(t, "read-only root filesystem without a rootfs pipeline", func() {
+		raw.Serialize()
+	})
+}
+
+func TestRawImageSerializeVerity(t *testing.T) {
+	repos := []rpmmd.RepoConfig{}
+	m := manifest.New()
+	build := manifest.NewBuild(&m, &runner.Fedora{Version: 41}, repos, nil)
+	build.Checkpoint()
+
+	// the root hash cannot be part of the kernel command line of images
+	// with a bootloader
+	os := manifest.NewOS(build, &platform.PlatformConf{Arch: arch.ARCH_X86_64}, repos)
+	os.PartitionTable = testdisk.MakeFakeVerityPartitionTable("/", "/boot", "/boot/efi")
+	raw := manifest.NewRawImage(build, os)
+	raw.ReadOnlyRootfs = manifest.NewReadOnlyRootfs(build, os)
+
+	// the hash tree is computed after the root filesystem is written
+	imagePipeline := raw.Serialize()
+	assert.Equal(t, []string{
+		"org.osbuild.truncate",
+		"org.osbuild.sfdisk",
+		"org.osbuild.mkfs.ext4",
+		"org.osbuild.mkfs.fat",
+		"org.osbuild.write-device",
+		"org.osbuild.copy",
+		"org.osbuild.dmverity",
+	}, stageTypes(imagePipeline.Stages))
+	verity := manifest.FindStage("org.osbuild.dmverity", imagePipeline.Stages)
+	assert.Equal(t, &osbuild.DMVerityStageOptions{
+		RootHashFile: "disk.roothash",
+	}, verity.Options)
+}
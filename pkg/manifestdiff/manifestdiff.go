@@ -0,0 +1,863 @@
+// Package manifestdiff compares two osbuild manifests semantically: instead
+// of the lines of the json documents it compares pipelines, stages, stage
+// options and the content (packages, containers and ostree commits) that the
+// stages of each pipeline consume.
+package manifestdiff
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Diff is the difference between two osbuild manifests.
+type Diff struct {
+	PipelinesAdded   []string `json:"pipelines_added,omitempty"`
+	PipelinesRemoved []string `json:"pipelines_removed,omitempty"`
+
+	// Pipelines that exist in both manifests and changed, in the order of
+	// the new manifest
+	Pipelines []PipelineDiff `json:"pipelines,omitempty"`
+}
+
+// PipelineDiff is the difference between two pipelines of the same name.
+//
+// The stages of the pipelines are aligned like the lines of a text diff:
+// stages are matched in order, preferring identical stages over stages of the
+// same type. Matched stages that differ are changed. Stages of the same type
+// that can't be matched in order are moved (and changed if they differ), the
+// others are added or removed.
+//
+// Stages are identified by their type. If a type is used more than once in
+// a pipeline, the n-th stage of the type is identified as "type#n" (for
+// n > 1), counted in the new pipeline for added, moved and changed stages
+// and in the old pipeline for removed stages.
+type PipelineDiff struct {
+	Name string `json:"name"`
+
+	StagesAdded   []string    `json:"stages_added,omitempty"`
+	StagesRemoved []string    `json:"stages_removed,omitempty"`
+	StagesMoved   []string    `json:"stages_moved,omitempty"`
+	StagesChanged []StageDiff `json:"stages_changed,omitempty"`
+
+	Packages   *ContentDiff `json:"packages,omitempty"`
+	Containers *ContentDiff `json:"containers,omitempty"`
+	Commits    *ContentDiff `json:"ostree_commits,omitempty"`
+}
+
+// StageDiff is the difference of the options, inputs, devices and mounts of
+// a stage.
+type StageDiff struct {
+	Stage   string         `json:"stage"`
+	Options []OptionChange `json:"options,omitempty"`
+	Inputs  []OptionChange `json:"inputs,omitempty"`
+	Devices []OptionChange `json:"devices,omitempty"`
+	Mounts  []OptionChange `json:"mounts,omitempty"`
+}
+
+func (sd *StageDiff) empty() bool {
+	return len(sd.Options) == 0 && len(sd.Inputs) == 0 && len(sd.Devices) == 0 && len(sd.Mounts) == 0
+}
+
+// OptionChange is a changed value in the options (or inputs, devices or
+// mounts) of a stage. Path is the path of the value in the options, e.g.
+// ".users.root.groups[0]". Old is nil for added values and New for removed
+// values.
+type OptionChange struct {
+	Path string          `json:"path"`
+	Old  json.RawMessage `json:"old,omitempty"`
+	New  json.RawMessage `json:"new,omitempty"`
+}
+
+// ContentDiff is the difference of the content of the same kind (e.g.
+// packages) that a pipeline consumes. Content is identified by a name (the
+// rpm name and architecture, the container name or the ostree ref) and has
+// a version (the rpm version and release, the container digest or the
+// ostree commit).
+type ContentDiff struct {
+	Added   []string        `json:"added,omitempty"`
+	Removed []string        `json:"removed,omitempty"`
+	Changed []ContentChange `json:"changed,omitempty"`
+}
+
+type ContentChange struct {
+	Name string `json:"name"`
+	Old  string `json:"old"`
+	New  string `json:"new"`
+}
+
+func (d *Diff) Empty() bool {
+	return len(d.PipelinesAdded) == 0 && len(d.PipelinesRemoved) == 0 && len(d.Pipelines) == 0
+}
+
+func (pd *PipelineDiff) empty() bool {
+	return len(pd.StagesAdded) == 0 && len(pd.StagesRemoved) == 0 && len(pd.StagesMoved) == 0 &&
+		len(pd.StagesChanged) == 0 && pd.Packages == nil && pd.Containers == nil && pd.Commits == nil
+}
+
+func (cd *ContentDiff) empty() bool {
+	return len(cd.Added) == 0 && len(cd.Removed) == 0 && len(cd.Changed) == 0
+}
+
+// the parts of an osbuild manifest that are compared
+type manifest struct {
+	Pipelines []pipeline                 `json:"pipelines"`
+	Sources   map[string]json.RawMessage `json:"sources"`
+}
+
+type pipeline struct {
+	Name   string  `json:"name"`
+	Stages []stage `json:"stages"`
+}
+
+type stage struct {
+	Type    string          `json:"type"`
+	Options json.RawMessage `json:"options"`
+	Inputs  json.RawMessage `json:"inputs"`
+	Devices json.RawMessage `json:"devices"`
+	Mounts  json.RawMessage `json:"mounts"`
+}
+
+// key returns a string that is equal for stages with the same type,
+// options, inputs, devices and mounts.
+func (s *stage) key() (string, error) {
+	values := make([]interface{}, 0, 4)
+	for _, data := range []json.RawMessage{s.Options, s.Inputs, s.Devices, s.Mounts} {
+		value, err := unmarshalValue(data)
+		if err != nil {
+			return "", err
+		}
+		values = append(values, value)
+	}
+	// maps are marshalled with sorted keys, so the key doesn't depend on the
+	// formatting of the manifest
+	data, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+	return s.Type + " " + string(data), nil
+}
+
+func (s *stage) inputs() (map[string]stageInput, error) {
+	var inputs map[string]stageInput
+	if len(s.Inputs) > 0 {
+		if err := json.Unmarshal(s.Inputs, &inputs); err != nil {
+			return nil, fmt.Errorf("cannot parse inputs: %w", err)
+		}
+	}
+	return inputs, nil
+}
+
+type stageInput struct {
+	Type       string          `json:"type"`
+	Origin     string          `json:"origin"`
+	References json.RawMessage `json:"references"`
+}
+
+// loadManifest loads an osbuild manifest. The manifest can also be wrapped
+// in the "manifest" key of an object, like the test manifests that
+// cmd/gen-manifests writes with metadata.
+func loadManifest(data []byte) (*manifest, error) {
+	var wrapped struct {
+		Manifest json.RawMessage `json:"manifest"`
+	}
+	if err := json.Unmarshal(data, &wrapped); err != nil {
+		return nil, fmt.Errorf("cannot parse manifest: %w", err)
+	}
+	if len(wrapped.Manifest) > 0 {
+		data = wrapped.Manifest
+	}
+
+	var m manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("cannot parse manifest: %w", err)
+	}
+	return &m, nil
+}
+
+// Compare returns the difference between the json encoded osbuild manifests
+// oldManifest and newManifest.
+func Compare(oldManifest, newManifest []byte) (*Diff, error) {
+	oldM, err := loadManifest(oldManifest)
+	if err != nil {
+		return nil, fmt.Errorf("old manifest: %w", err)
+	}
+	newM, err := loadManifest(newManifest)
+	if err != nil {
+		return nil, fmt.Errorf("new manifest: %w", err)
+	}
+	oldContent, err := oldM.content()
+	if err != nil {
+		return nil, fmt.Errorf("old manifest: %w", err)
+	}
+	newContent, err := newM.content()
+	if err != nil {
+		return nil, fmt.Errorf("new manifest: %w", err)
+	}
+
+	diff := &Diff{}
+	oldPipelines := make(map[string]*pipeline)
+	for idx := range oldM.Pipelines {
+		oldPipelines[oldM.Pipelines[idx].Name] = &oldM.Pipelines[idx]
+	}
+	newNames := make(map[string]bool)
+	for idx := range newM.Pipelines {
+		newP := &newM.Pipelines[idx]
+		newNames[newP.Name] = true
+		oldP, exists := oldPipelines[newP.Name]
+		if !exists {
+			diff.PipelinesAdded = append(diff.PipelinesAdded, newP.Name)
+			continue
+		}
+		pd, err := comparePipelines(oldP, newP)
+		if err != nil {
+			return nil, fmt.Errorf("pipeline %s: %w", newP.Name, err)
+		}
+		for kind, items := range newContent[newP.Name] {
+			pd.setContentDiff(kind, compareContent(oldContent[newP.Name][kind], items))
+		}
+		for kind, items := range oldContent[newP.Name] {
+			if _, exists := newContent[newP.Name][kind]; !exists {
+				pd.setContentDiff(kind, compareContent(items, nil))
+			}
+		}
+		if !pd.empty() {
+			diff.Pipelines = append(diff.Pipelines, *pd)
+		}
+	}
+	for _, oldP := range oldM.Pipelines {
+		if !newNames[oldP.Name] {
+			diff.PipelinesRemoved = append(diff.PipelinesRemoved, oldP.Name)
+		}
+	}
+
+	return diff, nil
+}
+
+// stageIDs returns the identifiers of the stages of the pipeline, see
+// PipelineDiff.
+func stageIDs(p *pipeline) []string {
+	ids := make([]string, len(p.Stages))
+	count := make(map[string]int)
+	for idx, s := range p.Stages {
+		count[s.Type]++
+		if n := count[s.Type]; n > 1 {
+			ids[idx] = fmt.Sprintf("%s#%d", s.Type, n)
+		} else {
+			ids[idx] = s.Type
+		}
+	}
+	return ids
+}
+
+func stageKeys(p *pipeline) ([]string, error) {
+	keys := make([]string, len(p.Stages))
+	for idx := range p.Stages {
+		key, err := p.Stages[idx].key()
+		if err != nil {
+			return nil, fmt.Errorf("stage %s: %w", p.Stages[idx].Type, err)
+		}
+		keys[idx] = key
+	}
+	return keys, nil
+}
+
+func comparePipelines(oldP, newP *pipeline) (*PipelineDiff, error) {
+	pd := &PipelineDiff{Name: newP.Name}
+
+	oldKeys, err := stageKeys(oldP)
+	if err != nil {
+		return nil, err
+	}
+	newKeys, err := stageKeys(newP)
+	if err != nil {
+		return nil, err
+	}
+
+	// oldMatch[i] is the index of the new stage that the i-th old stage is
+	// matched with (or -1), newMatch the other way around
+	oldMatch := make([]int, len(oldP.Stages))
+	for i := range oldMatch {
+		oldMatch[i] = -1
+	}
+	newMatch := make([]int, len(newP.Stages))
+	for j := range newMatch {
+		newMatch[j] = -1
+	}
+	for _, pair := range alignStages(oldP, newP, oldKeys, newKeys) {
+		oldMatch[pair[0]] = pair[1]
+		newMatch[pair[1]] = pair[0]
+	}
+
+	// the remaining stages of the same type are paired in order, they can't
+	// be part of the alignment so they have been moved
+	moved := make(map[int]bool)
+	for j := range newP.Stages {
+		if newMatch[j] >= 0 {
+			continue
+		}
+		for i := range oldP.Stages {
+			if oldMatch[i] < 0 && oldP.Stages[i].Type == newP.Stages[j].Type {
+				oldMatch[i] = j
+				newMatch[j] = i
+				moved[j] = true
+				break
+			}
+		}
+	}
+
+	oldIDs := stageIDs(oldP)
+	newIDs := stageIDs(newP)
+	for i, id := range oldIDs {
+		if oldMatch[i] < 0 {
+			pd.StagesRemoved = append(pd.StagesRemoved, id)
+		}
+	}
+	for j, id := range newIDs {
+		i := newMatch[j]
+		if i < 0 {
+			pd.StagesAdded = append(pd.StagesAdded, id)
+			continue
+		}
+		if moved[j] {
+			pd.StagesMoved = append(pd.StagesMoved, id)
+		}
+		if oldKeys[i] == newKeys[j] {
+			continue
+		}
+		sd, err := compareStages(&oldP.Stages[i], &newP.Stages[j])
+		if err != nil {
+			return nil, fmt.Errorf("stage %s: %w", id, err)
+		}
+		sd.Stage = id
+		if !sd.empty() {
+			pd.StagesChanged = append(pd.StagesChanged, *sd)
+		}
+	}
+
+	return pd, nil
+}
+
+// alignStages returns the index pairs of the old and new stages that are
+// matched in order. Only stages of the same type are matched and identical
+// stages are preferred: the alignment maximizes its score, where identical
+// stages score 2 and other stages of the same type 1.
+func alignStages(oldP, newP *pipeline, oldKeys, newKeys []string) [][2]int {
+	score := func(i, j int) int {
+		switch {
+		case oldKeys[i] == newKeys[j]:
+			return 2
+		case oldP.Stages[i].Type == newP.Stages[j].Type:
+			return 1
+		default:
+			return 0
+		}
+	}
+
+	// best[i][j] is the score of the best alignment of the old stages i: and
+	// the new stages j:
+	n, m := len(oldP.Stages), len(newP.Stages)
+	best := make([][]int, n+1)
+	for i := range best {
+		best[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			best[i][j] = max(best[i+1][j], best[i][j+1])
+			if s := score(i, j); s > 0 {
+				best[i][j] = max(best[i][j], best[i+1][j+1]+s)
+			}
+		}
+	}
+
+	var pairs [][2]int
+	for i, j := 0, 0; i < n && j < m; {
+		s := score(i, j)
+		switch {
+		case s > 0 && best[i][j] == best[i+1][j+1]+s:
+			pairs = append(pairs, [2]int{i, j})
+			i++
+			j++
+		case best[i+1][j] >= best[i][j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+	return pairs
+}
+
+// compareStages returns the changes of the options, inputs, devices and
+// mounts of two stages.
+func compareStages(oldS, newS *stage) (*StageDiff, error) {
+	sd := &StageDiff{}
+	for _, part := range []struct {
+		name     string
+		old, new json.RawMessage
+		changes  *[]OptionChange
+	}{
+		{"options", oldS.Options, newS.Options, &sd.Options},
+		{"inputs", oldS.Inputs, newS.Inputs, &sd.Inputs},
+		{"devices", oldS.Devices, newS.Devices, &sd.Devices},
+		{"mounts", oldS.Mounts, newS.Mounts, &sd.Mounts},
+	} {
+		changes, err := compareJSON(part.old, part.new)
+		if err != nil {
+			return nil, fmt.Errorf("cannot compare %s: %w", part.name, err)
+		}
+		*part.changes = changes
+	}
+	return sd, nil
+}
+
+func unmarshalValue(data json.RawMessage) (interface{}, error) {
+	var value interface{}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &value); err != nil {
+			return nil, err
+		}
+	}
+	return value, nil
+}
+
+// compareJSON returns the changed values of two json documents. Objects and
+// arrays are compared recursively.
+func compareJSON(oldData, newData json.RawMessage) ([]OptionChange, error) {
+	oldValue, err := unmarshalValue(oldData)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse old value: %w", err)
+	}
+	newValue, err := unmarshalValue(newData)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse new value: %w", err)
+	}
+	var changes []OptionChange
+	compareValues("", oldValue, newValue, &changes)
+	return changes, nil
+}
+
+func compareValues(valuePath string, oldValue, newValue interface{}, changes *[]OptionChange) {
+	if reflect.DeepEqual(oldValue, newValue) {
+		return
+	}
+
+	switch oldV := oldValue.(type) {
+	case map[string]interface{}:
+		if newV, ok := newValue.(map[string]interface{}); ok {
+			keys := make(map[string]bool)
+			for key := range oldV {
+				keys[key] = true
+			}
+			for key := range newV {
+				keys[key] = true
+			}
+			sortedKeys := make([]string, 0, len(keys))
+			for key := range keys {
+				sortedKeys = append(sortedKeys, key)
+			}
+			sort.Strings(sortedKeys)
+			for _, key := range sortedKeys {
+				compareValues(valuePath+"."+key, oldV[key], newV[key], changes)
+			}
+			return
+		}
+	case []interface{}:
+		if newV, ok := newValue.([]interface{}); ok {
+			for idx := 0; idx < max(len(oldV), len(newV)); idx++ {
+				var oldItem, newItem interface{}
+				if idx < len(oldV) {
+					oldItem = oldV[idx]
+				}
+				if idx < len(newV) {
+					newItem = newV[idx]
+				}
+				compareValues(fmt.Sprintf("%s[%d]", valuePath, idx), oldItem, newItem, changes)
+			}
+			return
+		}
+	}
+
+	if valuePath == "" {
+		valuePath = "."
+	}
+	*changes = append(*changes, OptionChange{
+		Path: valuePath,
+		Old:  marshalValue(oldValue),
+		New:  marshalValue(newValue),
+	})
+}
+
+func marshalValue(value interface{}) json.RawMessage {
+	if value == nil {
+		return nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+// The kinds of content of a pipeline
+const (
+	contentPackages   = "packages"
+	contentContainers = "containers"
+	contentCommits    = "ostree_commits"
+)
+
+func (pd *PipelineDiff) setContentDiff(kind string, cd *ContentDiff) {
+	if cd.empty() {
+		return
+	}
+	switch kind {
+	case contentPackages:
+		pd.Packages = cd
+	case contentContainers:
+		pd.Containers = cd
+	case contentCommits:
+		pd.Commits = cd
+	}
+}
+
+// pipelineContent maps the names of the content of each kind that a
+// pipeline consumes to its version, see ContentDiff.
+type pipelineContent map[string]map[string]map[string]string
+
+func (c pipelineContent) add(pipeline, kind, name, version string) {
+	if c[pipeline] == nil {
+		c[pipeline] = make(map[string]map[string]string)
+	}
+	if c[pipeline][kind] == nil {
+		c[pipeline][kind] = make(map[string]string)
+	}
+	// e.g. the same rpm from different repositories
+	if old, exists := c[pipeline][kind][name]; exists && old != version {
+		versions := append(strings.Split(old, ", "), version)
+		sort.Strings(versions)
+		version = strings.Join(versions, ", ")
+	}
+	c[pipeline][kind][name] = version
+}
+
+// content returns the content of the pipelines of the manifest. It is
+// collected from the stage inputs that reference sources: rpms are the files
+// consumed by org.osbuild.rpm stages, containers and ostree commits are
+// taken from the inputs of their types.
+func (m *manifest) content() (pipelineContent, error) {
+	src, err := m.sourceItems()
+	if err != nil {
+		return nil, err
+	}
+
+	c := make(pipelineContent)
+	for _, p := range m.Pipelines {
+		for _, s := range p.Stages {
+			inputs, err := s.inputs()
+			if err != nil {
+				return nil, fmt.Errorf("pipeline %s, stage %s: %w", p.Name, s.Type, err)
+			}
+			for _, input := range inputs {
+				if input.Origin != "org.osbuild.source" {
+					continue
+				}
+				refs, err := parseReferences(input.References)
+				if err != nil {
+					return nil, fmt.Errorf("pipeline %s, stage %s: %w", p.Name, s.Type, err)
+				}
+				for _, ref := range refs {
+					switch {
+					case input.Type == "org.osbuild.files" && s.Type == "org.osbuild.rpm":
+						name, version := rpmNameVersion(src.files[ref.id], ref.id)
+						c.add(p.Name, contentPackages, name, version)
+					case input.Type == "org.osbuild.containers":
+						name := ref.options["name"]
+						if name == "" {
+							name = src.containers[ref.id].name
+						}
+						version := src.containers[ref.id].digest
+						if version == "" {
+							version = ref.id
+						}
+						c.add(p.Name, contentContainers, name, version)
+					case input.Type == "org.osbuild.ostree":
+						name := ref.options["ref"]
+						if name == "" {
+							name = src.commits[ref.id]
+						}
+						c.add(p.Name, contentCommits, name, ref.id)
+					}
+				}
+			}
+		}
+	}
+	return c, nil
+}
+
+type reference struct {
+	id      string
+	options map[string]string
+}
+
+// parseReferences parses the references of an input, which can be a list of
+// ids, a list of objects with an id and options or a map of ids to options.
+// Only string options are returned.
+func parseReferences(data json.RawMessage) ([]reference, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	var refs []reference
+	var ids []string
+	if err := json.Unmarshal(data, &ids); err == nil {
+		for _, id := range ids {
+			refs = append(refs, reference{id: id})
+		}
+		return refs, nil
+	}
+
+	var list []struct {
+		ID      string                 `json:"id"`
+		Options map[string]interface{} `json:"options"`
+	}
+	if err := json.Unmarshal(data, &list); err == nil {
+		for _, item := range list {
+			refs = append(refs, reference{id: item.ID, options: stringOptions(item.Options)})
+		}
+		return refs, nil
+	}
+
+	var refMap map[string]map[string]interface{}
+	if err := json.Unmarshal(data, &refMap); err != nil {
+		return nil, fmt.Errorf("cannot parse input references: %w", err)
+	}
+	for id, options := range refMap {
+		refs = append(refs, reference{id: id, options: stringOptions(options)})
+	}
+	sort.Slice(refs, func(i, j int) bool { return refs[i].id < refs[j].id })
+	return refs, nil
+}
+
+func stringOptions(options map[string]interface{}) map[string]string {
+	strOptions := make(map[string]string)
+	for key, value := range options {
+		if str, ok := value.(string); ok {
+			strOptions[key] = str
+		}
+	}
+	return strOptions
+}
+
+// rpmNameVersion returns the name and architecture and the version and
+// release of an rpm from its filename (name-version-release.arch.rpm). Files
+// that aren't named like rpms are identified by their filename and their
+// checksum is the version.
+func rpmNameVersion(location, checksum string) (string, string) {
+	filename := path.Base(location)
+	if location == "" {
+		filename = checksum
+	}
+	nevra, isRPM := strings.CutSuffix(filename, ".rpm")
+	nvr, arch, hasArch := cutLast(nevra, ".")
+	nv, release, hasRelease := cutLast(nvr, "-")
+	name, version, hasVersion := cutLast(nv, "-")
+	if !isRPM || !hasArch || !hasRelease || !hasVersion {
+		return filename, checksum
+	}
+	return name + "." + arch, version + "-" + release
+}
+
+func cutLast(s, sep string) (string, string, bool) {
+	idx := strings.LastIndex(s, sep)
+	if idx < 0 {
+		return s, "", false
+	}
+	return s[:idx], s[idx+len(sep):], true
+}
+
+type containerItem struct {
+	name   string
+	digest string
+}
+
+// sourceItems are the items of the sources of a manifest
+type sourceItems struct {
+	// locations (urls or paths) of files
+	files map[string]string
+	// containers
+	containers map[string]containerItem
+	// remote urls of ostree commits
+	commits map[string]string
+}
+
+func (m *manifest) sourceItems() (*sourceItems, error) {
+	src := &sourceItems{
+		files:      make(map[string]string),
+		containers: make(map[string]containerItem),
+		commits:    make(map[string]string),
+	}
+
+	for name, data := range m.Sources {
+		var source struct {
+			Items map[string]json.RawMessage `json:"items"`
+		}
+		if err := json.Unmarshal(data, &source); err != nil {
+			return nil, fmt.Errorf("cannot parse source %s: %w", name, err)
+		}
+
+		for id, itemData := range source.Items {
+			var item struct {
+				URL   string `json:"url"`
+				Path  string `json:"path"`
+				Image struct {
+					Name   string `json:"name"`
+					Digest string `json:"digest"`
+				} `json:"image"`
+				Remote struct {
+					URL string `json:"url"`
+				} `json:"remote"`
+			}
+			// curl source items can be plain urls
+			var url string
+			if err := json.Unmarshal(itemData, &url); err == nil {
+				item.URL = url
+			} else if err := json.Unmarshal(itemData, &item); err != nil {
+				return nil, fmt.Errorf("cannot parse item %s of source %s: %w", id, name, err)
+			}
+
+			switch name {
+			case "org.osbuild.curl":
+				src.files[id] = item.URL
+			case "org.osbuild.librepo":
+				src.files[id] = item.Path
+			case "org.osbuild.skopeo", "org.osbuild.skopeo-index":
+				src.containers[id] = containerItem{name: item.Image.Name, digest: item.Image.Digest}
+			case "org.osbuild.ostree":
+				src.commits[id] = item.Remote.URL
+			}
+		}
+	}
+	return src, nil
+}
+
+func compareContent(oldContent, newContent map[string]string) *ContentDiff {
+	cd := &ContentDiff{}
+	for name, newVersion := range newContent {
+		oldVersion, exists := oldContent[name]
+		switch {
+		case !exists:
+			cd.Added = append(cd.Added, contentString(name, newVersion))
+		case oldVersion != newVersion:
+			cd.Changed = append(cd.Changed, ContentChange{Name: name, Old: oldVersion, New: newVersion})
+		}
+	}
+	for name, oldVersion := range oldContent {
+		if _, exists := newContent[name]; !exists {
+			cd.Removed = append(cd.Removed, contentString(name, oldVersion))
+		}
+	}
+	sort.Strings(cd.Added)
+	sort.Strings(cd.Removed)
+	sort.Slice(cd.Changed, func(i, j int) bool { return cd.Changed[i].Name < cd.Changed[j].Name })
+	return cd
+}
+
+func contentString(name, version string) string {
+	return fmt.Sprintf("%s %s", name, version)
+}
+
+// maximum length of an option value in the text output
+const maxTextValueLength = 80
+
+// WriteText writes the diff in a human readable form to w.
+func (d *Diff) WriteText(w io.Writer) error {
+	var lines []string
+	add := func(indent int, format string, a ...interface{}) {
+		lines = append(lines, strings.Repeat("  ", indent)+fmt.Sprintf(format, a...))
+	}
+
+	for _, name := range d.PipelinesAdded {
+		add(0, "pipeline added: %s", name)
+	}
+	for _, name := range d.PipelinesRemoved {
+		add(0, "pipeline removed: %s", name)
+	}
+	for _, pd := range d.Pipelines {
+		add(0, "pipeline %s:", pd.Name)
+		for _, id := range pd.StagesAdded {
+			add(1, "stage added: %s", id)
+		}
+		for _, id := range pd.StagesRemoved {
+			add(1, "stage removed: %s", id)
+		}
+		for _, id := range pd.StagesMoved {
+			add(1, "stage moved: %s", id)
+		}
+		for _, sd := range pd.StagesChanged {
+			add(1, "stage changed: %s", sd.Stage)
+			// option paths are written as they are, the paths of the
+			// other parts of the stage are prefixed with the part
+			for _, part := range []struct {
+				prefix  string
+				changes []OptionChange
+			}{
+				{"", sd.Options},
+				{"inputs", sd.Inputs},
+				{"devices", sd.Devices},
+				{"mounts", sd.Mounts},
+			} {
+				for _, change := range part.changes {
+					changePath := part.prefix + change.Path
+					switch {
+					case change.Old == nil:
+						add(2, "%s: added %s", changePath, textValue(change.New))
+					case change.New == nil:
+						add(2, "%s: removed %s", changePath, textValue(change.Old))
+					default:
+						add(2, "%s: %s -> %s", changePath, textValue(change.Old), textValue(change.New))
+					}
+				}
+			}
+		}
+		for _, c := range []struct {
+			title string
+			diff  *ContentDiff
+		}{
+			{"packages", pd.Packages},
+			{"containers", pd.Containers},
+			{"ostree commits", pd.Commits},
+		} {
+			if c.diff == nil {
+				continue
+			}
+			add(1, "%s:", c.title)
+			for _, item := range c.diff.Added {
+				add(2, "+ %s", item)
+			}
+			for _, item := range c.diff.Removed {
+				add(2, "- %s", item)
+			}
+			for _, change := range c.diff.Changed {
+				add(2, "~ %s %s -> %s", change.Name, change.Old, change.New)
+			}
+		}
+	}
+
+	for _, line := range lines {
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func textValue(value json.RawMessage) string {
+	text := []rune(string(value))
+	if len(text) > maxTextValueLength {
+		return string(text[:maxTextValueLength-3]) + "..."
+	}
+	return string(text)
+}
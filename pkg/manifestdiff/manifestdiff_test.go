@@ -0,0 +1,314 @@
+package manifestdiff_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/pkg/manifestdiff"
+)
+
+const oldManifest = `{
+  "version": "2",
+  "pipelines": [
+    {
+      "name": "build",
+      "stages": [
+        {
+          "type": "org.osbuild.rpm",
+          "inputs": {
+            "packages": {
+              "type": "org.osbuild.files",
+              "origin": "org.osbuild.source",
+              "references": ["sha256:bash1", "sha256:glibc1"]
+            }
+          }
+        },
+        {"type": "org.osbuild.selinux", "options": {"file_contexts": "etc/selinux/targeted/contexts/files/file_contexts"}}
+      ]
+    },
+    {
+      "name": "os",
+      "stages": [
+        {
+          "type": "org.osbuild.rpm",
+          "inputs": {
+            "packages": {
+              "type": "org.osbuild.files",
+              "origin": "org.osbuild.source",
+              "references": [{"id": "sha256:bash1"}, {"id": "sha256:vim1"}]
+            }
+          }
+        },
+        {"type": "org.osbuild.locale", "options": {"language": "en_US.UTF-8"}},
+        {"type": "org.osbuild.hostname", "options": {"hostname": "old"}},
+        {"type": "org.osbuild.mkdir", "options": {"paths": [{"path": "/a"}]}},
+        {"type": "org.osbuild.mkdir", "options": {"paths": [{"path": "/b"}]}},
+        {
+          "type": "org.osbuild.skopeo",
+          "inputs": {
+            "images": {
+              "type": "org.osbuild.containers",
+              "origin": "org.osbuild.source",
+              "references": {"sha256:image1": {"name": "registry.example.com/app:latest"}}
+            }
+          }
+        }
+      ]
+    },
+    {"name": "qcow2", "stages": [{"type": "org.osbuild.qemu", "options": {"format": {"type": "qcow2"}}}]}
+  ],
+  "sources": {
+    "org.osbuild.curl": {
+      "items": {
+        "sha256:bash1": {"url": "https://example.com/Packages/bash-5.2.15-5.fc39.x86_64.rpm"},
+        "sha256:glibc1": "https://example.com/Packages/glibc-2.38-7.fc39.x86_64.rpm",
+        "sha256:vim1": {"url": "https://example.com/Packages/vim-minimal-9.0.2048-1.fc39.x86_64.rpm"}
+      }
+    },
+    "org.osbuild.skopeo": {
+      "items": {
+        "sha256:image1": {"image": {"name": "registry.example.com/app", "digest": "sha256:digest1"}}
+      }
+    }
+  }
+}`
+
+const newManifest = `{
+  "version": "2",
+  "pipelines": [
+    {
+      "name": "build",
+      "stages": [
+        {
+          "type": "org.osbuild.rpm",
+          "inputs": {
+            "packages": {
+              "type": "org.osbuild.files",
+              "origin": "org.osbuild.source",
+              "references": ["sha256:bash1", "sha256:glibc1"]
+            }
+          }
+        },
+        {"type": "org.osbuild.selinux", "options": {"file_contexts": "etc/selinux/targeted/contexts/files/file_contexts"}}
+      ]
+    },
+    {
+      "name": "os",
+      "stages": [
+        {
+          "type": "org.osbuild.rpm",
+          "inputs": {
+            "packages": {
+              "type": "org.osbuild.files",
+              "origin": "org.osbuild.source",
+              "references": [{"id": "sha256:bash2"}, {"id": "sha256:nano1"}]
+            }
+          }
+        },
+        {"type": "org.osbuild.hostname", "options": {"hostname": "new"}},
+        {"type": "org.osbuild.locale", "options": {"language": "en_US.UTF-8"}},
+        {"type": "org.osbuild.mkdir", "options": {"paths": [{"path": "/a"}, {"path": "/c", "mode": 448}]}},
+        {"type": "org.osbuild.timezone", "options": {"zone": "UTC"}},
+        {
+          "type": "org.osbuild.ostree.pull",
+          "inputs": {
+            "commits": {
+              "type": "org.osbuild.ostree",
+              "origin": "org.osbuild.source",
+              "references": {"commit1": {"ref": "fedora/x86_64/iot"}}
+            }
+          }
+        }
+      ]
+    },
+    {"name": "image", "stages": [{"type": "org.osbuild.truncate", "options": {"filename": "disk.img", "size": "10G"}}]}
+  ],
+  "sources": {
+    "org.osbuild.librepo": {
+      "items": {
+        "sha256:bash2": {"path": "Packages/bash-5.2.26-1.fc39.x86_64.rpm", "mirror": "mirror1"},
+        "sha256:glibc1": {"path": "Packages/glibc-2.38-7.fc39.x86_64.rpm", "mirror": "mirror1"},
+        "sha256:nano1": {"path": "Packages/nano-7.2-4.fc39.x86_64.rpm", "mirror": "mirror1"}
+      }
+    },
+    "org.osbuild.curl": {
+      "items": {
+        "sha256:bash1": {"url": "https://example.com/Packages/bash-5.2.15-5.fc39.x86_64.rpm"}
+      }
+    },
+    "org.osbuild.ostree": {
+      "items": {
+        "commit1": {"remote": {"url": "https://ostree.example.com/repo"}}
+      }
+    }
+  }
+}`
+
+func TestCompare(t *testing.T) {
+	diff, err := manifestdiff.Compare([]byte(oldManifest), []byte(newManifest))
+	require.NoError(t, err)
+
+	expected := &manifestdiff.Diff{
+		PipelinesAdded:   []string{"image"},
+		PipelinesRemoved: []string{"qcow2"},
+		Pipelines: []manifestdiff.PipelineDiff{
+			{
+				Name:          "os",
+				StagesAdded:   []string{"org.osbuild.timezone", "org.osbuild.ostree.pull"},
+				StagesRemoved: []string{"org.osbuild.mkdir#2", "org.osbuild.skopeo"},
+				StagesMoved:   []string{"org.osbuild.hostname"},
+				StagesChanged: []manifestdiff.StageDiff{
+					{
+						Stage: "org.osbuild.rpm",
+						Inputs: []manifestdiff.OptionChange{
+							{Path: ".packages.references[0].id", Old: json.RawMessage(`"sha256:bash1"`), New: json.RawMessage(`"sha256:bash2"`)},
+							{Path: ".packages.references[1].id", Old: json.RawMessage(`"sha256:vim1"`), New: json.RawMessage(`"sha256:nano1"`)},
+						},
+					},
+					{
+						Stage: "org.osbuild.hostname",
+						Options: []manifestdiff.OptionChange{
+							{Path: ".hostname", Old: json.RawMessage(`"old"`), New: json.RawMessage(`"new"`)},
+						},
+					},
+					{
+						Stage: "org.osbuild.mkdir",
+						Options: []manifestdiff.OptionChange{
+							{Path: ".paths[1]", New: json.RawMessage(`{"mode":448,"path":"/c"}`)},
+						},
+					},
+				},
+				Packages: &manifestdiff.ContentDiff{
+					Added:   []string{"nano.x86_64 7.2-4.fc39"},
+					Removed: []string{"vim-minimal.x86_64 9.0.2048-1.fc39"},
+					Changed: []manifestdiff.ContentChange{
+						{Name: "bash.x86_64", Old: "5.2.15-5.fc39", New: "5.2.26-1.fc39"},
+					},
+				},
+				Containers: &manifestdiff.ContentDiff{
+					Removed: []string{"registry.example.com/app:latest sha256:digest1"},
+				},
+				Commits: &manifestdiff.ContentDiff{
+					Added: []string{"fedora/x86_64/iot commit1"},
+				},
+			},
+		},
+	}
+	assert.Equal(t, expected, diff)
+	assert.False(t, diff.Empty())
+}
+
+func TestCompareStageAlignment(t *testing.T) {
+	oldStages := `{"pipelines": [{"name": "image", "stages": [
+	  {"type": "org.osbuild.mkdir", "options": {"paths": [{"path": "/a"}]}},
+	  {"type": "org.osbuild.mkdir", "options": {"paths": [{"path": "/b"}]}},
+	  {"type": "org.osbuild.mkfs.ext4", "options": {"uuid": "1"}, "devices": {"device": {"type": "org.osbuild.loopback", "options": {"filename": "disk.img", "start": 2048}}}},
+	  {"type": "org.osbuild.copy", "mounts": [{"name": "root", "type": "org.osbuild.ext4", "source": "device", "target": "/"}]}
+	]}]}`
+	newStages := `{"pipelines": [{"name": "image", "stages": [
+	  {"type": "org.osbuild.mkdir", "options": {"paths": [{"path": "/b"}]}},
+	  {"type": "org.osbuild.mkfs.ext4", "options": {"uuid": "1"}, "devices": {"device": {"type": "org.osbuild.loopback", "options": {"filename": "disk.img", "start": 4096}}}},
+	  {"type": "org.osbuild.copy", "mounts": [{"name": "root", "type": "org.osbuild.xfs", "source": "device", "target": "/"}]}
+	]}]}`
+
+	diff, err := manifestdiff.Compare([]byte(oldStages), []byte(newStages))
+	require.NoError(t, err)
+
+	// the identical mkdir stages are matched, the first mkdir stage is
+	// removed instead of the second one being changed
+	expected := &manifestdiff.Diff{
+		Pipelines: []manifestdiff.PipelineDiff{
+			{
+				Name:          "image",
+				StagesRemoved: []string{"org.osbuild.mkdir"},
+				StagesChanged: []manifestdiff.StageDiff{
+					{
+						Stage: "org.osbuild.mkfs.ext4",
+						Devices: []manifestdiff.OptionChange{
+							{Path: ".device.options.start", Old: json.RawMessage(`2048`), New: json.RawMessage(`4096`)},
+						},
+					},
+					{
+						Stage: "org.osbuild.copy",
+						Mounts: []manifestdiff.OptionChange{
+							{Path: "[0].type", Old: json.RawMessage(`"org.osbuild.ext4"`), New: json.RawMessage(`"org.osbuild.xfs"`)},
+						},
+					},
+				},
+			},
+		},
+	}
+	assert.Equal(t, expected, diff)
+
+	var buf bytes.Buffer
+	require.NoError(t, diff.WriteText(&buf))
+	assert.Equal(t, `pipeline image:
+  stage removed: org.osbuild.mkdir
+  stage changed: org.osbuild.mkfs.ext4
+    devices.device.options.start: 2048 -> 4096
+  stage changed: org.osbuild.copy
+    mounts[0].type: "org.osbuild.ext4" -> "org.osbuild.xfs"
+`, buf.String())
+}
+
+func TestCompareSame(t *testing.T) {
+	diff, err := manifestdiff.Compare([]byte(oldManifest), []byte(oldManifest))
+	require.NoError(t, err)
+	assert.True(t, diff.Empty())
+
+	var buf bytes.Buffer
+	require.NoError(t, diff.WriteText(&buf))
+	assert.Equal(t, "", buf.String())
+}
+
+func TestCompareWrappedManifest(t *testing.T) {
+	wrapped := `{"build-request": {"distro": "fedora-39"}, "manifest": ` + newManifest + `}`
+	diff, err := manifestdiff.Compare([]byte(newManifest), []byte(wrapped))
+	require.NoError(t, err)
+	assert.True(t, diff.Empty())
+}
+
+func TestCompareBadManifest(t *testing.T) {
+	_, err := manifestdiff.Compare([]byte(`[]`), []byte(newManifest))
+	assert.ErrorContains(t, err, "old manifest: cannot parse manifest: ")
+
+	badRefs := `{"pipelines": [{"name": "os", "stages": [{"type": "org.osbuild.rpm", "inputs": {"packages": {"type": "org.osbuild.files", "origin": "org.osbuild.source", "references": 1}}}]}]}`
+	_, err = manifestdiff.Compare([]byte(oldManifest), []byte(badRefs))
+	assert.ErrorContains(t, err, "new manifest: pipeline os, stage org.osbuild.rpm: cannot parse input references: ")
+}
+
+func TestWriteText(t *testing.T) {
+	diff, err := manifestdiff.Compare([]byte(oldManifest), []byte(newManifest))
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, diff.WriteText(&buf))
+	assert.Equal(t, `pipeline added: image
+pipeline removed: qcow2
+pipeline os:
+  stage added: org.osbuild.timezone
+  stage added: org.osbuild.ostree.pull
+  stage removed: org.osbuild.mkdir#2
+  stage removed: org.osbuild.skopeo
+  stage moved: org.osbuild.hostname
+  stage changed: org.osbuild.rpm
+    inputs.packages.references[0].id: "sha256:bash1" -> "sha256:bash2"
+    inputs.packages.references[1].id: "sha256:vim1" -> "sha256:nano1"
+  stage changed: org.osbuild.hostname
+    .hostname: "old" -> "new"
+  stage changed: org.osbuild.mkdir
+    .paths[1]: added {"mode":448,"path":"/c"}
+  packages:
+    + nano.x86_64 7.2-4.fc39
+    - vim-minimal.x86_64 9.0.2048-1.fc39
+    ~ bash.x86_64 5.2.15-5.fc39 -> 5.2.26-1.fc39
+  containers:
+    - registry.example.com/app:latest sha256:digest1
+  ostree commits:
+    + fedora/x86_64/iot commit1
+`, buf.String())
+}
@@ -0,0 +1,302 @@
+package manifestgen
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+
+	"github.com/osbuild/images/pkg/arch"
+	"github.com/osbuild/images/pkg/container"
+	"github.com/osbuild/images/pkg/distro"
+	"github.com/osbuild/images/pkg/dnfjson"
+	"github.com/osbuild/images/pkg/ostree"
+	"github.com/osbuild/images/pkg/rpmmd"
+	"github.com/osbuild/images/pkg/sbom"
+)
+
+const lockfileVersion = "1"
+
+// Lockfile contains the resolved content of a manifest: the depsolved
+// packages, the container digests and the ostree commit checksums of each
+// pipeline. Generating a manifest from a lockfile (see Options.Lockfile)
+// gives the same manifest as the one the lockfile was written for, without
+// contacting any repository, registry or ostree remote.
+type Lockfile struct {
+	Version string `json:"version"`
+
+	// The image the lockfile was written for
+	Distro    string `json:"distro"`
+	Arch      string `json:"arch"`
+	ImageType string `json:"image_type"`
+
+	// The rng seed of the manifest, e.g. for the partition uuids
+	Seed int64 `json:"seed"`
+
+	Pipelines map[string]*LockfilePipeline `json:"pipelines"`
+}
+
+// LockfilePipeline is the resolved content of a pipeline.
+type LockfilePipeline struct {
+	// The package sets that were depsolved, to check that the lockfile
+	// matches the manifest it is used for
+	PackageSets []LockedPackageSet `json:"package_sets,omitempty"`
+
+	// Depsolve is nil for pipelines that don't install packages
+	Depsolve   *LockedDepsolveResult `json:"depsolve,omitempty"`
+	Containers []LockedContainer     `json:"containers,omitempty"`
+	Commits    []LockedCommit        `json:"ostree_commits,omitempty"`
+}
+
+// LockedPackageSet is an rpmmd.PackageSet, the repositories are identified
+// by their hashes (see rpmmd.RepoConfig.Hash).
+type LockedPackageSet struct {
+	Include         []string `json:"include,omitempty"`
+	Exclude         []string `json:"exclude,omitempty"`
+	EnabledModules  []string `json:"enabled_modules,omitempty"`
+	Repositories    []string `json:"repositories,omitempty"`
+	InstallWeakDeps bool     `json:"install_weak_deps,omitempty"`
+}
+
+func newLockedPackageSet(ps rpmmd.PackageSet) LockedPackageSet {
+	// empty lists are omitted in the json, they are nil after loading
+	nilIfEmpty := func(l []string) []string {
+		if len(l) == 0 {
+			return nil
+		}
+		return l
+	}
+	lps := LockedPackageSet{
+		Include:         nilIfEmpty(ps.Include),
+		Exclude:         nilIfEmpty(ps.Exclude),
+		EnabledModules:  nilIfEmpty(ps.EnabledModules),
+		InstallWeakDeps: ps.InstallWeakDeps,
+	}
+	for idx := range ps.Repositories {
+		lps.Repositories = append(lps.Repositories, ps.Repositories[idx].Hash())
+	}
+	return lps
+}
+
+// LockedDepsolveResult is a dnfjson.DepsolveResult
+type LockedDepsolveResult struct {
+	Packages []rpmmd.PackageSpec `json:"packages"`
+	Modules  []rpmmd.ModuleSpec  `json:"modules,omitempty"`
+	Repos    []rpmmd.RepoConfig  `json:"repos"`
+	SBOM     *sbom.Document      `json:"sbom,omitempty"`
+	Solver   string              `json:"solver,omitempty"`
+}
+
+// LockedContainer is a container.Spec
+type LockedContainer struct {
+	Source       string `json:"source"`
+	Digest       string `json:"digest"`
+	TLSVerify    *bool  `json:"tls_verify,omitempty"`
+	ImageID      string `json:"image_id"`
+	LocalName    string `json:"local_name"`
+	ListDigest   string `json:"list_digest,omitempty"`
+	LocalStorage bool   `json:"local_storage,omitempty"`
+	Arch         string `json:"arch,omitempty"`
+}
+
+// LockedCommit is an ostree.CommitSpec
+type LockedCommit struct {
+	Ref        string `json:"ref,omitempty"`
+	URL        string `json:"url,omitempty"`
+	ContentURL string `json:"content_url,omitempty"`
+	Secrets    string `json:"secrets,omitempty"`
+	Checksum   string `json:"checksum"`
+}
+
+// NewLockfile returns the lockfile of the resolved content of a manifest
+// for the given distro/imageType/arch combination, that was generated with
+// the rng seed and the package sets that were depsolved.
+func NewLockfile(dist distro.Distro, imgType distro.ImageType, a distro.Arch, seed int64, packageSets map[string][]rpmmd.PackageSet, depsolved map[string]dnfjson.DepsolveResult, containerSpecs map[string][]container.Spec, commitSpecs map[string][]ostree.CommitSpec) *Lockfile {
+	lf := &Lockfile{
+		Version:   lockfileVersion,
+		Distro:    dist.Name(),
+		Arch:      a.Name(),
+		ImageType: imgType.Name(),
+		Seed:      seed,
+		Pipelines: make(map[string]*LockfilePipeline),
+	}
+	pipeline := func(name string) *LockfilePipeline {
+		if lf.Pipelines[name] == nil {
+			lf.Pipelines[name] = &LockfilePipeline{}
+		}
+		return lf.Pipelines[name]
+	}
+
+	for name, chain := range packageSets {
+		p := pipeline(name)
+		for _, ps := range chain {
+			p.PackageSets = append(p.PackageSets, newLockedPackageSet(ps))
+		}
+	}
+	for name, res := range depsolved {
+		pipeline(name).Depsolve = &LockedDepsolveResult{
+			Packages: res.Packages,
+			Modules:  res.Modules,
+			Repos:    res.Repos,
+			SBOM:     res.SBOM,
+			Solver:   res.Solver,
+		}
+	}
+	for name, specs := range containerSpecs {
+		p := pipeline(name)
+		for _, spec := range specs {
+			lc := LockedContainer{
+				Source:       spec.Source,
+				Digest:       spec.Digest,
+				TLSVerify:    spec.TLSVerify,
+				ImageID:      spec.ImageID,
+				LocalName:    spec.LocalName,
+				ListDigest:   spec.ListDigest,
+				LocalStorage: spec.LocalStorage,
+			}
+			if spec.Arch != arch.ARCH_UNSET {
+				lc.Arch = spec.Arch.String()
+			}
+			p.Containers = append(p.Containers, lc)
+		}
+	}
+	for name, specs := range commitSpecs {
+		p := pipeline(name)
+		for _, spec := range specs {
+			p.Commits = append(p.Commits, LockedCommit(spec))
+		}
+	}
+
+	return lf
+}
+
+// LoadLockfile reads a json encoded lockfile.
+func LoadLockfile(r io.Reader) (*Lockfile, error) {
+	var lf Lockfile
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&lf); err != nil {
+		return nil, fmt.Errorf("cannot load lockfile: %w", err)
+	}
+	if lf.Version != lockfileVersion {
+		return nil, fmt.Errorf("unsupported lockfile version %q", lf.Version)
+	}
+	return &lf, nil
+}
+
+// Write writes the lockfile json encoded to w.
+func (lf *Lockfile) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(lf)
+}
+
+// checkImage returns an error if the lockfile was written for another
+// distro/imageType/arch combination.
+func (lf *Lockfile) checkImage(dist distro.Distro, imgType distro.ImageType, a distro.Arch) error {
+	if lf.Distro != dist.Name() || lf.Arch != a.Name() || lf.ImageType != imgType.Name() {
+		return fmt.Errorf("lockfile is for %s/%s/%s, not for %s/%s/%s", lf.Distro, lf.Arch, lf.ImageType, dist.Name(), a.Name(), imgType.Name())
+	}
+	return nil
+}
+
+// sortedNames returns the sorted keys of the map m, for stable error
+// messages
+func sortedNames[T any](m map[string]T) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// depsolved returns the locked depsolve results of the pipelines of the
+// package sets. The package sets must be the ones the lockfile was written
+// for.
+func (lf *Lockfile) depsolved(packageSets map[string][]rpmmd.PackageSet) (map[string]dnfjson.DepsolveResult, error) {
+	depsolved := make(map[string]dnfjson.DepsolveResult, len(packageSets))
+	for _, name := range sortedNames(packageSets) {
+		p := lf.Pipelines[name]
+		if p == nil || p.Depsolve == nil {
+			return nil, fmt.Errorf("lockfile has no packages for pipeline %q", name)
+		}
+		chain := packageSets[name]
+		if len(chain) != len(p.PackageSets) {
+			return nil, fmt.Errorf("lockfile has %d package sets for pipeline %q, expected %d", len(p.PackageSets), name, len(chain))
+		}
+		for idx, ps := range chain {
+			if !reflect.DeepEqual(newLockedPackageSet(ps), p.PackageSets[idx]) {
+				return nil, fmt.Errorf("package set %d of pipeline %q does not match the lockfile", idx, name)
+			}
+		}
+		depsolved[name] = dnfjson.DepsolveResult{
+			Packages: p.Depsolve.Packages,
+			Modules:  p.Depsolve.Modules,
+			Repos:    p.Depsolve.Repos,
+			SBOM:     p.Depsolve.SBOM,
+			Solver:   p.Depsolve.Solver,
+		}
+	}
+	return depsolved, nil
+}
+
+// containers returns the locked containers of the pipelines of the
+// container sources. Only the number of containers is checked, the
+// resolved specs don't have to match their sources (see
+// ContainerResolverFunc).
+func (lf *Lockfile) containers(containerSources map[string][]container.SourceSpec) (map[string][]container.Spec, error) {
+	containerSpecs := make(map[string][]container.Spec, len(containerSources))
+	for _, name := range sortedNames(containerSources) {
+		var locked []LockedContainer
+		if p := lf.Pipelines[name]; p != nil {
+			locked = p.Containers
+		}
+		if len(locked) != len(containerSources[name]) {
+			return nil, fmt.Errorf("lockfile has %d containers for pipeline %q, expected %d", len(locked), name, len(containerSources[name]))
+		}
+		specs := make([]container.Spec, len(locked))
+		for idx, lc := range locked {
+			specs[idx] = container.Spec{
+				Source:       lc.Source,
+				Digest:       lc.Digest,
+				TLSVerify:    lc.TLSVerify,
+				ImageID:      lc.ImageID,
+				LocalName:    lc.LocalName,
+				ListDigest:   lc.ListDigest,
+				LocalStorage: lc.LocalStorage,
+			}
+			if lc.Arch != "" {
+				var err error
+				specs[idx].Arch, err = arch.FromString(lc.Arch)
+				if err != nil {
+					return nil, fmt.Errorf("lockfile container %s of pipeline %q: %w", lc.Source, name, err)
+				}
+			}
+		}
+		containerSpecs[name] = specs
+	}
+	return containerSpecs, nil
+}
+
+// commits returns the locked ostree commits of the pipelines of the commit
+// sources.
+func (lf *Lockfile) commits(commitSources map[string][]ostree.SourceSpec) (map[string][]ostree.CommitSpec, error) {
+	commitSpecs := make(map[string][]ostree.CommitSpec, len(commitSources))
+	for _, name := range sortedNames(commitSources) {
+		var locked []LockedCommit
+		if p := lf.Pipelines[name]; p != nil {
+			locked = p.Commits
+		}
+		if len(locked) != len(commitSources[name]) {
+			return nil, fmt.Errorf("lockfile has %d ostree commits for pipeline %q, expected %d", len(locked), name, len(commitSources[name]))
+		}
+		specs := make([]ostree.CommitSpec, len(locked))
+		for idx, lc := range locked {
+			specs[idx] = ostree.CommitSpec(lc)
+		}
+		commitSpecs[name] = specs
+	}
+	return commitSpecs, nil
+}
@@ -0,0 +1,222 @@
+package manifestgen_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/pkg/arch"
+	"github.com/osbuild/images/pkg/blueprint"
+	"github.com/osbuild/images/pkg/container"
+	"github.com/osbuild/images/pkg/distro"
+	"github.com/osbuild/images/pkg/distrofactory"
+	"github.com/osbuild/images/pkg/dnfjson"
+	"github.com/osbuild/images/pkg/imagefilter"
+	"github.com/osbuild/images/pkg/manifestgen"
+	"github.com/osbuild/images/pkg/ostree"
+	"github.com/osbuild/images/pkg/rpmmd"
+	testrepos "github.com/osbuild/images/test/data/repositories"
+)
+
+func panicDepsolve(cacheDir string, depsolveWarningsOutput io.Writer, packageSets map[string][]rpmmd.PackageSet, d distro.Distro, arch string) (map[string]dnfjson.DepsolveResult, error) {
+	panic("panicDepsolve")
+}
+
+func fakeArchContainerResolver(containerSources map[string][]container.SourceSpec, archName string) (map[string][]container.Spec, error) {
+	specs, err := fakeContainerResolver(containerSources, archName)
+	for _, plSpecs := range specs {
+		for idx := range plSpecs {
+			plSpecs[idx].Arch = arch.ARCH_X86_64
+			plSpecs[idx].LocalName = "localhost/app"
+		}
+	}
+	return specs, err
+}
+
+func filterImage(t *testing.T, imgType string) imagefilter.Result {
+	repos, err := testrepos.New()
+	require.NoError(t, err)
+	filter, err := imagefilter.New(distrofactory.NewDefault(), repos)
+	require.NoError(t, err)
+	res, err := filter.Filter("distro:centos-9", "type:"+imgType, "arch:x86_64")
+	require.NoError(t, err)
+	require.Equal(t, 1, len(res))
+	return res[0]
+}
+
+func TestManifestGeneratorLockfileReplay(t *testing.T) {
+	repos, err := testrepos.New()
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		imgType   string
+		imageOpts *distro.ImageOptions
+		bp        blueprint.Blueprint
+	}{
+		{
+			imgType: "qcow2",
+			bp: blueprint.Blueprint{
+				Containers: []blueprint.Container{
+					{Source: "registry.example.com/org/app"},
+				},
+			},
+		},
+		{
+			imgType: "edge-ami",
+			imageOpts: &distro.ImageOptions{
+				OSTree: &ostree.ImageOptions{
+					URL: "http://example.com/",
+				},
+			},
+		},
+	} {
+		t.Run(tc.imgType, func(t *testing.T) {
+			res := filterImage(t, tc.imgType)
+
+			// the random seed is written to the lockfile
+			var manifest, lockfile bytes.Buffer
+			opts := &manifestgen.Options{
+				Output:            &manifest,
+				LockfileOutput:    &lockfile,
+				Depsolver:         fakeDepsolve,
+				CommitResolver:    fakeCommitResolver,
+				ContainerResolver: fakeArchContainerResolver,
+			}
+			mg, err := manifestgen.New(repos, opts)
+			require.NoError(t, err)
+			err = mg.Generate(&tc.bp, res.Distro, res.ImgType, res.Arch, tc.imageOpts)
+			require.NoError(t, err)
+
+			lf, err := manifestgen.LoadLockfile(bytes.NewReader(lockfile.Bytes()))
+			require.NoError(t, err)
+			assert.Equal(t, "centos-9", lf.Distro)
+			assert.Equal(t, "x86_64", lf.Arch)
+			assert.Equal(t, tc.imgType, lf.ImageType)
+			assert.NotNil(t, lf.Pipelines["build"].Depsolve)
+			assert.NotEmpty(t, lf.Pipelines["build"].PackageSets)
+
+			// nothing is resolved when generating from the lockfile and
+			// the seed of the lockfile gives the same partition uuids
+			var replayedManifest, replayedLockfile bytes.Buffer
+			opts = &manifestgen.Options{
+				Output:            &replayedManifest,
+				LockfileOutput:    &replayedLockfile,
+				Lockfile:          lf,
+				Depsolver:         panicDepsolve,
+				CommitResolver:    panicCommitResolver,
+				ContainerResolver: panicContainerResolver,
+			}
+			mg, err = manifestgen.New(repos, opts)
+			require.NoError(t, err)
+			err = mg.Generate(&tc.bp, res.Distro, res.ImgType, res.Arch, tc.imageOpts)
+			require.NoError(t, err)
+
+			assert.Equal(t, manifest.String(), replayedManifest.String())
+			assert.Equal(t, lockfile.String(), replayedLockfile.String())
+		})
+	}
+}
+
+func TestManifestGeneratorLockfileMismatch(t *testing.T) {
+	repos, err := testrepos.New()
+	require.NoError(t, err)
+	res := filterImage(t, "qcow2")
+
+	generateLockfile := func(bp blueprint.Blueprint) *manifestgen.Lockfile {
+		var lockfile bytes.Buffer
+		opts := &manifestgen.Options{
+			Output:            io.Discard,
+			LockfileOutput:    &lockfile,
+			Depsolver:         fakeDepsolve,
+			ContainerResolver: fakeContainerResolver,
+		}
+		mg, err := manifestgen.New(repos, opts)
+		require.NoError(t, err)
+		err = mg.Generate(&bp, res.Distro, res.ImgType, res.Arch, nil)
+		require.NoError(t, err)
+		lf, err := manifestgen.LoadLockfile(bytes.NewReader(lockfile.Bytes()))
+		require.NoError(t, err)
+		return lf
+	}
+
+	containersBp := blueprint.Blueprint{
+		Containers: []blueprint.Container{
+			{Source: "registry.example.com/org/app"},
+		},
+	}
+
+	qcow2Lockfile := generateLockfile(blueprint.Blueprint{})
+	otherSeed := qcow2Lockfile.Seed + 1
+	noContainersLockfile := generateLockfile(containersBp)
+	noContainersLockfile.Pipelines["os"].Containers = nil
+
+	for _, tc := range []struct {
+		lockfile    *manifestgen.Lockfile
+		customSeed  *int64
+		bp          blueprint.Blueprint
+		expectedErr string
+	}{
+		{
+			lockfile:    qcow2Lockfile,
+			customSeed:  &otherSeed,
+			expectedErr: fmt.Sprintf("custom seed %d does not match the seed %d of the lockfile", otherSeed, qcow2Lockfile.Seed),
+		},
+		{
+			lockfile: qcow2Lockfile,
+			bp: blueprint.Blueprint{
+				Packages: []blueprint.Package{{Name: "tmux"}},
+			},
+			expectedErr: `lockfile has 2 package sets for pipeline "os", expected 3`,
+		},
+		{
+			lockfile: qcow2Lockfile,
+			bp: blueprint.Blueprint{
+				Customizations: &blueprint.Customizations{
+					Firewall: &blueprint.FirewallCustomization{Ports: []string{"22:tcp"}},
+				},
+			},
+			expectedErr: `package set 1 of pipeline "os" does not match the lockfile`,
+		},
+		{
+			lockfile:    &manifestgen.Lockfile{Distro: "centos-9", Arch: "x86_64", ImageType: "ami"},
+			expectedErr: "lockfile is for centos-9/x86_64/ami, not for centos-9/x86_64/qcow2",
+		},
+		{
+			lockfile:    &manifestgen.Lockfile{Distro: "centos-9", Arch: "x86_64", ImageType: "qcow2"},
+			expectedErr: `lockfile has no packages for pipeline "build"`,
+		},
+		{
+			lockfile:    noContainersLockfile,
+			bp:          containersBp,
+			expectedErr: `lockfile has 0 containers for pipeline "os", expected 1`,
+		},
+	} {
+		t.Run(tc.expectedErr, func(t *testing.T) {
+			opts := &manifestgen.Options{
+				Output:            io.Discard,
+				CustomSeed:        tc.customSeed,
+				Lockfile:          tc.lockfile,
+				Depsolver:         panicDepsolve,
+				CommitResolver:    panicCommitResolver,
+				ContainerResolver: panicContainerResolver,
+			}
+			mg, err := manifestgen.New(repos, opts)
+			require.NoError(t, err)
+			err = mg.Generate(&tc.bp, res.Distro, res.ImgType, res.Arch, nil)
+			assert.EqualError(t, err, tc.expectedErr)
+		})
+	}
+}
+
+func TestLoadLockfileBad(t *testing.T) {
+	_, err := manifestgen.LoadLockfile(strings.NewReader(`{"version": "2"}`))
+	assert.EqualError(t, err, `unsupported lockfile version "2"`)
+
+	_, err = manifestgen.LoadLockfile(strings.NewReader(`{"version": "1", "unknown": true}`))
+	assert.EqualError(t, err, `cannot load lockfile: json: unknown field "unknown"`)
+}
@@ -12,6 +12,7 @@ import (
 
 	"github.com/osbuild/images/pkg/blueprint"
 	"github.com/osbuild/images/pkg/container"
+	"github.com/osbuild/images/pkg/customizations/remotefile"
 	"github.com/osbuild/images/pkg/distro"
 	"github.com/osbuild/images/pkg/dnfjson"
 	"github.com/osbuild/images/pkg/manifest"
@@ -24,9 +25,10 @@ import (
 
 const (
 	defaultDepsolverSBOMType = sbom.StandardTypeSpdx
-	defaultSBOMExt           = "spdx.json"
 
 	defaultDepsolveCacheDir = "osbuild-depsolve-dnf"
+
+	defaultDepsolveResultCacheSize = 1024 * 1024 * 1024 // 1 GiB
 )
 
 // Options contains the optional settings for the manifest generation.
@@ -44,6 +46,13 @@ type Options struct {
 	// content can be read
 	SBOMWriter SBOMWriterFunc
 
+	// SBOMType selects the standard of the SBOMs passed to the
+	// SBOMWriter, defaults to SPDX. SBOMs are generated for the
+	// build pipelines (the buildroot) and for the payload
+	// pipelines (the OS), the payload SBOMs reference the
+	// embedded containers.
+	SBOMType sbom.StandardType
+
 	// WarningsOutput will receive any warnings that are part of
 	// the manifest generation. If it is unset any warnings will
 	// generate an error.
@@ -64,13 +73,26 @@ type Options struct {
 
 	// Custom "solver" functions, if unset the defaults will be
 	// used. Only needed for specialized use-cases.
-	Depsolver         DepsolveFunc
-	ContainerResolver ContainerResolverFunc
-	CommitResolver    CommitResolverFunc
+	Depsolver          DepsolveFunc
+	ContainerResolver  ContainerResolverFunc
+	CommitResolver     CommitResolverFunc
+	RemoteFileResolver RemoteFileResolverFunc
 
 	// Use the a bootstrap container to buildroot (useful for e.g.
 	// cross-arch or cross-distro builds)
 	UseBootstrapContainer bool
+
+	// LockfileOutput will receive the lockfile with the resolved
+	// packages, containers and ostree commits of the generated
+	// manifest, see Lockfile.
+	LockfileOutput io.Writer
+
+	// Lockfile makes the generator take the packages, containers
+	// and ostree commits from the lockfile instead of resolving
+	// them, no repositories, registries or ostree remotes are
+	// contacted. The custom "solver" functions are not used then.
+	// The manifest is generated with the rng seed of the lockfile.
+	Lockfile *Lockfile
 }
 
 // Generator can generate an osbuild manifest from a given repository
@@ -82,7 +104,9 @@ type Generator struct {
 	depsolver              DepsolveFunc
 	containerResolver      ContainerResolverFunc
 	commitResolver         CommitResolverFunc
+	remoteFileResolver     RemoteFileResolverFunc
 	sbomWriter             SBOMWriterFunc
+	sbomType               sbom.StandardType
 	warningsOutput         io.Writer
 	depsolveWarningsOutput io.Writer
 
@@ -94,6 +118,9 @@ type Generator struct {
 	overrideRepos []rpmmd.RepoConfig
 
 	useBootstrapContainer bool
+
+	lockfileOutput io.Writer
+	lockfile       *Lockfile
 }
 
 // New will create a new manifest generator
@@ -109,13 +136,17 @@ func New(reporegistry *reporegistry.RepoRegistry, opts *Options) (*Generator, er
 		depsolver:              opts.Depsolver,
 		containerResolver:      opts.ContainerResolver,
 		commitResolver:         opts.CommitResolver,
+		remoteFileResolver:     opts.RemoteFileResolver,
 		rpmDownloader:          opts.RpmDownloader,
 		sbomWriter:             opts.SBOMWriter,
+		sbomType:               opts.SBOMType,
 		warningsOutput:         opts.WarningsOutput,
 		depsolveWarningsOutput: opts.DepsolveWarningsOutput,
 		customSeed:             opts.CustomSeed,
 		overrideRepos:          opts.OverrideRepos,
 		useBootstrapContainer:  opts.UseBootstrapContainer,
+		lockfileOutput:         opts.LockfileOutput,
+		lockfile:               opts.Lockfile,
 	}
 	if mg.out == nil {
 		mg.out = os.Stdout
@@ -129,6 +160,15 @@ func New(reporegistry *reporegistry.RepoRegistry, opts *Options) (*Generator, er
 	if mg.commitResolver == nil {
 		mg.commitResolver = DefaultCommitResolver
 	}
+	if mg.remoteFileResolver == nil {
+		mg.remoteFileResolver = DefaultRemoteFileResolver
+	}
+	if mg.sbomType == sbom.StandardTypeNone {
+		mg.sbomType = defaultDepsolverSBOMType
+	}
+	if _, ok := sbomExtensions[mg.sbomType]; !ok {
+		return nil, fmt.Errorf("unsupported SBOM type: %d", mg.sbomType)
+	}
 
 	return mg, nil
 }
@@ -154,7 +194,18 @@ func (mg *Generator) Generate(bp *blueprint.Blueprint, dist distro.Distro, imgTy
 	// will have to be added to the repos with
 	// <repo_item>.PackageSets set to the "payload" pipeline names
 	// for the given image type, see e.g. distro/rhel/imagetype.go:Manifest()
-	preManifest, warnings, err := imgType.Manifest(bp, *imgOpts, repos, mg.customSeed)
+	// the seed is written to the lockfile, so it has to be known
+	seed := distro.SeedFrom(mg.customSeed)
+	if mg.lockfile != nil {
+		if err := mg.lockfile.checkImage(dist, imgType, a); err != nil {
+			return err
+		}
+		if mg.customSeed != nil && *mg.customSeed != mg.lockfile.Seed {
+			return fmt.Errorf("custom seed %d does not match the seed %d of the lockfile", *mg.customSeed, mg.lockfile.Seed)
+		}
+		seed = mg.lockfile.Seed
+	}
+	preManifest, warnings, err := imgType.Manifest(bp, *imgOpts, repos, &seed)
 	if err != nil {
 		return err
 	}
@@ -166,15 +217,18 @@ func (mg *Generator) Generate(bp *blueprint.Blueprint, dist distro.Distro, imgTy
 			return fmt.Errorf("Warnings during manifest creation:\n%v", warn)
 		}
 	}
-	depsolved, err := mg.depsolver(mg.cacheDir, mg.depsolveWarningsOutput, preManifest.GetPackageSetChains(), dist, a.Name())
-	if err != nil {
-		return err
-	}
-	containerSpecs, err := mg.containerResolver(preManifest.GetContainerSourceSpecs(), a.Name())
-	if err != nil {
-		return err
+	// the package sets of the lockfile are taken before the
+	// manifest is serialized, which adds the depsolved repositories
+	// to the repositories of the pipelines
+	packageSets := preManifest.GetPackageSetChains()
+	var depsolved map[string]dnfjson.DepsolveResult
+	var containerSpecs map[string][]container.Spec
+	var commitSpecs map[string][]ostree.CommitSpec
+	if mg.lockfile != nil {
+		depsolved, containerSpecs, commitSpecs, err = mg.fromLockfile(preManifest)
+	} else {
+		depsolved, containerSpecs, commitSpecs, err = mg.resolve(preManifest, dist, a)
 	}
-	commitSpecs, err := mg.commitResolver(preManifest.GetOSTreeSourceSpecs())
 	if err != nil {
 		return err
 	}
@@ -187,33 +241,125 @@ func (mg *Generator) Generate(bp *blueprint.Blueprint, dist distro.Distro, imgTy
 	}
 	fmt.Fprintf(mg.out, "%s\n", mf)
 
+	if mg.lockfileOutput != nil {
+		lf := NewLockfile(dist, imgType, a, seed, packageSets, depsolved, containerSpecs, commitSpecs)
+		if err := lf.Write(mg.lockfileOutput); err != nil {
+			return err
+		}
+	}
+
 	if mg.sbomWriter != nil {
-		// XXX: this is very similar to
-		// osbuild-composer:jobimpl-osbuild.go, see if code
-		// can be shared
-		for plName, depsolvedPipeline := range depsolved {
-			pipelinePurpose := "unknown"
-			switch {
-			case slices.Contains(imgType.PayloadPipelines(), plName):
-				pipelinePurpose = "image"
-			case slices.Contains(imgType.BuildPipelines(), plName):
-				pipelinePurpose = "buildroot"
-			}
-			// XXX: sync with image-builder-cli:build.go name generation - can we have a shared helper?
-			imageName := fmt.Sprintf("%s-%s-%s", dist.Name(), imgType.Name(), a.Name())
-			sbomDocOutputFilename := fmt.Sprintf("%s.%s-%s.%s", imageName, pipelinePurpose, plName, defaultSBOMExt)
+		if err := mg.writeSBOMs(depsolved, containerSpecs, dist, imgType, a); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// resolve resolves the content of the manifest with the "solver"
+// functions.
+func (mg *Generator) resolve(preManifest *manifest.Manifest, dist distro.Distro, a distro.Arch) (map[string]dnfjson.DepsolveResult, map[string][]container.Spec, map[string][]ostree.CommitSpec, error) {
+	depsolved, err := mg.depsolver(mg.cacheDir, mg.depsolveWarningsOutput, preManifest.GetPackageSetChains(), dist, a.Name())
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	containerSpecs, err := mg.containerResolver(preManifest.GetContainerSourceSpecs(), a.Name())
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	commitSpecs, err := mg.commitResolver(preManifest.GetOSTreeSourceSpecs())
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	// remote files are pinned by their checksums, they are only
+	// fetched to check them before the build
+	if err := mg.remoteFileResolver(preManifest.GetRemoteFileSourceSpecs()); err != nil {
+		return nil, nil, nil, err
+	}
+	return depsolved, containerSpecs, commitSpecs, nil
+}
+
+// fromLockfile takes the content of the manifest from the lockfile.
+// Remote files are not fetched, they are pinned by their checksums
+// anyway.
+func (mg *Generator) fromLockfile(preManifest *manifest.Manifest) (map[string]dnfjson.DepsolveResult, map[string][]container.Spec, map[string][]ostree.CommitSpec, error) {
+	depsolved, err := mg.lockfile.depsolved(preManifest.GetPackageSetChains())
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	containerSpecs, err := mg.lockfile.containers(preManifest.GetContainerSourceSpecs())
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	commitSpecs, err := mg.lockfile.commits(preManifest.GetOSTreeSourceSpecs())
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return depsolved, containerSpecs, commitSpecs, nil
+}
+
+// sbomExtensions are the file extensions of the SBOM documents
+var sbomExtensions = map[sbom.StandardType]string{
+	sbom.StandardTypeSpdx:      "spdx.json",
+	sbom.StandardTypeCycloneDX: "cdx.json",
+}
 
-			var buf bytes.Buffer
-			enc := json.NewEncoder(&buf)
-			if err := enc.Encode(depsolvedPipeline.SBOM.Document); err != nil {
+func (mg *Generator) writeSBOMs(depsolved map[string]dnfjson.DepsolveResult, containerSpecs map[string][]container.Spec, dist distro.Distro, imgType distro.ImageType, a distro.Arch) error {
+	// XXX: this is very similar to
+	// osbuild-composer:jobimpl-osbuild.go, see if code
+	// can be shared
+	for plName, depsolvedPipeline := range depsolved {
+		pipelinePurpose := "unknown"
+		switch {
+		case slices.Contains(imgType.PayloadPipelines(), plName):
+			pipelinePurpose = "image"
+		case slices.Contains(imgType.BuildPipelines(), plName):
+			pipelinePurpose = "buildroot"
+		}
+		// XXX: sync with image-builder-cli:build.go name generation - can we have a shared helper?
+		imageName := fmt.Sprintf("%s-%s-%s", dist.Name(), imgType.Name(), a.Name())
+		sbomDocOutputFilename := fmt.Sprintf("%s.%s-%s.%s", imageName, pipelinePurpose, plName, sbomExtensions[mg.sbomType])
+
+		doc := depsolvedPipeline.SBOM
+		if doc == nil || doc.DocType != mg.sbomType {
+			// the depsolver only generates SPDX documents, all
+			// other types are generated from the depsolved packages
+			if mg.sbomType != sbom.StandardTypeCycloneDX {
+				return fmt.Errorf("depsolver did not return a %s SBOM for pipeline %q", mg.sbomType, plName)
+			}
+			var err error
+			doc, err = sbom.NewCycloneDXDocument(fmt.Sprintf("%s-%s", imageName, plName), dist.Name(), depsolvedPipeline.Packages)
+			if err != nil {
 				return err
 			}
-			if err := mg.sbomWriter(sbomDocOutputFilename, &buf, depsolvedPipeline.SBOM.DocType); err != nil {
+		}
+
+		// containers are only embedded in the payload
+		if pipelinePurpose == "image" {
+			var containers []sbom.Container
+			for _, spec := range containerSpecs[plName] {
+				containers = append(containers, sbom.Container{
+					Source: spec.Source,
+					Digest: spec.Digest,
+				})
+			}
+			// do not modify the depsolve result
+			doc = &sbom.Document{DocType: doc.DocType, Document: doc.Document}
+			if err := doc.AddContainers(containers); err != nil {
 				return err
 			}
 		}
-	}
 
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		if err := enc.Encode(doc.Document); err != nil {
+			return err
+		}
+		if err := mg.sbomWriter(sbomDocOutputFilename, &buf, doc.DocType); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -233,28 +379,87 @@ func xdgCacheHome() (string, error) {
 // It should rarely be necessary to use it directly and will be used
 // by default by manifestgen (unless overriden)
 func DefaultDepsolver(cacheDir string, depsolveWarningsOutput io.Writer, packageSets map[string][]rpmmd.PackageSet, d distro.Distro, arch string) (map[string]dnfjson.DepsolveResult, error) {
-	if cacheDir == "" {
-		xdgCacheHomeDir, err := xdgCacheHome()
+	cacheDir, err := depsolveCacheDir(cacheDir)
+	if err != nil {
+		return nil, err
+	}
+
+	solver := dnfjson.NewSolver(d.ModulePlatformID(), d.Releasever(), arch, d.Name(), cacheDir)
+	if depsolveWarningsOutput != nil {
+		solver.Stderr = depsolveWarningsOutput
+	}
+	// Always generate Spdx SBOMs, this makes the default
+	// depsolve slightly slower but it means we need no
+	// extra argument here to select the SBOM type. Other
+	// SBOM types are generated from the depsolved
+	// packages by the Generator.
+	return depsolve(solver, defaultDepsolverSBOMType, packageSets)
+}
+
+// NewCachingDepsolver returns a depsolver like DefaultDepsolver that reuses
+// depsolve results from the persistent cache in depsolveCacheDir as long
+// as the metadata of the repositories doesn't change (see
+// dnfjson.BaseSolver.SetDepsolveCache()).
+func NewCachingDepsolver(depsolveResultCacheDir string) DepsolveFunc {
+	return func(cacheDir string, depsolveWarningsOutput io.Writer, packageSets map[string][]rpmmd.PackageSet, d distro.Distro, arch string) (map[string]dnfjson.DepsolveResult, error) {
+		cacheDir, err := depsolveCacheDir(cacheDir)
 		if err != nil {
 			return nil, err
 		}
-		cacheDir = filepath.Join(xdgCacheHomeDir, defaultDepsolveCacheDir)
+
+		solver := dnfjson.NewSolver(d.ModulePlatformID(), d.Releasever(), arch, d.Name(), cacheDir)
+		solver.SetDepsolveCache(depsolveResultCacheDir, defaultDepsolveResultCacheSize)
+		if depsolveWarningsOutput != nil {
+			solver.Stderr = depsolveWarningsOutput
+		}
+		return depsolve(solver, defaultDepsolverSBOMType, packageSets)
 	}
+}
 
-	solver := dnfjson.NewSolver(d.ModulePlatformID(), d.Releasever(), arch, d.Name(), cacheDir)
+// NewDepsolver returns a depsolver that uses the dnfjson.Depsolver that is
+// created by newDepsolver for the distro and arch of each manifest. No
+// SBOMs are requested from the depsolver, the Generator can only generate
+// CycloneDX SBOMs then.
+func NewDepsolver(newDepsolver NewDepsolverFunc) DepsolveFunc {
+	return func(cacheDir string, depsolveWarningsOutput io.Writer, packageSets map[string][]rpmmd.PackageSet, d distro.Distro, arch string) (map[string]dnfjson.DepsolveResult, error) {
+		cacheDir, err := depsolveCacheDir(cacheDir)
+		if err != nil {
+			return nil, err
+		}
 
-	if depsolveWarningsOutput != nil {
-		solver.Stderr = depsolveWarningsOutput
+		solver, err := newDepsolver(cacheDir, depsolveWarningsOutput, d, arch)
+		if err != nil {
+			return nil, err
+		}
+		return depsolve(solver, sbom.StandardTypeNone, packageSets)
+	}
+}
+
+// RepodataDepsolver is an experimental depsolver that uses the pure-Go
+// dnfjson.RepodataSolver instead of osbuild-depsolve-dnf, so that
+// manifests can be generated on hosts without python3-dnf. Its results
+// can differ from the ones of dnf.
+var RepodataDepsolver = NewDepsolver(func(cacheDir string, depsolveWarningsOutput io.Writer, d distro.Distro, arch string) (dnfjson.Depsolver, error) {
+	return dnfjson.NewRepodataSolver(d.ModulePlatformID(), d.Releasever(), arch, d.Name(), cacheDir), nil
+})
+
+// depsolveCacheDir returns the cache directory for the repository
+// metadata, defaults to the XDG cache dir
+func depsolveCacheDir(cacheDir string) (string, error) {
+	if cacheDir != "" {
+		return cacheDir, nil
+	}
+	xdgCacheHomeDir, err := xdgCacheHome()
+	if err != nil {
+		return "", err
 	}
+	return filepath.Join(xdgCacheHomeDir, defaultDepsolveCacheDir), nil
+}
 
+func depsolve(solver dnfjson.Depsolver, sbomType sbom.StandardType, packageSets map[string][]rpmmd.PackageSet) (map[string]dnfjson.DepsolveResult, error) {
 	depsolvedSets := make(map[string]dnfjson.DepsolveResult)
 	for name, pkgSet := range packageSets {
-		// Always generate Spdx SBOMs for now, this makes the
-		// default depsolve slightly slower but it means we
-		// need no extra argument here to select the SBOM
-		// type. Once we have more types than Spdx of course
-		// we need to add a option to select the type.
-		res, err := solver.Depsolve(pkgSet, sbom.StandardTypeSpdx)
+		res, err := solver.Depsolve(pkgSet, sbomType)
 		if err != nil {
 			return nil, fmt.Errorf("error depsolving: %w", err)
 		}
@@ -309,12 +514,33 @@ func DefaultCommitResolver(commitSources map[string][]ostree.SourceSpec) (map[st
 	return commits, nil
 }
 
+// DefaultRemoteFileResolver provides a default implementation for
+// remote file resolving. It checks that all remote files are pinned by a
+// valid checksum, the content is fetched and verified against it by the
+// org.osbuild.curl source when the image is built.
+// It should rarely be necessary to use it directly and will be used
+// by default by manifestgen (unless overriden)
+func DefaultRemoteFileResolver(remoteFileSources map[string][]remotefile.SourceSpec) error {
+	for _, sources := range remoteFileSources {
+		for _, source := range sources {
+			if err := source.Validate(); err != nil {
+				return fmt.Errorf("error remote file resolving: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
 type (
 	DepsolveFunc func(cacheDir string, depsolveWarningsOutput io.Writer, packageSets map[string][]rpmmd.PackageSet, d distro.Distro, arch string) (map[string]dnfjson.DepsolveResult, error)
 
+	NewDepsolverFunc func(cacheDir string, depsolveWarningsOutput io.Writer, d distro.Distro, arch string) (dnfjson.Depsolver, error)
+
 	ContainerResolverFunc func(containerSources map[string][]container.SourceSpec, archName string) (map[string][]container.Spec, error)
 
 	CommitResolverFunc func(commitSources map[string][]ostree.SourceSpec) (map[string][]ostree.CommitSpec, error)
 
+	RemoteFileResolverFunc func(remoteFileSources map[string][]remotefile.SourceSpec) error
+
 	SBOMWriterFunc func(filename string, content io.Reader, docType sbom.StandardType) error
 )
@@ -6,14 +6,20 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/osbuild/images/internal/mocks/rpmrepo"
 	"github.com/osbuild/images/pkg/blueprint"
 	"github.com/osbuild/images/pkg/container"
+	"github.com/osbuild/images/pkg/customizations/remotefile"
 	"github.com/osbuild/images/pkg/distro"
 	"github.com/osbuild/images/pkg/distrofactory"
 	"github.com/osbuild/images/pkg/dnfjson"
@@ -288,6 +294,74 @@ func TestManifestGeneratorDepsolveWithSbomWriter(t *testing.T) {
 	assert.Equal(t, expected, generatedSboms)
 }
 
+func TestManifestGeneratorSbomCycloneDXWithContainers(t *testing.T) {
+	repos, err := testrepos.New()
+	assert.NoError(t, err)
+	fac := distrofactory.NewDefault()
+
+	filter, err := imagefilter.New(fac, repos)
+	assert.NoError(t, err)
+	res, err := filter.Filter("distro:centos-9", "type:qcow2", "arch:x86_64")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(res))
+
+	var osbuildManifest bytes.Buffer
+	generatedSboms := map[string]map[string]any{}
+	opts := &manifestgen.Options{
+		Output:            &osbuildManifest,
+		Depsolver:         fakeDepsolve,
+		CommitResolver:    panicCommitResolver,
+		ContainerResolver: fakeContainerResolver,
+		SBOMType:          sbom.StandardTypeCycloneDX,
+
+		SBOMWriter: func(filename string, content io.Reader, docType sbom.StandardType) error {
+			assert.Equal(t, sbom.StandardTypeCycloneDX, docType)
+
+			var doc map[string]any
+			assert.NoError(t, json.NewDecoder(content).Decode(&doc))
+			generatedSboms[filename] = doc
+			return nil
+		},
+	}
+	mg, err := manifestgen.New(repos, opts)
+	assert.NoError(t, err)
+	assert.NotNil(t, mg)
+	fakeContainerSource := "registry.example.com/org/app"
+	bp := blueprint.Blueprint{
+		Containers: []blueprint.Container{
+			{
+				Source: fakeContainerSource,
+			},
+		},
+	}
+	err = mg.Generate(&bp, res[0].Distro, res[0].ImgType, res[0].Arch, nil)
+	require.NoError(t, err)
+
+	require.Contains(t, generatedSboms, "centos-9-qcow2-x86_64.buildroot-build.cdx.json")
+	require.Contains(t, generatedSboms, "centos-9-qcow2-x86_64.image-os.cdx.json")
+
+	findContainers := func(doc map[string]any) []any {
+		var containers []any
+		for _, c := range doc["components"].([]any) {
+			if c.(map[string]any)["type"] == "container" {
+				containers = append(containers, c)
+			}
+		}
+		return containers
+	}
+	assert.Empty(t, findContainers(generatedSboms["centos-9-qcow2-x86_64.buildroot-build.cdx.json"]))
+	containers := findContainers(generatedSboms["centos-9-qcow2-x86_64.image-os.cdx.json"])
+	require.Len(t, containers, 1)
+	container := containers[0].(map[string]any)
+	assert.Equal(t, "resolved-cnt-"+fakeContainerSource, container["name"])
+	assert.Equal(t, "sha256:"+sha256For("digest:"+fakeContainerSource), container["version"])
+}
+
+func TestManifestGeneratorUnsupportedSbomType(t *testing.T) {
+	_, err := manifestgen.New(nil, &manifestgen.Options{SBOMType: sbom.StandardType(99)})
+	assert.Error(t, err)
+}
+
 func TestManifestGeneratorSeed(t *testing.T) {
 	repos, err := testrepos.New()
 	assert.NoError(t, err)
@@ -440,3 +514,133 @@ func TestManifestGeneratorUseBootstrapContainer(t *testing.T) {
 		})
 	}
 }
+
+func TestManifestGeneratorRemoteFiles(t *testing.T) {
+	repos, err := testrepos.New()
+	assert.NoError(t, err)
+	fac := distrofactory.NewDefault()
+
+	filter, err := imagefilter.New(fac, repos)
+	assert.NoError(t, err)
+	res, err := filter.Filter("distro:centos-9", "type:qcow2", "arch:x86_64")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(res))
+
+	checksum := sha256For("bundle")
+	url := "https://example.com/bundle.tar"
+	var resolved map[string][]remotefile.SourceSpec
+	var osbuildManifest bytes.Buffer
+	opts := &manifestgen.Options{
+		Output:    &osbuildManifest,
+		Depsolver: fakeDepsolve,
+		RemoteFileResolver: func(remoteFileSources map[string][]remotefile.SourceSpec) error {
+			resolved = remoteFileSources
+			return nil
+		},
+	}
+	mg, err := manifestgen.New(repos, opts)
+	assert.NoError(t, err)
+
+	bp := blueprint.Blueprint{
+		Customizations: &blueprint.Customizations{
+			Files: []blueprint.FileCustomization{
+				{
+					Path: "/etc/bundle.tar",
+					URI:  url + "#sha256=" + strings.TrimPrefix(checksum, "sha256:"),
+				},
+			},
+		},
+	}
+	err = mg.Generate(&bp, res[0].Distro, res[0].ImgType, res[0].Arch, nil)
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]remotefile.SourceSpec{
+		"os": {{URL: url, Checksum: checksum}},
+	}, resolved)
+
+	var mf struct {
+		Sources map[string]struct {
+			Items map[string]json.RawMessage `json:"items"`
+		} `json:"sources"`
+	}
+	err = json.Unmarshal(osbuildManifest.Bytes(), &mf)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"url": "https://example.com/bundle.tar"}`, string(mf.Sources["org.osbuild.curl"].Items[checksum]))
+	assert.Contains(t, osbuildManifest.String(), fmt.Sprintf(`"from":"input://file-%[1]s/sha256:%[1]s"`, strings.TrimPrefix(checksum, "sha256:")))
+}
+
+func TestDefaultRemoteFileResolver(t *testing.T) {
+	// nothing is fetched, the content is verified by the curl source
+	err := manifestgen.DefaultRemoteFileResolver(map[string][]remotefile.SourceSpec{
+		"os": {{URL: "https://example.com/bundle", Checksum: sha256For("bundle")}},
+	})
+	assert.NoError(t, err)
+
+	err = manifestgen.DefaultRemoteFileResolver(map[string][]remotefile.SourceSpec{
+		"os": {{URL: "https://example.com/bundle", Checksum: "md5:abc"}},
+	})
+	assert.EqualError(t, err, `error remote file resolving: remote file https://example.com/bundle has an invalid checksum "md5:abc" (must be "sha256:<hex digest>")`)
+
+	assert.NoError(t, manifestgen.DefaultRemoteFileResolver(nil))
+}
+
+func TestCachingDepsolver(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repo/repodata/repomd.xml" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, "<repomd/>")
+	}))
+	defer server.Close()
+
+	tmpdir := t.TempDir()
+	fakeSolverPath := filepath.Join(tmpdir, "fake-solver")
+	fakeSolver := `#!/bin/sh -e
+cat - > /dev/null
+echo call >> "$0".calls
+echo '{"solver": "dnf5"}'
+`
+	err := os.WriteFile(fakeSolverPath, []byte(fakeSolver), 0755) //nolint:gosec
+	require.NoError(t, err)
+	t.Setenv("OSBUILD_DEPSOLVE_DNF", fakeSolverPath)
+
+	distribution := distrofactory.NewDefault().GetDistro("centos-9")
+	require.NotNil(t, distribution)
+	packageSets := map[string][]rpmmd.PackageSet{
+		"os": {{Include: []string{"kernel"}, Repositories: []rpmmd.RepoConfig{{Name: "repo", BaseURLs: []string{server.URL + "/repo"}}}}},
+	}
+
+	depsolver := manifestgen.NewCachingDepsolver(filepath.Join(tmpdir, "depsolve"))
+	for i := 0; i < 2; i++ {
+		res, err := depsolver(filepath.Join(tmpdir, "rpmmd"), io.Discard, packageSets, distribution, "x86_64")
+		require.NoError(t, err)
+		assert.Equal(t, "dnf5", res["os"].Solver)
+	}
+
+	// the second depsolve is cached
+	calls, err := os.ReadFile(fakeSolverPath + ".calls")
+	require.NoError(t, err)
+	assert.Equal(t, "call\n", string(calls))
+}
+
+func TestRepodataDepsolver(t *testing.T) {
+	repoServer := rpmrepo.NewTestServer()
+	defer repoServer.Close()
+
+	distribution := distrofactory.NewDefault().GetDistro("centos-9")
+	require.NotNil(t, distribution)
+	packageSets := map[string][]rpmmd.PackageSet{
+		"os": {{Include: []string{"bash"}, Repositories: []rpmmd.RepoConfig{repoServer.RepoConfig}}},
+	}
+
+	res, err := manifestgen.RepodataDepsolver(t.TempDir(), io.Discard, packageSets, distribution, "x86_64")
+	require.NoError(t, err)
+	assert.Equal(t, dnfjson.RepodataSolverName, res["os"].Solver)
+	assert.Nil(t, res["os"].SBOM)
+	var names []string
+	for _, pkg := range res["os"].Packages {
+		names = append(names, pkg.Name)
+	}
+	assert.Contains(t, names, "bash")
+	assert.Contains(t, names, "glibc")
+}
@@ -0,0 +1,177 @@
+package osbuild
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// BuildFailureClass is the known cause of a failed build, see
+// AnalyzeBuildFailure().
+type BuildFailureClass string
+
+const (
+	// The cause of the failure is not known, see BuildFailure.LogTail
+	BuildFailureUnknown BuildFailureClass = "unknown"
+	// The manifest was rejected by osbuild, see BuildFailure.Errors
+	BuildFailureValidation BuildFailureClass = "validation"
+	// Packages could not be resolved or installed, e.g. because of missing
+	// dependencies or file conflicts between rpms
+	BuildFailureDepsolve BuildFailureClass = "depsolve"
+	// The build ran out of disk space, in the store or in an image
+	BuildFailureDiskSpace BuildFailureClass = "disk-space"
+	// The SELinux labelling of the tree failed
+	BuildFailureSELinux BuildFailureClass = "selinux"
+	// A stage needed a kernel but none is installed in the tree
+	BuildFailureMissingKernel BuildFailureClass = "missing-kernel"
+)
+
+// the number of lines of the stage output in BuildFailure.LogTail
+const buildFailureLogTailLines = 20
+
+// failureClassPatterns match the output of failed stages. The order matters:
+// running out of disk space for example makes rpm fail, so it's checked
+// first.
+var failureClassPatterns = []struct {
+	class   BuildFailureClass
+	pattern *regexp.Regexp
+}{
+	{
+		BuildFailureDiskSpace,
+		regexp.MustCompile(`(?i)no space left on device|ENOSPC|disk requirements:|more space needed on the`),
+	},
+	{
+		BuildFailureDepsolve,
+		regexp.MustCompile(`(?i)failed dependencies:|conflicts with file from package|transaction check error|nothing provides|is needed by|problem: (package|conflicting requests)|no match for argument`),
+	},
+	{
+		BuildFailureMissingKernel,
+		regexp.MustCompile(`(?i)cannot find module directory /lib/modules|no kernel (found|installed)|kernel not found|vmlinuz.*no such file or directory`),
+	},
+	{
+		BuildFailureSELinux,
+		regexp.MustCompile(`(?i)\bsetfiles\b|restorecon|invalid (security )?context|could not set context`),
+	},
+}
+
+// BuildFailure describes why an osbuild build failed.
+type BuildFailure struct {
+	// Known cause of the failure
+	Class BuildFailureClass
+
+	// Pipeline, type and ID of the stage that failed, empty if the build
+	// failed outside of a stage (e.g. validation). The ID is empty for v1
+	// results.
+	Pipeline  string
+	StageType string
+	StageID   string
+
+	// The last lines of the output of the failed stage
+	LogTail string
+
+	// Validation errors of the manifest
+	Errors []ValidationError
+}
+
+func (bf *BuildFailure) Error() string {
+	switch {
+	case bf.Class == BuildFailureValidation:
+		return fmt.Sprintf("osbuild manifest validation failed with %d errors", len(bf.Errors))
+	case bf.StageType == "":
+		return fmt.Sprintf("osbuild build failed (%s)", bf.Class)
+	default:
+		return fmt.Sprintf("stage %s in pipeline %s failed (%s)", bf.StageType, bf.Pipeline, bf.Class)
+	}
+}
+
+// AnalyzeBuildFailure returns why the build of the result failed: the
+// failed stage, the tail of its log and the class of the failure as far
+// as it can be determined from the output of the stage. It returns nil if
+// the build succeeded.
+func AnalyzeBuildFailure(res *Result) *BuildFailure {
+	if res == nil || res.Success {
+		return nil
+	}
+
+	if len(res.Errors) > 0 {
+		return &BuildFailure{
+			Class:  BuildFailureValidation,
+			Errors: res.Errors,
+		}
+	}
+
+	bf := &BuildFailure{
+		Class: BuildFailureUnknown,
+	}
+	stage, pipeline := res.failedStage()
+	if stage == nil {
+		return bf
+	}
+
+	bf.Pipeline = pipeline
+	bf.StageType = stage.Type
+	bf.StageID = stage.ID
+	bf.LogTail = logTail(stage.Output, buildFailureLogTailLines)
+	bf.Class = classifyStageFailure(stage)
+	return bf
+}
+
+// failedStage returns the first stage that did not succeed and the name of
+// its pipeline. If none of the stages in the log failed, the stage of the
+// error of a v2 result is returned, the pipeline is unknown then.
+func (res *Result) failedStage() (*StageResult, string) {
+	// the pipeline results don't have a stable order, see Write()
+	pipelineNames := make([]string, 0, len(res.Log))
+	for name := range res.Log {
+		pipelineNames = append(pipelineNames, name)
+	}
+	sort.Strings(pipelineNames)
+
+	for _, name := range pipelineNames {
+		for idx := range res.Log[name] {
+			if stage := &res.Log[name][idx]; !stage.Success {
+				return stage, name
+			}
+		}
+	}
+
+	if len(res.Error) == 0 {
+		return nil, ""
+	}
+	var stageError struct {
+		Details struct {
+			Stage *StageResult `json:"stage"`
+		} `json:"details"`
+	}
+	if err := json.Unmarshal(res.Error, &stageError); err != nil {
+		return nil, ""
+	}
+	return stageError.Details.Stage, ""
+}
+
+func classifyStageFailure(stage *StageResult) BuildFailureClass {
+	// only the end of the output is relevant, the start of the output of
+	// many stages contains harmless errors (e.g. of systemd-tmpfiles)
+	tail := logTail(stage.Output, buildFailureLogTailLines)
+	for _, p := range failureClassPatterns {
+		if p.pattern.MatchString(tail) {
+			return p.class
+		}
+	}
+
+	if stage.Type == "org.osbuild.selinux" {
+		return BuildFailureSELinux
+	}
+	return BuildFailureUnknown
+}
+
+// logTail returns the last n lines of output.
+func logTail(output string, n int) string {
+	lines := strings.Split(strings.TrimRight(output, "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
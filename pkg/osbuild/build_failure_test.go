@@ -0,0 +1,139 @@
+package osbuild
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalyzeBuildFailureSuccess(t *testing.T) {
+	var result Result
+	require.NoError(t, json.Unmarshal([]byte(v2ResultSuccess), &result))
+	assert.Nil(t, AnalyzeBuildFailure(&result))
+	assert.Nil(t, AnalyzeBuildFailure(nil))
+}
+
+func TestAnalyzeBuildFailureV1(t *testing.T) {
+	var result Result
+	require.NoError(t, json.Unmarshal([]byte(v1ResultFailure), &result))
+
+	bf := AnalyzeBuildFailure(&result)
+	require.NotNil(t, bf)
+	assert.Equal(t, BuildFailureSELinux, bf.Class)
+	assert.Equal(t, "os", bf.Pipeline)
+	assert.Equal(t, "org.osbuild.selinux", bf.StageType)
+	assert.Equal(t, "", bf.StageID)
+	assert.True(t, strings.HasPrefix(bf.LogTail, "[/usr/lib/tmpfiles.d/journal-nocow.conf:26]"))
+	assert.True(t, strings.HasSuffix(bf.LogTail, "returned non-zero exit status 255."))
+	assert.EqualError(t, bf, "stage org.osbuild.selinux in pipeline os failed (selinux)")
+}
+
+func TestAnalyzeBuildFailureV2(t *testing.T) {
+	var result Result
+	require.NoError(t, json.Unmarshal([]byte(v2ResultFailure), &result))
+
+	bf := AnalyzeBuildFailure(&result)
+	require.NotNil(t, bf)
+	assert.Equal(t, BuildFailureSELinux, bf.Class)
+	assert.Equal(t, "ostree-tree", bf.Pipeline)
+	assert.Equal(t, "org.osbuild.selinux", bf.StageType)
+	assert.Equal(t, "147fe506d915edb9e0eb8fdb88adb43c8603125f455f47d0228bca935bb997f6", bf.StageID)
+
+	// without the log, the stage of the error is used
+	result.Log = nil
+	bf = AnalyzeBuildFailure(&result)
+	require.NotNil(t, bf)
+	assert.Equal(t, BuildFailureSELinux, bf.Class)
+	assert.Equal(t, "", bf.Pipeline)
+	assert.Equal(t, "147fe506d915edb9e0eb8fdb88adb43c8603125f455f47d0228bca935bb997f6", bf.StageID)
+}
+
+func TestAnalyzeBuildFailureValidation(t *testing.T) {
+	var result Result
+	require.NoError(t, json.Unmarshal([]byte(validationResultFailure), &result))
+
+	bf := AnalyzeBuildFailure(&result)
+	require.NotNil(t, bf)
+	assert.Equal(t, BuildFailureValidation, bf.Class)
+	assert.Len(t, bf.Errors, 2)
+	assert.Equal(t, "", bf.StageType)
+	assert.EqualError(t, bf, "osbuild manifest validation failed with 2 errors")
+}
+
+func TestAnalyzeBuildFailureNoStage(t *testing.T) {
+	bf := AnalyzeBuildFailure(&Result{Type: "error"})
+	require.NotNil(t, bf)
+	assert.Equal(t, BuildFailureUnknown, bf.Class)
+	assert.EqualError(t, bf, "osbuild build failed (unknown)")
+}
+
+func TestAnalyzeBuildFailureClasses(t *testing.T) {
+	testCases := []struct {
+		stageType string
+		output    string
+		expected  BuildFailureClass
+	}{
+		{
+			"org.osbuild.rpm",
+			"Preparing packages...\nerror: unpacking of archive failed on file /usr/lib64/libfoo.so.1;65f1: cpio: write failed - No space left on device\n",
+			BuildFailureDiskSpace,
+		},
+		{
+			"org.osbuild.rpm",
+			"Preparing packages...\nfile /usr/bin/foo from install of foo-1.0-1.x86_64 conflicts with file from package bar-2.0-1.x86_64\n",
+			BuildFailureDepsolve,
+		},
+		{
+			"org.osbuild.rpm",
+			"error: Failed dependencies:\n\tlibbar.so.2()(64bit) is needed by foo-1.0-1.x86_64\n",
+			BuildFailureDepsolve,
+		},
+		{
+			"org.osbuild.dracut",
+			"dracut: Cannot find module directory /lib/modules/6.5.0-1.fc39.x86_64/\ndracut: and --no-kernel was not specified\n",
+			BuildFailureMissingKernel,
+		},
+		{
+			"org.osbuild.selinux",
+			"setfiles: /run/osbuild/tree/etc/foo: invalid context system_u:object_r:foo_t:s0\n",
+			BuildFailureSELinux,
+		},
+		{
+			"org.osbuild.selinux",
+			"Traceback (most recent call last):\n",
+			BuildFailureSELinux,
+		},
+		{
+			"org.osbuild.locale",
+			"Traceback (most recent call last):\n",
+			BuildFailureUnknown,
+		},
+		{
+			// only the tail of the output is classified
+			"org.osbuild.rpm",
+			"No space left on device" + strings.Repeat("\nselinux-policy-38.1-1.noarch", buildFailureLogTailLines) + "\nerror: rpmdb open failed\n",
+			BuildFailureUnknown,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(string(tc.expected), func(t *testing.T) {
+			result := &Result{
+				Log: map[string]PipelineResult{
+					"os": {
+						{Type: "org.osbuild.kernel-cmdline", Success: true},
+						{Type: tc.stageType, Output: tc.output},
+					},
+				},
+			}
+			bf := AnalyzeBuildFailure(result)
+			require.NotNil(t, bf)
+			assert.Equal(t, tc.expected, bf.Class)
+			assert.Equal(t, "os", bf.Pipeline)
+			assert.Equal(t, tc.stageType, bf.StageType)
+		})
+	}
+}
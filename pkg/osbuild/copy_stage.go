@@ -2,6 +2,7 @@ package osbuild
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/osbuild/images/pkg/disk"
 )
@@ -66,6 +67,12 @@ func GenCopyFSTreeOptions(inputName, inputPipeline, filename string, pt *disk.Pa
 		panic(err)
 	}
 
+	if fsRootMntName == "" {
+		// read-only root filesystem: only the content of the other
+		// filesystems is copied
+		return &CopyStageOptions{Paths: genCopyMountPaths(inputName, mounts)}, devices, mounts
+	}
+
 	options := CopyStageOptions{
 		Paths: []CopyStagePath{
 			{
@@ -77,3 +84,29 @@ func GenCopyFSTreeOptions(inputName, inputPipeline, filename string, pt *disk.Pa
 
 	return &options, devices, mounts
 }
+
+// genCopyMountPaths returns a path for each of the mounts that is not nested
+// in another mount, the content of the nested mounts is copied with their
+// parent. The mounts are sorted by their target.
+func genCopyMountPaths(inputName string, mounts []Mount) []CopyStagePath {
+	var paths []CopyStagePath
+	var parents []string
+	for _, mnt := range mounts {
+		nested := false
+		for _, parent := range parents {
+			if strings.HasPrefix(mnt.Target, parent+"/") {
+				nested = true
+				break
+			}
+		}
+		if nested {
+			continue
+		}
+		parents = append(parents, mnt.Target)
+		paths = append(paths, CopyStagePath{
+			From: fmt.Sprintf("input://%s%s/", inputName, mnt.Target),
+			To:   fmt.Sprintf("mount://%s/", mnt.Name),
+		})
+	}
+	return paths
+}
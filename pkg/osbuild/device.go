@@ -164,6 +164,8 @@ func deviceName(p disk.Entity) string {
 		return "btrfs-" + payload.UUID[:4]
 	case *disk.Swap:
 		return "swap-" + payload.UUID[:4]
+	case *disk.Verity:
+		return "verity-" + pathEscape(payload.Mountpoint)
 	}
 	panic(fmt.Sprintf("unsupported device type in deviceName: '%T'", p))
 }
@@ -271,8 +273,11 @@ func genOsbuildMount(source string, mnt disk.Mountable) (*Mount, error) {
 // GenMountsDevicesFromPT generates osbuild mounts and devices from a disk.PartitionTable
 // filename is the name of the underlying image file (which will get loop-mounted).
 //
+// Read-only filesystems are not mounted (see disk.FSType.ReadOnly()).
+//
 // Returned values:
-// 1) the name of the mount for the filesystem root
+// 1) the name of the mount for the filesystem root, empty if the root
+// filesystem is read-only
 // 2) generated mounts
 // 3) generated devices
 // 4) error if any
@@ -281,6 +286,10 @@ func GenMountsDevicesFromPT(filename string, pt *disk.PartitionTable) (string, [
 	mounts := make([]Mount, 0, len(pt.Partitions))
 	var fsRootMntName string
 	genMounts := func(mnt disk.Mountable, path []disk.Entity) error {
+		if disk.IsReadOnlyFSType(mnt.GetFSType()) {
+			// created from the tree, it is never mounted while building
+			return nil
+		}
 		stageDevices, leafDeviceName := getDevices(path, filename, false)
 		mount, err := genOsbuildMount(leafDeviceName, mnt)
 		if err != nil {
@@ -320,7 +329,7 @@ func GenMountsDevicesFromPT(filename string, pt *disk.PartitionTable) (string, [
 		return mounts[i].Target < mounts[j].Target
 	})
 
-	if fsRootMntName == "" {
+	if fsRootMntName == "" && !pt.ReadOnlyRoot() {
 		return "", nil, nil, fmt.Errorf("no mount found for the filesystem root")
 	}
 
@@ -2,6 +2,7 @@ package osbuild
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/google/uuid"
 
@@ -140,6 +141,34 @@ func GenImageKernelOptions(pt *disk.PartitionTable, mountUnits bool) (string, []
 		)
 	}
 
+	// a read-only root filesystem stays read-only, the system writes either
+	// to a separate /var filesystem or to a volatile overlay of the root
+	if pt.ReadOnlyRoot() {
+		cmdline = append(cmdline, fmt.Sprintf("rootfstype=%s", rootFs.GetFSType()))
+		if pt.FindMountable("/var") == nil {
+			cmdline = append(cmdline, "systemd.volatile=overlay")
+		}
+		// the filesystem has no stable UUID, the root= option for its
+		// partition overrides the one for the filesystem UUID
+		if part := pt.ReadOnlyRootPartition(); part != nil && !pt.VerityRoot() {
+			cmdline = append(cmdline, fmt.Sprintf("root=PARTUUID=%s", strings.ToLower(part.UUID)))
+		}
+	}
+
+	// a root filesystem protected by dm-verity is opened by
+	// systemd-veritysetup-generator(8) as /dev/mapper/root, with the root
+	// hash from the roothash= option that is added when the image is
+	// deployed (see GenImageVerityStage()). The root= option is added after
+	// the one for the root filesystem UUID, which it overrides.
+	if data, hash := pt.VerityPartitions("/"); data != nil {
+		cmdline = append(
+			cmdline,
+			fmt.Sprintf("systemd.verity_root_data=PARTUUID=%s", strings.ToLower(data.UUID)),
+			fmt.Sprintf("systemd.verity_root_hash=PARTUUID=%s", strings.ToLower(hash.UUID)),
+			"root=/dev/mapper/root",
+		)
+	}
+
 	genOptions := func(e disk.Entity, path []disk.Entity) error {
 		switch ent := e.(type) {
 		case *disk.LUKSContainer:
@@ -0,0 +1,66 @@
+package osbuild
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/osbuild/images/pkg/disk"
+)
+
+// Format a dm-verity hash device for a data device and write the root hash of
+// the hash tree to a file in the tree. The root hash is also reported in the
+// metadata of the stage, see DMVerityStageMetadata.
+
+type DMVerityStageOptions struct {
+	// Path of the file in the tree that the root hash is written to
+	RootHashFile string `json:"root_hash"`
+}
+
+func (DMVerityStageOptions) isStageOptions() {}
+
+func NewDMVerityStage(options *DMVerityStageOptions, devices map[string]Device) *Stage {
+	return &Stage{
+		Type:    "org.osbuild.dmverity",
+		Options: options,
+		Devices: devices,
+	}
+}
+
+type DMVerityStageMetadata struct {
+	RootHash string `json:"root_hash"`
+}
+
+func (DMVerityStageMetadata) isStageMetadata() {}
+
+// VerityRootHashFilename returns the name of the file with the root hash for
+// the image filename. Like systemd's image dissection, the extension of the
+// image is replaced with ".roothash".
+func VerityRootHashFilename(filename string) string {
+	return strings.TrimSuffix(filename, filepath.Ext(filename)) + ".roothash"
+}
+
+// GenImageVerityStage returns the stage that formats the dm-verity hash
+// partition of the root filesystem of the partition table and writes the
+// root hash next to the image (see VerityRootHashFilename()). The root hash
+// must be passed to the kernel with the roothash= option by whatever boots
+// the image. It cannot be part of the boot entries or the UKI of the image,
+// so images with a bootloader cannot use dm-verity. It returns
+// nil if the root filesystem is not protected by dm-verity, see
+// disk.PartitionTable.VerityRoot().
+func GenImageVerityStage(pt *disk.PartitionTable, filename string) *Stage {
+	data, hash := pt.VerityPartitions("/")
+	if data == nil {
+		return nil
+	}
+
+	devices := make(map[string]Device)
+	for name, part := range map[string]*disk.Partition{"data_device": data, "hash_device": hash} {
+		partDevices, lastName := getDevices([]disk.Entity{pt, part}, filename, true)
+		devices[name] = partDevices[lastName]
+	}
+
+	options := &DMVerityStageOptions{
+		RootHashFile: VerityRootHashFilename(filename),
+	}
+	return NewDMVerityStage(options, devices)
+}
@@ -0,0 +1,60 @@
+package osbuild
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/internal/testdisk"
+	"github.com/osbuild/images/pkg/disk"
+)
+
+func TestVerityRootHashFilename(t *testing.T) {
+	assert.Equal(t, "disk.roothash", VerityRootHashFilename("disk.img"))
+	assert.Equal(t, "image.roothash", VerityRootHashFilename("image"))
+}
+
+func TestGenImageVerityStage(t *testing.T) {
+	pt := testdisk.MakeFakeVerityPartitionTable("/boot", "/")
+	stage := GenImageVerityStage(pt, "disk.img")
+
+	loopback := *NewLoopbackDevice(&LoopbackDeviceOptions{
+		Filename: "disk.img",
+		Size:     testdisk.FakePartitionSize / 512,
+		Lock:     true,
+	})
+	assert.Equal(t, &Stage{
+		Type: "org.osbuild.dmverity",
+		Options: &DMVerityStageOptions{
+			RootHashFile: "disk.roothash",
+		},
+		Devices: map[string]Device{
+			"data_device": loopback,
+			"hash_device": loopback,
+		},
+	}, stage)
+
+	assert.Nil(t, GenImageVerityStage(testdisk.MakeFakeEROFSPartitionTable("/boot", "/"), "disk.img"))
+}
+
+func TestGenImageKernelOptionsVerity(t *testing.T) {
+	pt := testdisk.MakeFakeVerityPartitionTable("/boot", "/var", "/")
+	rootUUID, cmdline, err := GenImageKernelOptions(pt, false)
+	require.NoError(t, err)
+	assert.Equal(t, disk.RootPartitionUUID, rootUUID)
+	assert.Equal(t, []string{
+		"rootfstype=erofs",
+		"systemd.verity_root_data=PARTUUID=00000000-0000-0000-0000-000000000003",
+		"systemd.verity_root_hash=PARTUUID=00000000-0000-0000-0000-000000000004",
+		"root=/dev/mapper/root",
+	}, cmdline)
+}
+
+func TestDMVerityStageMetadata(t *testing.T) {
+	var md PipelineMetadata
+	err := json.Unmarshal([]byte(`{"org.osbuild.dmverity": {"root_hash": "abc123"}}`), &md)
+	require.NoError(t, err)
+	assert.Equal(t, &DMVerityStageMetadata{RootHash: "abc123"}, md["org.osbuild.dmverity"])
+}
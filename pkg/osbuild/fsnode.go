@@ -3,6 +3,7 @@ package osbuild
 import (
 	"crypto/sha256"
 	"fmt"
+	"strings"
 
 	"github.com/osbuild/images/pkg/customizations/fsnode"
 	"github.com/osbuild/images/pkg/hashutil"
@@ -24,9 +25,13 @@ func GenFileNodesStages(files []*fsnode.File) []*Stage {
 
 	for _, file := range files {
 		var fileDataChecksum string
-		if file.URI() == "" {
+		switch {
+		case file.URI() == "":
 			fileDataChecksum = fmt.Sprintf("%x", sha256.Sum256(file.Data()))
-		} else {
+		case file.Checksum() != "":
+			// remote files are pinned by their checksum
+			fileDataChecksum = strings.TrimPrefix(file.Checksum(), "sha256:")
+		default:
 			var err error
 			fileDataChecksum, err = hashutil.Sha256sum(file.URI())
 			if err != nil {
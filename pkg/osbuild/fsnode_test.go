@@ -8,6 +8,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/osbuild/images/internal/common"
 	"github.com/osbuild/images/pkg/customizations/fsnode"
@@ -288,3 +289,17 @@ func TestGenFileNodeStage(t *testing.T) {
 	st := stages[0]
 	assert.Contains(t, st.Options.(*CopyStageOptions).Paths[0].From, sha256sum)
 }
+
+func TestGenFileNodeStageRemote(t *testing.T) {
+	sha256sum := "ed7002b439e9ac845f22357d822bac1444730fbdb6016d3ec9432297b9ec9f73"
+	fsNodeFile, err := fsnode.NewFileForURI("/some/path", nil, nil, nil, "https://example.com/file#sha256="+sha256sum)
+	assert.NoError(t, err)
+
+	stages := GenFileNodesStages([]*fsnode.File{fsNodeFile})
+	require.Len(t, stages, 1)
+	assert.Equal(t, []CopyStagePath{{
+		From:              "input://file-" + sha256sum + "/sha256:" + sha256sum,
+		To:                "tree:///some/path",
+		RemoveDestination: true,
+	}}, stages[0].Options.(*CopyStageOptions).Paths)
+}
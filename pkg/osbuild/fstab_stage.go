@@ -59,6 +59,11 @@ func (options *FSTabStageOptions) AddFilesystem(id string, vfsType string, path
 func NewFSTabStageOptions(pt *disk.PartitionTable) (*FSTabStageOptions, error) {
 	var options FSTabStageOptions
 	genOption := func(mnt disk.FSTabEntity, path []disk.Entity) error {
+		// a read-only root filesystem has no stable UUID, it is only
+		// mounted from the kernel command line (see GenImageKernelOptions())
+		if mnt.GetFSFile() == "/" && disk.IsReadOnlyFSType(mnt.GetFSType()) {
+			return nil
+		}
 		fsSpec := mnt.GetFSSpec()
 		fsOptions, err := mnt.GetFSTabOptions()
 		if err != nil {
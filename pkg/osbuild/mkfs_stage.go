@@ -31,6 +31,9 @@ func GenFsStages(pt *disk.PartitionTable, filename string) []*Stage {
 			stageDevices["device"] = lastDevice
 
 			switch e.GetFSType() {
+			case "erofs":
+				// read-only filesystems are created from the tree, see
+				// GenImageReadOnlyRootStage()
 			case "xfs":
 				options := &MkfsXfsStageOptions{
 					UUID:  e.UUID,
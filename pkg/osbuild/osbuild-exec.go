@@ -2,12 +2,15 @@ package osbuild
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/osbuild/images/data/dependencies"
 	"github.com/osbuild/images/pkg/datasizes"
@@ -28,25 +31,7 @@ func RunOSBuild(manifest []byte, store, outputDirectory string, exports, checkpo
 	var stdoutBuffer bytes.Buffer
 	var res Result
 
-	cmd := exec.Command(
-		"osbuild",
-		"--store", store,
-		"--output-directory", outputDirectory,
-		"-",
-	)
-
-	for _, export := range exports {
-		cmd.Args = append(cmd.Args, "--export", export)
-	}
-
-	for _, checkpoint := range checkpoints {
-		cmd.Args = append(cmd.Args, "--checkpoint", checkpoint)
-	}
-
-	if len(checkpoints) > 0 {
-		// set the cache-max-size to a reasonable size that the checkpoints actually get stored
-		cmd.Args = append(cmd.Args, "--cache-max-size", fmt.Sprint(20*datasizes.GiB))
-	}
+	cmd := exec.Command("osbuild", osbuildArgs(store, outputDirectory, exports, checkpoints)...)
 
 	if result {
 		cmd.Args = append(cmd.Args, "--json")
@@ -103,6 +88,154 @@ func RunOSBuild(manifest []byte, store, outputDirectory string, exports, checkpo
 	return &res, nil
 }
 
+// osbuildArgs returns the arguments of an osbuild invocation that reads the
+// manifest from stdin.
+func osbuildArgs(store, outputDirectory string, exports, checkpoints []string) []string {
+	args := []string{
+		"--store", store,
+		"--output-directory", outputDirectory,
+		"-",
+	}
+
+	for _, export := range exports {
+		args = append(args, "--export", export)
+	}
+
+	for _, checkpoint := range checkpoints {
+		args = append(args, "--checkpoint", checkpoint)
+	}
+
+	if len(checkpoints) > 0 {
+		// set the cache-max-size to a reasonable size that the checkpoints actually get stored
+		args = append(args, "--cache-max-size", fmt.Sprint(20*datasizes.GiB))
+	}
+
+	return args
+}
+
+// OSBuildOptions contains the options of RunOSBuildContext.
+type OSBuildOptions struct {
+	StoreDir    string
+	OutputDir   string
+	Exports     []string
+	Checkpoints []string
+	ExtraEnv    []string
+
+	// Stderr receives the stderr of osbuild, it is discarded if nil
+	Stderr io.Writer
+
+	// StatusCallback is called for every status update that osbuild
+	// reports on its monitor while building (pipeline and stage progress,
+	// messages and stage output). It is called from a separate goroutine
+	// but never concurrently and never after RunOSBuildContext returned.
+	StatusCallback func(*Status)
+}
+
+// RunOSBuildContext runs an instance of osbuild like RunOSBuild with --json
+// and returns the parsed osbuild.Result. While osbuild is running, its
+// progress is reported to opts.StatusCallback.
+//
+// When ctx is canceled, osbuild and all the processes it started (osbuild
+// runs in its own process group) are killed and the error of the context
+// is returned.
+func RunOSBuildContext(ctx context.Context, manifest []byte, opts *OSBuildOptions) (*Result, error) {
+	if opts == nil {
+		opts = &OSBuildOptions{}
+	}
+	if err := CheckMinimumOSBuildVersion(); err != nil {
+		return nil, err
+	}
+
+	// the write end of the monitor pipe is fd 3 of osbuild
+	monitorR, monitorW, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("error setting up the osbuild monitor pipe: %w", err)
+	}
+	defer monitorR.Close()
+
+	var stdoutBuffer bytes.Buffer
+	cmd := exec.CommandContext(ctx, "osbuild", osbuildArgs(opts.StoreDir, opts.OutputDir, opts.Exports, opts.Checkpoints)...)
+	cmd.Args = append(cmd.Args, "--json", "--monitor=JSONSeqMonitor", "--monitor-fd=3")
+	cmd.ExtraFiles = []*os.File{monitorW}
+	cmd.Stdin = bytes.NewReader(manifest)
+	cmd.Stdout = &stdoutBuffer
+	cmd.Stderr = opts.Stderr
+	if len(opts.ExtraEnv) > 0 {
+		cmd.Env = append(os.Environ(), opts.ExtraEnv...)
+	}
+	// run osbuild in its own process group and kill the whole group on
+	// cancel, just killing osbuild would leave the stage processes
+	// (bubblewrap, rpm, ...) running
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	// don't block forever on stray processes that keep stdout open
+	cmd.WaitDelay = 10 * time.Second
+
+	err = cmd.Start()
+	// only osbuild writes to the monitor, the pipe is at EOF when it exits
+	monitorW.Close()
+	if err != nil {
+		return nil, fmt.Errorf("error starting osbuild: %w", err)
+	}
+
+	monitorDone := make(chan error, 1)
+	go func() {
+		monitorDone <- scanStatus(monitorR, opts.StatusCallback)
+	}()
+
+	err = cmd.Wait()
+	// the monitor is at EOF once osbuild and its stages are gone
+	monitorErr := <-monitorDone
+
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, fmt.Errorf("running osbuild canceled: %w", ctxErr)
+	}
+
+	// try to decode the output even though the job could have failed
+	if stdoutBuffer.Len() == 0 {
+		if err != nil {
+			return nil, fmt.Errorf("running osbuild failed: %w", err)
+		}
+		return nil, fmt.Errorf("osbuild did not return any output")
+	}
+	var res Result
+	if decodeErr := json.Unmarshal(stdoutBuffer.Bytes(), &res); decodeErr != nil {
+		return nil, fmt.Errorf("error decoding osbuild output: %v\nthe raw output:\n%s", decodeErr, stdoutBuffer.String())
+	}
+
+	// ignore ExitError if output could be decoded correctly
+	if _, isExitError := err.(*exec.ExitError); err != nil && !isExitError {
+		return nil, fmt.Errorf("running osbuild failed: %w", err)
+	}
+	if monitorErr != nil {
+		return nil, fmt.Errorf("error reading osbuild monitor: %w", monitorErr)
+	}
+
+	return &res, nil
+}
+
+// scanStatus calls cb for every status of the osbuild monitor r until EOF.
+// After an error, the rest of r is discarded so that osbuild never blocks
+// on a full pipe.
+func scanStatus(r io.Reader, cb func(*Status)) error {
+	scanner := NewStatusScanner(r)
+	for {
+		st, err := scanner.Status()
+		if err != nil {
+			_, _ = io.Copy(io.Discard, r)
+			return err
+		}
+		if st == nil {
+			return nil
+		}
+		if cb != nil {
+			cb(st)
+		}
+	}
+}
+
 func CheckMinimumOSBuildVersion() error {
 	osbuildVersion, err := OSBuildVersion()
 	if err != nil {
@@ -0,0 +1,148 @@
+package osbuild_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/pkg/osbuild"
+)
+
+// fakeOSBuild puts an "osbuild" script that runs body (after reporting a
+// recent version) first in PATH. The arguments of the call are recorded in
+// the "args" file of the returned directory and the manifest in "manifest".
+func fakeOSBuild(t *testing.T, body string) string {
+	dir := t.TempDir()
+	script := fmt.Sprintf(`#!/bin/sh
+if [ "$1" = "--version" ]; then
+	echo "osbuild 999"
+	exit 0
+fi
+echo "$@" > %[1]s/args
+cat > %[1]s/manifest
+%[2]s
+`, dir, body)
+	// #nosec G306
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "osbuild"), []byte(script), 0755))
+	t.Setenv("PATH", dir+":"+os.Getenv("PATH"))
+	return dir
+}
+
+// fakeMonitor writes the canned monitor lines as json-seq to fd 3
+func fakeMonitor(t *testing.T, dir string) string {
+	var seq strings.Builder
+	for _, line := range strings.Split(osbuildMonitorLines_curl, "\n") {
+		seq.WriteString("\x1e" + line + "\n")
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "monitor"), []byte(seq.String()), 0600))
+	return fmt.Sprintf("cat %s/monitor >&3", dir)
+}
+
+func TestRunOSBuildContext(t *testing.T) {
+	dir := t.TempDir()
+	body := fakeMonitor(t, dir) + `
+echo '{"type": "result", "success": true, "log": {}, "metadata": {}}'
+`
+	tmp := fakeOSBuild(t, body)
+
+	var statuses []*osbuild.Status
+	opts := &osbuild.OSBuildOptions{
+		StoreDir:    "/store",
+		OutputDir:   "/output",
+		Exports:     []string{"image"},
+		Checkpoints: []string{"build"},
+		StatusCallback: func(st *osbuild.Status) {
+			statuses = append(statuses, st)
+		},
+	}
+	res, err := osbuild.RunOSBuildContext(context.Background(), []byte(`{"version": "2"}`), opts)
+	require.NoError(t, err)
+	assert.True(t, res.Success)
+
+	require.Len(t, statuses, 3)
+	assert.Equal(t, "Pipeline source org.osbuild.curl", statuses[0].Progress.Message)
+	assert.Equal(t, "Starting pipeline build", statuses[2].Message)
+	assert.Equal(t, 1, statuses[2].Progress.Done)
+
+	args, err := os.ReadFile(filepath.Join(tmp, "args"))
+	require.NoError(t, err)
+	assert.Equal(t, "--store /store --output-directory /output - --export image --checkpoint build --cache-max-size 21474836480 --json --monitor=JSONSeqMonitor --monitor-fd=3\n", string(args))
+	manifest, err := os.ReadFile(filepath.Join(tmp, "manifest"))
+	require.NoError(t, err)
+	assert.Equal(t, `{"version": "2"}`, string(manifest))
+}
+
+func TestRunOSBuildContextBuildFailure(t *testing.T) {
+	fakeOSBuild(t, `
+echo '{"type": "result", "success": false, "log": {}, "metadata": {}}'
+exit 1
+`)
+
+	res, err := osbuild.RunOSBuildContext(context.Background(), nil, nil)
+	require.NoError(t, err)
+	assert.False(t, res.Success)
+}
+
+func TestRunOSBuildContextNoOutput(t *testing.T) {
+	fakeOSBuild(t, `exit 2`)
+
+	_, err := osbuild.RunOSBuildContext(context.Background(), nil, nil)
+	assert.EqualError(t, err, "running osbuild failed: exit status 2")
+}
+
+func TestRunOSBuildContextBadMonitor(t *testing.T) {
+	fakeOSBuild(t, `
+echo 'garbage' >&3
+echo '{"type": "result", "success": true, "log": {}, "metadata": {}}'
+`)
+
+	_, err := osbuild.RunOSBuildContext(context.Background(), nil, nil)
+	assert.ErrorContains(t, err, `error reading osbuild monitor: cannot scan line "garbage"`)
+}
+
+func TestRunOSBuildContextCancel(t *testing.T) {
+	dir := t.TempDir()
+	// a "stage" process that keeps running in the background, it must
+	// be killed with osbuild
+	body := fmt.Sprintf(`
+sleep 600 &
+echo $! > %s/stage.pid
+%s
+wait
+`, dir, fakeMonitor(t, dir))
+	fakeOSBuild(t, body)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	opts := &osbuild.OSBuildOptions{
+		StatusCallback: func(st *osbuild.Status) {
+			if st.Message == "Starting pipeline build" {
+				cancel()
+			}
+		},
+	}
+
+	start := time.Now()
+	_, err := osbuild.RunOSBuildContext(ctx, nil, opts)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Less(t, time.Since(start), 10*time.Second)
+
+	pidStr, err := os.ReadFile(filepath.Join(dir, "stage.pid"))
+	require.NoError(t, err)
+	pid, err := strconv.Atoi(strings.TrimSpace(string(pidStr)))
+	require.NoError(t, err)
+	// the stage is gone or a zombie waiting to be reaped (the kill is
+	// asynchronous)
+	assert.Eventually(t, func() bool {
+		stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+		return err != nil || strings.Fields(string(stat))[2] == "Z"
+	}, 5*time.Second, 10*time.Millisecond)
+}
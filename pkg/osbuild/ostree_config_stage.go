@@ -13,6 +13,9 @@ func (OSTreeConfigStageOptions) isStageOptions() {}
 type OSTreeConfig struct {
 	// Options concerning the sysroot
 	Sysroot *SysrootOptions `json:"sysroot,omitempty"`
+
+	// Options concerning the integrity of the repo objects
+	Integrity *IntegrityOptions `json:"integrity,omitempty"`
 }
 
 type SysrootOptions struct {
@@ -20,6 +23,12 @@ type SysrootOptions struct {
 	Bootloader string `json:"bootloader,omitempty"`
 }
 
+type IntegrityOptions struct {
+	// Enable fs-verity on the objects: "true", "false" or "maybe" (only if
+	// the filesystem supports it)
+	FSVerity string `json:"fsverity,omitempty"`
+}
+
 // A new org.osbuild.ostree.config stage to configure an OSTree repository
 func NewOSTreeConfigStage(options *OSTreeConfigStageOptions) *Stage {
 	return &Stage{
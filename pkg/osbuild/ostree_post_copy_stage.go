@@ -0,0 +1,20 @@
+package osbuild
+
+// Options for the org.osbuild.ostree.post-copy stage. The stage has no
+// options, it runs on the sysroot that is mounted at the root of the mounts.
+type OSTreePostCopyStageOptions struct{}
+
+func (OSTreePostCopyStageOptions) isStageOptions() {}
+
+// A new org.osbuild.ostree.post-copy stage to finish an ostree sysroot that
+// was copied onto its filesystem, e.g. to enable fs-verity on the objects of
+// the repo and the composefs images of the deployments, which does not
+// survive the copy.
+func NewOSTreePostCopyStage(devices map[string]Device, mounts []Mount) *Stage {
+	return &Stage{
+		Type:    "org.osbuild.ostree.post-copy",
+		Options: &OSTreePostCopyStageOptions{},
+		Devices: devices,
+		Mounts:  mounts,
+	}
+}
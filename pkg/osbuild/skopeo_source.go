@@ -22,6 +22,11 @@ type SkopeopSourceImage struct {
 	Name      string `json:"name,omitempty"`
 	Digest    string `json:"digest,omitempty"`
 	TLSVerify *bool  `json:"tls-verify,omitempty"`
+
+	// Fetch the image from the containers-storage at StorageLocation
+	// (with the ContainersStorageTransport) instead of the registry of Name
+	ContainersTransport string `json:"containers-transport,omitempty"`
+	StorageLocation     string `json:"storage-location,omitempty"`
 }
 
 type SkopeoSourceItem struct {
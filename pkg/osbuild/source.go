@@ -6,6 +6,7 @@ import (
 	"fmt"
 
 	"github.com/osbuild/images/pkg/container"
+	"github.com/osbuild/images/pkg/customizations/remotefile"
 	"github.com/osbuild/images/pkg/dnfjson"
 	"github.com/osbuild/images/pkg/hashutil"
 	"github.com/osbuild/images/pkg/ostree"
@@ -32,6 +33,9 @@ type SourceInputs struct {
 	InlineData []string
 	// FileRefs contains the references of paths/urls for fsnode.Files
 	FileRefs []string
+	// RemoteFiles contains the remote files for fsnode.Files that are
+	// pinned by their checksum
+	RemoteFiles []remotefile.SourceSpec
 }
 
 // A Sources map contains all the sources made available to an osbuild run
@@ -193,5 +197,22 @@ func GenSources(inputs SourceInputs, rpmDownloader RpmDownloader) (Sources, erro
 		sources["org.osbuild.curl"] = curl
 	}
 
+	// collect remote files
+	if len(inputs.RemoteFiles) > 0 {
+		curl, ok := sources[SourceNameCurl].(*CurlSource)
+		if !ok || curl == nil {
+			curl = NewCurlSource()
+		}
+		for _, remoteFile := range inputs.RemoteFiles {
+			if !curlDigestPattern.MatchString(remoteFile.Checksum) {
+				return nil, fmt.Errorf("curl source item for remote file %q has invalid digest %q", remoteFile.URL, remoteFile.Checksum)
+			}
+			curl.Items[remoteFile.Checksum] = &CurlSourceOptions{
+				URL: remoteFile.URL,
+			}
+		}
+		sources[SourceNameCurl] = curl
+	}
+
 	return sources, nil
 }
@@ -12,6 +12,7 @@ import (
 	"github.com/stretchr/testify/assert"
 
 	"github.com/osbuild/images/pkg/container"
+	"github.com/osbuild/images/pkg/customizations/remotefile"
 	"github.com/osbuild/images/pkg/dnfjson"
 	"github.com/osbuild/images/pkg/rpmmd"
 )
@@ -364,3 +365,32 @@ func TestGenSourcesFileRefs(t *testing.T) {
   }
 }`, testFile))
 }
+
+func TestGenSourcesRemoteFiles(t *testing.T) {
+	checksum := "sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	sources, err := GenSources(SourceInputs{
+		RemoteFiles: []remotefile.SourceSpec{
+			{URL: "https://example.com/empty", Checksum: checksum},
+		},
+	}, 0)
+	assert.NoError(t, err)
+
+	jsonOutput, err := json.MarshalIndent(sources, "", "  ")
+	assert.NoError(t, err)
+	assert.Equal(t, `{
+  "org.osbuild.curl": {
+    "items": {
+      "sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855": {
+        "url": "https://example.com/empty"
+      }
+    }
+  }
+}`, string(jsonOutput))
+
+	_, err = GenSources(SourceInputs{
+		RemoteFiles: []remotefile.SourceSpec{
+			{URL: "https://example.com/empty", Checksum: "e3b0"},
+		},
+	}, 0)
+	assert.EqualError(t, err, `curl source item for remote file "https://example.com/empty" has invalid digest "e3b0"`)
+}
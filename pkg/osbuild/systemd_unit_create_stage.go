@@ -279,7 +279,10 @@ func GenSystemdMountStages(pt *disk.PartitionTable) ([]*Stage, error) {
 		}
 
 		device := filepath.Join("/dev/disk/by-uuid", strings.ToLower(fsSpec.UUID))
-		if isFATVolID(fsSpec.UUID) {
+		if part := pt.ReadOnlyRootPartition(); part != nil && ent.GetFSFile() == "/" {
+			// a read-only root filesystem has no stable UUID
+			device = filepath.Join("/dev/disk/by-partuuid", strings.ToLower(part.UUID))
+		} else if isFATVolID(fsSpec.UUID) {
 			// vfat IDs aren't lowercased
 			device = filepath.Join("/dev/disk/by-uuid", fsSpec.UUID)
 		}
@@ -45,7 +45,7 @@ func NewUsersStageOptions(userCustomizations []users.User, omitKey bool) (*Users
 
 		// Hash non-empty un-hashed passwords
 		if uc.Password != nil && !crypt.PasswordIsCrypted(*uc.Password) {
-			cryptedPassword, err := crypt.CryptSHA512(*uc.Password)
+			cryptedPassword, err := crypt.Crypt(*uc.Password, uc.PasswordHashAlgorithm)
 			if err != nil {
 				return nil, err
 			}
@@ -8,6 +8,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/osbuild/images/internal/common"
+	"github.com/osbuild/images/pkg/crypt"
 	"github.com/osbuild/images/pkg/customizations/users"
 )
 
@@ -24,6 +25,7 @@ func TestNewUsersStageOptionsPassword(t *testing.T) {
 	Pass := "testpass"
 	EmptyPass := ""
 	CryptPass := "$6$RWdHzrPfoM6BMuIP$gKYlBXQuJgP.G2j2twbOyxYjFDPUQw8Jp.gWe1WD/obX0RMyfgw5vt.Mn/tLLX4mQjaklSiIzoAW3HrVQRg4Q." // #nosec G101
+	YescryptPass := "$y$j9T$saltsaltsalt$xrDDuMAdFROKSJ/d7QkiP2yBt56ZAYp42AH3eU0XVBB"                                         // #nosec G101
 
 	users := []users.User{
 		{
@@ -41,6 +43,16 @@ func TestNewUsersStageOptionsPassword(t *testing.T) {
 		{
 			Name: "homer",
 		},
+		{
+			Name:                  "marge",
+			Password:              &Pass,
+			PasswordHashAlgorithm: crypt.AlgorithmYescrypt,
+		},
+		{
+			Name:                  "abe",
+			Password:              &YescryptPass,
+			PasswordHashAlgorithm: crypt.AlgorithmYescrypt,
+		},
 	}
 
 	options, err := NewUsersStageOptions(users, false)
@@ -58,6 +70,12 @@ func TestNewUsersStageOptionsPassword(t *testing.T) {
 
 	// homer's password should still be nil (locked account)
 	assert.Nil(t, options.Users["homer"].Password)
+
+	// marge's password should be hashed with the selected algorithm
+	assert.True(t, strings.HasPrefix(*options.Users["marge"].Password, "$y$j9T$"))
+
+	// abe's password should be left alone (already hashed)
+	assert.Equal(t, YescryptPass, *options.Users["abe"].Password)
 }
 
 func TestGenUsersStageSameAsNewUsersStageOptions(t *testing.T) {
@@ -0,0 +1,57 @@
+package osbuild
+
+import (
+	"fmt"
+
+	"github.com/osbuild/images/pkg/disk"
+)
+
+// Write the content of a file to a device
+
+type WriteDeviceStageOptions struct {
+	// The file to write, e.g. input://tree/rootfs.img
+	From string `json:"from"`
+}
+
+func (WriteDeviceStageOptions) isStageOptions() {}
+
+func NewWriteDeviceStage(options *WriteDeviceStageOptions, inputs Inputs, devices map[string]Device) *Stage {
+	return &Stage{
+		Type:    "org.osbuild.write-device",
+		Options: options,
+		Inputs:  inputs,
+		Devices: devices,
+	}
+}
+
+// GenImageReadOnlyRootStage returns the stage that writes the read-only root
+// filesystem image, the file inputFilename of the tree of inputPipeline, to
+// the device of the root filesystem of the partition table. It returns nil if
+// the root filesystem is not read-only, see
+// disk.PartitionTable.ReadOnlyRoot().
+func GenImageReadOnlyRootStage(pt *disk.PartitionTable, filename, inputPipeline, inputFilename string) *Stage {
+	if !pt.ReadOnlyRoot() {
+		return nil
+	}
+
+	var stage *Stage
+	genStage := func(mnt disk.Mountable, path []disk.Entity) error {
+		if mnt.GetMountpoint() != "/" {
+			return nil
+		}
+		stageDevices, lastName := getDevices(path, filename, true)
+
+		// the device that is written to must be named "device"
+		lastDevice := stageDevices[lastName]
+		delete(stageDevices, lastName)
+		stageDevices["device"] = lastDevice
+
+		options := &WriteDeviceStageOptions{
+			From: fmt.Sprintf("input://tree/%s", inputFilename),
+		}
+		stage = NewWriteDeviceStage(options, NewPipelineTreeInputs("tree", inputPipeline), stageDevices)
+		return nil
+	}
+	_ = pt.ForEachMountable(genStage)
+	return stage
+}
@@ -0,0 +1,92 @@
+package osbuild
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/internal/testdisk"
+	"github.com/osbuild/images/pkg/disk"
+)
+
+func TestGenImageReadOnlyRootStage(t *testing.T) {
+	pt := testdisk.MakeFakeEROFSPartitionTable("/boot", "/")
+	stage := GenImageReadOnlyRootStage(pt, "disk.img", "rootfs", "rootfs.img")
+	assert.Equal(t, &Stage{
+		Type: "org.osbuild.write-device",
+		Options: &WriteDeviceStageOptions{
+			From: "input://tree/rootfs.img",
+		},
+		Inputs: NewPipelineTreeInputs("tree", "rootfs"),
+		Devices: map[string]Device{
+			"device": *NewLoopbackDevice(&LoopbackDeviceOptions{
+				Filename: "disk.img",
+				Size:     testdisk.FakePartitionSize / 512,
+				Lock:     true,
+			}),
+		},
+	}, stage)
+
+	assert.Nil(t, GenImageReadOnlyRootStage(testdisk.MakeFakePartitionTable("/boot", "/"), "disk.img", "rootfs", "rootfs.img"))
+}
+
+func TestReadOnlyRootNotMounted(t *testing.T) {
+	pt := testdisk.MakeFakeEROFSPartitionTable("/", "/boot", "/boot/efi", "/var")
+
+	fsRootMntName, mounts, _, err := GenMountsDevicesFromPT("disk.img", pt)
+	require.NoError(t, err)
+	assert.Equal(t, "", fsRootMntName)
+	assert.Equal(t, []Mount{
+		{Name: "boot", Type: "org.osbuild.ext4", Source: "boot", Target: "/boot"},
+		{Name: "boot-efi", Type: "org.osbuild.fat", Source: "boot-efi", Target: "/boot/efi"},
+		{Name: "var", Type: "org.osbuild.ext4", Source: "var", Target: "/var"},
+	}, mounts)
+
+	// the content of /boot/efi is copied with /boot
+	options, _, _ := GenCopyFSTreeOptions("root-tree", "os", "disk.img", pt)
+	assert.Equal(t, &CopyStageOptions{
+		Paths: []CopyStagePath{
+			{From: "input://root-tree/boot/", To: "mount://boot/"},
+			{From: "input://root-tree/var/", To: "mount://var/"},
+		},
+	}, options)
+
+	// no mkfs stage for the root filesystem
+	assert.Equal(t, []string{"org.osbuild.mkfs.ext4", "org.osbuild.mkfs.fat", "org.osbuild.mkfs.ext4"}, stageTypes(GenFsStages(pt, "disk.img")))
+}
+
+func TestGenImageKernelOptionsReadOnlyRoot(t *testing.T) {
+	pt := testdisk.MakeFakeEROFSPartitionTable("/", "/boot")
+	pt.ReadOnlyRootPartition().UUID = "6264D520-3FB9-423F-8AB8-7A0A8E3D3562"
+	rootUUID, cmdline, err := GenImageKernelOptions(pt, false)
+	require.NoError(t, err)
+	assert.Equal(t, disk.RootPartitionUUID, rootUUID)
+	assert.Equal(t, []string{"rootfstype=erofs", "systemd.volatile=overlay", "root=PARTUUID=6264d520-3fb9-423f-8ab8-7a0a8e3d3562"}, cmdline)
+
+	// with a writable /var there is no need for an overlay
+	pt = testdisk.MakeFakeEROFSPartitionTable("/", "/boot", "/var")
+	pt.ReadOnlyRootPartition().UUID = "6264D520-3FB9-423F-8AB8-7A0A8E3D3562"
+	_, cmdline, err = GenImageKernelOptions(pt, true)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"rootfstype=erofs", "root=PARTUUID=6264d520-3fb9-423f-8ab8-7a0a8e3d3562", "rootflags=ro"}, cmdline)
+}
+
+func TestFSTabStageOptionsReadOnlyRoot(t *testing.T) {
+	pt := testdisk.MakeFakeEROFSPartitionTable("/", "/boot")
+	options, err := NewFSTabStageOptions(pt)
+	require.NoError(t, err)
+	var paths []string
+	for _, fs := range options.FileSystems {
+		paths = append(paths, fs.Path)
+	}
+	assert.Equal(t, []string{"/boot"}, paths)
+}
+
+func stageTypes(stages []*Stage) []string {
+	var types []string
+	for _, stage := range stages {
+		types = append(types, stage.Type)
+	}
+	return types
+}
@@ -7,6 +7,7 @@ import (
 type Aarch64 struct {
 	BasePlatform
 	UEFIVendor string
+	Bootloader Bootloader
 }
 
 func (p *Aarch64) GetArch() arch.Arch {
@@ -20,20 +21,39 @@ func (p *Aarch64) GetUEFIVendor() string {
 func (p *Aarch64) GetPackages() []string {
 	packages := p.BasePlatform.FirmwarePackages
 
-	if p.UEFIVendor != "" {
+	switch p.GetBootloader() {
+	case BOOTLOADER_GRUB2:
+		if p.UEFIVendor != "" {
+			packages = append(packages,
+				"dracut-config-generic",
+				"efibootmgr",
+				"grub2-efi-aa64",
+				"grub2-tools",
+				"shim-aa64")
+		}
+	case BOOTLOADER_UKI:
 		packages = append(packages,
-			"dracut-config-generic",
 			"efibootmgr",
-			"grub2-efi-aa64",
-			"grub2-tools",
-			"shim-aa64")
+			"kernel-uki-virt-addons", // provides useful cmdline utilities for the UKI
+			"shim-aa64",
+			"uki-direct",
+		)
+	case BOOTLOADER_SYSTEMD_BOOT:
+		packages = append(packages,
+			"kernel-uki-virt-addons", // provides useful cmdline utilities for the UKI
+			"systemd-boot-unsigned",
+			"uki-direct",
+		)
 	}
 
 	return packages
 }
 
 func (p *Aarch64) GetBootloader() Bootloader {
-	return BOOTLOADER_GRUB2
+	if p.Bootloader == BOOTLOADER_NONE {
+		return BOOTLOADER_GRUB2
+	}
+	return p.Bootloader
 }
 
 type Aarch64_Fedora struct {
@@ -31,8 +31,16 @@ const ( // bootloader enum
 	BOOTLOADER_GRUB2
 	BOOTLOADER_ZIPL
 	BOOTLOADER_UKI
+	// BOOTLOADER_SYSTEMD_BOOT boots a UKI with systemd-boot instead of shim
+	BOOTLOADER_SYSTEMD_BOOT
 )
 
+// IsUKI returns true if the bootloader boots a Unified Kernel Image, which
+// is installed into the ESP instead of /boot.
+func (b Bootloader) IsUKI() bool {
+	return b == BOOTLOADER_UKI || b == BOOTLOADER_SYSTEMD_BOOT
+}
+
 func (b *Bootloader) UnmarshalJSON(data []byte) (err error) {
 	var s string
 	if err := json.Unmarshal(data, &s); err != nil {
@@ -55,6 +63,8 @@ func FromString(b string) (Bootloader, error) {
 		return BOOTLOADER_ZIPL, nil
 	case "uki":
 		return BOOTLOADER_UKI, nil
+	case "systemd-boot":
+		return BOOTLOADER_SYSTEMD_BOOT, nil
 	case "", "none":
 		return BOOTLOADER_NONE, nil
 	default:
@@ -47,3 +47,38 @@ func TestImageFormatUnmarshal(t *testing.T) {
 		assert.Equal(t, ifmt, f)
 	}
 }
+
+func TestBootloaderFromString(t *testing.T) {
+	for inp, expected := range map[string]platform.Bootloader{
+		"":             platform.BOOTLOADER_NONE,
+		"grub2":        platform.BOOTLOADER_GRUB2,
+		"zipl":         platform.BOOTLOADER_ZIPL,
+		"uki":          platform.BOOTLOADER_UKI,
+		"systemd-boot": platform.BOOTLOADER_SYSTEMD_BOOT,
+	} {
+		bl, err := platform.FromString(inp)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, bl)
+	}
+	_, err := platform.FromString("lilo")
+	assert.EqualError(t, err, `unsupported bootloader "lilo"`)
+}
+
+func TestBootloaderIsUKI(t *testing.T) {
+	assert.False(t, platform.BOOTLOADER_GRUB2.IsUKI())
+	assert.False(t, platform.BOOTLOADER_ZIPL.IsUKI())
+	assert.True(t, platform.BOOTLOADER_UKI.IsUKI())
+	assert.True(t, platform.BOOTLOADER_SYSTEMD_BOOT.IsUKI())
+}
+
+func TestAarch64UKIPackages(t *testing.T) {
+	p := &platform.Aarch64{UEFIVendor: "fedora"}
+	assert.Equal(t, platform.BOOTLOADER_GRUB2, p.GetBootloader())
+	assert.Contains(t, p.GetPackages(), "grub2-efi-aa64")
+
+	p.Bootloader = platform.BOOTLOADER_UKI
+	assert.Equal(t, []string{"efibootmgr", "kernel-uki-virt-addons", "shim-aa64", "uki-direct"}, p.GetPackages())
+
+	p.Bootloader = platform.BOOTLOADER_SYSTEMD_BOOT
+	assert.Equal(t, []string{"kernel-uki-virt-addons", "systemd-boot-unsigned", "uki-direct"}, p.GetPackages())
+}
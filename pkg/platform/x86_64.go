@@ -51,6 +51,12 @@ func (p *X86) GetPackages() []string {
 			"shim-x64",
 			"uki-direct",
 		)
+	case BOOTLOADER_SYSTEMD_BOOT:
+		packages = append(packages,
+			"kernel-uki-virt-addons", // provides useful cmdline utilities for the UKI
+			"systemd-boot-unsigned",
+			"uki-direct",
+		)
 	}
 
 	return packages
@@ -0,0 +1,140 @@
+package sbom
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// Container is a container image that is embedded in an image. It is
+// referenced from the SBOM documents as an external component, the content
+// of the container is described by its own SBOM.
+type Container struct {
+	// Source of the container, without a tag or digest
+	// (e.g. "registry.example.com/org/app")
+	Source string
+	// Resolved digest of the container manifest
+	Digest string
+}
+
+// ociPURL returns the package URL for a container.
+// See https://github.com/package-url/purl-spec/blob/master/PURL-TYPES.rst#oci
+func (c Container) ociPURL() string {
+	name := strings.ToLower(path.Base(c.Source))
+	qualifiers := url.Values{}
+	qualifiers.Set("repository_url", c.Source)
+	return fmt.Sprintf("pkg:oci/%s@%s?%s", url.PathEscape(name), url.QueryEscape(c.Digest), qualifiers.Encode())
+}
+
+// AddContainers adds the containers to the document as external
+// components.
+func (d *Document) AddContainers(containers []Container) error {
+	if len(containers) == 0 {
+		return nil
+	}
+
+	var err error
+	switch d.DocType {
+	case StandardTypeSpdx:
+		err = d.addSpdxContainers(containers)
+	case StandardTypeCycloneDX:
+		err = d.addCycloneDXContainers(containers)
+	default:
+		err = fmt.Errorf("unsupported SBOM document type: %s", d.DocType)
+	}
+	if err != nil {
+		return fmt.Errorf("cannot add containers to %s document: %w", d.DocType, err)
+	}
+	return nil
+}
+
+func (d *Document) addCycloneDXContainers(containers []Container) error {
+	var bom cdxBOM
+	if err := json.Unmarshal(d.Document, &bom); err != nil {
+		return err
+	}
+
+	for _, c := range containers {
+		purl := c.ociPURL()
+		component := cdxComponent{
+			BOMRef:  purl,
+			Type:    "container",
+			Name:    c.Source,
+			Version: c.Digest,
+			PURL:    purl,
+			ExternalReferences: []cdxExternalReference{
+				{
+					Type: "distribution",
+					URL:  fmt.Sprintf("%s@%s", c.Source, c.Digest),
+				},
+			},
+		}
+		if hash := cdxHashFromChecksum(c.Digest); hash != nil {
+			component.Hashes = []cdxHash{*hash}
+		}
+		bom.Components = append(bom.Components, component)
+	}
+
+	doc, err := newCycloneDXDocumentFromBOM(&bom)
+	if err != nil {
+		return err
+	}
+	*d = *doc
+	return nil
+}
+
+// addSpdxContainers adds the containers as packages to an SPDX 2.x
+// document. The document is generated by the depsolver, so only the
+// relevant keys are touched and everything else is kept as it is.
+func (d *Document) addSpdxContainers(containers []Container) error {
+	var doc map[string]any
+	if err := json.Unmarshal(d.Document, &doc); err != nil {
+		return err
+	}
+
+	packages, _ := doc["packages"].([]any)
+	relationships, _ := doc["relationships"].([]any)
+	for idx, c := range containers {
+		spdxID := fmt.Sprintf("SPDXRef-Container-%d", idx)
+		pkg := map[string]any{
+			"SPDXID":                spdxID,
+			"name":                  c.Source,
+			"versionInfo":           c.Digest,
+			"downloadLocation":      "NOASSERTION",
+			"filesAnalyzed":         false,
+			"primaryPackagePurpose": "CONTAINER",
+			"externalRefs": []any{
+				map[string]any{
+					"referenceCategory": "PACKAGE-MANAGER",
+					"referenceType":     "purl",
+					"referenceLocator":  c.ociPURL(),
+				},
+			},
+		}
+		if algo, value, ok := strings.Cut(c.Digest, ":"); ok {
+			pkg["checksums"] = []any{
+				map[string]any{
+					"algorithm":     strings.ToUpper(algo),
+					"checksumValue": value,
+				},
+			}
+		}
+		packages = append(packages, pkg)
+		relationships = append(relationships, map[string]any{
+			"spdxElementId":      "SPDXRef-DOCUMENT",
+			"relationshipType":   "DESCRIBES",
+			"relatedSpdxElement": spdxID,
+		})
+	}
+	doc["packages"] = packages
+	doc["relationships"] = relationships
+
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	d.Document = data
+	return nil
+}
@@ -0,0 +1,92 @@
+package sbom
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testContainers = []Container{
+	{
+		Source: "registry.example.com/org/App",
+		Digest: "sha256:0a1b2c3d4e5f60718293a4b5c6d7e8f90a1b2c3d4e5f60718293a4b5c6d7e8f9",
+	},
+}
+
+func TestContainerOCIPURL(t *testing.T) {
+	assert.Equal(t,
+		"pkg:oci/app@sha256%3A0a1b2c3d4e5f60718293a4b5c6d7e8f90a1b2c3d4e5f60718293a4b5c6d7e8f9?repository_url=registry.example.com%2Forg%2FApp",
+		testContainers[0].ociPURL())
+}
+
+func TestAddContainersCycloneDX(t *testing.T) {
+	doc, err := NewCycloneDXDocument("test", "fedora-41", testPackages)
+	require.NoError(t, err)
+	serial := func(doc *Document) string {
+		var bom cdxBOM
+		require.NoError(t, json.Unmarshal(doc.Document, &bom))
+		return bom.SerialNumber
+	}
+	before := serial(doc)
+
+	require.NoError(t, doc.AddContainers(testContainers))
+
+	var bom cdxBOM
+	require.NoError(t, json.Unmarshal(doc.Document, &bom))
+	require.Len(t, bom.Components, 3)
+	assert.Equal(t, cdxComponent{
+		BOMRef:  testContainers[0].ociPURL(),
+		Type:    "container",
+		Name:    "registry.example.com/org/App",
+		Version: testContainers[0].Digest,
+		PURL:    testContainers[0].ociPURL(),
+		Hashes: []cdxHash{
+			{Alg: "SHA-256", Content: "0a1b2c3d4e5f60718293a4b5c6d7e8f90a1b2c3d4e5f60718293a4b5c6d7e8f9"},
+		},
+		ExternalReferences: []cdxExternalReference{
+			{Type: "distribution", URL: "registry.example.com/org/App@" + testContainers[0].Digest},
+		},
+	}, bom.Components[2])
+	assert.NotEqual(t, before, serial(doc))
+}
+
+func TestAddContainersSpdx(t *testing.T) {
+	doc, err := NewDocument(StandardTypeSpdx, json.RawMessage(`{"spdxVersion":"SPDX-2.3","packages":[{"SPDXID":"SPDXRef-RPM-bash"}]}`))
+	require.NoError(t, err)
+
+	require.NoError(t, doc.AddContainers(testContainers))
+
+	var spdx map[string]any
+	require.NoError(t, json.Unmarshal(doc.Document, &spdx))
+	assert.Equal(t, "SPDX-2.3", spdx["spdxVersion"])
+	packages := spdx["packages"].([]any)
+	require.Len(t, packages, 2)
+	container := packages[1].(map[string]any)
+	assert.Equal(t, "SPDXRef-Container-0", container["SPDXID"])
+	assert.Equal(t, "registry.example.com/org/App", container["name"])
+	assert.Equal(t, testContainers[0].Digest, container["versionInfo"])
+	assert.Equal(t, []any{
+		map[string]any{
+			"referenceCategory": "PACKAGE-MANAGER",
+			"referenceType":     "purl",
+			"referenceLocator":  testContainers[0].ociPURL(),
+		},
+	}, container["externalRefs"])
+	assert.Equal(t, []any{
+		map[string]any{
+			"spdxElementId":      "SPDXRef-DOCUMENT",
+			"relationshipType":   "DESCRIBES",
+			"relatedSpdxElement": "SPDXRef-Container-0",
+		},
+	}, spdx["relationships"])
+}
+
+func TestAddContainersNone(t *testing.T) {
+	raw := json.RawMessage(`{"sbom":"unchanged"}`)
+	doc, err := NewDocument(StandardTypeSpdx, raw)
+	require.NoError(t, err)
+	require.NoError(t, doc.AddContainers(nil))
+	assert.Equal(t, raw, doc.Document)
+}
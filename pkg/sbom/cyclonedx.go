@@ -0,0 +1,194 @@
+package sbom
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/osbuild/images/pkg/rpmmd"
+)
+
+const (
+	cycloneDXBOMFormat   = "CycloneDX"
+	cycloneDXSpecVersion = "1.5"
+)
+
+// cdxBOM is the subset of the CycloneDX 1.5 JSON format that is used to
+// describe the content of an image.
+// See https://cyclonedx.org/docs/1.5/json/
+type cdxBOM struct {
+	BOMFormat    string         `json:"bomFormat"`
+	SpecVersion  string         `json:"specVersion"`
+	SerialNumber string         `json:"serialNumber"`
+	Version      int            `json:"version"`
+	Metadata     *cdxMetadata   `json:"metadata,omitempty"`
+	Components   []cdxComponent `json:"components"`
+}
+
+type cdxMetadata struct {
+	Tools     *cdxTools     `json:"tools,omitempty"`
+	Component *cdxComponent `json:"component,omitempty"`
+}
+
+type cdxTools struct {
+	Components []cdxComponent `json:"components"`
+}
+
+type cdxComponent struct {
+	BOMRef             string                 `json:"bom-ref,omitempty"`
+	Type               string                 `json:"type"`
+	Name               string                 `json:"name"`
+	Version            string                 `json:"version,omitempty"`
+	PURL               string                 `json:"purl,omitempty"`
+	Hashes             []cdxHash              `json:"hashes,omitempty"`
+	ExternalReferences []cdxExternalReference `json:"externalReferences,omitempty"`
+}
+
+type cdxHash struct {
+	Alg     string `json:"alg"`
+	Content string `json:"content"`
+}
+
+type cdxExternalReference struct {
+	Type string `json:"type"`
+	URL  string `json:"url"`
+}
+
+// cdxHashAlgorithms maps the checksum types used in rpm metadata and
+// container digests to the CycloneDX hash algorithm names.
+var cdxHashAlgorithms = map[string]string{
+	"md5":    "MD5",
+	"sha1":   "SHA-1",
+	"sha256": "SHA-256",
+	"sha384": "SHA-384",
+	"sha512": "SHA-512",
+}
+
+// NewCycloneDXDocument creates a CycloneDX 1.5 document for the given
+// packages. The name describes the content of the document (e.g. the image
+// or pipeline name) and distro is the distribution the packages belong to
+// (e.g. "fedora-41"), which is used for the package URLs.
+//
+// The document does not contain a timestamp and its serial number is
+// derived from its content, so the same set of packages always results in
+// the same document.
+func NewCycloneDXDocument(name, distro string, packages []rpmmd.PackageSpec) (*Document, error) {
+	bom := cdxBOM{
+		BOMFormat:   cycloneDXBOMFormat,
+		SpecVersion: cycloneDXSpecVersion,
+		Version:     1,
+		Metadata: &cdxMetadata{
+			Tools: &cdxTools{
+				Components: []cdxComponent{
+					{
+						Type: "application",
+						Name: "osbuild/images",
+					},
+				},
+			},
+			Component: &cdxComponent{
+				BOMRef: name,
+				Type:   "operating-system",
+				Name:   name,
+			},
+		},
+		Components: make([]cdxComponent, 0, len(packages)),
+	}
+
+	for _, pkg := range packages {
+		bom.Components = append(bom.Components, cdxPackageComponent(distro, pkg))
+	}
+
+	return newCycloneDXDocumentFromBOM(&bom)
+}
+
+func newCycloneDXDocumentFromBOM(bom *cdxBOM) (*Document, error) {
+	// the serial number must not be part of the data it is derived from
+	bom.SerialNumber = ""
+	data, err := json.Marshal(bom)
+	if err != nil {
+		return nil, err
+	}
+	bom.SerialNumber = "urn:uuid:" + uuid.NewSHA1(uuid.NameSpaceURL, data).String()
+
+	doc, err := json.Marshal(bom)
+	if err != nil {
+		return nil, err
+	}
+	return NewDocument(StandardTypeCycloneDX, doc)
+}
+
+func cdxPackageComponent(distro string, pkg rpmmd.PackageSpec) cdxComponent {
+	evr := fmt.Sprintf("%s-%s", pkg.Version, pkg.Release)
+	version := evr
+	if pkg.Epoch > 0 {
+		version = fmt.Sprintf("%d:%s", pkg.Epoch, evr)
+	}
+
+	purl := rpmPURL(distro, pkg.Name, evr, pkg.Arch, pkg.Epoch)
+	component := cdxComponent{
+		BOMRef:  purl,
+		Type:    "library",
+		Name:    pkg.Name,
+		Version: version,
+		PURL:    purl,
+	}
+	if hash := cdxHashFromChecksum(pkg.Checksum); hash != nil {
+		component.Hashes = []cdxHash{*hash}
+	}
+	if pkg.RemoteLocation != "" {
+		component.ExternalReferences = []cdxExternalReference{
+			{
+				Type: "distribution",
+				URL:  pkg.RemoteLocation,
+			},
+		}
+	}
+	return component
+}
+
+// cdxHashFromChecksum converts a "<type>:<value>" checksum to a CycloneDX
+// hash, checksums of unknown types are dropped.
+func cdxHashFromChecksum(checksum string) *cdxHash {
+	algo, value, ok := strings.Cut(checksum, ":")
+	if !ok {
+		return nil
+	}
+	alg, ok := cdxHashAlgorithms[algo]
+	if !ok {
+		return nil
+	}
+	return &cdxHash{Alg: alg, Content: value}
+}
+
+// rpmPURL returns the package URL for an rpm.
+// See https://github.com/package-url/purl-spec/blob/master/PURL-TYPES.rst#rpm
+func rpmPURL(distro, name, evr, arch string, epoch uint) string {
+	namespace, _, _ := strings.Cut(distro, "-")
+
+	qualifiers := url.Values{}
+	if arch != "" {
+		qualifiers.Set("arch", arch)
+	}
+	if epoch > 0 {
+		qualifiers.Set("epoch", fmt.Sprint(epoch))
+	}
+	if distro != "" {
+		qualifiers.Set("distro", distro)
+	}
+
+	purl := "pkg:rpm/"
+	if namespace != "" {
+		purl += url.PathEscape(namespace) + "/"
+	}
+	purl += fmt.Sprintf("%s@%s", url.PathEscape(name), url.PathEscape(evr))
+	if len(qualifiers) > 0 {
+		// url.Values.Encode() sorts the qualifiers by key as required by
+		// the purl spec
+		purl += "?" + qualifiers.Encode()
+	}
+	return purl
+}
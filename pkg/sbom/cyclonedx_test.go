@@ -0,0 +1,79 @@
+package sbom
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/pkg/rpmmd"
+)
+
+var testPackages = []rpmmd.PackageSpec{
+	{
+		Name:           "bash",
+		Version:        "5.2.26",
+		Release:        "3.fc41",
+		Arch:           "x86_64",
+		Checksum:       "sha256:7cbb1b2e4a6ec1a2d2f4b8c7c1d8d5e0a1b9c6a5a8c7e1f2d3c4b5a6978e1f2a",
+		RemoteLocation: "https://example.com/Packages/bash-5.2.26-3.fc41.x86_64.rpm",
+	},
+	{
+		Name:     "shadow-utils",
+		Epoch:    2,
+		Version:  "4.15.1",
+		Release:  "12.fc41",
+		Arch:     "x86_64",
+		Checksum: "md4:deadbeef",
+	},
+}
+
+func TestNewCycloneDXDocument(t *testing.T) {
+	doc, err := NewCycloneDXDocument("fedora-41-qcow2-x86_64-os", "fedora-41", testPackages)
+	require.NoError(t, err)
+	assert.Equal(t, StandardTypeCycloneDX, doc.DocType)
+
+	var bom cdxBOM
+	require.NoError(t, json.Unmarshal(doc.Document, &bom))
+	assert.Equal(t, "CycloneDX", bom.BOMFormat)
+	assert.Equal(t, "1.5", bom.SpecVersion)
+	assert.Regexp(t, `^urn:uuid:[0-9a-f-]{36}$`, bom.SerialNumber)
+	assert.Equal(t, "fedora-41-qcow2-x86_64-os", bom.Metadata.Component.Name)
+
+	expected := []cdxComponent{
+		{
+			BOMRef:  "pkg:rpm/fedora/bash@5.2.26-3.fc41?arch=x86_64&distro=fedora-41",
+			Type:    "library",
+			Name:    "bash",
+			Version: "5.2.26-3.fc41",
+			PURL:    "pkg:rpm/fedora/bash@5.2.26-3.fc41?arch=x86_64&distro=fedora-41",
+			Hashes: []cdxHash{
+				{Alg: "SHA-256", Content: "7cbb1b2e4a6ec1a2d2f4b8c7c1d8d5e0a1b9c6a5a8c7e1f2d3c4b5a6978e1f2a"},
+			},
+			ExternalReferences: []cdxExternalReference{
+				{Type: "distribution", URL: "https://example.com/Packages/bash-5.2.26-3.fc41.x86_64.rpm"},
+			},
+		},
+		{
+			BOMRef:  "pkg:rpm/fedora/shadow-utils@4.15.1-12.fc41?arch=x86_64&distro=fedora-41&epoch=2",
+			Type:    "library",
+			Name:    "shadow-utils",
+			Version: "2:4.15.1-12.fc41",
+			PURL:    "pkg:rpm/fedora/shadow-utils@4.15.1-12.fc41?arch=x86_64&distro=fedora-41&epoch=2",
+		},
+	}
+	assert.Equal(t, expected, bom.Components)
+}
+
+func TestNewCycloneDXDocumentReproducible(t *testing.T) {
+	doc1, err := NewCycloneDXDocument("test", "fedora-41", testPackages)
+	require.NoError(t, err)
+	doc2, err := NewCycloneDXDocument("test", "fedora-41", testPackages)
+	require.NoError(t, err)
+	assert.Equal(t, doc1, doc2)
+
+	doc3, err := NewCycloneDXDocument("test", "fedora-41", testPackages[:1])
+	require.NoError(t, err)
+	assert.NotEqual(t, doc1.Document, doc3.Document)
+}
@@ -1,7 +1,6 @@
 package azure
 
 import (
-	"bufio"
 	"bytes"
 	"context"
 	// azure uses MD5 hashes
@@ -14,7 +13,6 @@ import (
 	"os"
 	"regexp"
 	"strings"
-	"sync"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
@@ -25,6 +23,7 @@ import (
 
 	"github.com/osbuild/images/internal/common"
 	"github.com/osbuild/images/pkg/datasizes"
+	"github.com/osbuild/images/pkg/upload/chunked"
 )
 
 // StorageClient is a client for the Azure Storage API,
@@ -81,16 +80,6 @@ func allZerosSlice(slice []byte) bool {
 // Note that if you want to create an image out of the page blob, make sure that metadata.BlobName
 // has a .vhd extension, see EnsureVHDExtension.
 func (c StorageClient) UploadPageBlob(metadata BlobMetadata, fileName string, threads int) error {
-	// Create a page blob client.
-	URL, _ := url.Parse(fmt.Sprintf("https://%s.blob.core.windows.net/%s/%s", metadata.StorageAccount, metadata.ContainerName, metadata.BlobName))
-	client, err := pageblob.NewClientWithSharedKeyCredential(URL.String(), c.credential, nil)
-	if err != nil {
-		return fmt.Errorf("cannot create a pageblob client: %w", err)
-	}
-
-	// Create the container, use a never-expiring context
-	ctx := context.Background()
-
 	// Open the image file for reading
 	imageFile, err := os.Open(fileName)
 	if err != nil {
@@ -104,94 +93,138 @@ func (c StorageClient) UploadPageBlob(metadata BlobMetadata, fileName string, th
 		return fmt.Errorf("cannot stat the image: %w", err)
 	}
 
-	if stat.Size()%512 != 0 {
+	// use a never-expiring context
+	return c.UploadPageBlobFromReader(context.Background(), metadata, imageFile, stat.Size(), &chunked.Options{
+		PartSize:    PageBlobMaxUploadPagesBytes,
+		Concurrency: threads,
+	})
+}
+
+// UploadPageBlobFromReader uploads size bytes read from r as a page blob,
+// see UploadPageBlob. The size must be known beforehand as the page blob is
+// created with a fixed size. The MD5 sum of the blob is computed while
+// reading and set once all pages are uploaded.
+//
+// The pages are uploaded using chunked.Upload(), set opts.StateFile to be
+// able to resume a failed upload. The part size must be aligned to 512
+// bytes.
+func (c StorageClient) UploadPageBlobFromReader(ctx context.Context, metadata BlobMetadata, r io.Reader, size int64, opts *chunked.Options) error {
+	if size%512 != 0 {
 		return errors.New("size for azure image must be aligned to 512 bytes")
 	}
+	if opts == nil {
+		opts = &chunked.Options{}
+	}
+	if opts.PartSize%512 != 0 {
+		return errors.New("part size for azure image must be aligned to 512 bytes")
+	}
+
+	URL, _ := url.Parse(fmt.Sprintf("https://%s.blob.core.windows.net/%s/%s", metadata.StorageAccount, metadata.ContainerName, metadata.BlobName))
+	client, err := pageblob.NewClientWithSharedKeyCredential(URL.String(), c.credential, nil)
+	if err != nil {
+		return fmt.Errorf("cannot create a pageblob client: %w", err)
+	}
 
-	// Hash the imageFile
+	// The hash is computed from all data, including the parts that are
+	// skipped when an upload is resumed, the tee reader hides the
+	// io.Seeker of r so the skipped parts are still read.
 	// azure uses MD5 hashes
 	/* #nosec G401 */
-	imageFileHash := md5.New()
-	if _, err := io.Copy(imageFileHash, imageFile); err != nil {
-		return fmt.Errorf("cannot create md5 of the image: %w", err)
+	imageHash := md5.New()
+	backend := &pageBlobBackend{
+		client: client,
+		size:   size,
 	}
-	// Move the cursor back to the start of the imageFile
-	if _, err := imageFile.Seek(0, 0); err != nil {
-		return fmt.Errorf("cannot seek the image: %w", err)
+	if err := chunked.Upload(ctx, backend, io.TeeReader(r, imageHash), size, opts); err != nil {
+		return err
+	}
+
+	_, err = client.SetHTTPHeaders(ctx, blob.HTTPHeaders{
+		BlobContentMD5: imageHash.Sum(nil),
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("cannot set the md5 sum of the page blob: %w", err)
 	}
 
+	return nil
+}
+
+// pageBlobBackend uploads the parts of a chunked upload as pages of a
+// page blob. The blob is created with its final size, so the parts are
+// written in place and nothing needs to be assembled at the end.
+type pageBlobBackend struct {
+	client *pageblob.Client
+	size   int64
+}
+
+var _ chunked.Backend = &pageBlobBackend{}
+
+func (b *pageBlobBackend) Target() string {
+	return b.client.URL()
+}
+
+func (b *pageBlobBackend) Create(ctx context.Context) (string, error) {
 	// Create page blob. Page blob is required for VM images
-	_, err = client.Create(ctx, stat.Size(), &pageblob.CreateOptions{
-		HTTPHeaders: &blob.HTTPHeaders{
-			BlobContentMD5: imageFileHash.Sum(nil),
-		},
-	})
+	_, err := b.client.Create(ctx, b.size, nil)
 	if err != nil {
-		return fmt.Errorf("cannot create a new page blob: %w", err)
+		return "", fmt.Errorf("cannot create a new page blob: %w", err)
 	}
+	// page blobs have no upload ID, the pages are written to the blob
+	// directly
+	return b.client.URL(), nil
+}
 
-	// Create control variables
-	// This channel simulates behavior of a semaphore and bounds the number of parallel threads
-	var semaphore = make(chan int, threads)
-	// Forward error from goroutine to the caller
-	var errorInGoroutine = make(chan error, 1)
-	var counter int64 = 0
-
-	// Create buffered reader to speed up the upload
-	reader := bufio.NewReader(imageFile)
-	// Run the upload
-	run := true
-	var wg sync.WaitGroup
-	for run {
-		buffer := make([]byte, PageBlobMaxUploadPagesBytes)
-		n, err := reader.Read(buffer)
-		if err != nil {
-			if err == io.EOF {
-				run = false
-			} else {
-				return fmt.Errorf("reading the image failed: %w", err)
-			}
-		}
-		if n == 0 {
-			break
-		}
+func (b *pageBlobBackend) UploadPart(ctx context.Context, uploadID string, part chunked.Part, r io.ReadSeeker) (string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
 
-		// Skip the uploading part if there are only zeros in the buffer.
+	for offset := 0; offset < len(data); offset += PageBlobMaxUploadPagesBytes {
+		page := data[offset:min(offset+PageBlobMaxUploadPagesBytes, len(data))]
+		// Skip the uploading part if there are only zeros in the page.
 		// We already defined the size of the blob in the initial call and the blob is zero-initialized,
 		// so this pushing zeros would actually be a no-op.
-		if allZerosSlice(buffer) {
-			counter++
+		if allZerosSlice(page) {
 			continue
 		}
 
-		wg.Add(1)
-		semaphore <- 1
-		go func(counter int64, buffer []byte, n int) {
-			defer wg.Done()
-			uploadRange := blob.HTTPRange{
-				Offset: counter * PageBlobMaxUploadPagesBytes,
-				Count:  int64(n),
-			}
-			_, err := client.UploadPages(ctx, common.NopSeekCloser(bytes.NewReader(buffer[:n])), uploadRange, nil)
-			if err != nil {
-				err = fmt.Errorf("uploading a page failed: %w", err)
-				// Send the error to the error channel in a non-blocking way. If there is already an error, just discard this one
-				select {
-				case errorInGoroutine <- err:
-				default:
-				}
-			}
-			<-semaphore
-		}(counter, buffer, n)
-		counter++
+		uploadRange := blob.HTTPRange{
+			Offset: part.Offset + int64(offset),
+			Count:  int64(len(page)),
+		}
+		_, err := b.client.UploadPages(ctx, common.NopSeekCloser(bytes.NewReader(page)), uploadRange, nil)
+		if err != nil {
+			return "", fmt.Errorf("uploading a page failed: %w", err)
+		}
 	}
-	// Wait for all goroutines to finish
-	wg.Wait()
-	// Check any errors during the transmission using a nonblocking read from the channel
-	select {
-	case err := <-errorInGoroutine:
-		return err
-	default:
+	return "", nil
+}
+
+func (b *pageBlobBackend) Complete(ctx context.Context, uploadID string, parts []chunked.Part) error {
+	return nil
+}
+
+func (b *pageBlobBackend) Abort(ctx context.Context, uploadID string) error {
+	_, err := b.client.Delete(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("cannot delete the page blob: %w", err)
+	}
+	return nil
+}
+
+// DeleteBlob deletes the given blob.
+func (c StorageClient) DeleteBlob(ctx context.Context, metadata BlobMetadata) error {
+	URL, _ := url.Parse(fmt.Sprintf("https://%s.blob.core.windows.net/%s/%s", metadata.StorageAccount, metadata.ContainerName, metadata.BlobName))
+
+	client, err := blob.NewClientWithSharedKeyCredential(URL.String(), c.credential, nil)
+	if err != nil {
+		return fmt.Errorf("cannot create a blob client: %w", err)
+	}
+
+	_, err = client.Delete(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("cannot delete the blob: %w", err)
 	}
 
 	return nil
@@ -1,11 +1,25 @@
 package azure
 
 import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
 	"regexp"
+	"strconv"
+	"sync"
 	"testing"
 
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/pageblob"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/pkg/upload/chunked"
 )
 
 func TestRandomStorageAccountName(t *testing.T) {
@@ -31,3 +45,95 @@ func TestEnsureVHDExtension(t *testing.T) {
 		})
 	}
 }
+
+// fakePageBlobServer stores the pages of a single page blob
+type fakePageBlobServer struct {
+	mu      sync.Mutex
+	blob    []byte
+	deleted bool
+	// failOffset rejects the page written at this offset
+	failOffset int64
+}
+
+func (fs *fakePageBlobServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	switch {
+	case r.Method == http.MethodPut && r.URL.Query().Get("comp") == "page":
+		var start, end int64
+		if _, err := fmt.Sscanf(r.Header.Get("x-ms-range"), "bytes=%d-%d", &start, &end); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if start == fs.failOffset {
+			http.Error(w, "injected failure", http.StatusForbidden)
+			return
+		}
+		data, err := io.ReadAll(r.Body)
+		if err != nil || int64(len(data)) != end-start+1 || start%512 != 0 {
+			http.Error(w, "invalid page", http.StatusBadRequest)
+			return
+		}
+		copy(fs.blob[start:], data)
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodPut:
+		size, err := strconv.ParseInt(r.Header.Get("x-ms-blob-content-length"), 10, 64)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		fs.blob = make([]byte, size)
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodDelete:
+		fs.deleted = true
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		http.Error(w, "unexpected request", http.StatusBadRequest)
+	}
+}
+
+func TestPageBlobBackend(t *testing.T) {
+	fs := &fakePageBlobServer{failOffset: -1}
+	srv := httptest.NewServer(fs)
+	defer srv.Close()
+
+	client, err := pageblob.NewClientWithNoCredential(srv.URL+"/container/image.vhd", &pageblob.ClientOptions{
+		ClientOptions: azcore.ClientOptions{
+			Retry: policy.RetryOptions{MaxRetries: -1},
+		},
+	})
+	require.NoError(t, err)
+
+	// the first page is all zeros and not uploaded
+	data := make([]byte, 3*PageBlobMaxUploadPagesBytes+1024)
+	for i := PageBlobMaxUploadPagesBytes; i < len(data); i++ {
+		data[i] = byte(i % 251)
+	}
+
+	backend := &pageBlobBackend{client: client, size: int64(len(data))}
+	stateFile := filepath.Join(t.TempDir(), "upload.json")
+	opts := &chunked.Options{
+		PartSize:  2 * PageBlobMaxUploadPagesBytes,
+		Retries:   -1,
+		StateFile: stateFile,
+	}
+
+	// the page in the second part fails, the upload can be resumed
+	fs.failOffset = 2 * PageBlobMaxUploadPagesBytes
+	err = chunked.Upload(context.Background(), backend, bytes.NewReader(data), int64(len(data)), opts)
+	require.ErrorContains(t, err, "uploading part 2 failed")
+	assert.False(t, fs.deleted)
+
+	fs.failOffset = -1
+	err = chunked.Upload(context.Background(), backend, bytes.NewReader(data), int64(len(data)), opts)
+	require.NoError(t, err)
+	assert.Equal(t, data, fs.blob)
+	assert.NoFileExists(t, stateFile)
+
+	// without a state file a failed upload removes the blob
+	fs.failOffset = 0
+	err = chunked.Upload(context.Background(), backend, bytes.NewReader(bytes.Repeat([]byte{1}, 1024)), 1024, &chunked.Options{Retries: -1})
+	require.ErrorContains(t, err, "uploading part 1 failed")
+	assert.True(t, fs.deleted)
+}
@@ -0,0 +1,219 @@
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/osbuild/images/pkg/cloud"
+	"github.com/osbuild/images/pkg/upload/chunked"
+)
+
+// DefaultContainerName is the storage container used by the uploader if
+// none is set.
+const DefaultContainerName = "imagebuilder"
+
+type azureUploader struct {
+	client azureClient
+
+	resourceGroup  string
+	storageAccount string
+	container      string
+	imageName      string
+	location       string
+	hyperVGen      HyperVGenerationType
+	size           int64
+	upload         *chunked.Options
+}
+
+type UploaderOptions struct {
+	// Size of the image in bytes, must be a multiple of 512. Page blobs
+	// are created with a fixed size, so it must be known before the
+	// upload starts.
+	Size int64
+	// Container in the storage account, defaults to DefaultContainerName
+	Container string
+	// Location of the image, defaults to the location of the resource
+	// group
+	Location string
+	// HyperVGen defaults to HyperVGenV2
+	HyperVGen HyperVGenerationType
+	// Upload configures the chunked upload of the pages, for example to
+	// make it resumable or to report the progress. Defaults to parts of
+	// PageBlobMaxUploadPagesBytes uploaded by DefaultUploadThreads
+	// goroutines.
+	Upload *chunked.Options
+}
+
+// testing support
+type azureClient interface {
+	GetResourceGroupLocation(ctx context.Context, resourceGroup string) (string, error)
+	GetStorageAccountKey(ctx context.Context, resourceGroup string, storageAccount string) (string, error)
+	RegisterImage(ctx context.Context, resourceGroup, storageAccount, storageContainer, blobName, imageName, location string, hyperVGen HyperVGenerationType) error
+}
+
+type azureStorageClient interface {
+	CreateStorageContainerIfNotExist(ctx context.Context, storageAccount, name string) error
+	UploadPageBlobFromReader(ctx context.Context, metadata BlobMetadata, r io.Reader, size int64, opts *chunked.Options) error
+	DeleteBlob(ctx context.Context, metadata BlobMetadata) error
+}
+
+var newAzureClient = func(credentials Credentials, tenantID, subscriptionID string) (azureClient, error) {
+	return NewClient(credentials, tenantID, subscriptionID)
+}
+
+var newAzureStorageClient = func(storageAccount, storageAccessKey string) (azureStorageClient, error) {
+	return NewStorageClient(storageAccount, storageAccessKey)
+}
+
+// NewUploader returns an uploader that uploads the image as a page blob
+// to the given storage account and registers it as an image in the
+// resource group. The blob is removed once the image is registered.
+func NewUploader(credentials Credentials, tenantID, subscriptionID, resourceGroup, storageAccount, imageName string, opts *UploaderOptions) (cloud.Uploader, error) {
+	if opts == nil {
+		opts = &UploaderOptions{}
+	}
+	if opts.Size <= 0 || opts.Size%512 != 0 {
+		return nil, fmt.Errorf("image size must be a positive multiple of 512, got %d", opts.Size)
+	}
+	hyperVGen := opts.HyperVGen
+	switch hyperVGen {
+	case "":
+		hyperVGen = HyperVGenV2
+	case HyperVGenV1, HyperVGenV2:
+	default:
+		return nil, fmt.Errorf("unknown hyper v generation type %v", hyperVGen)
+	}
+	container := opts.Container
+	if container == "" {
+		container = DefaultContainerName
+	}
+	upload := opts.Upload
+	if upload == nil {
+		upload = &chunked.Options{
+			PartSize:    PageBlobMaxUploadPagesBytes,
+			Concurrency: DefaultUploadThreads,
+		}
+	}
+
+	client, err := newAzureClient(credentials, tenantID, subscriptionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &azureUploader{
+		client:         client,
+		resourceGroup:  resourceGroup,
+		storageAccount: storageAccount,
+		container:      container,
+		imageName:      imageName,
+		location:       opts.Location,
+		hyperVGen:      hyperVGen,
+		size:           opts.Size,
+		upload:         upload,
+	}, nil
+}
+
+var _ cloud.Uploader = &azureUploader{}
+
+func (au *azureUploader) Check(status io.Writer) error {
+	ctx := context.Background()
+
+	fmt.Fprintf(status, "Checking resource group...\n")
+	if _, err := au.client.GetResourceGroupLocation(ctx, au.resourceGroup); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(status, "Checking storage account permissions...\n")
+	if _, err := au.client.GetStorageAccountKey(ctx, au.resourceGroup, au.storageAccount); err != nil {
+		return err
+	}
+	fmt.Fprintf(status, "Upload conditions met.\n")
+	return nil
+}
+
+func (au *azureUploader) UploadAndRegister(r io.Reader, status io.Writer) (err error) {
+	ctx := context.Background()
+
+	key, err := au.client.GetStorageAccountKey(ctx, au.resourceGroup, au.storageAccount)
+	if err != nil {
+		return err
+	}
+	storageClient, err := newAzureStorageClient(au.storageAccount, key)
+	if err != nil {
+		return err
+	}
+
+	if err := storageClient.CreateStorageContainerIfNotExist(ctx, au.storageAccount, au.container); err != nil {
+		return err
+	}
+
+	metadata := BlobMetadata{
+		StorageAccount: au.storageAccount,
+		ContainerName:  au.container,
+		BlobName:       EnsureVHDExtension(au.imageName),
+	}
+	fmt.Fprintf(status, "Uploading %s to %s/%s\n", au.imageName, au.container, metadata.BlobName)
+	if err := storageClient.UploadPageBlobFromReader(ctx, metadata, r, au.size, au.upload); err != nil {
+		return err
+	}
+	defer func() {
+		fmt.Fprintf(status, "Deleting %s/%s\n", au.container, metadata.BlobName)
+		if deleteErr := storageClient.DeleteBlob(ctx, metadata); deleteErr != nil && err == nil {
+			err = deleteErr
+		}
+	}()
+
+	fmt.Fprintf(status, "Registering image %s\n", au.imageName)
+	err = au.client.RegisterImage(ctx, au.resourceGroup, au.storageAccount, au.container, metadata.BlobName, au.imageName, au.location, au.hyperVGen)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(status, "Image %s registered in resource group %s\n", au.imageName, au.resourceGroup)
+	return nil
+}
+
+// UploaderConfig is the configuration of the "azure" upload target, see
+// cloud.NewUploader().
+type UploaderConfig struct {
+	TenantID       string `json:"tenant_id"`
+	SubscriptionID string `json:"subscription_id"`
+	ResourceGroup  string `json:"resource_group"`
+	StorageAccount string `json:"storage_account"`
+	// Path to the credentials file, see ParseAzureCredentialsFile()
+	CredentialsFile  string               `json:"credentials_file"`
+	Size             int64                `json:"size"`
+	Container        string               `json:"container,omitempty"`
+	Location         string               `json:"location,omitempty"`
+	HyperVGeneration HyperVGenerationType `json:"hyperv_generation,omitempty"`
+	// Upload makes the upload resumable, see chunked.Options
+	Upload *chunked.Options `json:"upload,omitempty"`
+}
+
+func newUploaderFromConfig(imageName string, data json.RawMessage) (cloud.Uploader, error) {
+	var config UploaderConfig
+	if err := cloud.UnmarshalUploaderConfig(data, &config); err != nil {
+		return nil, err
+	}
+	if config.TenantID == "" || config.SubscriptionID == "" || config.ResourceGroup == "" || config.StorageAccount == "" || config.CredentialsFile == "" {
+		return nil, fmt.Errorf("tenant_id, subscription_id, resource_group, storage_account and credentials_file are required")
+	}
+
+	credentials, err := ParseAzureCredentialsFile(config.CredentialsFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewUploader(*credentials, config.TenantID, config.SubscriptionID, config.ResourceGroup, config.StorageAccount, imageName, &UploaderOptions{
+		Size:      config.Size,
+		Container: config.Container,
+		Location:  config.Location,
+		HyperVGen: config.HyperVGeneration,
+		Upload:    config.Upload,
+	})
+}
+
+func init() {
+	cloud.RegisterUploader("azure", newUploaderFromConfig)
+}
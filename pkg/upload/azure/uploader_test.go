@@ -0,0 +1,214 @@
+package azure_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/pkg/cloud"
+	"github.com/osbuild/images/pkg/upload/azure"
+	"github.com/osbuild/images/pkg/upload/chunked"
+)
+
+type fakeAzureClient struct {
+	locationErr error
+	keyErr      error
+	registerErr error
+
+	registeredBlob      string
+	registeredImage     string
+	registeredHyperVGen azure.HyperVGenerationType
+}
+
+func (fc *fakeAzureClient) GetResourceGroupLocation(ctx context.Context, resourceGroup string) (string, error) {
+	return "westeurope", fc.locationErr
+}
+
+func (fc *fakeAzureClient) GetStorageAccountKey(ctx context.Context, resourceGroup string, storageAccount string) (string, error) {
+	return "key", fc.keyErr
+}
+
+func (fc *fakeAzureClient) RegisterImage(ctx context.Context, resourceGroup, storageAccount, storageContainer, blobName, imageName, location string, hyperVGen azure.HyperVGenerationType) error {
+	if fc.registerErr != nil {
+		return fc.registerErr
+	}
+	fc.registeredBlob = storageContainer + "/" + blobName
+	fc.registeredImage = imageName
+	fc.registeredHyperVGen = hyperVGen
+	return nil
+}
+
+type fakeAzureStorageClient struct {
+	containers   []string
+	uploadedData []byte
+	uploadedBlob string
+	uploadedSize int64
+	uploadOpts   *chunked.Options
+	deleted      []string
+}
+
+func (fs *fakeAzureStorageClient) CreateStorageContainerIfNotExist(ctx context.Context, storageAccount, name string) error {
+	fs.containers = append(fs.containers, name)
+	return nil
+}
+
+func (fs *fakeAzureStorageClient) UploadPageBlobFromReader(ctx context.Context, metadata azure.BlobMetadata, r io.Reader, size int64, opts *chunked.Options) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	fs.uploadedData = data
+	fs.uploadedBlob = metadata.ContainerName + "/" + metadata.BlobName
+	fs.uploadedSize = size
+	fs.uploadOpts = opts
+	return nil
+}
+
+func (fs *fakeAzureStorageClient) DeleteBlob(ctx context.Context, metadata azure.BlobMetadata) error {
+	fs.deleted = append(fs.deleted, metadata.ContainerName+"/"+metadata.BlobName)
+	return nil
+}
+
+func mockAzureClients(t *testing.T, fc *fakeAzureClient, fs *fakeAzureStorageClient) {
+	restore := azure.MockNewAzureClient(func(azure.Credentials, string, string) (azure.AzureClient, error) {
+		return fc, nil
+	})
+	t.Cleanup(restore)
+	restore = azure.MockNewAzureStorageClient(func(account, key string) (azure.AzureStorageClient, error) {
+		return fs, nil
+	})
+	t.Cleanup(restore)
+}
+
+func newTestUploader(t *testing.T, opts *azure.UploaderOptions) cloud.Uploader {
+	uploader, err := azure.NewUploader(azure.Credentials{}, "tenant", "subscription", "rg", "account", "image", opts)
+	require.NoError(t, err)
+	return uploader
+}
+
+func TestUploaderCheck(t *testing.T) {
+	testCases := []struct {
+		name        string
+		client      *fakeAzureClient
+		expectedErr string
+	}{
+		{
+			name:   "happy",
+			client: &fakeAzureClient{},
+		},
+		{
+			name:        "no-resource-group",
+			client:      &fakeAzureClient{locationErr: fmt.Errorf("location-error")},
+			expectedErr: "location-error",
+		},
+		{
+			name:        "no-key",
+			client:      &fakeAzureClient{keyErr: fmt.Errorf("key-error")},
+			expectedErr: "key-error",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockAzureClients(t, tc.client, &fakeAzureStorageClient{})
+			uploader := newTestUploader(t, &azure.UploaderOptions{Size: 1024})
+
+			var status bytes.Buffer
+			err := uploader.Check(&status)
+			if tc.expectedErr != "" {
+				assert.EqualError(t, err, tc.expectedErr)
+			} else {
+				assert.NoError(t, err)
+				assert.Contains(t, status.String(), "Upload conditions met.")
+			}
+		})
+	}
+}
+
+func TestUploaderUploadAndRegister(t *testing.T) {
+	fc := &fakeAzureClient{}
+	fs := &fakeAzureStorageClient{}
+	mockAzureClients(t, fc, fs)
+	uploader := newTestUploader(t, &azure.UploaderOptions{Size: 512})
+
+	data := bytes.Repeat([]byte{1}, 512)
+	err := uploader.UploadAndRegister(bytes.NewReader(data), io.Discard)
+	require.NoError(t, err)
+	assert.Equal(t, []string{azure.DefaultContainerName}, fs.containers)
+	assert.Equal(t, data, fs.uploadedData)
+	assert.Equal(t, int64(512), fs.uploadedSize)
+	assert.Equal(t, "imagebuilder/image.vhd", fs.uploadedBlob)
+	assert.Equal(t, "imagebuilder/image.vhd", fc.registeredBlob)
+	assert.Equal(t, "image", fc.registeredImage)
+	assert.Equal(t, azure.HyperVGenV2, fc.registeredHyperVGen)
+	assert.Equal(t, []string{"imagebuilder/image.vhd"}, fs.deleted)
+	assert.Equal(t, &chunked.Options{PartSize: azure.PageBlobMaxUploadPagesBytes, Concurrency: azure.DefaultUploadThreads}, fs.uploadOpts)
+}
+
+func TestUploaderUploadAndRegisterResumable(t *testing.T) {
+	fs := &fakeAzureStorageClient{}
+	mockAzureClients(t, &fakeAzureClient{}, fs)
+	opts := &chunked.Options{StateFile: "/tmp/upload.json"}
+	uploader := newTestUploader(t, &azure.UploaderOptions{Size: 512, Upload: opts})
+
+	err := uploader.UploadAndRegister(bytes.NewReader(make([]byte, 512)), io.Discard)
+	require.NoError(t, err)
+	assert.Equal(t, opts, fs.uploadOpts)
+}
+
+func TestUploaderUploadAndRegisterError(t *testing.T) {
+	fc := &fakeAzureClient{registerErr: fmt.Errorf("register-error")}
+	fs := &fakeAzureStorageClient{}
+	mockAzureClients(t, fc, fs)
+	uploader := newTestUploader(t, &azure.UploaderOptions{Size: 512, Container: "container"})
+
+	err := uploader.UploadAndRegister(bytes.NewReader(make([]byte, 512)), io.Discard)
+	assert.EqualError(t, err, "register-error")
+	assert.Equal(t, []string{"container/image.vhd"}, fs.deleted)
+}
+
+func TestNewUploaderInvalidOptions(t *testing.T) {
+	mockAzureClients(t, &fakeAzureClient{}, &fakeAzureStorageClient{})
+
+	_, err := azure.NewUploader(azure.Credentials{}, "tenant", "subscription", "rg", "account", "image", nil)
+	assert.EqualError(t, err, "image size must be a positive multiple of 512, got 0")
+	_, err = azure.NewUploader(azure.Credentials{}, "tenant", "subscription", "rg", "account", "image", &azure.UploaderOptions{Size: 513})
+	assert.EqualError(t, err, "image size must be a positive multiple of 512, got 513")
+	_, err = azure.NewUploader(azure.Credentials{}, "tenant", "subscription", "rg", "account", "image", &azure.UploaderOptions{Size: 512, HyperVGen: "V3"})
+	assert.EqualError(t, err, "unknown hyper v generation type V3")
+}
+
+func TestUploaderFromRegistry(t *testing.T) {
+	fc := &fakeAzureClient{}
+	mockAzureClients(t, fc, &fakeAzureStorageClient{})
+
+	credentialsFile := filepath.Join(t.TempDir(), "credentials")
+	err := os.WriteFile(credentialsFile, []byte("client_id = \"id\"\nclient_secret = \"secret\"\n"), 0600)
+	require.NoError(t, err)
+
+	config, err := json.Marshal(map[string]any{
+		"tenant_id":         "tenant",
+		"subscription_id":   "subscription",
+		"resource_group":    "rg",
+		"storage_account":   "account",
+		"credentials_file":  credentialsFile,
+		"size":              512,
+		"hyperv_generation": "V1",
+	})
+	require.NoError(t, err)
+	uploader, err := cloud.NewUploader("azure", "image", config)
+	require.NoError(t, err)
+	require.NoError(t, uploader.UploadAndRegister(bytes.NewReader(make([]byte, 512)), io.Discard))
+	assert.Equal(t, azure.HyperVGenV1, fc.registeredHyperVGen)
+
+	_, err = cloud.NewUploader("azure", "image", json.RawMessage(`{"tenant_id": "tenant"}`))
+	assert.EqualError(t, err, `cannot create uploader for target "azure": tenant_id, subscription_id, resource_group, storage_account and credentials_file are required`)
+}
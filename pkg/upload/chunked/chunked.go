@@ -0,0 +1,422 @@
+// Package chunked implements resumable, parallel uploads of large images.
+//
+// The data is split into parts of a fixed size that are uploaded in
+// parallel through a Backend, usually the multipart upload API of an object
+// storage. Completed parts can be recorded in a local state file, an
+// interrupted upload that is restarted with the same state file only
+// uploads the parts that are missing.
+package chunked
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/osbuild/images/pkg/datasizes"
+)
+
+const (
+	DefaultPartSize    = 64 * datasizes.MiB
+	DefaultConcurrency = 4
+	DefaultRetries     = 3
+	DefaultRetryDelay  = 2 * time.Second
+)
+
+// Part is a part of an upload.
+type Part struct {
+	// Number of the part, starting at 1
+	Number int `json:"number"`
+	// Offset of the part in the uploaded data
+	Offset int64 `json:"offset"`
+	Size   int64 `json:"size"`
+	// ETag is the backend specific identifier of the uploaded part that
+	// is passed to Backend.Complete()
+	ETag string `json:"etag,omitempty"`
+}
+
+// Backend uploads the parts to a target. The methods of a backend may be
+// called from multiple goroutines in parallel.
+type Backend interface {
+	// Target identifies the uploaded object (e.g. its URL), the state
+	// file of an upload to another target is not resumed.
+	Target() string
+	// Create starts a new upload and returns its ID.
+	Create(ctx context.Context) (string, error)
+	// UploadPart uploads a single part and returns its ETag. It can be
+	// retried, the reader is positioned at the start of the part data.
+	UploadPart(ctx context.Context, uploadID string, part Part, r io.ReadSeeker) (string, error)
+	// Complete assembles the parts, they are sorted by their number.
+	Complete(ctx context.Context, uploadID string, parts []Part) error
+	// Abort cancels the upload and removes the uploaded parts.
+	Abort(ctx context.Context, uploadID string) error
+}
+
+// Progress is reported whenever a part is uploaded.
+type Progress struct {
+	// BytesDone includes the bytes of parts that were uploaded before the
+	// upload was resumed.
+	BytesDone int64
+	// BytesTotal is -1 if the size of the upload is not known
+	BytesTotal int64
+	PartsDone  int
+	// BytesPerSecond is the average rate of this upload session
+	BytesPerSecond float64
+	// ETA is the estimated remaining time, it is 0 if the size of the
+	// upload is not known
+	ETA time.Duration
+}
+
+type ProgressFunc func(Progress)
+
+// Options of an upload, they can be part of the JSON configuration of an
+// uploader (see cloud.NewUploader()).
+type Options struct {
+	// PartSize defaults to DefaultPartSize
+	PartSize int64 `json:"part_size,omitempty"`
+	// Concurrency is the number of parts that are uploaded in parallel,
+	// defaults to DefaultConcurrency. Each of them is buffered in memory.
+	Concurrency int `json:"concurrency,omitempty"`
+	// Retries of a failed part, defaults to DefaultRetries. Set to a
+	// negative number to disable retries.
+	Retries int `json:"retries,omitempty"`
+	// RetryDelay is multiplied by the number of the attempt, defaults to
+	// DefaultRetryDelay
+	RetryDelay time.Duration `json:"-"`
+	// StateFile records the completed parts, the upload is resumed if
+	// the file exists and it is removed once the upload is completed. If
+	// empty, a failed upload is aborted.
+	StateFile string `json:"state_file,omitempty"`
+	// Progress is called whenever a part is uploaded
+	Progress ProgressFunc `json:"-"`
+}
+
+func (o *Options) withDefaults() Options {
+	var opts Options
+	if o != nil {
+		opts = *o
+	}
+	if opts.PartSize <= 0 {
+		opts.PartSize = DefaultPartSize
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = DefaultConcurrency
+	}
+	if opts.Retries == 0 {
+		opts.Retries = DefaultRetries
+	} else if opts.Retries < 0 {
+		opts.Retries = 0
+	}
+	if opts.RetryDelay <= 0 {
+		opts.RetryDelay = DefaultRetryDelay
+	}
+	return opts
+}
+
+// state is the content of the state file
+type state struct {
+	Target   string `json:"target"`
+	UploadID string `json:"upload_id"`
+	PartSize int64  `json:"part_size"`
+	// Size is -1 if the size of the upload is not known
+	Size  int64  `json:"size"`
+	Parts []Part `json:"parts"`
+}
+
+// loadState returns the state from the given file if it belongs to an
+// upload that can be resumed, nil otherwise.
+func loadState(path, target string, partSize, size int64) (*state, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot read upload state: %w", err)
+	}
+
+	var st state
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, fmt.Errorf("cannot parse upload state %q: %w", path, err)
+	}
+	if st.Target != target || st.PartSize != partSize || st.Size != size || st.UploadID == "" {
+		return nil, nil
+	}
+	return &st, nil
+}
+
+// save writes the state atomically so an interrupted write does not
+// corrupt it.
+func (st *state) save(path string) error {
+	data, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("cannot write upload state: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("cannot write upload state: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("cannot write upload state: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("cannot write upload state: %w", err)
+	}
+	return nil
+}
+
+type progressTracker struct {
+	report ProgressFunc
+	start  time.Time
+
+	total     int64
+	done      int64
+	session   int64
+	partsDone int
+}
+
+func (pt *progressTracker) add(size int64, uploaded bool) {
+	pt.done += size
+	pt.partsDone++
+	if uploaded {
+		pt.session += size
+	}
+	if pt.report == nil {
+		return
+	}
+
+	p := Progress{
+		BytesDone:  pt.done,
+		BytesTotal: pt.total,
+		PartsDone:  pt.partsDone,
+	}
+	if elapsed := time.Since(pt.start).Seconds(); elapsed > 0 {
+		p.BytesPerSecond = float64(pt.session) / elapsed
+	}
+	if pt.total >= 0 && p.BytesPerSecond > 0 {
+		p.ETA = time.Duration(float64(pt.total-pt.done) / p.BytesPerSecond * float64(time.Second))
+	}
+	pt.report(p)
+}
+
+// Upload uploads the data read from r through the backend. The size is
+// only used for progress reporting and to check that the data is
+// complete, pass -1 if it is not known. If r is an *os.File of a regular
+// file, its size is used.
+//
+// When an upload is resumed, the parts that are already uploaded are
+// skipped: r must provide the same data as before. If r is an io.Seeker
+// the skipped parts are not read.
+func Upload(ctx context.Context, backend Backend, r io.Reader, size int64, opts *Options) error {
+	o := opts.withDefaults()
+
+	if f, ok := r.(*os.File); ok && size < 0 {
+		if fi, err := f.Stat(); err == nil && fi.Mode().IsRegular() {
+			size = fi.Size()
+		}
+	}
+
+	var st *state
+	if o.StateFile != "" {
+		var err error
+		st, err = loadState(o.StateFile, backend.Target(), o.PartSize, size)
+		if err != nil {
+			return err
+		}
+	}
+	if st == nil {
+		uploadID, err := backend.Create(ctx)
+		if err != nil {
+			return err
+		}
+		st = &state{
+			Target:   backend.Target(),
+			UploadID: uploadID,
+			PartSize: o.PartSize,
+			Size:     size,
+		}
+		if o.StateFile != "" {
+			if err := st.save(o.StateFile); err != nil {
+				return err
+			}
+		}
+	}
+
+	err := uploadParts(ctx, backend, r, st, &o)
+	if err != nil {
+		if o.StateFile == "" {
+			// nothing can be resumed, clean up the uploaded parts
+			if abortErr := backend.Abort(context.WithoutCancel(ctx), st.UploadID); abortErr != nil {
+				err = errors.Join(err, abortErr)
+			}
+		}
+		return err
+	}
+
+	parts := slices.Clone(st.Parts)
+	slices.SortFunc(parts, func(a, b Part) int {
+		return a.Number - b.Number
+	})
+	if err := backend.Complete(ctx, st.UploadID, parts); err != nil {
+		return err
+	}
+	if o.StateFile != "" {
+		if err := os.Remove(o.StateFile); err != nil {
+			return fmt.Errorf("cannot remove upload state: %w", err)
+		}
+	}
+	return nil
+}
+
+func uploadParts(ctx context.Context, backend Backend, r io.Reader, st *state, o *Options) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	completed := make(map[int]Part, len(st.Parts))
+	for _, part := range st.Parts {
+		completed[part.Number] = part
+	}
+
+	var mu sync.Mutex
+	var firstErr error
+	fail := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if firstErr == nil {
+			firstErr = err
+			cancel()
+		}
+	}
+	progress := &progressTracker{
+		report: o.Progress,
+		start:  time.Now(),
+		total:  st.Size,
+	}
+
+	// bounds the number of parts that are buffered and uploaded
+	semaphore := make(chan struct{}, o.Concurrency)
+	var wg sync.WaitGroup
+	var offset int64
+	for number := 1; ctx.Err() == nil; number++ {
+		if part, ok := completed[number]; ok {
+			if err := skip(r, part.Size); err != nil {
+				fail(fmt.Errorf("cannot skip uploaded part %d: %w", number, err))
+				break
+			}
+			offset += part.Size
+			mu.Lock()
+			progress.add(part.Size, false)
+			mu.Unlock()
+			continue
+		}
+
+		semaphore <- struct{}{}
+		buffer := make([]byte, o.PartSize)
+		n, err := io.ReadFull(r, buffer)
+		last := errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+		if err != nil && !last {
+			<-semaphore
+			fail(fmt.Errorf("reading the image failed: %w", err))
+			break
+		}
+		// an empty upload still needs a single (empty) part
+		if n == 0 && number > 1 {
+			<-semaphore
+			break
+		}
+
+		part := Part{
+			Number: number,
+			Offset: offset,
+			Size:   int64(n),
+		}
+		offset += int64(n)
+
+		wg.Add(1)
+		go func(part Part, data []byte) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+
+			etag, err := uploadPart(ctx, backend, st.UploadID, part, data, o)
+			if err != nil {
+				fail(err)
+				return
+			}
+			part.ETag = etag
+
+			mu.Lock()
+			defer mu.Unlock()
+			st.Parts = append(st.Parts, part)
+			if o.StateFile != "" {
+				if err := st.save(o.StateFile); err != nil && firstErr == nil {
+					firstErr = err
+					cancel()
+					return
+				}
+			}
+			progress.add(part.Size, true)
+		}(part, buffer[:n])
+
+		if last {
+			break
+		}
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if st.Size >= 0 && offset != st.Size {
+		return fmt.Errorf("read %d bytes of the image, expected %d", offset, st.Size)
+	}
+	return nil
+}
+
+func uploadPart(ctx context.Context, backend Backend, uploadID string, part Part, data []byte, o *Options) (string, error) {
+	var err error
+	for attempt := 0; ; attempt++ {
+		var etag string
+		etag, err = backend.UploadPart(ctx, uploadID, part, bytes.NewReader(data))
+		if err == nil {
+			return etag, nil
+		}
+		if attempt >= o.Retries {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(o.RetryDelay * time.Duration(attempt+1)):
+		}
+	}
+	return "", fmt.Errorf("uploading part %d failed: %w", part.Number, err)
+}
+
+// skip skips size bytes of the reader
+func skip(r io.Reader, size int64) error {
+	if s, ok := r.(io.Seeker); ok {
+		_, err := s.Seek(size, io.SeekCurrent)
+		return err
+	}
+	n, err := io.CopyN(io.Discard, r, size)
+	if err == io.EOF && n < size {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,400 @@
+package chunked_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/pkg/upload/chunked"
+)
+
+// fakeStorage is a local object storage with a multipart upload API that
+// fails on purpose.
+type fakeStorage struct {
+	mu      sync.Mutex
+	nextID  int
+	uploads map[string]map[int][]byte
+	objects map[string][]byte
+	aborted []string
+
+	// partPuts counts the upload requests of each part number
+	partPuts map[int]int
+	// failPart returns true if the upload of the part should fail
+	failPart func(number, attempt int) bool
+}
+
+func newFakeStorage(t *testing.T) (*fakeStorage, *httptest.Server) {
+	fs := &fakeStorage{
+		uploads:  map[string]map[int][]byte{},
+		objects:  map[string][]byte{},
+		partPuts: map[int]int{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /{object}/uploads", func(w http.ResponseWriter, r *http.Request) {
+		fs.mu.Lock()
+		defer fs.mu.Unlock()
+		fs.nextID++
+		id := fmt.Sprintf("upload-%d", fs.nextID)
+		fs.uploads[id] = map[int][]byte{}
+		fmt.Fprint(w, id)
+	})
+	mux.HandleFunc("PUT /{object}/uploads/{id}/{part}", func(w http.ResponseWriter, r *http.Request) {
+		number, err := strconv.Atoi(r.PathValue("part"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		fs.mu.Lock()
+		defer fs.mu.Unlock()
+		attempt := fs.partPuts[number]
+		fs.partPuts[number]++
+		if fs.failPart != nil && fs.failPart(number, attempt) {
+			http.Error(w, "injected failure", http.StatusServiceUnavailable)
+			return
+		}
+		upload, ok := fs.uploads[r.PathValue("id")]
+		if !ok {
+			http.Error(w, "no such upload", http.StatusNotFound)
+			return
+		}
+		upload[number] = data
+		w.Header().Set("ETag", fmt.Sprintf("etag-%d", number))
+	})
+	mux.HandleFunc("POST /{object}/uploads/{id}/complete", func(w http.ResponseWriter, r *http.Request) {
+		var parts []chunked.Part
+		if err := json.NewDecoder(r.Body).Decode(&parts); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		fs.mu.Lock()
+		defer fs.mu.Unlock()
+		upload, ok := fs.uploads[r.PathValue("id")]
+		if !ok {
+			http.Error(w, "no such upload", http.StatusNotFound)
+			return
+		}
+		object := []byte{}
+		for idx, part := range parts {
+			if part.Number != idx+1 || part.ETag != fmt.Sprintf("etag-%d", part.Number) {
+				http.Error(w, fmt.Sprintf("invalid part %+v", part), http.StatusBadRequest)
+				return
+			}
+			object = append(object, upload[part.Number]...)
+		}
+		fs.objects[r.PathValue("object")] = object
+		delete(fs.uploads, r.PathValue("id"))
+	})
+	mux.HandleFunc("DELETE /{object}/uploads/{id}", func(w http.ResponseWriter, r *http.Request) {
+		fs.mu.Lock()
+		defer fs.mu.Unlock()
+		delete(fs.uploads, r.PathValue("id"))
+		fs.aborted = append(fs.aborted, r.PathValue("id"))
+	})
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return fs, srv
+}
+
+func (fs *fakeStorage) object(name string) []byte {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return fs.objects[name]
+}
+
+func (fs *fakeStorage) resetPuts() {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.partPuts = map[int]int{}
+}
+
+// httpBackend uploads to the fakeStorage
+type httpBackend struct {
+	url string
+}
+
+func (hb *httpBackend) Target() string {
+	return hb.url
+}
+
+func (hb *httpBackend) do(ctx context.Context, method, url string, body io.Reader) (*http.Response, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("%s %s: %s", method, url, resp.Status)
+	}
+	return resp, data, nil
+}
+
+func (hb *httpBackend) Create(ctx context.Context) (string, error) {
+	_, data, err := hb.do(ctx, http.MethodPost, hb.url+"/uploads", nil)
+	return string(data), err
+}
+
+func (hb *httpBackend) UploadPart(ctx context.Context, uploadID string, part chunked.Part, r io.ReadSeeker) (string, error) {
+	resp, _, err := hb.do(ctx, http.MethodPut, fmt.Sprintf("%s/uploads/%s/%d", hb.url, uploadID, part.Number), r)
+	if err != nil {
+		return "", err
+	}
+	return resp.Header.Get("ETag"), nil
+}
+
+func (hb *httpBackend) Complete(ctx context.Context, uploadID string, parts []chunked.Part) error {
+	data, err := json.Marshal(parts)
+	if err != nil {
+		return err
+	}
+	_, _, err = hb.do(ctx, http.MethodPost, fmt.Sprintf("%s/uploads/%s/complete", hb.url, uploadID), bytes.NewReader(data))
+	return err
+}
+
+func (hb *httpBackend) Abort(ctx context.Context, uploadID string) error {
+	_, _, err := hb.do(ctx, http.MethodDelete, fmt.Sprintf("%s/uploads/%s", hb.url, uploadID), nil)
+	return err
+}
+
+func testData(size int) []byte {
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	return data
+}
+
+// onlyReader hides all interfaces but io.Reader
+type onlyReader struct {
+	io.Reader
+}
+
+func TestUpload(t *testing.T) {
+	for _, size := range []int{0, 1, 1024, 1000, 4097} {
+		t.Run(strconv.Itoa(size), func(t *testing.T) {
+			fs, srv := newFakeStorage(t)
+			data := testData(size)
+
+			var progress []chunked.Progress
+			err := chunked.Upload(context.Background(), &httpBackend{srv.URL + "/image"}, bytes.NewReader(data), int64(size), &chunked.Options{
+				PartSize:    256,
+				Concurrency: 3,
+				Progress: func(p chunked.Progress) {
+					progress = append(progress, p)
+				},
+			})
+			require.NoError(t, err)
+			assert.Equal(t, data, fs.object("image"))
+
+			require.NotEmpty(t, progress)
+			for idx, p := range progress {
+				assert.Equal(t, int64(size), p.BytesTotal)
+				assert.Equal(t, idx+1, p.PartsDone)
+				if idx > 0 {
+					assert.GreaterOrEqual(t, p.BytesDone, progress[idx-1].BytesDone)
+				}
+			}
+			last := progress[len(progress)-1]
+			assert.Equal(t, int64(size), last.BytesDone)
+			assert.Equal(t, time.Duration(0), last.ETA)
+		})
+	}
+}
+
+func TestUploadUnknownSize(t *testing.T) {
+	fs, srv := newFakeStorage(t)
+	data := testData(1000)
+
+	var progress []chunked.Progress
+	err := chunked.Upload(context.Background(), &httpBackend{srv.URL + "/image"}, onlyReader{bytes.NewReader(data)}, -1, &chunked.Options{
+		PartSize: 256,
+		Progress: func(p chunked.Progress) {
+			progress = append(progress, p)
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, data, fs.object("image"))
+	require.Len(t, progress, 4)
+	assert.Equal(t, int64(-1), progress[3].BytesTotal)
+	assert.Equal(t, int64(1000), progress[3].BytesDone)
+}
+
+func TestUploadSizeFromFile(t *testing.T) {
+	fs, srv := newFakeStorage(t)
+	data := testData(1000)
+	path := filepath.Join(t.TempDir(), "image")
+	require.NoError(t, os.WriteFile(path, data, 0600))
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	var last chunked.Progress
+	err = chunked.Upload(context.Background(), &httpBackend{srv.URL + "/image"}, f, -1, &chunked.Options{
+		PartSize: 256,
+		Progress: func(p chunked.Progress) {
+			last = p
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, data, fs.object("image"))
+	assert.Equal(t, int64(1000), last.BytesTotal)
+}
+
+func TestUploadRetry(t *testing.T) {
+	fs, srv := newFakeStorage(t)
+	// the second part fails twice
+	fs.failPart = func(number, attempt int) bool {
+		return number == 2 && attempt < 2
+	}
+	data := testData(1000)
+
+	err := chunked.Upload(context.Background(), &httpBackend{srv.URL + "/image"}, bytes.NewReader(data), int64(len(data)), &chunked.Options{
+		PartSize:   256,
+		Retries:    2,
+		RetryDelay: time.Millisecond,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, data, fs.object("image"))
+	assert.Equal(t, map[int]int{1: 1, 2: 3, 3: 1, 4: 1}, fs.partPuts)
+}
+
+func TestUploadFailureAborts(t *testing.T) {
+	fs, srv := newFakeStorage(t)
+	fs.failPart = func(number, attempt int) bool {
+		return number == 3
+	}
+	data := testData(1000)
+
+	err := chunked.Upload(context.Background(), &httpBackend{srv.URL + "/image"}, bytes.NewReader(data), int64(len(data)), &chunked.Options{
+		PartSize:   256,
+		Retries:    -1,
+		RetryDelay: time.Millisecond,
+	})
+	assert.ErrorContains(t, err, "uploading part 3 failed: PUT ")
+	assert.ErrorContains(t, err, "503 Service Unavailable")
+	assert.Nil(t, fs.object("image"))
+	assert.Equal(t, []string{"upload-1"}, fs.aborted)
+}
+
+func TestUploadResume(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		reader func([]byte) io.Reader
+	}{
+		{"seeker", func(data []byte) io.Reader { return bytes.NewReader(data) }},
+		{"stream", func(data []byte) io.Reader { return onlyReader{bytes.NewReader(data)} }},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fs, srv := newFakeStorage(t)
+			backend := &httpBackend{srv.URL + "/image"}
+			stateFile := filepath.Join(t.TempDir(), "upload.json")
+			data := testData(2000)
+			opts := &chunked.Options{
+				PartSize:    256,
+				Concurrency: 1,
+				Retries:     -1,
+				StateFile:   stateFile,
+			}
+
+			// the upload is interrupted after the fourth part
+			fs.failPart = func(number, attempt int) bool {
+				return number == 5
+			}
+			err := chunked.Upload(context.Background(), backend, tc.reader(data), int64(len(data)), opts)
+			require.ErrorContains(t, err, "uploading part 5 failed")
+			assert.Empty(t, fs.aborted)
+			assert.FileExists(t, stateFile)
+
+			fs.failPart = nil
+			fs.resetPuts()
+			var progress []chunked.Progress
+			opts.Progress = func(p chunked.Progress) {
+				progress = append(progress, p)
+			}
+			err = chunked.Upload(context.Background(), backend, tc.reader(data), int64(len(data)), opts)
+			require.NoError(t, err)
+			assert.Equal(t, data, fs.object("image"))
+			// only the missing parts are uploaded again
+			assert.Equal(t, map[int]int{5: 1, 6: 1, 7: 1, 8: 1}, fs.partPuts)
+			assert.NoFileExists(t, stateFile)
+
+			// the resumed parts are reported, but do not count for the rate
+			require.Len(t, progress, 8)
+			assert.Equal(t, int64(4*256), progress[3].BytesDone)
+			assert.Equal(t, float64(0), progress[3].BytesPerSecond)
+			assert.Equal(t, int64(2000), progress[7].BytesDone)
+		})
+	}
+}
+
+func TestUploadStateOfOtherTargetIgnored(t *testing.T) {
+	fs, srv := newFakeStorage(t)
+	stateFile := filepath.Join(t.TempDir(), "upload.json")
+	data := testData(1000)
+	opts := &chunked.Options{
+		PartSize:  256,
+		Retries:   -1,
+		StateFile: stateFile,
+	}
+
+	fs.failPart = func(number, attempt int) bool {
+		return number == 2
+	}
+	err := chunked.Upload(context.Background(), &httpBackend{srv.URL + "/other"}, bytes.NewReader(data), int64(len(data)), opts)
+	require.Error(t, err)
+
+	fs.failPart = nil
+	fs.resetPuts()
+	err = chunked.Upload(context.Background(), &httpBackend{srv.URL + "/image"}, bytes.NewReader(data), int64(len(data)), opts)
+	require.NoError(t, err)
+	assert.Equal(t, data, fs.object("image"))
+	assert.Equal(t, map[int]int{1: 1, 2: 1, 3: 1, 4: 1}, fs.partPuts)
+}
+
+func TestUploadShortRead(t *testing.T) {
+	_, srv := newFakeStorage(t)
+	data := testData(1000)
+
+	err := chunked.Upload(context.Background(), &httpBackend{srv.URL + "/image"}, bytes.NewReader(data), 1024, &chunked.Options{
+		PartSize: 256,
+	})
+	assert.EqualError(t, err, "read 1000 bytes of the image, expected 1024")
+}
+
+func TestUploadCanceled(t *testing.T) {
+	_, srv := newFakeStorage(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := chunked.Upload(ctx, &httpBackend{srv.URL + "/image"}, bytes.NewReader(testData(1000)), 1000, nil)
+	assert.ErrorIs(t, err, context.Canceled)
+}
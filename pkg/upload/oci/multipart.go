@@ -0,0 +1,106 @@
+package oci
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/oracle/oci-go-sdk/v54/common"
+	"github.com/oracle/oci-go-sdk/v54/objectstorage"
+
+	"github.com/osbuild/images/pkg/upload/chunked"
+)
+
+// multipartBackend uploads the parts of a chunked upload using the object
+// storage multipart upload API.
+type multipartBackend struct {
+	storageClient *objectstorage.ObjectStorageClient
+	namespace     string
+	bucketName    string
+	objectName    string
+}
+
+var _ chunked.Backend = &multipartBackend{}
+
+func (b *multipartBackend) Target() string {
+	return fmt.Sprintf("oci://%s/%s/%s", b.namespace, b.bucketName, b.objectName)
+}
+
+func (b *multipartBackend) Create(ctx context.Context) (string, error) {
+	resp, err := b.storageClient.CreateMultipartUpload(ctx, objectstorage.CreateMultipartUploadRequest{
+		NamespaceName: common.String(b.namespace),
+		BucketName:    common.String(b.bucketName),
+		CreateMultipartUploadDetails: objectstorage.CreateMultipartUploadDetails{
+			Object: common.String(b.objectName),
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to create a multipart upload for object %s: %w", b.objectName, err)
+	}
+	return *resp.UploadId, nil
+}
+
+func (b *multipartBackend) UploadPart(ctx context.Context, uploadID string, part chunked.Part, r io.ReadSeeker) (string, error) {
+	resp, err := b.storageClient.UploadPart(ctx, objectstorage.UploadPartRequest{
+		NamespaceName:  common.String(b.namespace),
+		BucketName:     common.String(b.bucketName),
+		ObjectName:     common.String(b.objectName),
+		UploadId:       common.String(uploadID),
+		UploadPartNum:  common.Int(part.Number),
+		ContentLength:  common.Int64(part.Size),
+		UploadPartBody: io.NopCloser(r),
+	})
+	if err != nil {
+		return "", err
+	}
+	return *resp.ETag, nil
+}
+
+func (b *multipartBackend) Complete(ctx context.Context, uploadID string, parts []chunked.Part) error {
+	commit := make([]objectstorage.CommitMultipartUploadPartDetails, 0, len(parts))
+	for _, part := range parts {
+		commit = append(commit, objectstorage.CommitMultipartUploadPartDetails{
+			PartNum: common.Int(part.Number),
+			Etag:    common.String(part.ETag),
+		})
+	}
+	_, err := b.storageClient.CommitMultipartUpload(ctx, objectstorage.CommitMultipartUploadRequest{
+		NamespaceName: common.String(b.namespace),
+		BucketName:    common.String(b.bucketName),
+		ObjectName:    common.String(b.objectName),
+		UploadId:      common.String(uploadID),
+		CommitMultipartUploadDetails: objectstorage.CommitMultipartUploadDetails{
+			PartsToCommit: commit,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to commit the multipart upload of object %s: %w", b.objectName, err)
+	}
+	return nil
+}
+
+func (b *multipartBackend) Abort(ctx context.Context, uploadID string) error {
+	_, err := b.storageClient.AbortMultipartUpload(ctx, objectstorage.AbortMultipartUploadRequest{
+		NamespaceName: common.String(b.namespace),
+		BucketName:    common.String(b.bucketName),
+		ObjectName:    common.String(b.objectName),
+		UploadId:      common.String(uploadID),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to abort the multipart upload of object %s: %w", b.objectName, err)
+	}
+	return nil
+}
+
+// uploadStreamToBucketResumable uploads the data read from r into an
+// objectName under the bucketName in the namespace using a chunked
+// upload, see chunked.Upload().
+func (c Client) uploadStreamToBucketResumable(objectName, bucketName, namespace string, r io.Reader, opts *chunked.Options) error {
+	backend := &multipartBackend{
+		storageClient: &c.storageClient,
+		namespace:     namespace,
+		bucketName:    bucketName,
+		objectName:    objectName,
+	}
+	return chunked.Upload(context.Background(), backend, r, -1, opts)
+}
@@ -3,6 +3,7 @@ package oci
 import (
 	"context"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os"
@@ -204,3 +205,35 @@ func NewClient(clientParams *ClientParams) (Client, error) {
 		workRequestsClient: workRequestsClient,
 	}}, nil
 }
+
+// checkBucket checks that the bucket exists and can be accessed.
+func (c Client) checkBucket(bucketName, namespace string) error {
+	_, err := c.storageClient.GetBucket(context.Background(), objectstorage.GetBucketRequest{
+		NamespaceName: common.String(namespace),
+		BucketName:    common.String(bucketName),
+	})
+	if err != nil {
+		return fmt.Errorf("cannot access bucket '%s' in namespace '%s': %w", bucketName, namespace, err)
+	}
+	return nil
+}
+
+// uploadStreamToBucket uploads the data read from r into an objectName
+// under the bucketName in the namespace using a multipart upload.
+func (c Client) uploadStreamToBucket(objectName, bucketName, namespace string, r io.Reader) error {
+	req := transfer.UploadStreamRequest{
+		UploadRequest: transfer.UploadRequest{
+			NamespaceName:       common.String(namespace),
+			BucketName:          common.String(bucketName),
+			ObjectName:          common.String(objectName),
+			ObjectStorageClient: &c.storageClient,
+		},
+		StreamReader: r,
+	}
+
+	uploadManager := transfer.NewUploadManager()
+	if _, err := uploadManager.UploadStream(context.Background(), req); err != nil {
+		return fmt.Errorf("failed to upload the stream to object %s: %w", objectName, err)
+	}
+	return nil
+}
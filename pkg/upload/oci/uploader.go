@@ -0,0 +1,169 @@
+package oci
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+
+	"github.com/google/uuid"
+
+	"github.com/osbuild/images/pkg/cloud"
+	"github.com/osbuild/images/pkg/upload/chunked"
+)
+
+type ociUploader struct {
+	client ociUploaderClient
+
+	bucketName    string
+	namespace     string
+	compartmentID string
+	imageName     string
+	upload        *chunked.Options
+}
+
+// testing support
+type ociUploaderClient interface {
+	checkBucket(bucketName, namespace string) error
+	uploadStreamToBucket(objectName, bucketName, namespace string, r io.Reader) error
+	uploadStreamToBucketResumable(objectName, bucketName, namespace string, r io.Reader, opts *chunked.Options) error
+	createImage(objectName, bucketName, namespace, compartmentID, imageName string) (string, error)
+	deleteObjectFromBucket(name string, bucket string, namespace string) error
+}
+
+var newOCIUploaderClient = func(clientParams *ClientParams) (ociUploaderClient, error) {
+	return NewClient(clientParams)
+}
+
+type UploaderOptions struct {
+	// ClientParams to use, the default configuration is used if nil, see
+	// NewClient()
+	ClientParams *ClientParams
+	// Upload enables resumable chunked uploads with progress reporting,
+	// see chunked.Options. If nil, the image is uploaded in one go.
+	Upload *chunked.Options
+}
+
+// NewUploader returns a cloud.Uploader that uploads a qcow2 image to the
+// bucket and creates a custom image from it in the compartment.
+func NewUploader(bucketName, namespace, compartmentID, imageName string, opts *UploaderOptions) (cloud.Uploader, error) {
+	if opts == nil {
+		opts = &UploaderOptions{}
+	}
+	client, err := newOCIUploaderClient(opts.ClientParams)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ociUploader{
+		client:        client,
+		bucketName:    bucketName,
+		namespace:     namespace,
+		compartmentID: compartmentID,
+		imageName:     imageName,
+		upload:        opts.Upload,
+	}, nil
+}
+
+var _ cloud.Uploader = &ociUploader{}
+
+func (ou *ociUploader) Check(status io.Writer) error {
+	fmt.Fprintf(status, "Checking bucket...\n")
+	if err := ou.client.checkBucket(ou.bucketName, ou.namespace); err != nil {
+		return err
+	}
+	fmt.Fprintf(status, "Upload conditions met.\n")
+	return nil
+}
+
+func (ou *ociUploader) UploadAndRegister(r io.Reader, status io.Writer) error {
+	objectID := uuid.New()
+	if ou.upload != nil && ou.upload.StateFile != "" {
+		// a resumed upload must use the object of the interrupted one
+		objectID = uuid.NewSHA1(uuid.NameSpaceURL, []byte(fmt.Sprintf("oci://%s/%s/%s", ou.namespace, ou.bucketName, ou.imageName)))
+	}
+	objectName := fmt.Sprintf("%s-%s", objectID.String(), ou.imageName)
+
+	fmt.Fprintf(status, "Uploading %s to %s:%s\n", ou.imageName, ou.bucketName, objectName)
+	var err error
+	if ou.upload != nil {
+		err = ou.client.uploadStreamToBucketResumable(objectName, ou.bucketName, ou.namespace, r, ou.upload)
+		if err != nil {
+			// keep the uploaded parts, the upload can be resumed
+			return err
+		}
+	} else {
+		err = ou.client.uploadStreamToBucket(objectName, ou.bucketName, ou.namespace, r)
+	}
+	// clean up the object even if we fail, a failed multipart upload
+	// may have left it behind
+	defer func() {
+		fmt.Fprintf(status, "Deleting %s:%s\n", ou.bucketName, objectName)
+		if err := ou.client.deleteObjectFromBucket(objectName, ou.bucketName, ou.namespace); err != nil {
+			log.Printf("failed to clean up the object '%s' from bucket '%s'", objectName, ou.bucketName)
+		}
+	}()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(status, "Creating image %s\n", ou.imageName)
+	imageID, err := ou.client.createImage(objectName, ou.bucketName, ou.namespace, ou.compartmentID, ou.imageName)
+	if err != nil {
+		return fmt.Errorf("failed to create a custom image using object '%s' bucket '%s' in namespace '%s': %w",
+			objectName,
+			ou.bucketName,
+			ou.namespace,
+			err)
+	}
+	fmt.Fprintf(status, "Image %s created: %s\n", ou.imageName, imageID)
+	return nil
+}
+
+// UploaderConfig is the configuration of the "oci" upload target, see
+// cloud.NewUploader(). The client parameters are optional, the default
+// configuration is used if none of them are set.
+type UploaderConfig struct {
+	Bucket        string `json:"bucket"`
+	Namespace     string `json:"namespace"`
+	CompartmentID string `json:"compartment_id"`
+
+	User        string `json:"user,omitempty"`
+	Region      string `json:"region,omitempty"`
+	Tenancy     string `json:"tenancy,omitempty"`
+	PrivateKey  string `json:"private_key,omitempty"`
+	Fingerprint string `json:"fingerprint,omitempty"`
+
+	// Upload makes the upload resumable, see chunked.Options
+	Upload *chunked.Options `json:"upload,omitempty"`
+}
+
+func newUploaderFromConfig(imageName string, data json.RawMessage) (cloud.Uploader, error) {
+	var config UploaderConfig
+	if err := cloud.UnmarshalUploaderConfig(data, &config); err != nil {
+		return nil, err
+	}
+	if config.Bucket == "" || config.Namespace == "" || config.CompartmentID == "" {
+		return nil, fmt.Errorf("bucket, namespace and compartment_id are required")
+	}
+
+	var clientParams *ClientParams
+	params := ClientParams{
+		User:        config.User,
+		Region:      config.Region,
+		Tenancy:     config.Tenancy,
+		PrivateKey:  config.PrivateKey,
+		Fingerprint: config.Fingerprint,
+	}
+	if params != (ClientParams{}) {
+		clientParams = &params
+	}
+	return NewUploader(config.Bucket, config.Namespace, config.CompartmentID, imageName, &UploaderOptions{
+		ClientParams: clientParams,
+		Upload:       config.Upload,
+	})
+}
+
+func init() {
+	cloud.RegisterUploader("oci", newUploaderFromConfig)
+}
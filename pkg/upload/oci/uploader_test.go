@@ -0,0 +1,147 @@
+package oci
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/pkg/cloud"
+	"github.com/osbuild/images/pkg/upload/chunked"
+)
+
+type fakeOCIClient struct {
+	checkErr  error
+	createErr error
+
+	uploadedData   []byte
+	uploadedObject string
+	uploadOpts     *chunked.Options
+	createdObject  string
+	deleted        []string
+}
+
+func (fc *fakeOCIClient) checkBucket(bucketName, namespace string) error {
+	return fc.checkErr
+}
+
+func (fc *fakeOCIClient) uploadStreamToBucket(objectName, bucketName, namespace string, r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	fc.uploadedData = data
+	fc.uploadedObject = objectName
+	return nil
+}
+
+func (fc *fakeOCIClient) uploadStreamToBucketResumable(objectName, bucketName, namespace string, r io.Reader, opts *chunked.Options) error {
+	if err := fc.uploadStreamToBucket(objectName, bucketName, namespace, r); err != nil {
+		return err
+	}
+	fc.uploadOpts = opts
+	return nil
+}
+
+func (fc *fakeOCIClient) createImage(objectName, bucketName, namespace, compartmentID, imageName string) (string, error) {
+	if fc.createErr != nil {
+		return "", fc.createErr
+	}
+	fc.createdObject = objectName
+	return "ocid1.image.test", nil
+}
+
+func (fc *fakeOCIClient) deleteObjectFromBucket(name string, bucket string, namespace string) error {
+	fc.deleted = append(fc.deleted, bucket+"/"+name)
+	return nil
+}
+
+func mockOCIClient(t *testing.T, fc *fakeOCIClient) *[]*ClientParams {
+	var params []*ClientParams
+	saved := newOCIUploaderClient
+	newOCIUploaderClient = func(clientParams *ClientParams) (ociUploaderClient, error) {
+		params = append(params, clientParams)
+		return fc, nil
+	}
+	t.Cleanup(func() {
+		newOCIUploaderClient = saved
+	})
+	return &params
+}
+
+func TestUploaderCheck(t *testing.T) {
+	mockOCIClient(t, &fakeOCIClient{})
+	uploader, err := NewUploader("bucket", "namespace", "compartment", "image", nil)
+	require.NoError(t, err)
+	var status bytes.Buffer
+	assert.NoError(t, uploader.Check(&status))
+	assert.Contains(t, status.String(), "Upload conditions met.")
+
+	mockOCIClient(t, &fakeOCIClient{checkErr: fmt.Errorf("check-error")})
+	uploader, err = NewUploader("bucket", "namespace", "compartment", "image", nil)
+	require.NoError(t, err)
+	assert.EqualError(t, uploader.Check(io.Discard), "check-error")
+}
+
+func TestUploaderUploadAndRegister(t *testing.T) {
+	fc := &fakeOCIClient{}
+	mockOCIClient(t, fc)
+	uploader, err := NewUploader("bucket", "namespace", "compartment", "image", nil)
+	require.NoError(t, err)
+
+	var status bytes.Buffer
+	err = uploader.UploadAndRegister(bytes.NewBufferString("image-data"), &status)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("image-data"), fc.uploadedData)
+	assert.True(t, strings.HasSuffix(fc.uploadedObject, "-image"))
+	assert.Equal(t, fc.uploadedObject, fc.createdObject)
+	assert.Equal(t, []string{"bucket/" + fc.uploadedObject}, fc.deleted)
+	assert.Contains(t, status.String(), "Image image created: ocid1.image.test\n")
+}
+
+func TestUploaderUploadAndRegisterResumable(t *testing.T) {
+	fc := &fakeOCIClient{}
+	mockOCIClient(t, fc)
+	opts := &chunked.Options{StateFile: "/tmp/upload.json"}
+	uploader, err := NewUploader("bucket", "namespace", "compartment", "image", &UploaderOptions{Upload: opts})
+	require.NoError(t, err)
+
+	err = uploader.UploadAndRegister(bytes.NewBufferString("image-data"), io.Discard)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("image-data"), fc.uploadedData)
+	assert.Equal(t, opts, fc.uploadOpts)
+	// the object does not change, so an interrupted upload can be resumed
+	object := fc.uploadedObject
+	err = uploader.UploadAndRegister(bytes.NewBufferString("image-data"), io.Discard)
+	require.NoError(t, err)
+	assert.Equal(t, object, fc.uploadedObject)
+}
+
+func TestUploaderUploadAndRegisterError(t *testing.T) {
+	fc := &fakeOCIClient{createErr: fmt.Errorf("create-error")}
+	mockOCIClient(t, fc)
+	uploader, err := NewUploader("bucket", "namespace", "compartment", "image", nil)
+	require.NoError(t, err)
+
+	err = uploader.UploadAndRegister(bytes.NewBufferString("image-data"), io.Discard)
+	assert.ErrorContains(t, err, "create-error")
+	assert.Equal(t, []string{"bucket/" + fc.uploadedObject}, fc.deleted)
+}
+
+func TestUploaderFromRegistry(t *testing.T) {
+	params := mockOCIClient(t, &fakeOCIClient{})
+
+	_, err := cloud.NewUploader("oci", "image", json.RawMessage(`{"bucket": "b", "namespace": "n", "compartment_id": "c"}`))
+	require.NoError(t, err)
+	_, err = cloud.NewUploader("oci", "image", json.RawMessage(`{"bucket": "b", "namespace": "n", "compartment_id": "c", "region": "eu-frankfurt-1"}`))
+	require.NoError(t, err)
+	assert.Equal(t, []*ClientParams{nil, {Region: "eu-frankfurt-1"}}, *params)
+
+	_, err = cloud.NewUploader("oci", "image", json.RawMessage(`{"bucket": "b"}`))
+	assert.EqualError(t, err, `cannot create uploader for target "oci": bucket, namespace and compartment_id are required`)
+}